@@ -0,0 +1,56 @@
+package main
+
+import "image/color"
+
+const (
+	highContrastTheme = "high-contrast"
+	reducedMotion     = 0.5 // Speed of the simulation with reduce motion
+)
+
+// accessibilityConfig makes the screensaver easier to see and more comfortable to watch
+type accessibilityConfig struct {
+	// HighContrast always uses the high-contrast theme, whatever presets or T select, and
+	// saturated guardian colors
+	HighContrast bool `json:"high_contrast"`
+
+	// ColorBlindSafe replaces the colors that tell things apart, the magnet poles, the
+	// portal pairs and the guardians, with a palette that stays distinct with every kind of color blindness
+	ColorBlindSafe bool `json:"color_blind_safe"`
+
+	// ReduceMotion runs everything at half speed and turns off the screen shake and the
+	// milestone flash
+	ReduceMotion bool `json:"reduce_motion"`
+}
+
+// The Okabe-Ito palette, distinct with protanopia, deuteranopia and tritanopia
+var (
+	okabeOrange    = color.RGBA{230, 159, 0, 255}
+	okabeSkyBlue   = color.RGBA{86, 180, 233, 255}
+	okabeYellow    = color.RGBA{240, 228, 66, 255}
+	okabeBlue      = color.RGBA{0, 114, 178, 255}
+	okabeVermilion = color.RGBA{213, 94, 0, 255}
+	okabePurple    = color.RGBA{204, 121, 167, 255}
+)
+
+func init() {
+	themes[highContrastTheme] = theme{
+		background: color.RGBA{A: 255},
+		timer:      white,
+		hud:        color.RGBA{255, 255, 0, 255},
+		highlight:  color.RGBA{0, 255, 255, 255},
+		tints:      []color.RGBA{white},
+		particles:  []color.RGBA{white, {255, 255, 0, 255}, {0, 255, 255, 255}},
+	}
+}
+
+// setupAccessibility applies the options that replace colors, before anything is drawn
+func setupAccessibility(cfg accessibilityConfig) {
+	if cfg.HighContrast {
+		guardianColors = [2]color.RGBA{{0, 255, 255, 255}, {255, 0, 255, 255}}
+	}
+	if cfg.ColorBlindSafe {
+		positivePole, negativePole = okabeOrange, okabeBlue
+		portalColors = []color.RGBA{okabeOrange, okabeSkyBlue, okabeYellow, okabePurple, okabeVermilion}
+		guardianColors = [2]color.RGBA{okabeSkyBlue, okabeOrange}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	achievementSaveInterval = time.Minute     // How often counters are written to the state file
+	toastDuration           = 4 * time.Second // How long an unlock is shown
+	toastFade               = 20              // Frames at 60 per second a toast takes to fade in and out
+)
+
+// Statistics that achievements are unlocked by
+const (
+	statCollisions = "collisions" // Collisions between donuts
+	statPops       = "pops"       // Donuts popped, in chat or shot in the target game
+	statUptime     = "uptime"     // Longest run in seconds
+	statGames      = "games"      // Arcade games played to the end
+)
+
+// achievement unlocks once a statistic reaches the goal
+type achievement struct {
+	id    string
+	title string
+	about string
+	stat  string
+	goal  int64
+}
+
+// achievements in the order they're listed
+var achievements = []achievement{
+	{"collisions-1000", "Fender bender", "1000 collisions witnessed", statCollisions, 1000},
+	{"collisions-1000000", "Demolition derby", "a million collisions witnessed", statCollisions, 1_000_000},
+	{"pops-100", "Pop star", "popped 100 donuts", statPops, 100},
+	{"uptime-1d", "Night shift", "ran for 24 hours straight", statUptime, 24 * 60 * 60},
+	{"uptime-7d", "Perpetual motion", "ran for 7 days straight", statUptime, 7 * 24 * 60 * 60},
+	{"games-1", "Player one", "finished an arcade game", statGames, 1},
+	{"games-50", "Arcade regular", "finished 50 arcade games", statGames, 50},
+}
+
+// achievementState is persisted in the state file
+type achievementState struct {
+	Stats    map[string]int64     `json:"stats,omitempty"`
+	Unlocked map[string]time.Time `json:"unlocked,omitempty"`
+}
+
+// achievementTracker counts the statistics, unlocks achievements and queues a toast for
+// each unlock. A nil tracker counts nothing, as in benchmarks.
+type achievementTracker struct {
+	state   achievementState
+	started time.Time
+	dirty   bool
+	saved   time.Time
+	toasts  []string // Titles waiting to be shown, the first one is on screen
+	shown   int      // Frames the first toast has been on screen
+}
+
+// loadAchievements reads the tracker's state from the state file
+func loadAchievements() *achievementTracker {
+	t := &achievementTracker{started: time.Now(), saved: time.Now()}
+	if st := loadState().Achievements; st != nil {
+		t.state = *st
+	}
+	if t.state.Stats == nil {
+		t.state.Stats = map[string]int64{}
+	}
+	if t.state.Unlocked == nil {
+		t.state.Unlocked = map[string]time.Time{}
+	}
+	return t
+}
+
+// addStat counts n more of stat
+func (g *Game) addStat(stat string, n int64) {
+	if t := g.achievements; t != nil {
+		g.setStat(stat, t.state.Stats[stat]+n)
+	}
+}
+
+// setStat raises stat to value and unlocks the achievements it reaches
+func (g *Game) setStat(stat string, value int64) {
+	t := g.achievements
+	if t == nil || value <= t.state.Stats[stat] {
+		return
+	}
+	t.state.Stats[stat] = value
+	t.dirty = true
+	for _, a := range achievements {
+		if _, done := t.state.Unlocked[a.id]; done || a.stat != stat || value < a.goal {
+			continue
+		}
+		t.state.Unlocked[a.id] = time.Now()
+		t.toasts = append(t.toasts, a.title)
+		slog.Info("achievement unlocked", "achievement", a.id)
+		g.notifier.notify(eventAchievement, fmt.Sprintf("Achievement unlocked: %s, %s", a.title, a.about), map[string]any{"achievement": a.id})
+	}
+}
+
+// achievementSystem tracks the uptime, advances the toasts and saves the state now and
+// then
+func (g *Game) achievementSystem() {
+	t := g.achievements
+	if t == nil {
+		return
+	}
+	if g.frame%ebiten.TPS() == 0 {
+		g.setStat(statUptime, int64(time.Since(t.started).Seconds()))
+	}
+	if len(t.toasts) > 0 {
+		if t.shown++; t.shown >= durationFrames(toastDuration) {
+			t.toasts, t.shown = t.toasts[1:], 0
+		}
+	}
+	if t.dirty && time.Since(t.saved) >= achievementSaveInterval {
+		t.save()
+	}
+}
+
+// save writes the statistics and unlocks to the state file
+func (t *achievementTracker) save() {
+	state := t.state
+	updateState(func(st *appState) { st.Achievements = &state })
+	t.dirty, t.saved = false, time.Now()
+}
+
+// drawToast draws the achievement being announced at the bottom of the screen
+func (g *Game) drawToast(screen *ebiten.Image) {
+	t := g.achievements
+	if t == nil || len(t.toasts) == 0 {
+		return
+	}
+	const scale = 2
+	msg := trf("Achievement unlocked: %s", t.toasts[0])
+	fade := float64(ticks(toastFade))
+	alpha := min(1, float64(t.shown)/fade, float64(durationFrames(toastDuration)-t.shown)/fade)
+	w, h := logicalSize(screen)
+	boxW, boxH := textWidth(msg, scale)+40, float64(baseFontHeight*scale+20)
+	x, y := (w-boxW)/2, h-boxH-40
+
+	drawFilledRect(screen, float32(x), float32(y), float32(boxW), float32(boxH), color.RGBA{A: uint8(180 * alpha)}, false)
+	clr := g.theme.hud
+	clr = color.RGBA{uint8(float64(clr.R) * alpha), uint8(float64(clr.G) * alpha), uint8(float64(clr.B) * alpha), uint8(255 * alpha)}
+	drawText(screen, msg, x+20, y+10, scale, clr)
+}
+
+// achievementsScene lists the achievements with the unlock date or the progress
+type achievementsScene struct{}
+
+func (achievementsScene) Update(a *app) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyTab) ||
+		inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		a.switchTo(&menuScene{})
+	}
+	return nil
+}
+
+func (achievementsScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	const titleScale, lineScale = 5, 2
+	w, h := logicalSize(screen)
+	y := h/2 - float64(len(achievements)+4)*baseFontHeight*lineScale*0.75
+	title := tr("ACHIEVEMENTS")
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, y, titleScale, a.game.theme.hud)
+	y += baseFontHeight * titleScale * 1.5
+
+	var state achievementState
+	if t := a.game.achievements; t != nil {
+		state = t.state
+	}
+	for _, ach := range achievements {
+		status := fmt.Sprintf("%d/%d", min(state.Stats[ach.stat], ach.goal), ach.goal)
+		at, done := state.Unlocked[ach.id]
+		if done {
+			status = at.Format(time.DateOnly)
+		}
+		line := fmt.Sprintf("%-18s %-30s %s", tr(ach.title), tr(ach.about), status)
+		drawText(screen, line, (w-textWidth(line, lineScale))/2, y, lineScale, a.menuColor(done))
+		y += baseFontHeight * lineScale * 1.5
+	}
+	y += baseFontHeight * lineScale
+	footer := tr("Esc goes back")
+	drawText(screen, footer, (w-textWidth(footer, lineScale))/2, y, lineScale, a.game.theme.hud)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+// minLifetimeFrames keeps jittered lifetimes long enough to fade in and out
+const minLifetimeFrames = 2 * fadeOutFrames
+
+// donutLifetime returns a random lifetime in frames from the lifetime settings, 0 when
+// donuts live forever
+func (g *Game) donutLifetime() int {
+	if g.lifetime <= 0 {
+		return 0
+	}
+	seconds := g.lifetime + (2*g.rng.Float64()-1)*g.lifetimeJitter
+	return max(ticks(minLifetimeFrames), durationFrames(time.Duration(seconds*float64(time.Second))))
+}
+
+// ageDonut gives a donut a lifetime when aging is enabled. Donuts of the first generation
+// start at a random age so they don't all expire together, fresh ones fade in.
+func (g *Game) ageDonut(e ecs.Entity, fresh bool) {
+	total := g.donutLifetime()
+	if total == 0 {
+		return
+	}
+	left := total
+	if !fresh {
+		left = 1 + g.rng.Intn(total)
+	}
+	g.world.lifetimes.Add(e, lifetime{left: left, total: total, fadeIn: fresh})
+}
+
+// respawnDonut replaces an expired donut with a fresh one that looks the same and moves in
+// the same depth layer, placed by the spawner
+func (g *Game) respawnDonut(img *ebiten.Image, depth int) {
+	d := createDonuts(g.rng, g.spawner, g.screenWidth, g.screenHeight, g.donutWidth, g.donutHeight, 1, g.minSpeed, g.maxSpeed, g.minSpin, g.maxSpin)[0]
+	e := g.world.spawnDonut(inDepthLayer(d, depth), img)
+	g.world.layers.Add(e, layer{depth})
+	g.ageDonut(e, true)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mlctrez/donut/client"
+)
+
+const (
+	commandQueueSize = 64
+	commandTimeout   = 2 * time.Second
+)
+
+// command is a change requested from outside the game loop. Commands are queued and run
+// at the start of the next frame, whatever scene is showing, so they never race with the
+// simulation.
+type command func(g *Game)
+
+// runCommands runs every queued command
+func (g *Game) runCommands() {
+	for {
+		select {
+		case cmd := <-g.commands:
+			cmd(g)
+		default:
+			return
+		}
+	}
+}
+
+// call runs fn on the game loop and waits for it to finish
+func (g *Game) call(fn func(g *Game)) error {
+	done := make(chan struct{})
+	select {
+	case g.commands <- func(g *Game) { fn(g); close(done) }:
+	case <-time.After(commandTimeout):
+		return errors.New("game loop is busy")
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(commandTimeout):
+		return errors.New("game loop didn't respond")
+	}
+}
+
+// setDonutCount changes the donut count as a recorded input
+func (g *Game) setDonutCount(n int) {
+	s := g.currentSettings()
+	s.Donuts = n
+	ev := replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s}
+	g.recordEvent(ev)
+	g.applyAction(ev)
+}
+
+// notifyDonutCount sends the donut count set through the API to the webhooks. It's called
+// on the game loop, which stops before the notifier is closed.
+func (g *Game) notifyDonutCount(n int) {
+	g.notifier.notify(eventDonuts, fmt.Sprintf("Donut count set to %d", n), map[string]any{"count": n})
+}
+
+// snapshot returns the state of the simulation for the API
+func (g *Game) snapshot() client.State {
+	w := g.world
+	s := client.State{
+		Frame:    g.frame,
+		Width:    g.screenWidth,
+		Height:   g.screenHeight,
+		Settings: apiSettings(g.currentSettings()),
+		Timer:    client.Timer{Start: g.timerStartTime, Elapsed: g.elapsed().Seconds()},
+		Donuts:   make([]client.Donut, 0, w.donuts.Len()),
+	}
+	for _, e := range w.donuts.Entities() {
+		pos, vel, sp := w.positions.Get(e), w.velocities.Get(e), w.spins.Get(e)
+		s.Donuts = append(s.Donuts, client.Donut{X: pos.x, Y: pos.y, VX: vel.x, VY: vel.y, Angle: sp.angle})
+	}
+	return s
+}
+
+// apiSettings converts settings to the API's. The API has its own type so the internal
+// struct can change without changing what clients see.
+func apiSettings(s settings) client.Settings {
+	return client.Settings{
+		Donuts:         s.Donuts,
+		Gravity:        s.Gravity,
+		Theme:          s.Theme,
+		Behavior:       s.Behavior,
+		MinSpeed:       s.MinSpeed,
+		MaxSpeed:       s.MaxSpeed,
+		MinSpin:        s.MinSpin,
+		MaxSpin:        s.MaxSpin,
+		Trails:         s.Trails,
+		Layers:         s.Layers,
+		Crumbs:         s.Crumbs,
+		Size:           s.Size,
+		Lifetime:       s.Lifetime,
+		LifetimeJitter: s.LifetimeJitter,
+		Chain:          s.Chain,
+		ChainRing:      s.ChainRing,
+		Magnets:        s.Magnets,
+		Rainbow:        s.Rainbow,
+		RainbowTimer:   s.RainbowTimer,
+	}
+}
+
+// settingsFromAPI converts settings received through the API
+func settingsFromAPI(s client.Settings) settings {
+	return settings{
+		Donuts:         s.Donuts,
+		Gravity:        s.Gravity,
+		Theme:          s.Theme,
+		Behavior:       s.Behavior,
+		MinSpeed:       s.MinSpeed,
+		MaxSpeed:       s.MaxSpeed,
+		MinSpin:        s.MinSpin,
+		MaxSpin:        s.MaxSpin,
+		Trails:         s.Trails,
+		Layers:         s.Layers,
+		Crumbs:         s.Crumbs,
+		Size:           s.Size,
+		Lifetime:       s.Lifetime,
+		LifetimeJitter: s.LifetimeJitter,
+		Chain:          s.Chain,
+		ChainRing:      s.ChainRing,
+		Magnets:        s.Magnets,
+		Rainbow:        s.Rainbow,
+		RainbowTimer:   s.RainbowTimer,
+	}
+}
+
+// handleAPIv1 adds the routes of the structured API to mux
+func handleAPIv1(mux *http.ServeMux, g *Game) {
+	// respond runs fn on the game loop and writes its result as JSON, or no content when
+	// it returns nil
+	respond := func(w http.ResponseWriter, fn func(g *Game) any) {
+		var out any
+		if err := g.call(func(g *Game) { out = fn(g) }); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if out == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(out)
+	}
+	decode := func(w http.ResponseWriter, r *http.Request, v any) bool {
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(v); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("GET /api/v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(client.OpenAPI)
+	})
+	mux.HandleFunc("GET /api/v1/state", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, func(g *Game) any { return g.snapshot() })
+	})
+	mux.HandleFunc("GET /api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, func(g *Game) any { return apiSettings(g.currentSettings()) })
+	})
+	mux.HandleFunc("PUT /api/v1/settings", func(w http.ResponseWriter, r *http.Request) {
+		var s client.Settings
+		if !decode(w, r, &s) {
+			return
+		}
+		respond(w, func(g *Game) any {
+			set := settingsFromAPI(s)
+			ev := replayEvent{Frame: g.frame, Action: actionSettings, Settings: &set}
+			g.recordEvent(ev)
+			g.applyAction(ev)
+			return apiSettings(g.currentSettings())
+		})
+	})
+	mux.HandleFunc("GET /api/v1/themes", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, func(g *Game) any { return client.Themes{Current: g.themeName, Available: themeNames()} })
+	})
+	mux.HandleFunc("PUT /api/v1/theme", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if !decode(w, r, &body) {
+			return
+		}
+		// Themes are only added at startup, so they can be read here
+		if _, ok := themes[body.Name]; !ok {
+			http.Error(w, fmt.Sprintf("unknown theme %q, available: %v", body.Name, themeNames()), http.StatusBadRequest)
+			return
+		}
+		respond(w, func(g *Game) any { g.setTheme(body.Name); return nil })
+	})
+	mux.HandleFunc("PUT /api/v1/donuts", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Count int `json:"count"`
+		}
+		if !decode(w, r, &body) {
+			return
+		}
+		if body.Count < minDonuts || body.Count > maxDonuts {
+			http.Error(w, fmt.Sprintf("count must be between %d and %d", minDonuts, maxDonuts), http.StatusBadRequest)
+			return
+		}
+		respond(w, func(g *Game) any { g.setDonutCount(body.Count); g.notifyDonutCount(body.Count); return nil })
+	})
+	mux.HandleFunc("PUT /api/v1/timer", func(w http.ResponseWriter, r *http.Request) {
+		var t client.Timer
+		if !decode(w, r, &t) {
+			return
+		}
+		if t.Start.IsZero() {
+			http.Error(w, "start is required", http.StatusBadRequest)
+			return
+		}
+		respond(w, func(g *Game) any { g.restartTimer(t.Start); return nil })
+	})
+	mux.HandleFunc("POST /api/v1/timer/reset", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, func(g *Game) any { g.resetTimer(); return nil })
+	})
+}
+
+// startAPI serves the HTTP control API on addr in the background:
+//
+//	GET  /api/state               current settings and donut count as JSON
+//	POST /api/donuts?count=20     set the number of donuts
+//
+// and the structured API described by client/openapi.yaml under /api/v1, see the client
+// package. The returned function stops the server, waiting for the requests in progress.
+func startAPI(addr string, g *Game) (stop func()) {
+	mux := http.NewServeMux()
+	handleAPIv1(mux, g)
+	mux.HandleFunc("GET /api/state", func(w http.ResponseWriter, r *http.Request) {
+		var s settings
+		if err := g.call(func(g *Game) { s = g.currentSettings() }); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+	mux.HandleFunc("POST /api/donuts", func(w http.ResponseWriter, r *http.Request) {
+		count, err := strconv.Atoi(r.URL.Query().Get("count"))
+		if err != nil || count < minDonuts || count > maxDonuts {
+			http.Error(w, fmt.Sprintf("count must be between %d and %d", minDonuts, maxDonuts), http.StatusBadRequest)
+			return
+		}
+		if err := g.call(func(g *Game) { g.setDonutCount(count); g.notifyDonutCount(count) }); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		slog.Info("HTTP API listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP API stopped", "err", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Warn("HTTP API didn't stop cleanly", "err", err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mlctrez/donut/client"
+)
+
+// newAPIClient serves the v1 API of g and runs its commands like the game loop does, until
+// the test ends
+func newAPIClient(t *testing.T, g *Game) *client.Client {
+	t.Helper()
+	mux := http.NewServeMux()
+	handleAPIv1(mux, g)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case cmd := <-g.commands:
+				cmd(g)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(stop); <-done })
+	return client.New(srv.URL)
+}
+
+func TestAPIRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := newAPIClient(t, newBenchGame(settings{Donuts: 10}, 1))
+
+	s, err := c.Settings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Donuts, s.Gravity, s.Trails, s.Rainbow = 25, 0.1, true, true
+	applied, err := c.SetSettings(ctx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != s {
+		t.Errorf("applied %+v, want %+v", applied, s)
+	}
+
+	if err := c.SetDonutCount(ctx, 30); err != nil {
+		t.Fatal(err)
+	}
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Donuts) != 30 || state.Settings.Donuts != 30 || state.Settings.Gravity != 0.1 {
+		t.Errorf("%d donuts with settings %+v, want 30 with the gravity set before", len(state.Donuts), state.Settings)
+	}
+
+	var apiErr *client.Error
+	if err := c.SetDonutCount(ctx, maxDonuts+1); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("setting too many donuts: got %v, want a bad request", err)
+	}
+	if err := c.SetTheme(ctx, "no such theme"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("setting an unknown theme: got %v, want a bad request", err)
+	}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := c.SetTimerStart(ctx, start); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = c.State(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !state.Timer.Start.Equal(start) || state.Timer.Elapsed < time.Hour.Seconds() {
+		t.Errorf("timer %+v, want started at %v", state.Timer, start)
+	}
+}
+
+// TestAPISettingsFields checks that every field of the settings makes it through the API
+// and back, so a new setting can't be left out of the conversions
+func TestAPISettingsFields(t *testing.T) {
+	var s settings
+	v := reflect.ValueOf(&s).Elem()
+	for i := range v.NumField() {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Int:
+			f.SetInt(int64(i + 1))
+		case reflect.Float64:
+			f.SetFloat(float64(i) + 0.5)
+		case reflect.String:
+			f.SetString(v.Type().Field(i).Name)
+		case reflect.Bool:
+			f.SetBool(true)
+		default:
+			t.Fatalf("settings field %s of kind %s isn't covered", v.Type().Field(i).Name, f.Kind())
+		}
+	}
+	if n, m := reflect.TypeOf(s).NumField(), reflect.TypeOf(client.Settings{}).NumField(); n != m {
+		t.Errorf("settings has %d fields and client.Settings %d", n, m)
+	}
+	if got := settingsFromAPI(apiSettings(s)); got != s {
+		t.Errorf("got %+v back, want %+v", got, s)
+	}
+}
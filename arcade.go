@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var gameFlag = runFlags.String("game", "", "start an arcade game instead of the screensaver: "+fmt.Sprint(arcadeNames()))
+
+// arcadeGame is a game played with the donuts, run by arcadeScene. The simulation keeps
+// running underneath, set up by start and steered by update.
+type arcadeGame interface {
+	// start sets up the simulation for the game, the previous settings are restored when
+	// the game ends
+	start(g *Game)
+	// update runs after each simulation step and reports whether the game is over
+	update(g *Game) (over bool)
+	// draw draws the game's pieces over the simulation
+	draw(g *Game, screen *ebiten.Image)
+	// scoreboard returns the text shown in place of the timer
+	scoreboard() string
+	// score returns the result for the high score table, higher is better
+	score() float64
+}
+
+// arcadeCleanup is implemented by games that add entities of their own, to remove them
+// when the game ends
+type arcadeCleanup interface {
+	stop(g *Game)
+}
+
+// arcadeSummary is implemented by games with more to show on the game over screen than
+// the scoreboard
+type arcadeSummary interface {
+	summary() []string
+}
+
+// arcadeEntry is a game, the name it's selected by and the format of its scores
+type arcadeEntry struct {
+	name   string
+	format string
+	new    func() arcadeGame
+}
+
+// arcadeGames are the games selectable in the games menu and with -game, in menu order
+var arcadeGames = []arcadeEntry{
+	{"pong", "%.0f", func() arcadeGame { return &pong{} }},
+	{"breakout", "%.0f", func() arcadeGame { return &breakout{} }},
+	{"dodge", "%.1fs", func() arcadeGame { return &dodge{} }},
+	{"target", "%.0f", func() arcadeGame { return &target{} }},
+	{"guardians", "%.0f", func() arcadeGame { return &guardians{} }},
+}
+
+// arcadeNames returns the names of the arcade games in menu order
+func arcadeNames() []string {
+	names := make([]string, len(arcadeGames))
+	for i, a := range arcadeGames {
+		names[i] = a.name
+	}
+	return names
+}
+
+// newArcadeGame returns a new game by name
+func newArcadeGame(name string) (arcadeGame, bool) {
+	i := slices.IndexFunc(arcadeGames, func(a arcadeEntry) bool { return a.name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return arcadeGames[i].new(), true
+}
+
+// formatScore formats a score of the named game for the high score table
+func formatScore(name string, score float64) string {
+	i := slices.IndexFunc(arcadeGames, func(a arcadeEntry) bool { return a.name == name })
+	if i < 0 {
+		return fmt.Sprint(score)
+	}
+	return fmt.Sprintf(arcadeGames[i].format, score)
+}
+
+// gameOverTimeout is how long the game over screen waits for another round before going
+// back to the screensaver
+const gameOverTimeout = 30 * time.Second
+
+// arcadeScene plays an arcade game. The keyboard belongs to the game, Escape ends it and
+// returns to the screensaver with the settings it had before.
+type arcadeScene struct {
+	name    string
+	game    arcadeGame
+	saved   settings
+	edges   [4]edgeMode
+	spawner spawner
+	locked  bool // Whether the simulation's keys were locked before, as in kiosk mode
+	over    bool
+	idle    int // Frames spent on the game over screen
+
+	entry  *initialsKeyboard // Set while initials are entered for a high score
+	scores []highScore       // High score table once a score was entered
+	rank   int               // Of the entered score in scores
+}
+
+// startArcade switches to the named game. Games steer the simulation with input that
+// isn't recorded, so they can't be played while recording or replaying.
+func (a *app) startArcade(name string) {
+	g := a.game
+	if g.recorder != nil || g.replay != nil {
+		slog.Warn("arcade games are disabled while recording or replaying", "game", name)
+		return
+	}
+	game, ok := newArcadeGame(name)
+	if !ok {
+		slog.Warn("unknown arcade game", "game", name, "available", arcadeNames())
+		return
+	}
+	s := &arcadeScene{name: name, game: game, saved: g.currentSettings(), edges: g.edges, spawner: g.spawner, locked: g.inputLocked}
+	game.start(g)
+	g.scoreboard = game.scoreboard()
+	a.switchTo(s)
+}
+
+// stop ends the game and restores the simulation
+func (s *arcadeScene) stop(a *app) {
+	g := a.game
+	if cleanup, ok := s.game.(arcadeCleanup); ok {
+		cleanup.stop(g)
+	}
+	g.edges = s.edges
+	g.spawner = s.spawner
+	g.applySettings(s.saved)
+	g.scoreboard = ""
+	g.inputLocked = s.locked
+	a.switchTo(simulationScene{})
+}
+
+func (s *arcadeScene) Update(a *app) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		s.stop(a)
+		return nil
+	}
+	if s.over && s.entry != nil {
+		if s.idle++; s.idle >= durationFrames(gameOverTimeout) {
+			// Nobody is there to enter initials, the score is dropped
+			s.stop(a)
+		} else if s.entry.update(a.game.screenWidth, a.game.screenHeight) {
+			hs := highScore{Initials: s.entry.initials, Score: s.game.score(), Date: time.Now()}
+			s.scores, s.rank = addHighScore(s.name, hs)
+			s.entry, s.idle = nil, 0
+		}
+		return nil
+	}
+	if s.over {
+		switch s.idle++; {
+		case inpututil.IsKeyJustPressed(ebiten.KeyEnter),
+			// A click only after a moment, so one meant for the game doesn't start another
+			s.idle > durationFrames(time.Second) && inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+			s.stop(a)
+			a.startArcade(s.name)
+		case s.idle >= durationFrames(gameOverTimeout):
+			s.stop(a)
+		}
+		return nil
+	}
+
+	// The simulation's own keys are off while playing, the game reads the keyboard
+	g := a.game
+	g.inputLocked = true
+	if err := g.Update(); err != nil {
+		return err
+	}
+	s.over = s.game.update(g)
+	g.scoreboard = s.game.scoreboard()
+	if s.over {
+		g.addStat(statGames, 1)
+	}
+	if s.over && isHighScore(loadState().HighScores[s.name], s.game.score()) {
+		s.entry = &initialsKeyboard{}
+	}
+	return nil
+}
+
+// drawScoreboard draws the arcade game's score centered at the top of the screen
+func (g *Game) drawScoreboard(screen *ebiten.Image) {
+	scale := float64(timerFontSize) / baseFontHeight
+	w, _ := logicalSize(screen)
+	drawText(screen, g.scoreboard, (w-textWidth(g.scoreboard, scale))/2, timerMargin, scale, g.timerColor())
+}
+
+func (s *arcadeScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	s.game.draw(a.game, screen)
+	switch {
+	case s.over && s.entry != nil:
+		dimScreen(screen)
+		s.entry.draw(a, screen, formatScore(s.name, s.game.score()))
+	case s.over && s.scores != nil:
+		dimScreen(screen)
+		drawHighScores(a, screen, s.name, s.scores, s.rank, tr("Enter or click plays again, Esc exits"))
+	case s.over:
+		dimScreen(screen)
+		lines := []string{s.game.scoreboard()}
+		if summary, ok := s.game.(arcadeSummary); ok {
+			lines = summary.summary()
+		}
+		drawMenu(a, screen, "GAME OVER", append(lines, "Enter or click plays again, Esc exits"), -1)
+	}
+}
+
+// gamesScene lists the arcade games
+type gamesScene struct {
+	selected int
+}
+
+func (m *gamesScene) Update(a *app) error {
+	items := len(arcadeGames) + 1 // The games and Back
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		m.selected = (m.selected + items - 1) % items
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		m.selected = (m.selected + 1) % items
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		a.switchTo(&menuScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		if m.selected == len(arcadeGames) {
+			a.switchTo(&menuScene{})
+			return nil
+		}
+		a.switchTo(simulationScene{})
+		a.startArcade(arcadeGames[m.selected].name)
+	}
+	return nil
+}
+
+func (m *gamesScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	drawMenu(a, screen, "GAMES", append(arcadeNames(), "Back"), m.selected)
+}
@@ -0,0 +1,33 @@
+package main
+
+import "time"
+
+// attractSequence is the list of configurations attract mode cycles through
+var attractSequence = []settings{
+	{Donuts: 6, Theme: "classic", Behavior: "bounce"},
+	{Donuts: 20, Gravity: 0.15, Theme: "classic", Behavior: "bounce"},
+	{Donuts: 12, Theme: "neon", Behavior: "swirl"},
+	{Donuts: 30, Theme: "sepia", Behavior: "wander"},
+	{Donuts: 3, Gravity: 0.3, Theme: "ice", Behavior: "bounce"},
+	{Donuts: 40, Theme: "neon", Behavior: "bounce"},
+}
+
+// attractMode switches to the next entry of attractSequence every period. Switching is
+// driven by the frame counter rather than the wall clock so recordings stay deterministic.
+type attractMode struct {
+	period time.Duration
+	index  int
+}
+
+func newAttractMode(period time.Duration) *attractMode {
+	return &attractMode{period: period}
+}
+
+// next returns the settings to switch to on this frame, if it is time to switch
+func (a *attractMode) next(frame int) (settings, bool) {
+	if frame == 0 || frame%max(1, durationFrames(a.period)) != 0 {
+		return settings{}, false
+	}
+	a.index = (a.index + 1) % len(attractSequence)
+	return attractSequence[a.index], true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sync/atomic"
+)
+
+const (
+	audioChunkSamples = 512 // Samples per loudness measurement
+	audioSampleRate   = 22050
+	audioGain         = 4    // Typical music is far from full scale
+	audioBeatRatio    = 1.4  // Loudness over the running average that counts as a beat
+	audioPulseDecay   = 0.9  // Per frame decay of a beat pulse
+	audioAverageRate  = 0.02 // Weight of the current loudness in the running average
+)
+
+// Audio reactive modes
+const (
+	audioSize  = "size"  // Donuts grow with the music
+	audioSpeed = "speed" // Donuts move faster with the music, replays won't reproduce it
+	audioTint  = "tint"  // Donuts are colored with the music
+)
+
+var audioModes = []string{audioSize, audioSpeed, audioTint}
+
+// errAudioUnsupported is returned by startAudioCapture on platforms without capture support
+var errAudioUnsupported = errors.New("audio capture is not supported on this platform")
+
+// audioMeter measures the loudness of signed 16-bit little-endian mono samples
+type audioMeter struct {
+	levelBits atomic.Uint64 // math.Float64bits of the latest loudness in [0, 1]
+}
+
+// run measures samples from r until it fails or is closed, then closes it
+func (m *audioMeter) run(r io.ReadCloser) {
+	defer r.Close()
+	samples := make([]int16, audioChunkSamples)
+	for {
+		if err := binary.Read(r, binary.LittleEndian, samples); err != nil {
+			slog.Warn("audio capture stopped", "err", err)
+			m.levelBits.Store(0)
+			return
+		}
+		var sum float64
+		for _, s := range samples {
+			v := float64(s) / math.MaxInt16
+			sum += v * v
+		}
+		m.levelBits.Store(math.Float64bits(math.Sqrt(sum / audioChunkSamples)))
+	}
+}
+
+func (m *audioMeter) level() float64 {
+	return math.Float64frombits(m.levelBits.Load())
+}
+
+// audioReactive turns the captured loudness into a pulse between 0 and 1 that jumps on
+// beats and decays between them
+type audioReactive struct {
+	mode    string
+	meter   *audioMeter
+	capture io.Closer // Nil without capture
+	average float64
+	pulse   float64
+}
+
+// newAudioReactive starts capturing from device, the platform default when empty. Without
+// capture support the pulse stays 0.
+func newAudioReactive(mode, device string) (*audioReactive, error) {
+	switch mode {
+	case audioSize, audioSpeed, audioTint:
+	default:
+		return nil, fmt.Errorf("unknown audio mode %q, want one of %v", mode, audioModes)
+	}
+	a := &audioReactive{mode: mode, meter: &audioMeter{}}
+	r, err := startAudioCapture(device)
+	if err != nil {
+		slog.Warn("audio reactive mode disabled", "err", err)
+		return a, nil
+	}
+	a.capture = r
+	go a.meter.run(r)
+	return a, nil
+}
+
+// close stops capturing
+func (a *audioReactive) close() {
+	if a != nil && a.capture != nil {
+		a.capture.Close()
+	}
+}
+
+// update advances the pulse by one frame
+func (a *audioReactive) update() {
+	level := a.meter.level()
+	beat := a.average > 0.01 && level > a.average*audioBeatRatio
+	a.average += (level - a.average) * audioAverageRate
+	a.pulse *= audioPulseDecay
+	if beat {
+		a.pulse = 1
+	}
+	a.pulse = max(a.pulse, min(1, level*audioGain))
+}
+
+// scale returns the donut size multiplier
+func (a *audioReactive) scale() float64 {
+	if a == nil || a.mode != audioSize {
+		return 1
+	}
+	return 1 + 0.4*a.pulse
+}
+
+// speed returns the movement multiplier
+func (a *audioReactive) speed() float64 {
+	if a == nil || a.mode != audioSpeed {
+		return 1
+	}
+	return 1 + 1.5*a.pulse
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	themeBackground     = "theme"     // The theme's background color, no plugin
+	slideshowBackground = "slideshow" // The configured slideshow
+)
+
+// backgroundNames returns the backgrounds K cycles through: the theme color, the
+// slideshow when configured and the registered plugins
+func (g *Game) backgroundNames() []string {
+	names := []string{themeBackground}
+	if g.slideshow != nil {
+		names = append(names, slideshowBackground)
+	}
+	return slices.Concat(names, plugin.Backgrounds())
+}
+
+// pollBackground switches to the next background on K, or the previous one with Shift.
+// Backgrounds are only drawn, so unlike themes the switch isn't recorded.
+func (g *Game) pollBackground() {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyK) {
+		return
+	}
+	if g.oled != nil {
+		slog.Info("OLED-safe mode keeps the background black")
+		return
+	}
+	dir := 1
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		dir = -1
+	}
+	g.setBackground(cycle(g.backgroundNames(), g.backgroundName, dir))
+}
+
+// setBackground draws the named background behind the donuts. It reports false for an
+// unknown name.
+func (g *Game) setBackground(name string) bool {
+	var background plugin.Background
+	switch name {
+	case themeBackground:
+	case slideshowBackground:
+		if g.slideshow == nil {
+			return false
+		}
+		background = g.slideshow
+	default:
+		var ok bool
+		if background, ok = plugin.LookupBackground(name); !ok {
+			return false
+		}
+	}
+	g.background, g.backgroundName = background, name
+	// The trails fade to the background, recreate them for the new one
+	if g.trailLayer != nil {
+		g.trailLayer.Dispose()
+		g.trailLayer = nil
+	}
+	slog.Info("background", "name", name)
+	return true
+}
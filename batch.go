@@ -0,0 +1,149 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	atlasPadding   = 1                  // Transparent pixels between sprites so filtering doesn't bleed
+	maxBatchBodies = math.MaxUint16 / 4 // Bodies per DrawTriangles call, limited by uint16 indices
+)
+
+var batchFlag = runFlags.Bool("batch", true, "draw all donuts with one draw call from a sprite atlas instead of one call per donut")
+
+// batchRenderer packs the sprite variants into one atlas and draws every donut of a layer
+// with a single DrawTriangles call. The vertices are transformed like the sprite renderer
+// does, so batched donuts look the same. Sprites missing from the atlas, like slideshow
+// images swapped in later, are drawn one by one by the wrapped renderer.
+type batchRenderer struct {
+	fallback plugin.Renderer
+	atlas    *ebiten.Image
+	rects    map[*ebiten.Image]image.Rectangle // Location of each sprite in the atlas
+	vertices []ebiten.Vertex
+	indices  []uint16
+	geoM     ebiten.GeoM
+	op       ebiten.DrawTrianglesOptions
+}
+
+// newBatchRenderer packs sprites into an atlas, left to right in rows no wider than
+// maxSpriteSize. Sprites that don't fit are left to the fallback renderer.
+func newBatchRenderer(fallback plugin.Renderer, sprites ...*ebiten.Image) *batchRenderer {
+	r := &batchRenderer{
+		fallback: fallback,
+		rects:    map[*ebiten.Image]image.Rectangle{},
+		op:       ebiten.DrawTrianglesOptions{ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha},
+	}
+
+	var x, y, rowHeight, width, height int
+	for _, sprite := range sprites {
+		if _, dup := r.rects[sprite]; dup || sprite == nil {
+			continue
+		}
+		size := sprite.Bounds().Size()
+		if x > 0 && x+size.X > maxSpriteSize {
+			x, y, rowHeight = 0, y+rowHeight+atlasPadding, 0
+		}
+		if y+size.Y > maxSpriteSize {
+			slog.Warn("sprite doesn't fit in the atlas, drawing it separately", "width", size.X, "height", size.Y)
+			continue
+		}
+		r.rects[sprite] = image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(size)}
+		x += size.X + atlasPadding
+		rowHeight = max(rowHeight, size.Y)
+		width, height = max(width, x), max(height, y+rowHeight)
+	}
+	if len(r.rects) == 0 {
+		return r
+	}
+
+	r.atlas = ebiten.NewImage(width, height)
+	op := &ebiten.DrawImageOptions{}
+	for sprite, rect := range r.rects {
+		op.GeoM.Reset()
+		op.GeoM.Translate(float64(rect.Min.X), float64(rect.Min.Y))
+		r.atlas.DrawImage(sprite, op)
+	}
+	return r
+}
+
+// replaceSprite packs the atlas again with img in place of old, which is disposed by the
+// caller. The new image usually has another size, so it can't reuse the old rectangle.
+func (r *batchRenderer) replaceSprite(old, img *ebiten.Image) {
+	if _, ok := r.rects[old]; !ok {
+		return
+	}
+	sprites := []*ebiten.Image{img}
+	for sprite := range r.rects {
+		if sprite != old {
+			sprites = append(sprites, sprite)
+		}
+	}
+	r.atlas.Dispose()
+	packed := newBatchRenderer(r.fallback, sprites...)
+	r.atlas, r.rects = packed.atlas, packed.rects
+}
+
+// DrawBody appends the body's quad to the batch. The batch is drawn by Flush, or right
+// away when it's full or the sprite isn't in the atlas, to keep the drawing order.
+func (r *batchRenderer) DrawBody(dst *ebiten.Image, sprite *ebiten.Image, b plugin.Body, tint color.Color) {
+	rect, ok := r.rects[sprite]
+	if !ok {
+		r.Flush(dst)
+		r.fallback.DrawBody(dst, sprite, b, tint)
+		return
+	}
+	if len(r.vertices) >= maxBatchBodies*4 {
+		r.Flush(dst)
+	}
+
+	w, h := b.Size()
+	x, y := b.Position()
+	sw, sh := float64(rect.Dx()), float64(rect.Dy())
+
+	// Same transformations as the sprite renderer, applied to the corners here
+	m := &r.geoM
+	m.Reset()
+	m.Scale(w/sw, h/sh)
+	m.Translate(-w/2, -h/2)
+	m.Rotate(b.Rotation())
+	if angle, along, across := b.Deformation(); along != 1 || across != 1 {
+		m.Rotate(-angle)
+		m.Scale(along, across)
+		m.Rotate(angle)
+	}
+	m.Translate(w/2+x, h/2+y)
+
+	// Vertex colors are premultiplied, like ColorScale.ScaleWithColor
+	cr, cg, cb, ca := tint.RGBA()
+	base := uint16(len(r.vertices))
+	for _, corner := range [4][2]float64{{0, 0}, {sw, 0}, {0, sh}, {sw, sh}} {
+		dx, dy := m.Apply(corner[0], corner[1])
+		r.vertices = append(r.vertices, ebiten.Vertex{
+			DstX:   float32(dx),
+			DstY:   float32(dy),
+			SrcX:   float32(float64(rect.Min.X) + corner[0]),
+			SrcY:   float32(float64(rect.Min.Y) + corner[1]),
+			ColorR: float32(cr) / 0xffff,
+			ColorG: float32(cg) / 0xffff,
+			ColorB: float32(cb) / 0xffff,
+			ColorA: float32(ca) / 0xffff,
+		})
+	}
+	r.indices = append(r.indices, base, base+1, base+2, base+1, base+3, base+2)
+}
+
+// Flush draws the batched bodies onto dst and empties the batch
+func (r *batchRenderer) Flush(dst *ebiten.Image) {
+	if len(r.indices) == 0 {
+		return
+	}
+	dst.DrawTriangles(r.vertices, r.indices, r.atlas, &r.op)
+	r.vertices = r.vertices[:0]
+	r.indices = r.indices[:0]
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+// benchmark measures one part of the simulation with a given number of donuts. The bench
+// command and the package's benchmarks and allocation tests share them.
+type benchmark struct {
+	name      string
+	setup     func(g *Game) func() // Prepares g and returns the measured operation
+	maxAllocs int64                // Allocations per operation that fail the benchmark, unchecked when negative
+}
+
+var benchmarks = []benchmark{
+	{"update", func(g *Game) func() {
+		return func() { g.Update() }
+	}, 0},
+	{"draw", func(g *Game) func() {
+		screen := ebiten.NewImage(g.screenWidth, g.screenHeight)
+		return func() { g.Draw(screen) }
+	}, 8},
+	{"draw-cached", func(g *Game) func() {
+		cache := newRotationCache(g.renderer, 64, g.donutWidth, g.donutHeight)
+		cache.prepare(g.donutImage)
+		g.renderer = cache
+		screen := ebiten.NewImage(g.screenWidth, g.screenHeight)
+		return func() { g.Draw(screen) }
+	}, 8},
+	{"draw-batched", func(g *Game) func() {
+		g.renderer = newBatchRenderer(g.renderer, g.donutImage)
+		screen := ebiten.NewImage(g.screenWidth, g.screenHeight)
+		return func() { g.Draw(screen) }
+	}, 8},
+	{"frame", func(g *Game) func() {
+		return func() {
+			g.movementSystem()
+			g.boundsSystem()
+			g.collisionSystem()
+			g.squashSystem()
+			g.lifetimeSystem()
+		}
+	}, 0},
+	{"movement", func(g *Game) func() {
+		return g.movementSystem
+	}, 0},
+	{"collision", func(g *Game) func() {
+		return g.collisionSystem
+	}, 0},
+}
+
+// benchTime is how long the bench command runs each benchmark, as go test -bench does
+const benchTime = time.Second
+
+// benchResult is what running an operation n times took
+type benchResult struct {
+	n             int
+	elapsed       time.Duration
+	bytes, allocs uint64
+}
+
+func (r benchResult) nsPerOp() int64     { return r.elapsed.Nanoseconds() / int64(r.n) }
+func (r benchResult) bytesPerOp() int64  { return int64(r.bytes) / int64(r.n) }
+func (r benchResult) allocsPerOp() int64 { return int64(r.allocs) / int64(r.n) }
+
+// String formats r like the results of go test -bench -benchmem
+func (r benchResult) String() string {
+	return fmt.Sprintf("%8d\t%10d ns/op\t%8d B/op\t%4d allocs/op", r.n, r.nsPerOp(), r.bytesPerOp(), r.allocsPerOp())
+}
+
+// measure runs the operation of bm on a game simulating s, more times in each round until
+// a round takes benchTime
+func (bm benchmark) measure(s settings, seed int64) benchResult {
+	op := bm.setup(newBenchGame(s, seed))
+	for n := 1; ; {
+		r := timeRuns(op, n)
+		if r.elapsed >= benchTime || n >= 1e9 {
+			return r
+		}
+		// Aim a little past benchTime, growing at most a hundredfold per round
+		next := 100 * n
+		if r.elapsed > 0 {
+			next = min(next, int(1.2*float64(n)*float64(benchTime)/float64(r.elapsed)))
+		}
+		n = max(next, n+1)
+	}
+}
+
+// timeRuns runs op n times, measuring the time taken and the memory allocated
+func timeRuns(op func(), n int) benchResult {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for range n {
+		op()
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return benchResult{n, elapsed, after.TotalAlloc - before.TotalAlloc, after.Mallocs - before.Mallocs}
+}
+
+// newBenchGame returns a game simulating the donuts of s on a 1920x1080 screen without a
+// window. Sprites are blank, only the simulation is measured.
+func newBenchGame(s settings, seed int64) *Game {
+	img := ebiten.NewImage(proceduralSpriteSize, proceduralSpriteSize)
+	g := &Game{
+		donutImage:    img,
+		donutImages:   []*ebiten.Image{img},
+		spriteWidth:   proceduralSpriteSize * donutScale,
+		spriteHeight:  proceduralSpriteSize * donutScale,
+		screenWidth:   1920,
+		screenHeight:  1080,
+		world:         newWorld(),
+		spawner:       spawners[defaultSpawner],
+		rng:           rand.New(rand.NewSource(seed)),
+		fx:            rand.New(rand.NewSource(seed + 1)),
+		squashEnabled: true,
+		step:          1,
+		sound:         &sound{},
+		commands:      make(chan command, commandQueueSize),
+	}
+	g.edges, _ = edgesConfig{}.modes()
+	g.renderer, _ = plugin.LookupRenderer("sprite")
+	g.applySettings(s)
+	return g
+}
+
+// runBench is the bench command: it runs every benchmark for each donut count and prints
+// the results like go test -bench does. It fails when a benchmark allocates more than it
+// may, updates must not allocate at all. With -cpu every benchmark is repeated for each
+// GOMAXPROCS value, showing how the parallel collision step scales, for example:
+//
+//	donut bench -preset stress -run collision -donuts 1000,4000 -cpu 1,2,4,8
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	counts := fs.String("donuts", "", "comma separated donut counts to measure, 10,25,50 or the preset's count by default")
+	seed := fs.Int64("seed", 1, "random seed of the simulated donuts")
+	presetName := fs.String("preset", "default", "preset to simulate, \"stress\" has thousands of small donuts")
+	cpus := fs.String("cpu", "", "comma separated GOMAXPROCS values to run each benchmark with, the current value by default")
+	run := fs.String("run", "", "only run benchmarks whose name contains this")
+	fs.Parse(args)
+
+	pre, err := findPreset(builtinPresets, *presetName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "donut bench: %v\n", err)
+		return 2
+	}
+	if *counts == "" {
+		*counts = "10,25,50"
+		if *presetName != "default" {
+			*counts = strconv.Itoa(pre.settings.Donuts)
+		}
+	}
+	ns, err := parseCounts(*counts, minDonuts, maxDonuts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "donut bench: invalid donut count: %v\n", err)
+		return 2
+	}
+	procs := []int{runtime.GOMAXPROCS(0)}
+	if *cpus != "" {
+		if procs, err = parseCounts(*cpus, 1, 1024); err != nil {
+			fmt.Fprintf(os.Stderr, "donut bench: invalid cpu count: %v\n", err)
+			return 2
+		}
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	code := 0
+	for _, bm := range benchmarks {
+		if !strings.Contains(bm.name, *run) {
+			continue
+		}
+		for _, n := range ns {
+			for _, p := range procs {
+				runtime.GOMAXPROCS(p)
+				s := pre.settings
+				s.Donuts = n
+				r := bm.measure(s, *seed)
+				name := fmt.Sprintf("%s/donuts=%d", bm.name, n)
+				if *cpus != "" {
+					name += fmt.Sprintf("-%d", p)
+				}
+				fmt.Printf("%-20s\t%s\n", name, r)
+				if bm.maxAllocs >= 0 && r.allocsPerOp() > bm.maxAllocs {
+					fmt.Fprintf(os.Stderr, "FAIL %s: %d allocs per frame, want at most %d\n", name, r.allocsPerOp(), bm.maxAllocs)
+					code = 1
+				}
+			}
+		}
+	}
+	return code
+}
+
+// parseCounts parses a comma separated list of integers between lo and hi
+func parseCounts(list string, lo, hi int) ([]int, error) {
+	var ns []int
+	for _, c := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil || n < lo || n > hi {
+			return nil, fmt.Errorf("%q must be between %d and %d", c, lo, hi)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+// benchSettings returns the settings of the named built-in preset with n donuts
+func benchSettings(tb testing.TB, preset string, n int) settings {
+	tb.Helper()
+	pre, err := findPreset(builtinPresets, preset)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := pre.settings
+	s.Donuts = n
+	return s
+}
+
+// run measures the operation of bm on a game simulating s
+func (bm benchmark) run(b *testing.B, s settings, seed int64) {
+	op := bm.setup(newBenchGame(s, seed))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		op()
+	}
+}
+
+// BenchmarkSimulation runs the benchmarks of the bench command with 50 donuts
+func BenchmarkSimulation(b *testing.B) {
+	s := benchSettings(b, "default", 50)
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) { bm.run(b, s, 1) })
+	}
+}
+
+// TestAllocations checks the benchmarks stay within their allocations per frame, none for
+// the simulation, so frames don't make work for the garbage collector
+func TestAllocations(t *testing.T) {
+	for _, n := range []int{10, 50} {
+		s := benchSettings(t, "default", n)
+		for _, bm := range benchmarks {
+			if bm.maxAllocs < 0 {
+				continue
+			}
+			op := bm.setup(newBenchGame(s, 1))
+			if allocs := testing.AllocsPerRun(100, op); allocs > float64(bm.maxAllocs) {
+				t.Errorf("%s with %d donuts: %v allocations per frame, want at most %d", bm.name, n, allocs, bm.maxAllocs)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	breakoutRows      = 6
+	breakoutCols      = 10
+	breakoutLives     = 3
+	breakoutPaddleGap = 40   // Distance of the paddle from the bottom
+	breakoutPaddleH   = 14   // Paddle height
+	breakoutBrickGap  = 4    // Space between bricks
+	breakoutSpeedUp   = 1.1  // Ball speed gained on every cleared level
+	breakoutSpin      = 4    // Sideways speed added when hitting with a paddle's end
+	breakoutTop       = 0.12 // Top of the bricks relative to the screen height
+	breakoutBottom    = 0.4  // Bottom of the bricks relative to the screen height
+)
+
+// breakout is a donut knocking out a wall of bricks, bounced back up by a paddle that
+// follows the mouse. Letting the donut past the paddle costs a life.
+type breakout struct {
+	bricks [breakoutRows][breakoutCols]bool // Bricks still standing
+	left   int
+	lives  int
+	points int
+	level  int
+	speed  float64
+	paddle float64 // Center of the paddle
+}
+
+func (b *breakout) start(g *Game) {
+	s := g.currentSettings()
+	s.Donuts, s.Gravity, s.Behavior, s.Layers = 1, 0, defaultBehavior, 1
+	s.Lifetime, s.Chain, s.Magnets = 0, 0, false
+	g.applySettings(s)
+	g.resetDonuts()
+	// The donut is lost when it gets past the paddle to the bottom. That edge bounces too,
+	// wrapping would move the donut to the top before update sees it.
+	g.edges = [4]edgeMode{edgeBounce, edgeBounce, edgeBounce, edgeBounce}
+
+	b.lives = breakoutLives
+	b.speed = (g.minSpeed + g.maxSpeed) / 2 * math.Sqrt2
+	b.paddle = float64(g.screenWidth) / 2
+	b.buildWall()
+	b.serve(g)
+}
+
+// buildWall puts up every brick
+func (b *breakout) buildWall() {
+	for r := range b.bricks {
+		for c := range b.bricks[r] {
+			b.bricks[r][c] = true
+		}
+	}
+	b.left = breakoutRows * breakoutCols
+}
+
+// serve puts the donut above the paddle, moving up
+func (b *breakout) serve(g *Game) {
+	e := g.world.donut(0)
+	pos, vel, spr := g.world.positions.Get(e), g.world.velocities.Get(e), g.world.sprites.Get(e)
+	pos.x = b.paddle - spr.width/2
+	pos.y = float64(g.screenHeight) - breakoutPaddleGap - spr.height - 1
+	angle := -math.Pi/2 + (g.rng.Float64()-0.5)*math.Pi/3
+	vel.x, vel.y = math.Cos(angle)*b.speed, math.Sin(angle)*b.speed
+}
+
+// brick returns the rectangle of the brick in row r and column c for the screen size
+func (b *breakout) brick(g *Game, r, c int) (x, y, w, h float64) {
+	width, height := float64(g.screenWidth), float64(g.screenHeight)
+	w = width / breakoutCols
+	h = height * (breakoutBottom - breakoutTop) / breakoutRows
+	return float64(c)*w + breakoutBrickGap/2, height*breakoutTop + float64(r)*h + breakoutBrickGap/2, w - breakoutBrickGap, h - breakoutBrickGap
+}
+
+// paddleWidth returns the paddle width for the screen size
+func (b *breakout) paddleWidth(g *Game) float64 {
+	return float64(g.screenWidth) / 8
+}
+
+func (b *breakout) update(g *Game) bool {
+	x, _ := g.camera.toWorld(cursorPosition())
+	half := b.paddleWidth(g) / 2
+	b.paddle = max(half, min(float64(g.screenWidth)-half, x))
+
+	e := g.world.donut(0)
+	pos, vel, spr := g.world.positions.Get(e), g.world.velocities.Get(e), g.world.sprites.Get(e)
+	radius := spr.width / 2
+	cx, cy := pos.x+radius, pos.y+radius
+
+	// Bounce off the paddle, angled by where it hit
+	top := float64(g.screenHeight) - breakoutPaddleGap
+	if vel.y > 0 && cy+radius >= top && cy+radius <= top+breakoutPaddleH+vel.y && math.Abs(cx-b.paddle) <= half+radius {
+		g.addSquash(e, 0, 1, vel.y)
+		vel.y = -math.Abs(vel.y)
+		vel.x += (cx - b.paddle) / half * breakoutSpin
+		// Keep the speed, only the direction changes
+		speed := math.Hypot(vel.x, vel.y)
+		vel.x, vel.y = vel.x/speed*b.speed, vel.y/speed*b.speed
+		pos.y = top - spr.height
+	}
+
+	b.hitBrick(g, cx, cy, radius, vel)
+
+	// Bouncing off the bottom leaves the donut resting on it
+	if pos.y >= float64(g.screenHeight)-spr.height {
+		b.lives--
+		if b.lives == 0 {
+			return true
+		}
+		b.serve(g)
+	}
+	if b.left == 0 {
+		b.level++
+		b.speed *= breakoutSpeedUp
+		b.buildWall()
+		b.serve(g)
+	}
+	return false
+}
+
+// hitBrick knocks out the first brick the donut overlaps and bounces it off the side of
+// the brick it went in deepest
+func (b *breakout) hitBrick(g *Game, cx, cy, radius float64, vel *velocity) {
+	for r := range b.bricks {
+		for c := range b.bricks[r] {
+			if !b.bricks[r][c] {
+				continue
+			}
+			x, y, w, h := b.brick(g, r, c)
+			// Nearest point of the brick to the center of the donut
+			nx, ny := max(x, min(x+w, cx)), max(y, min(y+h, cy))
+			if math.Hypot(cx-nx, cy-ny) > radius {
+				continue
+			}
+			b.bricks[r][c] = false
+			b.left--
+			b.points += 10 * (breakoutRows - r)
+			g.spawnFirework(x+w/2, y+h/2, 20)
+
+			overlapX := min(cx+radius-x, x+w-(cx-radius))
+			overlapY := min(cy+radius-y, y+h-(cy-radius))
+			if overlapX < overlapY {
+				vel.x = -vel.x
+			} else {
+				vel.y = -vel.y
+			}
+			return
+		}
+	}
+}
+
+func (b *breakout) draw(g *Game, screen *ebiten.Image) {
+	for r := range b.bricks {
+		clr := hsvColor(float64(r)/breakoutRows, 0.7, 0.95)
+		for c := range b.bricks[r] {
+			if b.bricks[r][c] {
+				x, y, w, h := b.brick(g, r, c)
+				drawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), clr, false)
+			}
+		}
+	}
+	w := b.paddleWidth(g)
+	drawFilledRect(screen, float32(b.paddle-w/2), float32(g.screenHeight-breakoutPaddleGap), float32(w), breakoutPaddleH, g.theme.hud, false)
+}
+
+func (b *breakout) scoreboard() string {
+	return trf("SCORE %d  LIVES %d", b.points, b.lives)
+}
+
+func (b *breakout) score() float64 {
+	return float64(b.points)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	brightnessStep = 0.1  // Change of the brightness per B press
+	minBrightness  = 0.05 // Dimmest level, so the screen never looks off
+)
+
+// brightnessConfig dims everything drawn, at a fixed level or on a schedule, so the screen
+// isn't blinding at night without changing the monitor settings
+type brightnessConfig struct {
+	// Level is the brightness from 0.05 to 1 outside the scheduled periods, 1 by default
+	Level *float64 `json:"level"`
+
+	// Schedule are the periods with another level, e.g. 0.3 from 22:00 to 07:00. The
+	// first period containing the current time wins.
+	Schedule []brightnessPeriod `json:"schedule"`
+}
+
+// brightnessPeriod is a daily period with its own brightness
+type brightnessPeriod struct {
+	clockPeriod
+	Level float64 `json:"level"`
+}
+
+// clockPeriod is a daily period of local time, wrapping past midnight when From is after To
+type clockPeriod struct {
+	From clockTime `json:"from"`
+	To   clockTime `json:"to"`
+}
+
+// clockTime is a time of day in minutes since midnight, HH:MM in JSON
+type clockTime int
+
+func (c *clockTime) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	hour, minute, ok := strings.Cut(str, ":")
+	if !ok {
+		return fmt.Errorf("invalid time %q, want HH:MM", str)
+	}
+	h, err := parseNumber(hour, 0, 23)
+	if err != nil {
+		return err
+	}
+	m, err := parseNumber(minute, 0, 59)
+	if err != nil {
+		return err
+	}
+	*c = clockTime(h*60 + m)
+	return nil
+}
+
+func (c clockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%02d:%02d", c/60, c%60))
+}
+
+// base returns the level outside the scheduled periods
+func (cfg brightnessConfig) base() float64 {
+	if cfg.Level == nil {
+		return 1
+	}
+	return *cfg.Level
+}
+
+// contains reports whether the period includes the minute of the day
+func (p clockPeriod) contains(minute clockTime) bool {
+	if p.From <= p.To {
+		return minute >= p.From && minute < p.To
+	}
+	return minute >= p.From || minute < p.To
+}
+
+func (cfg brightnessConfig) validate() error {
+	if l := cfg.Level; l != nil && (*l < minBrightness || *l > 1) {
+		return fmt.Errorf("level must be from %v to 1", minBrightness)
+	}
+	for i, p := range cfg.Schedule {
+		if p.Level < minBrightness || p.Level > 1 {
+			return fmt.Errorf("schedule %d: level must be from %v to 1", i+1, minBrightness)
+		}
+		if p.From == p.To {
+			return fmt.Errorf("schedule %d: from and to must differ", i+1)
+		}
+	}
+	return nil
+}
+
+// brightness is the dimming applied over the finished frame. B and Shift+B change the
+// level until the schedule moves to another period.
+type brightness struct {
+	config brightnessConfig
+	level  float64
+	limit  float64 // Highest level, lowered by OLED-safe mode
+	period int     // Index of the scheduled period in effect, -1 outside them
+}
+
+func newBrightness(cfg brightnessConfig) brightness {
+	return brightness{config: cfg, level: cfg.base(), limit: 1, period: -1}
+}
+
+// update follows the schedule and the B keys
+func (b *brightness) update(now time.Time, keys bool) {
+	if len(b.config.Schedule) > 0 {
+		minute := clockTime(now.Hour()*60 + now.Minute())
+		period := -1
+		for i, p := range b.config.Schedule {
+			if p.contains(minute) {
+				period = i
+				break
+			}
+		}
+		if period != b.period {
+			b.period = period
+			if period >= 0 {
+				b.level = b.config.Schedule[period].Level
+			} else {
+				b.level = b.config.base()
+			}
+			b.level = min(b.level, b.limit)
+			slog.Info("brightness", "level", b.level)
+		}
+	}
+	if keys && inpututil.IsKeyJustPressed(ebiten.KeyB) {
+		step := -brightnessStep
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			step = brightnessStep
+		}
+		// Rounded so repeated steps land on whole tenths
+		b.level = max(minBrightness, min(b.limit, math.Round((b.level+step)*10)/10))
+		slog.Info("brightness", "level", b.level)
+	}
+}
+
+// draw darkens the frame on screen by the brightness level. Source-atop keeps the alpha,
+// so a transparent window is dimmed without darkening the desktop behind it.
+func (b *brightness) draw(screen *ebiten.Image) {
+	if b.level >= 1 {
+		return
+	}
+	op := &ebiten.DrawImageOptions{Blend: ebiten.BlendSourceAtop}
+	size := screen.Bounds().Size()
+	op.GeoM.Scale(float64(size.X), float64(size.Y))
+	op.ColorScale.ScaleWithColor(color.RGBA{A: uint8(255 * (1 - b.level))})
+	screen.DrawImage(whitePixel, op)
+}
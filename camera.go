@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	maxZoom      = 8    // Closest zoom, 8 times the normal size
+	zoomPerWheel = 1.15 // Zoom factor of one wheel step
+)
+
+// camera is the view onto the simulation, zoomed with the mouse wheel and panned by
+// dragging with the middle button. It only changes what is drawn, the simulation keeps
+// its size and isn't recorded in replays.
+type camera struct {
+	zoom     float64 // 1 shows the whole screen, 0 before the first update
+	x, y     float64 // Simulation point at the top left corner of the view
+	dragging bool
+	dragX    int // Cursor position at the last drag update
+	dragY    int
+	image    *ebiten.Image // Offscreen frame while zoomed or shaking
+}
+
+// zoomed reports whether the view differs from the whole screen
+func (c *camera) zoomed() bool {
+	return c.zoom > 1
+}
+
+// toWorld converts a screen position to the simulation position under it
+func (c *camera) toWorld(x, y int) (float64, float64) {
+	if !c.zoomed() {
+		return float64(x), float64(y)
+	}
+	return c.x + float64(x)/c.zoom, c.y + float64(y)/c.zoom
+}
+
+// update zooms toward the cursor on wheel input, pans while the middle button is held
+// and resets the view on Home
+func (c *camera) update(g *Game) {
+	if c.zoom == 0 || inpututil.IsKeyJustPressed(ebiten.KeyHome) {
+		c.zoom, c.x, c.y = 1, 0, 0
+	}
+	cx, cy := cursorPosition()
+	if _, wheel := ebiten.Wheel(); wheel != 0 {
+		zoom := max(1, min(maxZoom, c.zoom*math.Pow(zoomPerWheel, wheel)))
+		// Keep the simulation point under the cursor in place
+		px, py := c.x+float64(cx)/c.zoom, c.y+float64(cy)/c.zoom
+		c.x, c.y = px-float64(cx)/zoom, py-float64(cy)/zoom
+		c.zoom = zoom
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+		if c.dragging {
+			c.x -= float64(cx-c.dragX) / c.zoom
+			c.y -= float64(cy-c.dragY) / c.zoom
+		}
+		c.dragging, c.dragX, c.dragY = true, cx, cy
+	} else {
+		c.dragging = false
+	}
+
+	// Keep the view inside the simulation
+	w, h := float64(g.screenWidth), float64(g.screenHeight)
+	c.x = max(0, min(w-w/c.zoom, c.x))
+	c.y = max(0, min(h-h/c.zoom, c.y))
+}
+
+// drawTransformed draws the frame offscreen and copies it to screen through the camera,
+// moved by the screen shake and the drift of OLED-safe mode
+func (g *Game) drawTransformed(screen *ebiten.Image) {
+	c := &g.camera
+	size := screen.Bounds().Size()
+	if c.image == nil || c.image.Bounds().Size() != size {
+		if c.image != nil {
+			c.image.Dispose()
+		}
+		c.image = ebiten.NewImage(size.X, size.Y)
+	}
+	g.drawScene(c.image)
+
+	if g.transparent {
+		screen.Clear()
+	} else {
+		screen.Fill(g.theme.background)
+	}
+	op := &ebiten.DrawImageOptions{}
+	if c.zoomed() {
+		op.GeoM.Translate(-c.x*pixelScale, -c.y*pixelScale)
+		op.GeoM.Scale(c.zoom, c.zoom)
+		op.Filter = ebiten.FilterLinear
+	}
+	op.GeoM.Translate(math.Round(g.shake.x*pixelScale), math.Round(g.shake.y*pixelScale))
+	if g.oled != nil {
+		dx, dy := g.oled.offset(g.now())
+		op.GeoM.Translate(dx*pixelScale, dy*pixelScale)
+	}
+	screen.DrawImage(c.image, op)
+}
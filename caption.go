@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	captionGap       = 0.3 // Space between the caption and the timer, in caption line heights
+	captionSizeRatio = 3   // The caption is this many times smaller than the timer by default
+)
+
+// captionConfig is text drawn above or below the timer, so visitors know what it counts
+type captionConfig struct {
+	Text     string          `json:"text"`     // Lines are split on \n and word wrapped
+	Position captionPosition `json:"position"` // Above or below the timer, above by default
+	Size     float64         `json:"size"`     // Font height in pixels, a third of the timer by default
+	Color    *hexColor       `json:"color"`    // Text color, the timer color by default
+	Width    int             `json:"width"`    // Wrap width in pixels, the width of the timer by default
+}
+
+// captionPosition is where the caption goes relative to the timer
+type captionPosition string
+
+const (
+	captionAbove captionPosition = "above"
+	captionBelow captionPosition = "below"
+)
+
+func (p *captionPosition) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if str != string(captionAbove) && str != string(captionBelow) {
+		return fmt.Errorf("invalid caption position %q, want above or below", str)
+	}
+	*p = captionPosition(str)
+	return nil
+}
+
+// captionLayout caches the wrapped caption lines, which only change with the wrap width
+type captionLayout struct {
+	chars int
+	text  string
+	lines []string
+}
+
+// wrap returns text wrapped to lines of at most chars characters. Words longer than a line
+// are left whole.
+func (c *captionLayout) wrap(text string, chars int) []string {
+	if c.lines != nil && c.chars == chars && c.text == text {
+		return c.lines
+	}
+	c.chars, c.text, c.lines = chars, text, c.lines[:0]
+	for paragraph := range strings.SplitSeq(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > chars {
+				c.lines = append(c.lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		c.lines = append(c.lines, line)
+	}
+	return c.lines
+}
+
+// caption returns the wrapped lines of the timer's caption with their scale and the size of
+// the block they take. timerWidth is the width of the scaled timer text.
+func (g *Game) caption(timerWidth, timerScale float64) (lines []string, scale, width, height float64) {
+	cfg := g.timerStyle.Caption
+	if cfg == nil || cfg.Text == "" {
+		return nil, 0, 0, 0
+	}
+	scale = timerScale / captionSizeRatio
+	if cfg.Size > 0 {
+		scale = cfg.Size / baseFontHeight
+	}
+	wrapWidth := timerWidth
+	if cfg.Width > 0 {
+		wrapWidth = float64(cfg.Width)
+	}
+	lines = g.captionLayout.wrap(cfg.Text, max(1, int(wrapWidth/(baseFontWidth*scale))))
+	for _, line := range lines {
+		width = max(width, textWidth(line, scale))
+	}
+	lineHeight := baseFontHeight * scale * 1.2
+	return lines, scale, width, float64(len(lines))*lineHeight + captionGap*lineHeight
+}
+
+// drawCaption draws the caption lines centered in a block width wide at x, y, leaving out
+// the gap next to the timer
+func (g *Game) drawCaption(screen *ebiten.Image, lines []string, scale, x, y, width float64) {
+	cfg := g.timerStyle.Caption
+	lineHeight := baseFontHeight * scale * 1.2
+	if cfg.Position == captionBelow {
+		y += captionGap * lineHeight
+	}
+	var clr color.Color = cfg.Color
+	if cfg.Color == nil {
+		g.scratchTint = g.timerColor()
+		clr = &g.scratchTint
+	}
+	for i, line := range lines {
+		lx := x + (width-textWidth(line, scale))/2
+		ly := y + float64(i)*lineHeight
+		if shadow := g.timerStyle.Shadow; shadow != nil {
+			drawText(screen, line, lx+scale*0.6, ly+scale*0.6, scale, shadow)
+		}
+		drawText(screen, line, lx, ly, scale, clr)
+	}
+}
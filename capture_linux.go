@@ -0,0 +1,57 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// startAudioCapture records from device with parec (PulseAudio and PipeWire) or arecord
+// (ALSA). The default device is the monitor of the default output, so it hears whatever is
+// playing. Closing the returned reader stops the recorder.
+func startAudioCapture(device string) (io.ReadCloser, error) {
+	rate := strconv.Itoa(audioSampleRate)
+	var cmd *exec.Cmd
+	if _, err := exec.LookPath("parec"); err == nil {
+		if device == "" {
+			device = "@DEFAULT_MONITOR@"
+		}
+		cmd = exec.Command("parec", "--format=s16le", "--rate="+rate, "--channels=1", "--latency-msec=20", "--device="+device)
+	} else if _, err := exec.LookPath("arecord"); err == nil {
+		args := []string{"-q", "-f", "S16_LE", "-r", rate, "-c", "1", "-t", "raw"}
+		if device != "" {
+			args = append(args, "-D", device)
+		}
+		cmd = exec.Command("arecord", args...)
+	} else {
+		return nil, errors.New("neither parec nor arecord is installed")
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &recorder{ReadCloser: out, cmd: cmd}, nil
+}
+
+// recorder is the output of a recording process
+type recorder struct {
+	io.ReadCloser
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+// Close kills the process and waits for it, so it doesn't linger as a zombie
+func (r *recorder) Close() error {
+	r.once.Do(func() {
+		r.cmd.Process.Kill()
+		r.cmd.Wait() // Fails with the kill signal, and closes the pipe
+	})
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "io"
+
+func startAudioCapture(device string) (io.ReadCloser, error) {
+	return nil, errAudioUnsupported
+}
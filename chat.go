@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	twitchIRCAddr       = "irc.chat.twitch.tv:6667"
+	youtubeChatURL      = "https://www.googleapis.com/youtube/v3/liveChat/messages"
+	defaultChatLifetime = time.Minute
+	defaultChatDonuts   = 20
+	chatCooldown        = 10 * time.Second // Per chatter, so one person can't flood the screen
+	chatRetryDelay      = 10 * time.Second
+	chatLabelScale      = 1.5
+)
+
+// chatConfig lets stream chat spawn and control donuts. Every message spawns a donut with
+// the chatter's name under it, "!pop" pops the oldest one and "!gravity 0.2" changes the
+// gravity.
+type chatConfig struct {
+	Twitch   string             `json:"twitch"`   // Channel name, read anonymously
+	YouTube  *youtubeChatConfig `json:"youtube"`  // Live chat of a YouTube broadcast
+	Lifetime duration           `json:"lifetime"` // How long chat donuts stay, 1m by default
+	Max      int                `json:"max"`      // Maximum chat donuts on screen, 20 by default
+}
+
+func (cfg chatConfig) validate() error {
+	if cfg.Max < 0 {
+		return fmt.Errorf("max must not be negative")
+	}
+	if cfg.Lifetime < 0 {
+		return fmt.Errorf("lifetime must not be negative")
+	}
+	return nil
+}
+
+type youtubeChatConfig struct {
+	APIKey     string `json:"api_key"`
+	LiveChatID string `json:"live_chat_id"`
+}
+
+// chat receives messages in the background and turns them into recorded actions on the
+// game loop
+type chat struct {
+	g        *Game
+	cfg      chatConfig
+	lastSeen map[string]time.Time // Last donut per chatter, only used on the game loop
+}
+
+func startChat(g *Game, cfg chatConfig) *chat {
+	c := &chat{g: g, cfg: cfg, lastSeen: map[string]time.Time{}}
+	if cfg.Twitch != "" {
+		go c.retry("twitch", c.readTwitch)
+	}
+	if cfg.YouTube != nil {
+		go c.retry("youtube", c.readYouTube)
+	}
+	return c
+}
+
+// retry runs read until it stops, reconnecting after a delay
+func (c *chat) retry(name string, read func() error) {
+	for {
+		err := read()
+		slog.Warn("chat disconnected", "platform", name, "err", err)
+		time.Sleep(chatRetryDelay)
+	}
+}
+
+// received passes a message to the game loop, dropping it when the loop is behind
+func (c *chat) received(user, text string) {
+	select {
+	case c.g.commands <- func(g *Game) { c.handle(user, text) }:
+	default:
+	}
+}
+
+// handle turns a chat message into an action. Chat is ignored while a replay plays.
+func (c *chat) handle(user, text string) {
+	g := c.g
+	if g.replay != nil {
+		return
+	}
+	ev := replayEvent{Frame: g.frame}
+	switch fields := strings.Fields(text); {
+	case len(fields) == 0:
+		return
+	case fields[0] == "!pop":
+		ev.Action = actionChatPop
+	case fields[0] == "!gravity" && len(fields) == 2:
+		gravity, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return
+		}
+		s := g.currentSettings()
+		s.Gravity = min(1, max(0, gravity))
+		ev.Action, ev.Settings = actionSettings, &s
+	default:
+		if time.Since(c.lastSeen[user]) < chatCooldown {
+			return
+		}
+		c.lastSeen[user] = time.Now()
+		ev.Action, ev.Name = actionChatDonut, user
+	}
+	g.recordEvent(ev)
+	g.applyAction(ev)
+}
+
+// readTwitch reads the channel's chat over IRC as an anonymous user
+func (c *chat) readTwitch() error {
+	conn, err := net.DialTimeout("tcp", twitchIRCAddr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "NICK justinfan%d\r\nJOIN #%s\r\n", time.Now().UnixNano()%100000, strings.ToLower(c.cfg.Twitch))
+
+	lines := bufio.NewScanner(conn)
+	for lines.Scan() {
+		line := lines.Text()
+		if rest, ok := strings.CutPrefix(line, "PING"); ok {
+			fmt.Fprintf(conn, "PONG%s\r\n", rest)
+			continue
+		}
+		// :user!user@user.tmi.twitch.tv PRIVMSG #channel :message
+		prefix, msg, ok := strings.Cut(line, " PRIVMSG ")
+		if !ok || !strings.HasPrefix(prefix, ":") {
+			continue
+		}
+		user, _, _ := strings.Cut(prefix[1:], "!")
+		if _, text, ok := strings.Cut(msg, " :"); ok {
+			c.received(user, text)
+		}
+	}
+	return cmp.Or(lines.Err(), fmt.Errorf("connection closed"))
+}
+
+// readYouTube polls the live chat at the rate the API asks for
+func (c *chat) readYouTube() error {
+	client := &http.Client{Timeout: 20 * time.Second}
+	pageToken := ""
+	for first := true; ; first = false {
+		query := url.Values{
+			"liveChatId": {c.cfg.YouTube.LiveChatID},
+			"part":       {"snippet,authorDetails"},
+			"key":        {c.cfg.YouTube.APIKey},
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := client.Get(youtubeChatURL + "?" + query.Encode())
+		if err != nil {
+			return err
+		}
+		var page struct {
+			NextPageToken         string `json:"nextPageToken"`
+			PollingIntervalMillis int    `json:"pollingIntervalMillis"`
+			Items                 []struct {
+				Snippet struct {
+					DisplayMessage string `json:"displayMessage"`
+				} `json:"snippet"`
+				AuthorDetails struct {
+					DisplayName string `json:"displayName"`
+				} `json:"authorDetails"`
+			} `json:"items"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		} else if err != nil {
+			return err
+		}
+		// The first page is the backlog from before the screensaver started
+		if !first {
+			for _, item := range page.Items {
+				c.received(item.AuthorDetails.DisplayName, item.Snippet.DisplayMessage)
+			}
+		}
+		pageToken = page.NextPageToken
+		time.Sleep(max(time.Second, time.Duration(page.PollingIntervalMillis)*time.Millisecond))
+	}
+}
+
+// spawnChatDonut adds a donut labeled with a chatter's name that leaves after a while,
+// making room by popping the oldest chat donut when there are too many chat donuts or
+// donuts in all
+func (g *Game) spawnChatDonut(name string) {
+	var cfg chatConfig // Replays can contain chat without chat being configured
+	if g.chat != nil {
+		cfg = g.chat.cfg
+	}
+	limit := cmp.Or(cfg.Max, defaultChatDonuts)
+	for g.world.labels.Len() >= limit || g.world.donuts.Len() >= g.maxDonutCount() {
+		if !g.popChatDonut() {
+			break
+		}
+	}
+	if g.world.donuts.Len() >= g.maxDonutCount() {
+		return
+	}
+	d := createDonuts(g.rng, g.spawner, g.screenWidth, g.screenHeight, g.donutWidth, g.donutHeight, 1, g.minSpeed, g.maxSpeed, g.minSpin, g.maxSpin)[0]
+	e := g.world.spawnDonut(d, g.donutImageFor(g.world.donuts.Len()))
+	g.world.layers.Add(e, layer{0})
+	g.world.labels.Add(e, label{name})
+	frames := durationFrames(cmp.Or(time.Duration(cfg.Lifetime), defaultChatLifetime))
+	g.world.lifetimes.Add(e, lifetime{left: frames, total: frames})
+}
+
+// popChatDonut removes the oldest chat donut with a firework, reporting whether there was one
+func (g *Game) popChatDonut() bool {
+	var oldest ecs.Entity
+	left := -1
+	for i := range g.world.labels.Len() {
+		e, _ := g.world.labels.At(i)
+		if l := g.world.lifetimes.Get(e); l != nil && (left < 0 || l.left < left) {
+			oldest, left = e, l.left
+		}
+	}
+	if left < 0 {
+		return false
+	}
+	pos, spr := g.world.positions.Get(oldest), g.world.sprites.Get(oldest)
+	g.spawnFirework(pos.x+spr.width/2, pos.y+spr.height/2, 40)
+	g.world.Despawn(oldest)
+	g.addStat(statPops, 1)
+	return true
+}
+
+// drawLabels draws each label centered under its entity
+func (g *Game) drawLabels(dst *ebiten.Image) {
+	w := g.world
+	for i := range w.labels.Len() {
+		e, l := w.labels.At(i)
+		pos, spr := w.positions.Get(e), w.sprites.Get(e)
+		if pos == nil || spr == nil {
+			continue
+		}
+		x := pos.x + spr.width/2 - textWidth(l.text, chatLabelScale)/2
+		drawText(dst, l.text, x, pos.y+spr.height, chatLabelScale, g.theme.hud)
+	}
+}
@@ -0,0 +1,192 @@
+// Package client is a typed client for the HTTP API of a running donut screensaver,
+// started with -http. It lets external tools and tests inspect and change the simulation:
+//
+//	c := client.New("http://localhost:8080")
+//	state, err := c.State(ctx)
+//	...
+//	err = c.SetDonutCount(ctx, 20)
+//
+// The API is described by the OpenAPI document in openapi.yaml, also served by the
+// screensaver at /api/v1/openapi.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAPI is the OpenAPI 3 document describing the API
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// State is a snapshot of the running simulation
+type State struct {
+	Frame    int      `json:"frame"`  // Frames simulated since the start
+	Width    int      `json:"width"`  // Screen width in pixels
+	Height   int      `json:"height"` // Screen height in pixels
+	Settings Settings `json:"settings"`
+	Timer    Timer    `json:"timer"`
+	Donuts   []Donut  `json:"donuts"`
+}
+
+// Settings are the runtime options of the simulation
+type Settings struct {
+	Donuts   int     `json:"donuts"`    // Number of donuts
+	Gravity  float64 `json:"gravity"`   // Downward acceleration in pixels per frame², 0 disables gravity
+	Theme    string  `json:"theme"`     // Name of a color theme
+	Behavior string  `json:"behavior"`  // Name of a movement behavior
+	MinSpeed float64 `json:"min_speed"` // Minimum initial speed per axis in pixels per frame
+	MaxSpeed float64 `json:"max_speed"` // Maximum initial speed per axis in pixels per frame
+	MinSpin  float64 `json:"min_spin"`  // Minimum rotation speed in radians per frame
+	MaxSpin  float64 `json:"max_spin"`  // Maximum rotation speed in radians per frame
+	Trails   bool    `json:"trails"`    // Leave fading trails behind the donuts
+	Layers   int     `json:"layers"`    // Number of parallax depth layers, 1 disables parallax
+	Crumbs   bool    `json:"crumbs"`    // Donuts drop crumbs that slowly fade
+	Size     float64 `json:"size"`      // Donut size relative to the default, 0 for the default
+
+	Lifetime       float64 `json:"lifetime"`        // Seconds before a donut fades out and a fresh one spawns, 0 to live forever
+	LifetimeJitter float64 `json:"lifetime_jitter"` // Random variation of Lifetime in seconds, plus or minus
+	Chain          int     `json:"chain"`           // Donuts linked by springs into each chain, 0 for none
+	ChainRing      bool    `json:"chain_ring"`      // Close the chains into rings
+	Magnets        bool    `json:"magnets"`         // Donuts get a random pole, like poles repel and opposite poles attract
+
+	Rainbow      bool `json:"rainbow"`       // Cycle each donut's tint through the spectrum
+	RainbowTimer bool `json:"rainbow_timer"` // Cycle the timer color too
+}
+
+// Timer is the elapsed time display
+type Timer struct {
+	Start   time.Time `json:"start"`   // When the timer started
+	Elapsed float64   `json:"elapsed"` // Seconds since the start, 0 while it is in the future
+}
+
+// Themes lists the color themes
+type Themes struct {
+	Current   string   `json:"current"`   // Theme in use
+	Available []string `json:"available"` // Built-in and configured themes, sorted by name
+}
+
+// Donut is the state of one donut
+type Donut struct {
+	X     float64 `json:"x"`     // Left edge in pixels
+	Y     float64 `json:"y"`     // Top edge in pixels
+	VX    float64 `json:"vx"`    // Horizontal velocity in pixels per frame
+	VY    float64 `json:"vy"`    // Vertical velocity in pixels per frame
+	Angle float64 `json:"angle"` // Rotation in radians
+}
+
+// Error is a request the screensaver refused or couldn't handle
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("donut API: %d %s", e.StatusCode, e.Message)
+}
+
+// Client talks to one screensaver
+type Client struct {
+	BaseURL string       // For example http://localhost:8080
+	HTTP    *http.Client // http.DefaultClient when nil
+}
+
+// New returns a client for the screensaver serving its API at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// State returns a snapshot of the simulation
+func (c *Client) State(ctx context.Context) (State, error) {
+	var s State
+	err := c.do(ctx, http.MethodGet, "/api/v1/state", nil, &s)
+	return s, err
+}
+
+// Settings returns the settings the simulation is running with
+func (c *Client) Settings(ctx context.Context) (Settings, error) {
+	var s Settings
+	err := c.do(ctx, http.MethodGet, "/api/v1/settings", nil, &s)
+	return s, err
+}
+
+// SetSettings switches the simulation to s and returns the settings applied, with
+// defaults filled in and unknown names replaced
+func (c *Client) SetSettings(ctx context.Context, s Settings) (Settings, error) {
+	var applied Settings
+	err := c.do(ctx, http.MethodPut, "/api/v1/settings", s, &applied)
+	return applied, err
+}
+
+// Themes returns the theme in use and the themes available
+func (c *Client) Themes(ctx context.Context) (Themes, error) {
+	var t Themes
+	err := c.do(ctx, http.MethodGet, "/api/v1/themes", nil, &t)
+	return t, err
+}
+
+// SetTheme switches to the named theme
+func (c *Client) SetTheme(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/theme", map[string]string{"name": name}, nil)
+}
+
+// SetDonutCount changes the number of donuts
+func (c *Client) SetDonutCount(ctx context.Context, n int) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/donuts", map[string]int{"count": n}, nil)
+}
+
+// SetTimerStart moves the start of the timer
+func (c *Client) SetTimerStart(ctx context.Context, start time.Time) error {
+	return c.do(ctx, http.MethodPut, "/api/v1/timer", Timer{Start: start}, nil)
+}
+
+// ResetTimer starts the timer over, or records a new incident when counting incidents
+func (c *Client) ResetTimer(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/timer/reset", nil, nil)
+}
+
+// do sends body as JSON and decodes the response into out, either may be nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
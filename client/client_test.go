@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request is what the test server received
+type request struct {
+	method, path, body string
+}
+
+// newServer answers every request with status and reply, recording the requests
+func newServer(t *testing.T, status int, reply string) (*Client, *[]request) {
+	t.Helper()
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, request{r.Method, r.URL.Path, strings.TrimSpace(string(body))})
+		if len(body) > 0 && r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: content type %q, want application/json", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if reply == "" {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL + "/"), &got
+}
+
+func TestRequests(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		call func(c *Client) error
+		want request
+	}{
+		{"set theme", func(c *Client) error { return c.SetTheme(context.Background(), "neon") },
+			request{http.MethodPut, "/api/v1/theme", `{"name":"neon"}`}},
+		{"set donut count", func(c *Client) error { return c.SetDonutCount(context.Background(), 20) },
+			request{http.MethodPut, "/api/v1/donuts", `{"count":20}`}},
+		{"set timer start", func(c *Client) error { return c.SetTimerStart(context.Background(), start) },
+			request{http.MethodPut, "/api/v1/timer", `{"start":"2024-03-01T12:00:00Z","elapsed":0}`}},
+		{"reset timer", func(c *Client) error { return c.ResetTimer(context.Background()) },
+			request{http.MethodPost, "/api/v1/timer/reset", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, got := newServer(t, http.StatusNoContent, "")
+			if err := tt.call(c); err != nil {
+				t.Fatal(err)
+			}
+			if len(*got) != 1 || (*got)[0] != tt.want {
+				t.Errorf("sent %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsRoundTrip(t *testing.T) {
+	want := Settings{Donuts: 42, Gravity: 0.2, Theme: "neon", Behavior: "orbit", MinSpeed: 1, MaxSpeed: 3,
+		MinSpin: 0.01, MaxSpin: 0.02, Trails: true, Layers: 3, Size: 1.5, Lifetime: 30, Chain: 4, Rainbow: true}
+	reply, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, got := newServer(t, http.StatusOK, string(reply))
+
+	applied, err := c.SetSettings(context.Background(), want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != want {
+		t.Errorf("applied %+v, want %+v", applied, want)
+	}
+	var sent Settings
+	if err := json.Unmarshal([]byte((*got)[0].body), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent != want {
+		t.Errorf("sent %+v, want %+v", sent, want)
+	}
+
+	read, err := c.Settings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != want || (*got)[1] != (request{http.MethodGet, "/api/v1/settings", ""}) {
+		t.Errorf("read %+v with %+v, want %+v", read, (*got)[1], want)
+	}
+}
+
+func TestState(t *testing.T) {
+	want := State{
+		Frame: 120, Width: 1920, Height: 1080,
+		Settings: Settings{Donuts: 2},
+		Timer:    Timer{Start: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), Elapsed: 60},
+		Donuts:   []Donut{{X: 1, Y: 2, VX: 3, VY: 4, Angle: 5}, {X: 6}},
+	}
+	reply, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, got := newServer(t, http.StatusOK, string(reply))
+	state, err := c.State(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state, want) || (*got)[0].path != "/api/v1/state" {
+		t.Errorf("state %+v from %s, want %+v", state, (*got)[0].path, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	c, _ := newServer(t, http.StatusBadRequest, "count must be between 1 and 5000\n")
+	err := c.SetDonutCount(context.Background(), 0)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "count must be between 1 and 5000" {
+		t.Errorf("got %d %q", apiErr.StatusCode, apiErr.Message)
+	}
+}
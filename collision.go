@@ -0,0 +1,316 @@
+package main
+
+import (
+	"cmp"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/mlctrez/donut/ecs"
+)
+
+// minParallelPairs is the number of touching pairs from which collisions are resolved by
+// the worker pool. Below it handing the islands to workers costs more than it saves.
+const minParallelPairs = 64
+
+// collisionPair is two touching colliders, by index into the solver's colliders with a < b
+type collisionPair struct{ a, b int32 }
+
+// contact is the outcome of resolving a pair, replayed on the game loop for squash and
+// MIDI effects since those aren't safe to trigger from the workers
+type contact struct {
+	hit              bool
+	nx, ny, impulse  float64
+	centerX, centerY float64
+}
+
+// collisionSolver finds touching donuts with a uniform grid, groups them into islands of
+// donuts that touch each other and resolves the islands in parallel. Only donuts of the
+// same island affect each other within a frame, so the result is the same however the
+// islands are spread over the workers, keeping seeded runs and replays deterministic.
+// Every buffer is reused between frames so the collision step doesn't allocate.
+type collisionSolver struct {
+	entities  []ecs.Entity
+	positions []*position
+	velocity  []*velocity
+	radius    []float64
+	layer     []int
+
+	grid     spatialGrid
+	pairs    []collisionPair
+	contacts []contact // Parallel to pairs
+
+	parent      []int32 // Union-find forest over the colliders
+	island      []int32 // Island of each root collider, -1 when not assigned yet
+	islandStart []int32 // Offsets into order for each island
+	order       []int32 // Pair indexes grouped by island, in pair order within an island
+	fill        []int32 // Next position in order of each island while sorting
+
+	wg sync.WaitGroup
+}
+
+// collisionJob resolves the islands from up to to with a worker of the pool
+type collisionJob struct {
+	s        *collisionSolver
+	from, to int
+}
+
+// collisionPool runs collision jobs. Workers are started as needed and live for the rest
+// of the process, so frames don't pay for starting goroutines.
+var collisionPool struct {
+	jobs    chan collisionJob
+	workers int
+}
+
+// collisionSystem checks for and resolves collisions between entities with colliders
+func (g *Game) collisionSystem() {
+	s := &g.collisions
+	s.gather(g.world)
+	s.findPairs(g.screenWidth, g.screenHeight)
+	s.groupIslands()
+	s.resolve()
+
+	for k, p := range s.pairs {
+		c := &s.contacts[k]
+		if !c.hit {
+			continue
+		}
+		g.addSquash(s.entities[p.a], c.nx, c.ny, -c.impulse)
+		g.addSquash(s.entities[p.b], c.nx, c.ny, -c.impulse)
+		g.addShake(-c.impulse)
+		g.addStat(statCollisions, 1)
+		if g.stats != nil {
+			g.stats.collisions++
+		}
+		if g.midi != nil && c.impulse < 0 {
+			g.midi.collision(c.centerX, c.centerY, -c.impulse, g.screenWidth, g.screenHeight, g.frame)
+		}
+	}
+}
+
+// gather copies the colliders and their components into the solver's slices
+func (s *collisionSolver) gather(w *world) {
+	s.entities, s.positions, s.velocity = s.entities[:0], s.positions[:0], s.velocity[:0]
+	s.radius, s.layer = s.radius[:0], s.layer[:0]
+	for _, e := range w.colliders.Entities() {
+		pos, vel := w.positions.Get(e), w.velocities.Get(e)
+		if pos == nil || vel == nil {
+			continue
+		}
+		s.entities = append(s.entities, e)
+		s.positions = append(s.positions, pos)
+		s.velocity = append(s.velocity, vel)
+		s.radius = append(s.radius, w.colliders.Get(e).radius)
+		s.layer = append(s.layer, w.layerOf(e))
+	}
+}
+
+// findPairs buckets the colliders into grid cells as wide as the largest donut and lists
+// every overlapping pair in the same layer, sorted so they're resolved in a fixed order
+func (s *collisionSolver) findPairs(screenWidth, screenHeight int) {
+	s.pairs = s.pairs[:0]
+	n := len(s.entities)
+	if n < 2 {
+		return
+	}
+
+	size := 1.0
+	for _, r := range s.radius {
+		size = max(size, 2*r)
+	}
+	s.grid.build(n, size, screenWidth, screenHeight, func(i int) (x, y float64) {
+		return s.positions[i].x + s.radius[i], s.positions[i].y + s.radius[i]
+	})
+	for i := range n {
+		s.grid.near(i, func(j int32) {
+			if int(j) > i && s.layer[i] == s.layer[j] && s.touching(i, int(j)) {
+				s.pairs = append(s.pairs, collisionPair{int32(i), j})
+			}
+		})
+	}
+	slices.SortFunc(s.pairs, comparePairs)
+	s.contacts = resize(s.contacts, len(s.pairs))
+}
+
+// spatialGrid buckets items into square cells by their centers so only items in
+// neighboring cells are compared. Items are indexes into the caller's slices and the
+// buffers are reused between frames.
+type spatialGrid struct {
+	cols, rows int
+	cell       []int32 // Cell of each item
+	cellStart  []int32 // Offsets into cellItems for each cell, counting sort style
+	cellItems  []int32
+}
+
+// build buckets n items centered at center(i) into cells size wide covering a width by
+// height area. Items outside it go in the nearest cell at its edge.
+func (sg *spatialGrid) build(n int, size float64, width, height int, center func(i int) (x, y float64)) {
+	sg.cols = int(float64(width)/size) + 1
+	sg.rows = int(float64(height)/size) + 1
+	sg.cellStart = resize(sg.cellStart, sg.cols*sg.rows+1)
+	clear(sg.cellStart)
+	sg.cell = resize(sg.cell, n)
+	for i := range n {
+		x, y := center(i)
+		cx := min(max(int(x/size), 0), sg.cols-1)
+		cy := min(max(int(y/size), 0), sg.rows-1)
+		sg.cell[i] = int32(cy*sg.cols + cx)
+		sg.cellStart[sg.cell[i]]++
+	}
+	for c := 1; c < len(sg.cellStart); c++ {
+		sg.cellStart[c] += sg.cellStart[c-1]
+	}
+	sg.cellItems = resize(sg.cellItems, n)
+	for i := n - 1; i >= 0; i-- {
+		// Fill each cell from its end, leaving cellStart[c] at the cell's first item
+		c := sg.cell[i]
+		sg.cellStart[c]--
+		sg.cellItems[sg.cellStart[c]] = int32(i)
+	}
+}
+
+// near calls fn with every item in the cell of item i and the cells around it, i included
+func (sg *spatialGrid) near(i int, fn func(j int32)) {
+	x, y := int(sg.cell[i])%sg.cols, int(sg.cell[i])/sg.cols
+	for cy := max(y-1, 0); cy <= min(y+1, sg.rows-1); cy++ {
+		for cx := max(x-1, 0); cx <= min(x+1, sg.cols-1); cx++ {
+			c := cy*sg.cols + cx
+			for _, j := range sg.cellItems[sg.cellStart[c]:sg.cellStart[c+1]] {
+				fn(j)
+			}
+		}
+	}
+}
+
+// comparePairs orders pairs like a nested loop over the colliders visits them
+func comparePairs(p, q collisionPair) int {
+	return cmp.Or(cmp.Compare(p.a, q.a), cmp.Compare(p.b, q.b))
+}
+
+// touching reports whether the circles of colliders i and j overlap
+func (s *collisionSolver) touching(i, j int) bool {
+	p1, p2 := s.positions[i], s.positions[j]
+	r1, r2 := s.radius[i], s.radius[j]
+	return areCirclesColliding(p1.x+r1, p1.y+r1, r1, p2.x+r2, p2.y+r2, r2)
+}
+
+// groupIslands joins the colliders of each pair and orders the pairs by island. Islands
+// are numbered in order of their first pair.
+func (s *collisionSolver) groupIslands() {
+	n := len(s.entities)
+	s.parent = resize(s.parent, n)
+	s.island = resize(s.island, n)
+	for i := range n {
+		s.parent[i] = int32(i)
+		s.island[i] = -1
+	}
+	for _, p := range s.pairs {
+		a, b := s.root(p.a), s.root(p.b)
+		if a != b {
+			s.parent[max(a, b)] = min(a, b)
+		}
+	}
+
+	islands := int32(0)
+	s.order = resize(s.order, len(s.pairs))
+	for k, p := range s.pairs {
+		r := s.root(p.a)
+		if s.island[r] < 0 {
+			s.island[r] = islands
+			islands++
+		}
+		s.order[k] = s.island[r] // The island of each pair until it's sorted below
+	}
+	s.islandStart = resize(s.islandStart, int(islands)+1)
+	clear(s.islandStart)
+	for _, island := range s.order {
+		s.islandStart[island+1]++
+	}
+	for i := 1; i < len(s.islandStart); i++ {
+		s.islandStart[i] += s.islandStart[i-1]
+	}
+
+	// Counting sort of the pairs by island
+	s.fill = resize(s.fill, int(islands))
+	copy(s.fill, s.islandStart)
+	for k := range s.pairs {
+		island := s.island[s.root(s.pairs[k].a)]
+		s.order[s.fill[island]] = int32(k)
+		s.fill[island]++
+	}
+}
+
+// root returns the representative collider of i's island, halving the path as it goes
+func (s *collisionSolver) root(i int32) int32 {
+	for s.parent[i] != i {
+		s.parent[i] = s.parent[s.parent[i]]
+		i = s.parent[i]
+	}
+	return i
+}
+
+// resolve resolves every island, spreading them over the worker pool when there are
+// enough pairs for it to pay off
+func (s *collisionSolver) resolve() {
+	islands := len(s.islandStart) - 1
+	workers := runtime.GOMAXPROCS(0)
+	if workers < 2 || islands < 2 || len(s.pairs) < minParallelPairs {
+		s.resolveIslands(0, islands)
+		return
+	}
+
+	startCollisionWorkers(workers)
+	// Split the islands into one job per worker with about the same number of pairs
+	perJob := (len(s.pairs) + workers - 1) / workers
+	from := 0
+	for island := range islands {
+		if int(s.islandStart[island+1])-int(s.islandStart[from]) >= perJob || island == islands-1 {
+			s.wg.Add(1)
+			collisionPool.jobs <- collisionJob{s, from, island + 1}
+			from = island + 1
+		}
+	}
+	s.wg.Wait()
+}
+
+// resolveIslands resolves the pairs of islands from up to to in order. A pair that no
+// longer overlaps after an earlier pair pushed it apart is skipped.
+func (s *collisionSolver) resolveIslands(from, to int) {
+	for _, k := range s.order[s.islandStart[from]:s.islandStart[to]] {
+		p := s.pairs[k]
+		c := &s.contacts[k]
+		pos1, pos2 := s.positions[p.a], s.positions[p.b]
+		radius1, radius2 := s.radius[p.a], s.radius[p.b]
+		center1X, center1Y := pos1.x+radius1, pos1.y+radius1
+		center2X, center2Y := pos2.x+radius2, pos2.y+radius2
+
+		c.hit = areCirclesColliding(center1X, center1Y, radius1, center2X, center2Y, radius2)
+		if c.hit {
+			c.nx, c.ny, c.impulse = resolveCollision(pos1, s.velocity[p.a], pos2, s.velocity[p.b], center1X, center1Y, center2X, center2Y, radius1+radius2)
+			c.centerX, c.centerY = (center1X+center2X)/2, (center1Y+center2Y)/2
+		}
+	}
+}
+
+// startCollisionWorkers grows the collision pool to at least n workers
+func startCollisionWorkers(n int) {
+	if collisionPool.jobs == nil {
+		collisionPool.jobs = make(chan collisionJob)
+	}
+	for ; collisionPool.workers < n; collisionPool.workers++ {
+		go func() {
+			for job := range collisionPool.jobs {
+				job.s.resolveIslands(job.from, job.to)
+				job.s.wg.Done()
+			}
+		}()
+	}
+}
+
+// resize returns s with length n, reusing its array when it's large enough
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// BenchmarkCollisionScaling measures the collision step of the stress preset, run with
+// -cpu to see how the parallel islands scale:
+//
+//	go test -run '^$' -bench CollisionScaling -cpu 1,2,4,8
+func BenchmarkCollisionScaling(b *testing.B) {
+	collision := benchmarks[slices.IndexFunc(benchmarks, func(bm benchmark) bool { return bm.name == "collision" })]
+	for _, n := range []int{1000, 4000} {
+		s := benchSettings(b, "stress", n)
+		b.Run(fmt.Sprintf("donuts=%d", n), func(b *testing.B) { collision.run(b, s, 1) })
+	}
+}
+
+// TestParallelCollisionsMatchSerial checks that resolving the islands on the worker pool
+// moves every donut exactly like resolving them in order on one goroutine, which is what
+// keeps seeded runs and replays deterministic
+func TestParallelCollisionsMatchSerial(t *testing.T) {
+	const frames = 120
+	s := benchSettings(t, "stress", 2000)
+	simulate := func(procs int) (g *Game, parallel bool) {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		g = newBenchGame(s, 7)
+		for range frames {
+			g.movementSystem()
+			g.boundsSystem()
+			g.collisionSystem()
+			c := &g.collisions
+			parallel = parallel || (len(c.pairs) >= minParallelPairs && len(c.islandStart) > 2)
+		}
+		return g, parallel
+	}
+	serial, _ := simulate(1)
+	parallel, used := simulate(4)
+	if !used {
+		t.Fatal("the collisions were never resolved in parallel, the test needs more touching donuts")
+	}
+
+	if n, m := serial.world.donuts.Len(), parallel.world.donuts.Len(); n != m {
+		t.Fatalf("%d donuts in parallel, want %d", m, n)
+	}
+	for i := range serial.world.donuts.Len() {
+		e, f := serial.world.donut(i), parallel.world.donut(i)
+		want, got := *serial.world.positions.Get(e), *parallel.world.positions.Get(f)
+		if got != want {
+			t.Fatalf("donut %d at %+v after %d frames in parallel, want %+v", i, got, frames, want)
+		}
+		wantVel, gotVel := *serial.world.velocities.Get(e), *parallel.world.velocities.Get(f)
+		if gotVel != wantVel {
+			t.Fatalf("donut %d moving at %+v after %d frames in parallel, want %+v", i, gotVel, frames, wantVel)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// subcommand is one of the commands of the donut binary, donut <name> [arguments]
+type subcommand struct {
+	name, args, help string
+	run              func(args []string) int
+}
+
+// subcommands are looked up by runCommand. It's a function rather than a variable so the
+// help command can list them.
+func subcommands() []subcommand {
+	return []subcommand{
+		{"run", "[flags]", "run the screensaver, the default when no command is given", runRun},
+		{"record", "[flags] file", "run the screensaver, recording the initial state and all inputs to file", runRecord},
+		{"bench", "[flags]", "measure the simulation without opening a window", runBench},
+		{"config", "validate [file]", "check a config file, the default one when file is omitted", runConfig},
+		{"ctl", "command", "control the running screensaver, see donut ctl help", runControlClient},
+		{"install-service", "[flags] [-- run flags]", "start the screensaver in kiosk mode at login", runInstallService},
+		{"update", "[flags]", "install the latest release from GitHub", runUpdate},
+		{"version", "", "print the version, commit and build date", runVersion},
+	}
+}
+
+// runCommand runs the subcommand named by args[0] and returns the process exit code.
+// Without a command, or when args start with a flag, the screensaver runs as before
+// subcommands existed.
+func runCommand(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runRun(args)
+	}
+	for _, cmd := range subcommands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "donut: unknown command %q\n\n", args[0])
+	}
+	fmt.Fprintln(os.Stderr, "usage: donut <command> [arguments]\n\ncommands:")
+	for _, cmd := range subcommands() {
+		fmt.Fprintf(os.Stderr, "  %s\n\t%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.help)
+	}
+	if args[0] != "help" {
+		return 2
+	}
+	return 0
+}
+
+func runRun(args []string) int {
+	runFlags.Parse(args)
+	if runFlags.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "donut run: unexpected arguments", runFlags.Args())
+		return 2
+	}
+	return runScreensaver()
+}
+
+func runRecord(args []string) int {
+	runFlags.Usage = func() {
+		fmt.Fprintln(runFlags.Output(), "usage: donut record [flags] file")
+		runFlags.PrintDefaults()
+	}
+	runFlags.Parse(args)
+	if runFlags.NArg() != 1 {
+		runFlags.Usage()
+		return 2
+	}
+	*recordFlag = runFlags.Arg(0)
+	return runScreensaver()
+}
+
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: donut config validate [file]")
+		return 2
+	}
+	path := defaultConfigPath()
+	if len(args) == 2 {
+		path = args[1]
+	}
+	errs := validateConfig(path)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, path+":", err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Println(path, "is valid")
+	return 0
+}
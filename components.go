@@ -0,0 +1,194 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+// Components of the simulation world. A donut is an entity that has all of them.
+type (
+	position struct{ x, y float64 }         // Top left corner in pixels
+	velocity struct{ x, y float64 }         // Pixels per frame
+	spin     struct{ angle, speed float64 } // Rotation in radians and radians per frame
+	collider struct{ radius float64 }       // Circle centered in the sprite
+	donutTag struct{}                       // Marks donuts, as opposed to effects or obstacles
+	layer    struct{ depth int }            // Parallax layer, 0 is the front
+	label    struct{ text string }          // Name drawn under the sprite
+
+	sprite struct {
+		image         *ebiten.Image
+		width, height float64 // Scaled size in pixels
+	}
+
+	// squash is the transient cartoon deformation after an impact
+	squash struct {
+		angle    float64 // Direction of the impact normal in radians
+		strength float64 // Maximum compression along the normal, 0.2 is 20%
+		frame    int     // Frames since the impact
+	}
+)
+
+// world is the ecs.World with the component stores used by the game
+type world struct {
+	*ecs.World
+	positions  *ecs.Store[position]
+	velocities *ecs.Store[velocity]
+	spins      *ecs.Store[spin]
+	sprites    *ecs.Store[sprite]
+	colliders  *ecs.Store[collider]
+	donuts     *ecs.Store[donutTag]
+	squashes   *ecs.Store[squash]
+	layers     *ecs.Store[layer]
+	particles  *ecs.Store[particle]
+	lifetimes  *ecs.Store[lifetime]
+	labels     *ecs.Store[label]
+	vortices   *ecs.Store[vortex]
+	portals    *ecs.Store[portal]
+	cooldowns  *ecs.Store[cooldown]
+	springs    *ecs.Store[spring]
+	polarities *ecs.Store[polarity]
+
+	donutChanges int // Donuts spawned, despawned or replaced so far, see energyMonitor
+}
+
+func newWorld() *world {
+	w := &world{World: ecs.NewWorld()}
+	w.positions = ecs.NewStore[position](w.World)
+	w.velocities = ecs.NewStore[velocity](w.World)
+	w.spins = ecs.NewStore[spin](w.World)
+	w.sprites = ecs.NewStore[sprite](w.World)
+	w.colliders = ecs.NewStore[collider](w.World)
+	w.donuts = ecs.NewStore[donutTag](w.World)
+	w.squashes = ecs.NewStore[squash](w.World)
+	w.layers = ecs.NewStore[layer](w.World)
+	w.particles = ecs.NewStore[particle](w.World)
+	w.lifetimes = ecs.NewStore[lifetime](w.World)
+	w.labels = ecs.NewStore[label](w.World)
+	w.vortices = ecs.NewStore[vortex](w.World)
+	w.portals = ecs.NewStore[portal](w.World)
+	w.cooldowns = ecs.NewStore[cooldown](w.World)
+	w.springs = ecs.NewStore[spring](w.World)
+	w.polarities = ecs.NewStore[polarity](w.World)
+	return w
+}
+
+// Donut describes the initial state of a donut, see world.spawnDonut
+type Donut struct {
+	x, y          float64
+	vx, vy        float64
+	width, height float64 // Scaled size in pixels
+	rotation      float64 // Rotation angle in radians
+	rotationSpeed float64 // Rotation speed in radians per frame
+}
+
+// spawnDonut creates a donut entity drawn with img
+func (w *world) spawnDonut(d Donut, img *ebiten.Image) ecs.Entity {
+	e := w.spawnDonutSprite(d, img)
+	w.colliders.Add(e, collider{radius: d.width / 2}) // Assuming width == height for circular donuts
+	w.donuts.Add(e, donutTag{})
+	w.donutChanges++
+	return e
+}
+
+// Despawn removes e and all of its components, counting the change when it's a donut
+func (w *world) Despawn(e ecs.Entity) {
+	if w.donuts.Has(e) {
+		w.donutChanges++
+	}
+	w.World.Despawn(e)
+}
+
+// spawnDonutSprite creates an entity that looks and moves like a donut but doesn't
+// collide or count as one, used for decorations
+func (w *world) spawnDonutSprite(d Donut, img *ebiten.Image) ecs.Entity {
+	e := w.Spawn()
+	w.positions.Add(e, position{d.x, d.y})
+	w.velocities.Add(e, velocity{d.vx, d.vy})
+	w.spins.Add(e, spin{d.rotation, d.rotationSpeed})
+	w.sprites.Add(e, sprite{image: img, width: d.width, height: d.height})
+	return e
+}
+
+// clearDonuts despawns every donut
+func (w *world) clearDonuts() {
+	for w.donuts.Len() > 0 {
+		e, _ := w.donuts.At(w.donuts.Len() - 1)
+		w.Despawn(e)
+	}
+}
+
+// donut returns the i-th donut entity in iteration order
+func (w *world) donut(i int) ecs.Entity {
+	return w.donuts.Entities()[i]
+}
+
+// body returns the plugin.Body view of e
+func (w *world) body(e ecs.Entity) body {
+	return body{
+		pos:    w.positions.Get(e),
+		vel:    w.velocities.Get(e),
+		spin:   w.spins.Get(e),
+		sprite: w.sprites.Get(e),
+		squash: w.squashes.Get(e),
+		scale:  1,
+	}
+}
+
+// body implements plugin.Body on top of the components of one entity. Missing components
+// read as zero and ignore writes.
+type body struct {
+	pos    *position
+	vel    *velocity
+	spin   *spin
+	sprite *sprite
+	squash *squash
+	scale  float64 // Extra uniform scale applied when drawing
+}
+
+func (b body) Position() (float64, float64) {
+	if b.pos == nil {
+		return 0, 0
+	}
+	return b.pos.x, b.pos.y
+}
+
+func (b body) SetPosition(x, y float64) {
+	if b.pos != nil {
+		b.pos.x, b.pos.y = x, y
+	}
+}
+
+func (b body) Velocity() (float64, float64) {
+	if b.vel == nil {
+		return 0, 0
+	}
+	return b.vel.x, b.vel.y
+}
+
+func (b body) SetVelocity(vx, vy float64) {
+	if b.vel != nil {
+		b.vel.x, b.vel.y = vx, vy
+	}
+}
+
+func (b body) Rotation() float64 {
+	if b.spin == nil {
+		return 0
+	}
+	return b.spin.angle
+}
+
+func (b body) Size() (float64, float64) {
+	if b.sprite == nil {
+		return 0, 0
+	}
+	return b.sprite.width, b.sprite.height
+}
+
+func (b body) Deformation() (float64, float64, float64) {
+	if b.squash == nil {
+		return 0, b.scale, b.scale
+	}
+	amount := b.squash.amount()
+	return b.squash.angle, (1 - amount) * b.scale, (1 + amount/2) * b.scale // Bulge sideways to roughly keep the area
+}
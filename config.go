@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mlctrez/donut/plugin"
+)
+
+// config is the optional JSON configuration file
+type config struct {
+	// Presets are user defined presets, added after the built-in ones. A preset with the
+	// same name as a built-in preset replaces it.
+	Presets map[string]settings `json:"presets"`
+
+	// Themes are custom color themes, selected by name like the built-in ones. A theme
+	// with the same name as a built-in theme replaces it.
+	Themes map[string]themeConfig `json:"themes"`
+
+	// Velocity is the speed and spin range of presets that don't set their own
+	Velocity velocityConfig `json:"velocity"`
+
+	// Edges sets what each screen edge does to donuts, bounce by default
+	Edges edgesConfig `json:"edges"`
+
+	// Vortices are placed at the start, more are added with V
+	Vortices []vortexConfig `json:"vortices"`
+
+	// Portals are pairs of rings, donuts entering one come out of the other
+	Portals []portalConfig `json:"portals"`
+
+	// Spawn is how new donuts are placed: center (default), uniform, edges, fountain or grid
+	Spawn string `json:"spawn"`
+
+	// Renderer is the name of the plugin.Renderer used to draw donuts, "sprite" by default
+	Renderer string `json:"renderer"`
+
+	// Background is the name of the plugin.Background drawn behind the donuts, like matrix
+	// or starfield, the theme's background color when empty. K cycles through them.
+	Background string `json:"background"`
+
+	// Slideshow shows photos from a directory behind the donuts when set, replacing
+	// Background
+	Slideshow *slideshowConfig `json:"slideshow"`
+
+	// Overlays are the names of the plugin.Overlay widgets to draw, in order
+	Overlays []string `json:"overlays"`
+
+	// Procedural replaces the donut image with generated donuts when set
+	Procedural *proceduralConfig `json:"procedural"`
+
+	// Locale selects the language of on-screen text, the OS language by default
+	Locale localeConfig `json:"locale"`
+
+	// Glyph bounces a character or emoji instead of the donut image when set
+	Glyph *glyphConfig `json:"glyph"`
+
+	// Timer customizes how the timer is drawn
+	Timer timerConfig `json:"timer"`
+
+	// Shake shakes the screen a little on heavy impacts, on by default
+	Shake *bool `json:"shake"`
+
+	// Accessibility has the high contrast, color blind safe and reduce motion options
+	Accessibility accessibilityConfig `json:"accessibility"`
+
+	// Milestones celebrates when the timer reaches configured durations
+	Milestones *milestoneConfig `json:"milestones"`
+
+	// Incident shows the days since the last incident instead of the timer when set
+	Incident *incidentConfig `json:"incident"`
+
+	// SystemStats shows a host CPU, memory and network widget when set
+	SystemStats *sysStatsConfig `json:"system_stats"`
+
+	// Stats shows collisions, distance, speed and donut counts of the run when set
+	Stats *simStatsConfig `json:"stats"`
+
+	// NowPlaying shows the currently playing track when set
+	NowPlaying *nowPlayingConfig `json:"now_playing"`
+
+	// Ticker scrolls news headlines along the bottom of the screen when set
+	Ticker *tickerConfig `json:"ticker"`
+
+	// Countdowns show the time left until recurring events, like "every friday 17:00"
+	Countdowns []countdownConfig `json:"countdowns"`
+
+	// WorldClock shows the time in several time zones along the bottom of the screen when set
+	WorldClock *worldClockConfig `json:"world_clock"`
+
+	// NTP corrects the timer with the time of an NTP server when set
+	NTP *ntpConfig `json:"ntp"`
+
+	// Weather shows the current weather for a location when set
+	Weather *weatherConfig `json:"weather"`
+
+	// Chat lets Twitch or YouTube chat spawn and control donuts when set
+	Chat *chatConfig `json:"chat"`
+
+	// Window runs the screensaver in a normal window instead of fullscreen
+	Window windowConfig `json:"window"`
+
+	// Display sets the frame rate and vsync
+	Display displayConfig `json:"display"`
+
+	// Brightness dims the screen, at a fixed level or on a daily schedule
+	Brightness brightnessConfig `json:"brightness"`
+
+	// NightLight shifts the colors warmer at night when set
+	NightLight *nightLightConfig `json:"night_light"`
+
+	// OLED turns on OLED-safe mode with these limits when set, like -oled
+	OLED *oledConfig `json:"oled"`
+
+	// Webhooks receive notifications about events
+	Webhooks []webhookConfig `json:"webhooks"`
+}
+
+// timerConfig customizes how the timer is drawn. Colors may include an alpha channel.
+type timerConfig struct {
+	Color   *hexColor `json:"color"`   // Text color, defaults to the theme's timer color
+	Panel   *hexColor `json:"panel"`   // Rounded background panel behind the text, none when unset
+	Shadow  *hexColor `json:"shadow"`  // Drop shadow, none when unset
+	Outline *hexColor `json:"outline"` // Outline around the text, none when unset
+
+	Anchor anchor `json:"anchor"` // Corner or center of the screen, top-left by default
+	Margin *int   `json:"margin"` // Distance from the screen edges in pixels
+
+	Caption *captionConfig `json:"caption"` // Text above or below the timer, none when unset
+}
+
+// proceduralConfig configures the generated donut sprites
+type proceduralConfig struct {
+	Variants  int        `json:"variants"`  // Number of different sprites, defaults to one per frosting
+	Dough     *hexColor  `json:"dough"`     // Dough color
+	Frosting  []hexColor `json:"frosting"`  // Frosting colors, used in turn by the variants
+	Sprinkles *int       `json:"sprinkles"` // Sprinkles per donut, defaults to 40, 0 for none
+}
+
+func (cfg proceduralConfig) validate() error {
+	if cfg.Variants < 0 || cfg.Variants > maxProceduralVariants {
+		return fmt.Errorf("variants must be from 0 to %d", maxProceduralVariants)
+	}
+	if s := cfg.Sprinkles; s != nil && (*s < 0 || *s > maxSprinkles) {
+		return fmt.Errorf("sprinkles must be from 0 to %d", maxSprinkles)
+	}
+	return nil
+}
+
+// hexColor is a non-premultiplied color written as "#rrggbb" or "#rrggbbaa" in the config
+// file
+type hexColor color.NRGBA
+
+func (c *hexColor) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := parseHexColor(str)
+	if err != nil {
+		return err
+	}
+	*c = hexColor(parsed)
+	return nil
+}
+
+func (c hexColor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A))
+}
+
+// RGBA implements color.Color
+func (c hexColor) RGBA() (r, g, b, a uint32) {
+	return color.NRGBA(c).RGBA()
+}
+
+// premultiplied converts c to a color.RGBA without going through the color.Color
+// interface, which would allocate
+func (c hexColor) premultiplied() color.RGBA {
+	a := uint16(c.A)
+	return color.RGBA{uint8(uint16(c.R) * a / 255), uint8(uint16(c.G) * a / 255), uint8(uint16(c.B) * a / 255), c.A}
+}
+
+// parseHexColor parses "#rrggbb" or "#rrggbbaa"
+func parseHexColor(str string) (color.NRGBA, error) {
+	var c color.NRGBA
+	var n int
+	var err error
+	switch len(str) {
+	case 7:
+		c.A = 255
+		n, err = fmt.Sscanf(str, "#%02x%02x%02x", &c.R, &c.G, &c.B)
+		n++
+	case 9:
+		n, err = fmt.Sscanf(str, "#%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A)
+	}
+	if err != nil || n != 4 {
+		return c, fmt.Errorf("invalid color %q, want #rrggbb or #rrggbbaa", str)
+	}
+	return c, nil
+}
+
+// defaultConfigPath returns the config file location used when -config isn't given
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "donut.json"
+	}
+	return filepath.Join(dir, "donut", "config.json")
+}
+
+// loadConfig reads the config file at path. A missing file is not an error and results
+// in an empty configuration.
+func loadConfig(path string) (config, error) {
+	var cfg config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validateConfig reads the config file at path more strictly than loadConfig, rejecting
+// unknown fields and names of plugins, themes and behaviors that don't exist
+func validateConfig(path string) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if v := cfg.Velocity; v.MinSpeed < 0 || v.MinSpin < 0 || v.MaxSpeed < v.MinSpeed || v.MaxSpin < v.MinSpin {
+		errs = append(errs, fmt.Errorf("velocity: ranges must not be negative and max must not be below min"))
+	}
+	if res := cfg.Display.Resolution; res != "" {
+		if _, _, err := parseResolution(res); err != nil {
+			errs = append(errs, fmt.Errorf("display: %w", err))
+		}
+	}
+	if n := cfg.NowPlaying; n != nil {
+		if err := n.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("now_playing: %w", err))
+		}
+	}
+	if c := cfg.Chat; c != nil {
+		if err := c.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("chat: %w", err))
+		}
+	}
+	if p := cfg.Procedural; p != nil {
+		if err := p.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("procedural: %w", err))
+		}
+	}
+	if err := cfg.Brightness.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("brightness: %w", err))
+	}
+	if o := cfg.OLED; o != nil {
+		if err := o.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("oled: %w", err))
+		}
+	}
+	if n := cfg.NightLight; n != nil {
+		if err := n.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("night_light: %w", err))
+		}
+	}
+	if n := cfg.NTP; n != nil {
+		if err := n.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("ntp: %w", err))
+		}
+	}
+	if _, err := cfg.Edges.modes(); err != nil {
+		errs = append(errs, fmt.Errorf("edges: %w", err))
+	}
+	for i, v := range cfg.Vortices {
+		if err := v.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("vortex %d: %w", i+1, err))
+		}
+	}
+	for i, p := range cfg.Portals {
+		if err := p.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("portal %d: %w", i+1, err))
+		}
+	}
+	if _, ok := spawners[cfg.Spawn]; cfg.Spawn != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown spawn %q, available: %v", cfg.Spawn, spawnerNames()))
+	}
+	if _, ok := plugin.LookupRenderer(cmp.Or(cfg.Renderer, "sprite")); !ok {
+		errs = append(errs, fmt.Errorf("unknown renderer %q, available: %v", cfg.Renderer, plugin.Renderers()))
+	}
+	if _, ok := plugin.LookupBackground(cfg.Background); cfg.Background != "" && !ok {
+		errs = append(errs, fmt.Errorf("unknown background %q, available: %v", cfg.Background, plugin.Backgrounds()))
+	}
+	for _, name := range cfg.Overlays {
+		if _, ok := plugin.LookupOverlay(name); !ok {
+			errs = append(errs, fmt.Errorf("unknown overlay %q, available: %v", name, plugin.Overlays()))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Themes)) {
+		if _, err := cfg.Themes[name].theme(themes); err != nil {
+			errs = append(errs, fmt.Errorf("theme %q: %w", name, err))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Presets)) {
+		p := cfg.Presets[name]
+		_, custom := cfg.Themes[p.Theme]
+		if _, ok := themes[p.Theme]; p.Theme != "" && !ok && !custom {
+			errs = append(errs, fmt.Errorf("preset %q: unknown theme %q, available: %v", name, p.Theme, themeNames()))
+		}
+		if _, ok := plugin.LookupBehavior(p.Behavior); p.Behavior != "" && !ok {
+			errs = append(errs, fmt.Errorf("preset %q: unknown behavior %q, available: %v", name, p.Behavior, plugin.Behaviors()))
+		}
+	}
+	for i, w := range cfg.Webhooks {
+		if w.URL == "" {
+			errs = append(errs, fmt.Errorf("webhook %d: url is required", i+1))
+		}
+		if w.Format != "" && w.Format != "json" && w.Format != "slack" {
+			errs = append(errs, fmt.Errorf("webhook %d: unknown format %q, want json or slack", i+1, w.Format))
+		}
+	}
+	return errs
+}
+
+// ptrOr returns *p, or the zero value when p is nil
+func ptrOr[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// controlUsage lists the commands of the control socket
+const controlUsage = `commands:
+  pause            pause the donuts
+  resume           resume the donuts
+  set count N      set the number of donuts
+  timer reset      restart the timer, or record an incident when counting incidents
+  state            print the current settings as JSON
+  quit             exit the screensaver`
+
+// defaultControlSocket returns where the control socket is created with -control default,
+// in the user's runtime directory when there is one and in a directory of the user's own
+// under the temporary directory otherwise
+func defaultControlSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "donut.sock")
+	}
+	name := "donut"
+	if uid := os.Getuid(); uid >= 0 { // -1 on Windows, where the temporary directory is per user
+		name = fmt.Sprintf("donut-%d", uid)
+	}
+	return filepath.Join(os.TempDir(), name, "donut.sock")
+}
+
+// privateDir creates dir if needed and checks that other users can't get into it, so nobody
+// else can connect to a socket in it before its mode is set
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s isn't a directory", dir)
+	}
+	// Windows reports no permission bits, sockets there get the directory's access list
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is open to other users, its mode is %v", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// call runs fn on the game loop with the app and waits for it to finish
+func (a *app) call(fn func(a *app) error) error {
+	done := make(chan error, 1)
+	select {
+	case a.commands <- func(a *app) error { err := fn(a); done <- err; return nil }:
+	case <-time.After(commandTimeout):
+		return errors.New("game loop is busy")
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(commandTimeout):
+		return errors.New("game loop didn't respond")
+	}
+}
+
+// startControl accepts commands from donutctl on a Unix socket at path, one line per
+// command answered by one line, "ok" or "error: ...". It's meant for scripting kiosks
+// without opening a TCP port. The socket's directory must be private to the user. The
+// returned function removes the socket.
+func startControl(path string, a *app) (stop func(), err error) {
+	if err := privateDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	// A socket left behind by an instance that crashed refuses connections
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s is in use by another instance", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go a.serveControl(conn)
+		}
+	}()
+	return func() { l.Close() }, nil
+}
+
+func (a *app) serveControl(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		reply, err := a.control(strings.Fields(scanner.Text()))
+		switch {
+		case err != nil:
+			fmt.Fprintln(conn, "error:", err)
+		case reply != "":
+			fmt.Fprintln(conn, reply)
+		default:
+			fmt.Fprintln(conn, "ok")
+		}
+	}
+}
+
+// control runs one command, returning the text to reply with when it isn't just "ok"
+func (a *app) control(args []string) (string, error) {
+	switch strings.Join(args, " ") {
+	case "pause":
+		return "", a.call(func(a *app) error { a.switchTo(pauseScene{}); return nil })
+	case "resume":
+		return "", a.call(func(a *app) error { a.switchTo(simulationScene{}); return nil })
+	case "timer reset":
+		return "", a.call(func(a *app) error { a.game.resetTimer(); return nil })
+	case "state":
+		var s settings
+		if err := a.call(func(a *app) error { s = a.game.currentSettings(); return nil }); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(s)
+		return string(data), err
+	case "quit":
+		a.send(func(a *app) error { return ebiten.Termination })
+		return "", nil
+	}
+
+	if len(args) == 3 && args[0] == "set" && args[1] == "count" {
+		count, err := strconv.Atoi(args[2])
+		if err != nil || count < minDonuts || count > maxDonuts {
+			return "", fmt.Errorf("count must be between %d and %d", minDonuts, maxDonuts)
+		}
+		return "", a.call(func(a *app) error { a.game.setDonutCount(count); return nil })
+	}
+	return "", fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
+
+// runControlClient is donutctl: it sends the command in args to the running instance and
+// prints the reply. It returns the process exit code.
+func runControlClient(args []string) int {
+	path := defaultControlSocket()
+	if len(args) >= 2 && args[0] == "-socket" {
+		path, args = args[1], args[2:]
+	}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" {
+		fmt.Fprintln(os.Stderr, "usage: donutctl [-socket path] command\n"+
+			"the screensaver listens when started with -control default, or -control path\n"+controlUsage)
+		return 2
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donutctl: is the screensaver running?", err)
+		return 1
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, strings.Join(args, " "))
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donutctl:", err)
+		return 1
+	}
+	reply = strings.TrimSpace(reply)
+	if msg, ok := strings.CutPrefix(reply, "error: "); ok {
+		fmt.Fprintln(os.Stderr, "donutctl:", msg)
+		return 1
+	}
+	if reply != "ok" {
+		fmt.Println(reply)
+	}
+	return 0
+}
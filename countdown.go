@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+// maxScheduleSearch is how far ahead the next occurrence of a schedule is looked for, long
+// enough for a February 29th
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// countdownConfig shows the time left until the next occurrence of a recurring event in a
+// HUD widget
+type countdownConfig struct {
+	widgetConfig
+	Label     string   `json:"label"`     // Shown above the time left, e.g. "Weekend"
+	Schedule  schedule `json:"schedule"`  // When the event happens, see parseSchedule
+	Location  string   `json:"location"`  // IANA time zone of the schedule, local time by default
+	Celebrate bool     `json:"celebrate"` // Celebrate like a milestone when the countdown reaches zero
+}
+
+// schedule is a set of minutes an event recurs at, like a cron table entry. Each field is
+// a bit set, bit n meaning value n is included.
+type schedule struct {
+	spec    string
+	minute  uint64 // 0-59
+	hour    uint64 // 0-23
+	day     uint64 // Day of the month, 1-31
+	month   uint64 // 1-12
+	weekday uint64 // 0-6, Sunday is 0
+
+	anyDay, anyWeekday bool // The field was *, see matchesDay
+}
+
+func (s *schedule) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := parseSchedule(str)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+func (s schedule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.spec)
+}
+
+// parseSchedule parses one of three forms of a recurring event:
+//
+//	every friday 17:00              every day|weekday|weekend|<weekday>[,<weekday>...] [at] HH:MM
+//	0 17 * * 5                      minute hour day-of-month month day-of-week, cron style
+//	FREQ=WEEKLY;BYDAY=FR;BYHOUR=17  a subset of an iCalendar RRULE
+func parseSchedule(spec string) (schedule, error) {
+	str := strings.TrimSpace(spec)
+	var s schedule
+	var err error
+	switch lower := strings.ToLower(str); {
+	case strings.HasPrefix(lower, "every "):
+		s, err = parseEvery(strings.TrimPrefix(lower, "every "))
+	case strings.HasPrefix(strings.ToUpper(str), "FREQ=") || strings.HasPrefix(strings.ToUpper(str), "RRULE:"):
+		s, err = parseRRule(strings.TrimPrefix(strings.ToUpper(str), "RRULE:"))
+	default:
+		s, err = parseCron(str)
+	}
+	if err != nil {
+		return schedule{}, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	s.spec = spec
+	return s, nil
+}
+
+var weekdayNames = map[string]int{
+	"sunday": 0, "monday": 1, "tuesday": 2, "wednesday": 3, "thursday": 4, "friday": 5, "saturday": 6,
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	"su": 0, "mo": 1, "tu": 2, "we": 3, "th": 4, "fr": 5, "sa": 6,
+}
+
+// parseEvery parses the words after "every", e.g. "friday 17:00" or "weekday at 9:30"
+func parseEvery(str string) (schedule, error) {
+	fields := strings.Fields(strings.ReplaceAll(str, ",", " "))
+	if n := len(fields); n > 1 && fields[n-2] == "at" {
+		fields = append(fields[:n-2], fields[n-1])
+	}
+	if len(fields) < 2 {
+		return schedule{}, fmt.Errorf("want days and a time, e.g. every friday 17:00")
+	}
+	hour, minute, ok := strings.Cut(fields[len(fields)-1], ":")
+	if !ok {
+		return schedule{}, fmt.Errorf("want the time as HH:MM")
+	}
+	s := schedule{day: allBits(1, 31), month: allBits(1, 12), anyDay: true}
+	var err error
+	if s.hour, err = parseValue(hour, 0, 23); err != nil {
+		return schedule{}, err
+	}
+	if s.minute, err = parseValue(minute, 0, 59); err != nil {
+		return schedule{}, err
+	}
+	for _, word := range fields[:len(fields)-1] {
+		word = strings.TrimSuffix(word, "s") // "fridays" or "weekdays"
+		switch word {
+		case "day":
+			s.weekday |= allBits(0, 6)
+		case "weekday":
+			s.weekday |= allBits(1, 5)
+		case "weekend":
+			s.weekday |= 1<<0 | 1<<6
+		default:
+			n, ok := weekdayNames[word]
+			if !ok {
+				return schedule{}, fmt.Errorf("unknown day %q", word)
+			}
+			s.weekday |= 1 << n
+		}
+	}
+	return s, nil
+}
+
+// parseCron parses a five field cron expression. Fields are lists of values, ranges and
+// steps like "1-5", "*/15" or "0,30".
+func parseCron(str string) (schedule, error) {
+	fields := strings.Fields(str)
+	if len(fields) != 5 {
+		return schedule{}, fmt.Errorf("want 5 cron fields or a schedule starting with every or FREQ=")
+	}
+	var s schedule
+	var err error
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := [5]*uint64{&s.minute, &s.hour, &s.day, &s.month, &s.weekday}
+	for i, field := range fields {
+		if *sets[i], err = parseCronField(field, bounds[i][0], bounds[i][1]); err != nil {
+			return schedule{}, err
+		}
+	}
+	// Sunday may be written 7 as well as 0
+	if s.weekday&(1<<7) != 0 {
+		s.weekday = s.weekday&^(1<<7) | 1
+	}
+	s.anyDay, s.anyWeekday = fields[2] == "*", fields[4] == "*"
+	return s, nil
+}
+
+// parseCronField parses one cron field with values from lo to hi
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = parseNumber(first, lo, hi); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = parseNumber(last, from, hi); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseRRule parses an RRULE with FREQ of DAILY, WEEKLY, MONTHLY or YEARLY and the BYDAY,
+// BYMONTHDAY, BYMONTH, BYHOUR and BYMINUTE parts. Times default to midnight since there's
+// no DTSTART to take them from.
+func parseRRule(str string) (schedule, error) {
+	parts := map[string]string{}
+	for part := range strings.SplitSeq(str, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return schedule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+		parts[key] = value
+	}
+	if interval, ok := parts["INTERVAL"]; ok && interval != "1" {
+		return schedule{}, fmt.Errorf("INTERVAL isn't supported")
+	}
+
+	s := schedule{minute: 1, hour: 1, day: allBits(1, 31), month: allBits(1, 12), weekday: allBits(0, 6), anyDay: true, anyWeekday: true}
+	var err error
+	switch parts["FREQ"] {
+	case "DAILY":
+	case "WEEKLY":
+		if parts["BYDAY"] == "" {
+			return schedule{}, fmt.Errorf("WEEKLY needs BYDAY")
+		}
+	case "MONTHLY":
+		if parts["BYDAY"] == "" && parts["BYMONTHDAY"] == "" {
+			s.day, s.anyDay = 1<<1, false
+		}
+	case "YEARLY":
+		if parts["BYMONTH"] == "" {
+			s.month = 1 << 1
+		}
+		if parts["BYDAY"] == "" && parts["BYMONTHDAY"] == "" {
+			s.day, s.anyDay = 1<<1, false
+		}
+	default:
+		return schedule{}, fmt.Errorf("unsupported FREQ %q", parts["FREQ"])
+	}
+	if days := parts["BYDAY"]; days != "" {
+		s.weekday, s.anyWeekday = 0, false
+		for day := range strings.SplitSeq(days, ",") {
+			n, ok := weekdayNames[strings.ToLower(day)]
+			if !ok || len(day) != 2 {
+				return schedule{}, fmt.Errorf("unsupported BYDAY %q", day)
+			}
+			s.weekday |= 1 << n
+		}
+	}
+	if days := parts["BYMONTHDAY"]; days != "" {
+		if parts["BYDAY"] != "" {
+			// Both have to match in an RRULE, unlike cron where either does
+			return schedule{}, fmt.Errorf("BYDAY and BYMONTHDAY together aren't supported")
+		}
+		if s.day, err = parseList(days, 1, 31); err != nil {
+			return schedule{}, err
+		}
+		s.anyDay = false
+	}
+	if months := parts["BYMONTH"]; months != "" {
+		if s.month, err = parseList(months, 1, 12); err != nil {
+			return schedule{}, err
+		}
+	}
+	if hours := parts["BYHOUR"]; hours != "" {
+		if s.hour, err = parseList(hours, 0, 23); err != nil {
+			return schedule{}, err
+		}
+	}
+	if minutes := parts["BYMINUTE"]; minutes != "" {
+		if s.minute, err = parseList(minutes, 0, 59); err != nil {
+			return schedule{}, err
+		}
+	}
+	return s, nil
+}
+
+// parseList parses comma separated values from lo to hi
+func parseList(str string, lo, hi int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(str, ",") {
+		v, err := parseValue(part, lo, hi)
+		if err != nil {
+			return 0, err
+		}
+		set |= v
+	}
+	return set, nil
+}
+
+// parseValue parses a single value from lo to hi as a set
+func parseValue(str string, lo, hi int) (uint64, error) {
+	n, err := parseNumber(str, lo, hi)
+	return 1 << n, err
+}
+
+func parseNumber(str string, lo, hi int) (int, error) {
+	n, err := strconv.Atoi(str)
+	if err != nil || n < lo || n > hi {
+		return 0, fmt.Errorf("invalid value %q, want %d to %d", str, lo, hi)
+	}
+	return n, nil
+}
+
+// allBits returns a set of the values from lo to hi
+func allBits(lo, hi int) uint64 {
+	return (1<<(hi+1) - 1) &^ (1<<lo - 1)
+}
+
+// matchesDay reports whether the event happens on the day of t. Like cron, when both the
+// day of the month and the day of the week are restricted either one matching is enough.
+func (s schedule) matchesDay(t time.Time) bool {
+	if s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	day, weekday := s.day&(1<<t.Day()) != 0, s.weekday&(1<<int(t.Weekday())) != 0
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// next returns the first occurrence strictly after t, in t's location. It's false when
+// there is none within maxScheduleSearch, like on February 30th.
+func (s schedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+	for day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()); day.Before(limit); day = day.AddDate(0, 0, 1) {
+		if !s.matchesDay(day) {
+			continue
+		}
+		for hours := s.hour; hours != 0; hours &= hours - 1 {
+			hour := bits.TrailingZeros64(hours)
+			for minutes := s.minute; minutes != 0; minutes &= minutes - 1 {
+				at := time.Date(day.Year(), day.Month(), day.Day(), hour, bits.TrailingZeros64(minutes), 0, 0, day.Location())
+				// Times skipped by a DST change are normalized into another hour, skip those
+				if !at.Before(t) && at.Hour() == hour {
+					return at, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// countdownWidget shows the time left until the next occurrence of its schedule and rolls
+// over to the following one when it's reached
+type countdownWidget struct {
+	g        *Game
+	cfg      countdownConfig
+	location *time.Location
+	target   time.Time // Next occurrence, zero when the schedule never occurs
+}
+
+func newCountdownWidget(g *Game, cfg countdownConfig) (*countdownWidget, error) {
+	if cfg.Schedule.spec == "" {
+		return nil, fmt.Errorf("countdown %q has no schedule", cfg.Label)
+	}
+	location := time.Local
+	if cfg.Location != "" {
+		var err error
+		if location, err = time.LoadLocation(cfg.Location); err != nil {
+			return nil, err
+		}
+	}
+	w := &countdownWidget{g: g, cfg: cfg, location: location}
+	w.roll(g.now())
+	return w, nil
+}
+
+// roll moves the target to the next occurrence after now
+func (w *countdownWidget) roll(now time.Time) {
+	next, ok := w.cfg.Schedule.next(now.In(w.location))
+	if !ok {
+		slog.Warn("countdown schedule never occurs", "schedule", w.cfg.Schedule.spec)
+	}
+	w.target = next
+}
+
+func (w *countdownWidget) Update(plugin.World) error {
+	now := w.g.now()
+	if w.target.IsZero() || now.Before(w.target) {
+		return nil
+	}
+	slog.Info("countdown reached", "label", w.cfg.Label, "at", w.target)
+	if w.cfg.Celebrate {
+		w.g.celebrate()
+	}
+	w.roll(now)
+	return nil
+}
+
+func (w *countdownWidget) Draw(dst *ebiten.Image, _ plugin.World) {
+	if w.target.IsZero() {
+		return
+	}
+	left := max(0, w.target.Sub(w.g.now()))
+	lines := []string{formatCountdown(left)}
+	if w.cfg.Label != "" {
+		lines = append([]string{w.cfg.Label}, lines...)
+	}
+	drawWidget(dst, w.cfg.widgetConfig, lines, w.g.theme.hud)
+}
+
+// formatCountdown returns d as days and a clock, e.g. "2d 04:05:06"
+func formatCountdown(d time.Duration) string {
+	seconds := int64(d.Seconds())
+	clock := fmt.Sprintf("%02d:%02d:%02d", seconds/3600%24, seconds/60%60, seconds%60)
+	if days := seconds / 86400; days > 0 {
+		return fmt.Sprintf("%dd %s", days, clock)
+	}
+	return clock
+}
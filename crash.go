@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	crashRestartDelay  = time.Minute // The crash screen restarts the simulation after this long
+	maxCrashRestarts   = 3           // After this many crashes the crash screen stays up
+	crashReportsToKeep = 20
+)
+
+// crashConfig is the loaded config file, included in crash reports
+var crashConfig *config
+
+// crashDir returns where crash reports are written
+func crashDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "donut", "crashes")
+}
+
+// writeCrashReport writes the panic value, its stack, the build, the redacted config and
+// the recent log lines to a new file in crashDir, readable only by the user, and returns
+// its path. Old reports are
+// removed so a crash loop can't fill the disk.
+func writeCrashReport(value any, stack []byte, s *settings) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "donut crashed at %s\n%s\n\npanic: %v\n\n%s\n", time.Now().Format(time.RFC3339), currentBuild(), value, stack)
+	if s != nil {
+		data, _ := json.MarshalIndent(s, "", "  ")
+		fmt.Fprintf(&b, "settings:\n%s\n\n", data)
+	}
+	if crashConfig != nil {
+		data, _ := json.MarshalIndent(crashConfig.redacted(), "", "  ")
+		fmt.Fprintf(&b, "config:\n%s\n\n", data)
+	}
+	fmt.Fprintf(&b, "recent log:\n%s", recentLogs)
+
+	dir := crashDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405.000")+".txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+
+	// Report names sort by time
+	if old, err := filepath.Glob(filepath.Join(dir, "crash-*.txt")); err == nil && len(old) > crashReportsToKeep {
+		for _, p := range old[:len(old)-crashReportsToKeep] {
+			os.Remove(p)
+		}
+	}
+	return path, nil
+}
+
+// redacted returns a copy of cfg without its secrets, the webhook URLs and the YouTube API
+// key, for crash reports that get attached to bug reports
+func (cfg config) redacted() config {
+	cfg.Webhooks = slices.Clone(cfg.Webhooks)
+	for i, w := range cfg.Webhooks {
+		// Webhook URLs carry their token in the path, only the host is kept
+		if u, err := url.Parse(w.URL); err == nil && u.Host != "" {
+			cfg.Webhooks[i].URL = u.Scheme + "://" + u.Host + "/redacted"
+		} else {
+			cfg.Webhooks[i].URL = "redacted"
+		}
+	}
+	if cfg.Chat != nil && cfg.Chat.YouTube != nil && cfg.Chat.YouTube.APIKey != "" {
+		chat, youtube := *cfg.Chat, *cfg.Chat.YouTube
+		youtube.APIKey = "redacted"
+		chat.YouTube = &youtube
+		cfg.Chat = &chat
+	}
+	return cfg
+}
+
+// reportPanic writes a crash report for a panic outside the game loop, where no scene can
+// show it, and exits. It must be deferred directly.
+func reportPanic() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	path, err := writeCrashReport(value, debug.Stack(), nil)
+	if err != nil {
+		slog.Error("failed to write crash report", "err", err)
+	}
+	fatal("crashed", "panic", value, "report", path)
+}
+
+// recoverCrash turns a panic in a scene into the crash scene. It must be deferred directly
+// by app.Update and app.Draw.
+func (a *app) recoverCrash() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	a.crash(value, debug.Stack())
+}
+
+// crash writes a crash report for value and switches to the crash scene
+func (a *app) crash(value any, stack []byte) {
+	// The settings are read defensively, the game may be what is broken
+	var s *settings
+	func() {
+		defer func() { recover() }()
+		current := a.game.currentSettings()
+		s = &current
+	}()
+
+	path, err := writeCrashReport(value, stack, s)
+	if err != nil {
+		slog.Error("failed to write crash report", "err", err)
+	}
+	slog.Error("crashed", "panic", value, "report", path)
+	a.crashes++
+	a.switchTo(&crashScene{report: path, since: time.Now()})
+}
+
+// crashScene replaces a crashed scene with an explanation instead of the process dying
+// unnoticed on a wall display. It restarts the simulation after crashRestartDelay, unless
+// it crashed too often outside kiosk mode, Enter restarts it right away and Escape exits.
+type crashScene struct {
+	report string
+	since  time.Time
+}
+
+func (c *crashScene) canRestart(a *app) bool { return a.kiosk != nil || a.crashes <= maxCrashRestarts }
+
+func (c *crashScene) Update(a *app) error {
+	locked := a.kiosk.locked()
+	switch {
+	case !locked && inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		return ebiten.Termination
+	case !locked && inpututil.IsKeyJustPressed(ebiten.KeyEnter),
+		c.canRestart(a) && time.Since(c.since) > crashRestartDelay:
+		slog.Info("restarting the simulation after a crash")
+		a.game.resetDonuts()
+		a.switchTo(simulationScene{})
+	}
+	return nil
+}
+
+func (c *crashScene) Draw(a *app, screen *ebiten.Image) {
+	screen.Fill(a.game.theme.background)
+	lines := []string{tr("The report was saved to"), c.report, ""}
+	if c.report == "" {
+		lines = []string{tr("The report couldn't be saved, see the log"), ""}
+	}
+	if left := max(0, crashRestartDelay-time.Since(c.since)).Round(time.Second); a.kiosk.locked() {
+		lines = append(lines, trf("Restarting in %s", left))
+	} else if c.canRestart(a) {
+		lines = append(lines, trf("Restarting in %s, Enter restarts now, Esc exits", left))
+	} else {
+		lines = append(lines, tr("Enter restarts, Esc exits"))
+	}
+
+	const titleScale, lineScale = 4, 2
+	w, h := logicalSize(screen)
+	y := h/3 - baseFontHeight*titleScale
+	title := tr("Something went wrong")
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, y, titleScale, a.game.theme.hud)
+	y += baseFontHeight * titleScale * 2
+	for _, line := range lines {
+		drawText(screen, line, (w-textWidth(line, lineScale))/2, y, lineScale, a.game.theme.hud)
+		y += baseFontHeight * lineScale * 1.5
+	}
+}
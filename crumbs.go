@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	crumbChance      = 0.04 // Chance per donut per frame of dropping a crumb
+	crumbFadeFrames  = 20   // The crumb layer decays once every this many frames at 60 per second
+	crumbFadeAlpha   = 0.92 // Alpha multiplier applied at each decay step
+	maxPendingCrumbs = 512  // Crumbs dropped while nothing is drawn are capped
+)
+
+var crumbColors = []color.RGBA{
+	{170, 110, 50, 255},
+	{200, 150, 90, 255},
+	{120, 75, 35, 255},
+}
+
+// crumb is a particle waiting to be stamped onto the crumb layer
+type crumb struct {
+	x, y, radius float32
+	clr          color.RGBA
+}
+
+// crumbLayer accumulates dropped crumbs and slowly fades them. Images can't be drawn onto
+// themselves, so fading copies between two images.
+type crumbLayer struct {
+	images  [2]*ebiten.Image
+	current int
+	pending []crumb
+}
+
+// dropCrumbs randomly drops crumbs under the donuts. It uses the effects random source so
+// the simulation is the same with crumbs on or off.
+func (g *Game) dropCrumbs() {
+	w := g.world
+	for _, e := range w.donuts.Entities() {
+		if g.fx.Float64() >= crumbChance || len(g.crumbs.pending) >= maxPendingCrumbs {
+			continue
+		}
+		pos, spr := w.positions.Get(e), w.sprites.Get(e)
+		g.crumbs.pending = append(g.crumbs.pending, crumb{
+			x:      float32(pos.x + spr.width*(0.3+0.4*g.fx.Float64())),
+			y:      float32(pos.y + spr.height*(0.3+0.4*g.fx.Float64())),
+			radius: float32(1 + 2*g.fx.Float64()),
+			clr:    crumbColors[g.fx.Intn(len(crumbColors))],
+		})
+	}
+}
+
+// draw stamps pending crumbs, decays the layer when due and draws it onto dst
+func (c *crumbLayer) draw(dst *ebiten.Image, frame int) {
+	width, height := dst.Bounds().Dx(), dst.Bounds().Dy()
+	img := c.images[c.current]
+	if img == nil || img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+		for i := range c.images {
+			if c.images[i] != nil {
+				c.images[i].Dispose()
+			}
+			c.images[i] = ebiten.NewImage(width, height)
+		}
+		img = c.images[c.current]
+	}
+
+	if frame%ticks(crumbFadeFrames) == 0 {
+		next := c.images[1-c.current]
+		next.Clear()
+		op := &ebiten.DrawImageOptions{}
+		op.ColorScale.ScaleAlpha(crumbFadeAlpha)
+		next.DrawImage(img, op)
+		c.current = 1 - c.current
+		img = next
+	}
+
+	for _, cr := range c.pending {
+		drawFilledCircle(img, cr.x, cr.y, cr.radius, cr.clr, true)
+	}
+	c.pending = c.pending[:0]
+
+	dst.DrawImage(img, nil)
+}
+
+// clear removes all crumbs, used when crumbs are switched off
+func (c *crumbLayer) clear() {
+	for _, img := range c.images {
+		if img != nil {
+			img.Clear()
+		}
+	}
+	c.pending = c.pending[:0]
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+var hideCursorFlag = runFlags.Duration("hide-cursor", 3*time.Second, "hide the mouse cursor after it hasn't moved for this long, 0 keeps it visible")
+
+// cursorHider hides the mouse cursor while it rests and shows it again when it moves
+type cursorHider struct {
+	delay  time.Duration
+	x, y   int
+	moved  time.Time
+	hidden bool
+}
+
+// update checks the cursor position, called once per update
+func (c *cursorHider) update() {
+	x, y := ebiten.CursorPosition()
+	now := time.Now()
+	switch {
+	case x != c.x || y != c.y || c.moved.IsZero():
+		c.x, c.y, c.moved = x, y, now
+		if c.hidden {
+			c.hidden = false
+			ebiten.SetCursorMode(ebiten.CursorModeVisible)
+		}
+	case !c.hidden && now.Sub(c.moved) >= c.delay:
+		c.hidden = true
+		ebiten.SetCursorMode(ebiten.CursorModeHidden)
+	}
+}
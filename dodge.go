@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	dodgeDonuts   = 4               // Donuts at the start of a round
+	dodgeMax      = 60              // Most donuts the ramp adds up to
+	dodgeGrace    = 2 * time.Second // Time to get the cursor to safety before donuts count
+	dodgeRamp     = 5 * time.Second // Time between difficulty steps
+	dodgeSpeedUp  = 1.08            // Speed gained by every donut on each step
+	dodgeMaxSpeed = 4               // Most speed relative to the start, as a multiple
+	dodgeCursor   = 6               // Radius of the cursor marker
+)
+
+// dodge is survival against the donuts: the cursor must stay clear of them, and every few
+// seconds another donut joins and all of them speed up. Leaving the screen counts as
+// being hit, so the cursor can't hide outside the window.
+type dodge struct {
+	frames int     // Frames survived
+	speed  float64 // Current speed relative to the start
+	best   float64 // Best time in seconds, from the state file
+	caught bool
+	x, y   float64 // Cursor in world coordinates
+}
+
+func (d *dodge) start(g *Game) {
+	s := g.currentSettings()
+	s.Donuts, s.Gravity, s.Behavior, s.Layers = dodgeDonuts, 0, defaultBehavior, 1
+	s.Lifetime, s.Chain, s.Magnets = 0, 0, false
+	// Donuts come in from the edges rather than on top of the cursor
+	g.spawner = spawners["edges"]
+	g.applySettings(s)
+	g.resetDonuts()
+	g.edges = [4]edgeMode{edgeBounce, edgeBounce, edgeBounce, edgeBounce}
+
+	d.speed = 1
+	if st := loadState(); st.BestDodge != nil {
+		d.best = *st.BestDodge
+	}
+}
+
+// seconds returns the time survived
+func (d *dodge) seconds() float64 {
+	return float64(d.frames) / float64(ebiten.TPS())
+}
+
+func (d *dodge) update(g *Game) bool {
+	cx, cy := cursorPosition()
+	d.x, d.y = g.camera.toWorld(cx, cy)
+	d.frames++
+	if d.frames < durationFrames(dodgeGrace) {
+		return false
+	}
+	if d.frames%durationFrames(dodgeRamp) == 0 {
+		d.harder(g)
+	}
+
+	d.caught = cx < 0 || cy < 0 || cx >= g.screenWidth || cy >= g.screenHeight
+	for _, e := range g.world.donuts.Entities() {
+		pos, spr := g.world.positions.Get(e), g.world.sprites.Get(e)
+		radius := spr.width / 2
+		if math.Hypot(pos.x+radius-d.x, pos.y+radius-d.y) < radius {
+			d.caught = true
+			break
+		}
+	}
+	if !d.caught {
+		return false
+	}
+
+	g.spawnFirework(d.x, d.y, 60)
+	g.addShake(2 * g.maxSpeed)
+	if t := d.seconds(); t > d.best {
+		d.best = t
+		updateState(func(st *appState) { st.BestDodge = &t })
+	}
+	return true
+}
+
+// harder adds a donut and speeds every donut up, until the limits are reached
+func (d *dodge) harder(g *Game) {
+	if g.numDonuts < dodgeMax {
+		g.respawnDonut(g.donutImageFor(g.numDonuts), 0)
+		g.numDonuts++
+		// The newcomer moves at the base speed, catch it up with the others
+		last := g.world.donut(g.world.donuts.Len() - 1)
+		vel := g.world.velocities.Get(last)
+		vel.x, vel.y = vel.x*d.speed, vel.y*d.speed
+	}
+	if d.speed*dodgeSpeedUp > dodgeMaxSpeed {
+		return
+	}
+	d.speed *= dodgeSpeedUp
+	for _, e := range g.world.donuts.Entities() {
+		vel := g.world.velocities.Get(e)
+		vel.x, vel.y = vel.x*dodgeSpeedUp, vel.y*dodgeSpeedUp
+	}
+}
+
+func (d *dodge) draw(g *Game, screen *ebiten.Image) {
+	clr := g.theme.hud
+	// The marker blinks during the grace period
+	if d.frames < durationFrames(dodgeGrace) && d.frames/ticks(10)%2 == 1 {
+		return
+	}
+	if d.caught {
+		clr = hsvColor(0, 0.9, 1)
+	}
+	strokeCircle(screen, float32(d.x), float32(d.y), dodgeCursor, 2, clr, true)
+}
+
+func (d *dodge) scoreboard() string {
+	return trf("%.1fs   BEST %.1fs", d.seconds(), max(d.best, d.seconds()))
+}
+
+// score is the time survived in seconds
+func (d *dodge) score() float64 {
+	return d.seconds()
+}
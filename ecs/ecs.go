@@ -0,0 +1,123 @@
+// Package ecs is a small entity-component-system. Entities are plain ids, each component
+// type lives in its own densely packed Store, and systems are ordinary functions that
+// iterate the stores they need.
+package ecs
+
+// Entity identifies a thing in the world. Ids are never reused.
+type Entity uint32
+
+// World hands out entity ids and removes despawned entities from every store
+type World struct {
+	next   Entity
+	alive  int
+	stores []remover
+}
+
+type remover interface {
+	Remove(e Entity)
+}
+
+// NewWorld returns an empty world
+func NewWorld() *World {
+	return &World{next: 1}
+}
+
+// Spawn creates a new entity without any components
+func (w *World) Spawn() Entity {
+	e := w.next
+	w.next++
+	w.alive++
+	return e
+}
+
+// Despawn removes e and all of its components
+func (w *World) Despawn(e Entity) {
+	for _, s := range w.stores {
+		s.Remove(e)
+	}
+	w.alive--
+}
+
+// Alive returns the number of entities that have been spawned and not despawned
+func (w *World) Alive() int {
+	return w.alive
+}
+
+// Store holds one component type for any number of entities. Components are kept in a
+// dense slice so iterating them is cache friendly; removal swaps the last element into
+// the hole, so iteration order is deterministic but not insertion order after removals.
+//
+// Pointers returned by Add, Get and At are only valid until the next Add or Remove.
+type Store[T any] struct {
+	values   []T
+	entities []Entity
+	index    map[Entity]int
+}
+
+// NewStore creates a store for T that is cleaned up when entities despawn from w
+func NewStore[T any](w *World) *Store[T] {
+	s := &Store[T]{index: map[Entity]int{}}
+	w.stores = append(w.stores, s)
+	return s
+}
+
+// Add sets the component of e to v, replacing any existing value
+func (s *Store[T]) Add(e Entity, v T) *T {
+	if i, ok := s.index[e]; ok {
+		s.values[i] = v
+		return &s.values[i]
+	}
+	s.index[e] = len(s.values)
+	s.values = append(s.values, v)
+	s.entities = append(s.entities, e)
+	return &s.values[len(s.values)-1]
+}
+
+// Get returns the component of e, or nil if e doesn't have one
+func (s *Store[T]) Get(e Entity) *T {
+	if i, ok := s.index[e]; ok {
+		return &s.values[i]
+	}
+	return nil
+}
+
+// Has reports whether e has this component
+func (s *Store[T]) Has(e Entity) bool {
+	_, ok := s.index[e]
+	return ok
+}
+
+// Remove deletes the component of e, if any
+func (s *Store[T]) Remove(e Entity) {
+	i, ok := s.index[e]
+	if !ok {
+		return
+	}
+	last := len(s.values) - 1
+	if i != last {
+		s.values[i] = s.values[last]
+		s.entities[i] = s.entities[last]
+		s.index[s.entities[i]] = i
+	}
+	var zero T
+	s.values[last] = zero
+	s.values = s.values[:last]
+	s.entities = s.entities[:last]
+	delete(s.index, e)
+}
+
+// Len returns the number of entities with this component
+func (s *Store[T]) Len() int {
+	return len(s.values)
+}
+
+// At returns the i-th entity and its component in iteration order
+func (s *Store[T]) At(i int) (Entity, *T) {
+	return s.entities[i], &s.values[i]
+}
+
+// Entities returns the entities with this component in iteration order. The slice is
+// owned by the store and must not be modified.
+func (s *Store[T]) Entities() []Entity {
+	return s.entities
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mlctrez/donut/ecs"
+)
+
+// edgeMode is what happens to a donut reaching a screen edge
+type edgeMode string
+
+const (
+	edgeBounce edgeMode = "bounce" // Bounce back, the default
+	edgeWrap   edgeMode = "wrap"   // Leave and come back in through the opposite edge
+	edgeAbsorb edgeMode = "absorb" // Vanish on contact and respawn where the spawner puts it
+	edgeOpen   edgeMode = "open"   // Leave, while a donut enters through another open edge
+)
+
+// Screen edges, indexes into Game.edges
+const (
+	edgeLeft = iota
+	edgeRight
+	edgeTop
+	edgeBottom
+)
+
+// edgesConfig sets the mode of each screen edge, bounce when empty. Leaving the top and
+// bottom open with gravity makes donuts rain from the top.
+type edgesConfig struct {
+	Left   edgeMode `json:"left"`
+	Right  edgeMode `json:"right"`
+	Top    edgeMode `json:"top"`
+	Bottom edgeMode `json:"bottom"`
+}
+
+// modes returns the mode of each edge in edge index order, with the defaults filled in
+func (c edgesConfig) modes() ([4]edgeMode, error) {
+	modes := [4]edgeMode{c.Left, c.Right, c.Top, c.Bottom}
+	for i, m := range modes {
+		switch m {
+		case "":
+			modes[i] = edgeBounce
+		case edgeBounce, edgeWrap, edgeAbsorb, edgeOpen:
+		default:
+			return modes, fmt.Errorf("unknown edge mode %q, want bounce, wrap, absorb or open", m)
+		}
+	}
+	return modes, nil
+}
+
+// edgeSystem applies the edge modes to an entity touching or past the screen edges.
+// Entities only react to an edge they're moving towards, so donuts entering from outside
+// aren't sent back.
+func (g *Game) edgeSystem(e ecs.Entity, pos *position, vel *velocity, spr *sprite) {
+	width, height := float64(g.screenWidth), float64(g.screenHeight)
+	switch {
+	case pos.x <= 0 && vel.x <= 0:
+		g.hitEdge(e, edgeLeft, pos, vel, spr)
+	case pos.x >= width-spr.width && vel.x >= 0:
+		g.hitEdge(e, edgeRight, pos, vel, spr)
+	}
+	switch {
+	case pos.y <= 0 && vel.y <= 0:
+		g.hitEdge(e, edgeTop, pos, vel, spr)
+	case pos.y >= height-spr.height && vel.y >= 0:
+		g.hitEdge(e, edgeBottom, pos, vel, spr)
+	}
+}
+
+// hitEdge handles an entity touching the given edge
+func (g *Game) hitEdge(e ecs.Entity, edge int, pos *position, vel *velocity, spr *sprite) {
+	width, height := float64(g.screenWidth), float64(g.screenHeight)
+	horizontal := edge == edgeLeft || edge == edgeRight
+	outside := edge == edgeLeft && pos.x <= -spr.width || edge == edgeRight && pos.x >= width ||
+		edge == edgeTop && pos.y <= -spr.height || edge == edgeBottom && pos.y >= height
+
+	switch g.edges[edge] {
+	case edgeBounce:
+		if horizontal {
+			g.addSquash(e, 1, 0, math.Abs(vel.x))
+			g.addShake(math.Abs(vel.x))
+			vel.x = -vel.x
+			pos.x = max(0, min(width-spr.width, pos.x))
+		} else {
+			g.addSquash(e, 0, 1, math.Abs(vel.y))
+			g.addShake(math.Abs(vel.y))
+			vel.y = -vel.y
+			pos.y = max(0, min(height-spr.height, pos.y))
+		}
+	case edgeWrap:
+		if !outside {
+			return
+		}
+		switch edge {
+		case edgeLeft:
+			pos.x = width
+		case edgeRight:
+			pos.x = -spr.width
+		case edgeTop:
+			pos.y = height
+		case edgeBottom:
+			pos.y = -spr.height
+		}
+	case edgeAbsorb:
+		g.spawnFirework(pos.x+spr.width/2, pos.y+spr.height/2, 12)
+		g.replaceDonut(e, pos, vel, spr, -1)
+	case edgeOpen:
+		if !outside {
+			return
+		}
+		g.replaceDonut(e, pos, vel, spr, g.entryEdge(edge))
+	}
+}
+
+// entryEdge returns a random open edge other than exit for a donut to enter through, or
+// -1 when there is none
+func (g *Game) entryEdge(exit int) int {
+	var open [4]int
+	n := 0
+	for edge, m := range g.edges {
+		if m == edgeOpen && edge != exit {
+			open[n] = edge
+			n++
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return open[g.rng.Intn(n)]
+}
+
+// replaceDonut turns e into a new donut with a fresh velocity, keeping its sprite, layer
+// and label. It enters from outside the entry edge, or is placed by the spawner when entry
+// is -1.
+func (g *Game) replaceDonut(e ecs.Entity, pos *position, vel *velocity, spr *sprite, entry int) {
+	width, height := float64(g.screenWidth), float64(g.screenHeight)
+	d := createDonuts(g.rng, g.spawner, g.screenWidth, g.screenHeight, spr.width, spr.height, 1, g.minSpeed, g.maxSpeed, g.minSpin, g.maxSpin)[0]
+	speed := depthLayers[g.world.layerOf(e)].speed
+	g.world.donutChanges++
+	pos.x, pos.y = d.x, d.y
+	vel.x, vel.y = d.vx*speed, d.vy*speed
+
+	along := g.rng.Float64()
+	switch entry {
+	case edgeLeft:
+		pos.x, pos.y, vel.x = -spr.width, along*(height-spr.height), math.Abs(vel.x)
+	case edgeRight:
+		pos.x, pos.y, vel.x = width, along*(height-spr.height), -math.Abs(vel.x)
+	case edgeTop:
+		pos.x, pos.y, vel.y = along*(width-spr.width), -spr.height, math.Abs(vel.y)
+	case edgeBottom:
+		pos.x, pos.y, vel.y = along*(width-spr.width), height, -math.Abs(vel.y)
+	}
+}
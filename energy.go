@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// energyMode is what the energy monitor does about drift
+type energyMode string
+
+const (
+	energyOff     energyMode = "off"
+	energyWarn    energyMode = "warn"    // Log when the energy drifted
+	energyCorrect energyMode = "correct" // Scale the velocities back to the baseline energy
+)
+
+const (
+	energyWarnDrift    = 0.05  // Drift that is logged, relative to the baseline
+	energyCorrectDrift = 0.005 // Drift that is corrected, smaller ones are left alone
+	energyWarnInterval = time.Minute
+)
+
+var energyFlag = runFlags.String("energy", string(energyOff), "watch the kinetic energy of the donuts for drift: off, warn to log it or correct to scale the velocities back")
+
+// parseEnergyMode validates the -energy flag
+func parseEnergyMode(s string) (energyMode, error) {
+	switch m := energyMode(s); m {
+	case energyOff, energyWarn, energyCorrect:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown energy mode %q, want off, warn or correct", s)
+}
+
+// energyMonitor compares the kinetic energy of the donuts to the energy they had when
+// they were created. Collisions between donuts of equal mass should keep it, but the
+// overlap correction and floating point errors make it creep over long runs. The baseline
+// is measured again whenever a donut is spawned, removed or replaced, since new donuts
+// bring velocities of their own.
+type energyMonitor struct {
+	mode     energyMode
+	baseline float64 // Average energy per donut, 0 until measured
+	changes  int     // world.donutChanges when the baseline was measured
+	warned   time.Time
+}
+
+// rebase measures the baseline again on the next frame, after the donuts were recreated
+// or their velocities changed on purpose
+func (m *energyMonitor) rebase() {
+	m.baseline = 0
+}
+
+// conservesEnergy reports whether nothing but collisions changes the donuts' velocities,
+// so the energy is expected to stay the same
+func (g *Game) conservesEnergy() bool {
+	w := g.world
+	return g.gravity == 0 && g.behaviorName == defaultBehavior && !g.magnets && g.field.live == 0 &&
+		w.vortices.Len() == 0 && w.springs.Len() == 0 && g.script == nil && g.scoreboard == ""
+}
+
+// meanEnergy returns the average kinetic energy of the donuts, with unit mass
+func (g *Game) meanEnergy() float64 {
+	w := g.world
+	if w.donuts.Len() == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range w.donuts.Entities() {
+		vel := w.velocities.Get(e)
+		sum += (vel.x*vel.x + vel.y*vel.y) / 2
+	}
+	return sum / float64(w.donuts.Len())
+}
+
+// energySystem warns about or corrects drift of the kinetic energy
+func (g *Game) energySystem() {
+	m := &g.energy
+	if m.mode == "" || m.mode == energyOff {
+		return
+	}
+	if !g.conservesEnergy() {
+		m.rebase()
+		return
+	}
+	energy := g.meanEnergy()
+	if m.baseline == 0 || m.changes != g.world.donutChanges {
+		m.baseline, m.changes = energy, g.world.donutChanges
+		return
+	}
+	drift := energy/m.baseline - 1
+
+	switch {
+	case m.mode == energyCorrect && math.Abs(drift) > energyCorrectDrift && energy > 0:
+		scale := math.Sqrt(m.baseline / energy)
+		for _, e := range g.world.donuts.Entities() {
+			vel := g.world.velocities.Get(e)
+			vel.x, vel.y = vel.x*scale, vel.y*scale
+		}
+	case m.mode == energyWarn && math.Abs(drift) > energyWarnDrift && time.Since(m.warned) >= energyWarnInterval:
+		m.warned = time.Now()
+		slog.Warn("kinetic energy drifted", "drift", fmt.Sprintf("%+.1f%%", drift*100), "frame", g.frame,
+			"seconds", g.frame/ebiten.TPS())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	fieldCellSize   = 48   // Width and height of a force field cell in pixels
+	fieldLifeFrames = 3600 // Frames at 60 per second until painted arrows fade away
+	fieldPerPixel   = 0.01 // Force painted per pixel the mouse moves
+	maxFieldForce   = 0.3  // Strongest push of a cell in pixels per frame²
+)
+
+// fieldStroke is one mouse movement painting the force field, recorded in replays
+type fieldStroke struct {
+	X  float64 `json:"x"` // Simulation position of the mouse
+	Y  float64 `json:"y"`
+	DX float64 `json:"dx"` // Movement since the previous stroke
+	DY float64 `json:"dy"`
+}
+
+// fieldCell is the push of one cell, fading as its life runs out
+type fieldCell struct {
+	x, y float64 // Force in pixels per frame² at full life
+	life float64 // 1 when just painted, 0 when faded
+}
+
+// forceField is a grid of arrows painted with the mouse that push the donuts passing
+// through them. G toggles painting, the arrows fade over a minute.
+type forceField struct {
+	painting     bool // Dragging with the left button paints
+	dragging     bool
+	lastX, lastY float64 // Simulation position of the previous stroke
+	cols, rows   int
+	cells        []fieldCell
+	live         int // Cells with life left, the field is skipped when 0
+}
+
+// pollField toggles painting on G and returns the strokes of a left button drag
+func (g *Game) pollField(events []replayEvent) []replayEvent {
+	f := &g.field
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		f.painting = !f.painting
+	}
+	if !f.painting || !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		f.dragging = false
+		return events
+	}
+	x, y := g.camera.toWorld(cursorPosition())
+	if f.dragging && (x != f.lastX || y != f.lastY) {
+		stroke := &fieldStroke{X: x, Y: y, DX: x - f.lastX, DY: y - f.lastY}
+		events = append(events, replayEvent{Frame: g.frame, Action: actionPaintField, Stroke: stroke})
+	}
+	f.dragging, f.lastX, f.lastY = true, x, y
+	return events
+}
+
+// paint adds a stroke to the cell under it and, more weakly, to its neighbors
+func (f *forceField) paint(s fieldStroke, screenWidth, screenHeight int) {
+	f.resize(screenWidth, screenHeight)
+	col, row := int(s.X/fieldCellSize), int(s.Y/fieldCellSize)
+	for r := max(0, row-1); r <= min(f.rows-1, row+1); r++ {
+		for c := max(0, col-1); c <= min(f.cols-1, col+1); c++ {
+			weight := 1.0
+			if r != row || c != col {
+				weight = 0.4
+			}
+			cell := &f.cells[r*f.cols+c]
+			if cell.life <= 0 {
+				cell.x, cell.y = 0, 0
+				f.live++
+			}
+			cell.x += s.DX * fieldPerPixel * weight
+			cell.y += s.DY * fieldPerPixel * weight
+			if force := math.Hypot(cell.x, cell.y); force > maxFieldForce {
+				cell.x, cell.y = cell.x/force*maxFieldForce, cell.y/force*maxFieldForce
+			}
+			cell.life = 1
+		}
+	}
+}
+
+// resize empties the field when the screen size changed
+func (f *forceField) resize(screenWidth, screenHeight int) {
+	cols := (screenWidth + fieldCellSize - 1) / fieldCellSize
+	rows := (screenHeight + fieldCellSize - 1) / fieldCellSize
+	if cols != f.cols || rows != f.rows {
+		f.cols, f.rows = cols, rows
+		f.cells = make([]fieldCell, cols*rows)
+		f.live = 0
+	}
+}
+
+// fieldSystem pushes the donuts with the cell under their center and fades the cells
+func (g *Game) fieldSystem() {
+	f := &g.field
+	if f.live == 0 {
+		return
+	}
+	f.resize(g.screenWidth, g.screenHeight)
+	w := g.world
+	for _, e := range w.donuts.Entities() {
+		pos, vel, spr := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e)
+		col := int((pos.x + spr.width/2) / fieldCellSize)
+		row := int((pos.y + spr.height/2) / fieldCellSize)
+		if col < 0 || col >= f.cols || row < 0 || row >= f.rows {
+			continue
+		}
+		if cell := f.cells[row*f.cols+col]; cell.life > 0 {
+			vel.x += cell.x * cell.life * g.step
+			vel.y += cell.y * cell.life * g.step
+		}
+	}
+
+	fade := g.step / fieldLifeFrames
+	for i := range f.cells {
+		cell := &f.cells[i]
+		if cell.life > 0 {
+			cell.life -= fade
+			if cell.life <= 0 {
+				f.live--
+			}
+		}
+	}
+}
+
+// draw draws an arrow for every live cell, fading with it
+func (f *forceField) draw(dst *ebiten.Image) {
+	if f.live == 0 {
+		return
+	}
+	for i, cell := range f.cells {
+		if cell.life <= 0 {
+			continue
+		}
+		force := math.Hypot(cell.x, cell.y)
+		if force == 0 {
+			continue
+		}
+		// Arrows are as long as a cell at the strongest force
+		length := float32(force / maxFieldForce * fieldCellSize * 0.8)
+		dx, dy := float32(cell.x/force), float32(cell.y/force)
+		cx := float32(i%f.cols)*fieldCellSize + fieldCellSize/2
+		cy := float32(i/f.cols)*fieldCellSize + fieldCellSize/2
+		x0, y0 := cx-dx*length/2, cy-dy*length/2
+		x1, y1 := cx+dx*length/2, cy+dy*length/2
+		a := uint8(160 * cell.life)
+		clr := color.RGBA{a, a, a, a}
+		strokeLine(dst, x0, y0, x1, y1, 2, clr, true)
+		// Arrow head
+		head := min(length/2, 8)
+		strokeLine(dst, x1, y1, x1-(dx+dy*0.6)*head, y1-(dy-dx*0.6)*head, 2, clr, true)
+		strokeLine(dst, x1, y1, x1-(dx-dy*0.6)*head, y1-(dy+dx*0.6)*head, 2, clr, true)
+	}
+}
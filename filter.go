@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+var (
+	filterFlag    = runFlags.String("filter", "", "sprite filtering: linear or nearest, linear by default")
+	antialiasFlag = runFlags.Bool("antialias", false, "draw the donuts at twice the resolution and scale them down, smoothing rotated edges")
+)
+
+const (
+	supersampling       = 2    // Resolution multiplier of the anti-aliased donut layer
+	maxSupersampledSize = 8192 // Larger layers, past common GPU texture limits, aren't anti-aliased
+)
+
+// parseFilter parses the name of a sprite filter. Linear filtering also uses mipmaps when
+// sprites are drawn smaller than they are, which keeps rotated donuts smooth at donutScale.
+func parseFilter(s string) (ebiten.Filter, error) {
+	switch s {
+	case "", "linear":
+		return ebiten.FilterLinear, nil
+	case "nearest":
+		return ebiten.FilterNearest, nil
+	}
+	return 0, fmt.Errorf("invalid filter %q, want linear or nearest", s)
+}
+
+// setRendererFilter makes r and the renderers it wraps scale sprites with filter. Plugin
+// renderers opt in with a SetFilter(ebiten.Filter) method.
+func setRendererFilter(r plugin.Renderer, filter ebiten.Filter) {
+	switch r := r.(type) {
+	case *batchRenderer:
+		r.op.Filter = filter
+		setRendererFilter(r.fallback, filter)
+	case *rotationCache:
+		r.op.Filter = filter
+		setRendererFilter(r.fallback, filter)
+	case interface{ SetFilter(ebiten.Filter) }:
+		r.SetFilter(filter)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// monoAdvance is the advance of every Go Mono glyph in ems. Faces are sized so glyphs
+	// are baseFontWidth wide at scale 1 like basicfont, keeping layouts measured in
+	// characters as they were.
+	monoAdvance = 0.6
+
+	faceSizeSteps  = 4  // Faces are cached per quarter pixel of size
+	maxCachedFaces = 32 // Faces kept before the cache is emptied
+	minGlyphScale  = 1  // Up to this scale the bitmap font is sharper than a tiny vector face
+)
+
+// fonts rasterizes Go Mono at the size text is drawn at, instead of scaling up the 7x13
+// bitmap font, so large text like the timer stays sharp. It's only used on the game loop.
+var fonts struct {
+	mono     *opentype.Font
+	fallback []*opentype.Font // For characters Go Mono doesn't have, see setupFallbackFonts
+	faces    map[int]font.Face
+}
+
+func init() {
+	var err error
+	if fonts.mono, err = opentype.Parse(gomono.TTF); err != nil {
+		slog.Error("failed to parse the font, using the bitmap font", "err", err)
+	}
+	fonts.faces = map[int]font.Face{}
+}
+
+// faceFor returns a face drawing text at scale times the base font size, and the scale
+// left to apply to what it draws. The bitmap font is used for ASCII at scale 1 and below,
+// or when the vector font is missing.
+func faceFor(scale float64, ascii bool) (font.Face, float64) {
+	if fonts.mono == nil || (ascii && scale <= minGlyphScale) {
+		return basicfont.Face7x13, scale
+	}
+	key := int(math.Round(scale * baseFontWidth / monoAdvance * faceSizeSteps))
+	if face, ok := fonts.faces[key]; ok {
+		return face, 1
+	}
+	if len(fonts.faces) >= maxCachedFaces {
+		clear(fonts.faces)
+	}
+	options := &opentype.FaceOptions{
+		Size:    float64(key) / faceSizeSteps,
+		DPI:     72, // Size in pixels
+		Hinting: font.HintingFull,
+	}
+	face, err := opentype.NewFace(fonts.mono, options)
+	if err != nil {
+		slog.Error("failed to create a font face, using the bitmap font", "err", err)
+		fonts.mono = nil
+		return basicfont.Face7x13, scale
+	}
+	if len(fonts.fallback) > 0 {
+		faces := fallbackFace{face}
+		for _, f := range fonts.fallback {
+			if face, err := opentype.NewFace(f, options); err == nil {
+				faces = append(faces, face)
+			}
+		}
+		face = faces
+	}
+	fonts.faces[key] = face
+	return face, 1
+}
+
+// textWidth returns the width of str drawn with drawText at scale. Go Mono and basicfont
+// are monospaced, so only text with other characters is measured.
+func textWidth(str string, scale float64) float64 {
+	if isASCII(str) {
+		return float64(len(str)*baseFontWidth) * scale
+	}
+	// Measured with the face it's drawn with
+	face, rest := faceFor(scale*pixelScale, false)
+	if face == basicfont.Face7x13 {
+		return float64(utf8.RuneCountInString(str)*baseFontWidth) * rest / pixelScale
+	}
+	return float64(font.MeasureString(face, str)) / 64 / pixelScale
+}
+
+func isASCII(str string) bool {
+	for i := range len(str) {
+		if str[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// systemFonts are fonts with wide Unicode coverage commonly found on each OS, tried for
+// characters missing from Go Mono when no fonts are configured
+var systemFonts = map[string][]string{
+	"linux": {
+		"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	},
+	"darwin": {
+		"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+		"/System/Library/Fonts/Hiragino Sans GB.ttc",
+	},
+	"windows": {
+		`C:\Windows\Fonts\msgothic.ttc`,
+		`C:\Windows\Fonts\seguisym.ttf`,
+	},
+}
+
+// setupFallbackFonts loads the fonts tried for characters missing from Go Mono. Without
+// configured paths the system fonts are searched when search is set, which is when a
+// translation is in use.
+func setupFallbackFonts(paths []string, search bool) {
+	configured := len(paths) > 0
+	if !configured && search {
+		paths = systemFonts[runtime.GOOS]
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if configured || !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("failed to read the font", "path", path, "err", err)
+			}
+			continue
+		}
+		f, err := opentype.Parse(data)
+		if err != nil {
+			// Collections hold several fonts, the first is the regular one
+			var c *opentype.Collection
+			if c, err = opentype.ParseCollection(data); err == nil {
+				f, err = c.Font(0)
+			}
+		}
+		if err != nil {
+			slog.Warn("invalid font", "path", path, "err", err)
+			continue
+		}
+		slog.Debug("loaded fallback font", "path", path)
+		fonts.fallback = append(fonts.fallback, f)
+	}
+	clear(fonts.faces)
+}
+
+// fallbackFace draws each character with the first face that has it
+type fallbackFace []font.Face
+
+func (f fallbackFace) pick(r rune) font.Face {
+	for _, face := range f {
+		if _, ok := face.GlyphAdvance(r); ok {
+			return face
+		}
+	}
+	return f[0]
+}
+
+func (f fallbackFace) Close() error { return nil }
+
+func (f fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.pick(r).Glyph(dot, r)
+}
+
+func (f fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.pick(r).GlyphBounds(r)
+}
+
+func (f fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.pick(r).GlyphAdvance(r)
+}
+
+func (f fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	if face := f.pick(r0); face == f.pick(r1) {
+		return face.Kern(r0, r1)
+	}
+	return 0
+}
+
+func (f fallbackFace) Metrics() font.Metrics { return f[0].Metrics() }
+
+// DrawText draws str with its top left corner at x, y at scale times the base font size,
+// for plugins drawing text the same way as the HUD
+func (g *Game) DrawText(dst *ebiten.Image, str string, x, y, scale float64, clr color.Color) {
+	drawText(dst, str, x, y, scale, clr)
+}
+
+// drawGlyphs draws str with its top left corner at x, y, at scale times the base font
+// size, keeping the line box of the bitmap font so callers can lay text out the same way.
+// Glyphs are rasterized at the screen's pixelScale.
+func drawGlyphs(dst *ebiten.Image, str string, x, y, scale float64, op *ebiten.DrawImageOptions, clr color.Color) {
+	x, y, scale = x*pixelScale, y*pixelScale, scale*pixelScale
+	face, rest := faceFor(scale, isASCII(str))
+	op.GeoM.Reset()
+	op.ColorScale.Reset()
+	if face == basicfont.Face7x13 {
+		op.GeoM.Translate(0, baseFontHeight-3) // basicfont draws relative to the baseline
+		op.GeoM.Scale(rest, rest)
+	} else {
+		// Center the glyphs vertically in the line box
+		m := face.Metrics()
+		ascent, height := float64(m.Ascent)/64, float64(m.Ascent+m.Descent)/64
+		op.GeoM.Translate(0, ascent+(baseFontHeight*scale-height)/2)
+	}
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(clr)
+	text.DrawWithOptions(dst, str, face, op)
+}
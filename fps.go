@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// fpsCaps are the frame rates the F key cycles through
+var fpsCaps = []int{30, 60, 120, 144}
+
+var (
+	fpsFlag   = runFlags.Int("fps", 0, "frames per second, 60 by default, donuts keep their speed at any rate")
+	vsyncFlag = runFlags.String("vsync", "", "sync frames with the display: on or off, on by default")
+	resFlag   = runFlags.String("resolution", "", "fixed simulation size like 1920x1080, scaled to fit the window, the window size by default")
+)
+
+// displayConfig sets the frame rate, see the -fps and -vsync flags
+type displayConfig struct {
+	FPS   int   `json:"fps"`
+	VSync *bool `json:"vsync"`
+
+	// Resolution is a fixed size of the simulation like "1920x1080", scaled to fit the
+	// window with black bars so resizing the window leaves the donuts alone. The
+	// simulation is the size of the window when empty. See the -resolution flag.
+	Resolution string `json:"resolution"`
+
+	// Filter scales sprites with "linear" filtering, the default, or "nearest" for hard
+	// pixel edges. Antialias supersamples the donuts. See the -filter and -antialias flags.
+	Filter    string `json:"filter"`
+	Antialias bool   `json:"antialias"`
+}
+
+// setupDisplay applies the frame rate and vsync settings of cfg and the flags, which take
+// precedence. Frames are only drawn after an update, so the cap applies to both.
+func (g *Game) setupDisplay(cfg displayConfig) error {
+	vsync := cfg.VSync == nil || *cfg.VSync
+	switch *vsyncFlag {
+	case "":
+	case "on":
+		vsync = true
+	case "off":
+		vsync = false
+	default:
+		return fmt.Errorf("invalid -vsync %q, want on or off", *vsyncFlag)
+	}
+	fps := cfg.FPS
+	if *fpsFlag != 0 {
+		fps = *fpsFlag
+	}
+	if fps < 0 || fps > 1000 {
+		return fmt.Errorf("invalid frame rate %d", fps)
+	}
+	if res := cmp.Or(*resFlag, cfg.Resolution); res != "" && g.replay == nil {
+		w, h, err := parseResolution(res)
+		if err != nil {
+			return err
+		}
+		g.screenWidth, g.screenHeight, g.fixedSize = w, h, true
+	}
+
+	filter, err := parseFilter(cmp.Or(*filterFlag, cfg.Filter))
+	if err != nil {
+		return err
+	}
+	g.filter, g.antialias = filter, *antialiasFlag || cfg.Antialias
+
+	ebiten.SetVsyncEnabled(vsync)
+	ebiten.SetScreenClearedEveryFrame(false)
+	g.setFPS(cmp.Or(fps, ebiten.DefaultTPS))
+	return nil
+}
+
+// parseResolution parses a WIDTHxHEIGHT size
+func parseResolution(s string) (width, height int, err error) {
+	if _, err := fmt.Sscanf(s, "%dx%d", &width, &height); err != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("invalid resolution %q, want WIDTHxHEIGHT like 1920x1080", s)
+	}
+	return width, height, nil
+}
+
+// setFPS changes the tick rate. Velocities are in pixels per frame at 60 frames per second,
+// so the movement step is scaled to keep their speed on screen.
+func (g *Game) setFPS(fps int) {
+	g.fps = fps
+	g.applyTPS()
+}
+
+// ticks converts a number of frames at 60 frames per second to updates at the current tick
+// rate, so animations, lifetimes and timers counted in frames last as long at any rate
+func ticks(frames int) int {
+	return max(1, frames*ebiten.TPS()/ebiten.DefaultTPS)
+}
+
+// cycleFPS switches to the next cap in fpsCaps when F is pressed. Recordings and replays
+// keep their frame rate, the simulation depends on it.
+func (g *Game) cycleFPS() {
+	if g.inputLocked || !inpututil.IsKeyJustPressed(ebiten.KeyF) || g.recorder != nil || g.replay != nil {
+		return
+	}
+	i := slices.Index(fpsCaps, g.fps)
+	g.setFPS(fpsCaps[(i+1)%len(fpsCaps)])
+	slog.Info("frame rate changed", "fps", g.fps)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+const (
+	defaultGlyph   = "🍩"
+	glyphFill      = 0.9 // Share of the sprite the glyph fills, leaving room for antialiasing
+	glyphLoadPPEM  = 256 // Size outlines are loaded at before they're fitted to the sprite
+	foregroundSlot = 0xffff
+)
+
+var glyphFlag = runFlags.String("glyph", "", "bounce this character or emoji instead of the image, e.g. 🍩")
+
+// glyphConfig bounces a character or emoji rendered from a font instead of the image
+type glyphConfig struct {
+	Text  string    `json:"text"`  // The character, 🍩 by default. Only the first one is used.
+	Font  string    `json:"font"`  // Font file, color emoji fonts included. System emoji fonts by default.
+	Color *hexColor `json:"color"` // Color of glyphs without colors of their own, white by default
+}
+
+// emojiFonts are the color emoji fonts of each OS, tried when no font is configured.
+// Go Regular comes after them for plain characters.
+var emojiFonts = map[string][]string{
+	"linux": {
+		"/usr/share/fonts/truetype/noto/NotoColorEmoji.ttf",
+		"/usr/share/fonts/noto/NotoColorEmoji.ttf",
+		"/usr/share/fonts/google-noto-emoji/NotoColorEmoji.ttf",
+		"/usr/share/fonts/noto-emoji/NotoColorEmoji.ttf",
+		"/usr/share/fonts/truetype/twemoji/TwitterColorEmoji-SVGinOT.ttf",
+	},
+	"darwin":  {"/System/Library/Fonts/Apple Color Emoji.ttc"},
+	"windows": {`C:\Windows\Fonts\seguiemj.ttf`},
+}
+
+// glyphSprite renders the configured character as a sprite proceduralSpriteSize wide,
+// from the first font that has it. Color emoji are read from CBDT and sbix bitmaps or
+// COLR layers, other glyphs are filled with the configured color.
+func glyphSprite(cfg glyphConfig) (*ebiten.Image, error) {
+	text := cmp.Or(cfg.Text, defaultGlyph)
+	r := []rune(text)[0]
+	if len([]rune(text)) > 1 {
+		slog.Warn("only the first character of the glyph is used", "glyph", text)
+	}
+	fill := color.Color(color.White)
+	if cfg.Color != nil {
+		fill = *cfg.Color
+	}
+
+	paths := emojiFonts[runtime.GOOS]
+	if cfg.Font != "" {
+		paths = []string{cfg.Font}
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if cfg.Font != "" {
+				return nil, err
+			}
+			continue
+		}
+		img, err := renderGlyph(data, r, fill)
+		if err == nil {
+			slog.Info("rendered the glyph", "glyph", string(r), "font", path)
+			return ebiten.NewImageFromImage(img), nil
+		}
+		slog.Debug("font can't render the glyph", "font", path, "err", err)
+	}
+	img, err := renderGlyph(goregular.TTF, r, fill)
+	if err != nil {
+		return nil, fmt.Errorf("no font has %q", string(r))
+	}
+	return ebiten.NewImageFromImage(img), nil
+}
+
+// renderGlyph renders r from the font or font collection in data
+func renderGlyph(data []byte, r rune, fill color.Color) (image.Image, error) {
+	var faces []*sfnt.Font
+	if c, err := sfnt.ParseCollection(data); err == nil {
+		for i := range c.NumFonts() {
+			if f, err := c.Font(i); err == nil {
+				faces = append(faces, f)
+			}
+		}
+	} else if f, err := sfnt.Parse(data); err == nil {
+		faces = append(faces, f)
+	} else {
+		return nil, err
+	}
+
+	var buf sfnt.Buffer
+	for i, f := range faces {
+		index, err := f.GlyphIndex(&buf, r)
+		if err != nil || index == 0 {
+			continue
+		}
+		tables, err := fontTables(data, i)
+		if err != nil {
+			return nil, err
+		}
+		if png := bitmapGlyph(tables, f, uint16(index)); png != nil {
+			return fitBitmap(png)
+		}
+		layers := colorLayers(tables, uint16(index), fill)
+		if layers == nil {
+			layers = []glyphLayer{{index, fill}}
+		}
+		return rasterize(f, &buf, layers)
+	}
+	return nil, errors.New("glyph not in the font")
+}
+
+// fontTables returns the tables of the font at index in data, which is a collection when
+// it starts with ttcf
+func fontTables(data []byte, index int) (map[string][]byte, error) {
+	offset := 0
+	if bytes.HasPrefix(data, []byte("ttcf")) {
+		at := 12 + 4*index
+		if len(data) < at+4 {
+			return nil, errors.New("invalid font collection")
+		}
+		offset = int(binary.BigEndian.Uint32(data[at:]))
+	}
+	if len(data) < offset+12 {
+		return nil, errors.New("invalid font")
+	}
+	tables := map[string][]byte{}
+	n := int(binary.BigEndian.Uint16(data[offset+4:]))
+	for i := range n {
+		rec := offset + 12 + 16*i
+		if len(data) < rec+16 {
+			return nil, errors.New("invalid font")
+		}
+		start := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if start+length <= len(data) {
+			tables[string(data[rec:rec+4])] = data[start : start+length]
+		}
+	}
+	return tables, nil
+}
+
+// bitmapGlyph returns the largest PNG of the glyph in the CBDT or sbix tables, nil when
+// the font has none
+func bitmapGlyph(tables map[string][]byte, f *sfnt.Font, index uint16) image.Image {
+	var data []byte
+	if cblc, cbdt := tables["CBLC"], tables["CBDT"]; cblc != nil && cbdt != nil {
+		data = cbdtPNG(cblc, cbdt, index)
+	} else if sbix := tables["sbix"]; sbix != nil {
+		data = sbixPNG(sbix, f.NumGlyphs(), index)
+	}
+	if data == nil {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("invalid emoji bitmap", "err", err)
+		return nil
+	}
+	return img
+}
+
+// cbdtPNG finds the glyph in the strike with the most pixels per em. Index subtable formats
+// 1, 3 and 4 and the PNG image formats 17, 18 and 19 are supported.
+func cbdtPNG(cblc, cbdt []byte, glyph uint16) []byte {
+	be := binary.BigEndian
+	if len(cblc) < 8 {
+		return nil
+	}
+	var best []byte
+	bestPPEM := -1
+	for size := range int(be.Uint32(cblc[4:])) {
+		rec := 8 + 48*size
+		if len(cblc) < rec+48 {
+			break
+		}
+		arrayOffset := int(be.Uint32(cblc[rec:]))
+		subtables := int(be.Uint32(cblc[rec+8:]))
+		ppem := int(cblc[rec+44])
+		if ppem <= bestPPEM || glyph < be.Uint16(cblc[rec+40:]) || glyph > be.Uint16(cblc[rec+42:]) {
+			continue
+		}
+		for i := range subtables {
+			entry := arrayOffset + 8*i
+			if len(cblc) < entry+8 {
+				break
+			}
+			first, last := be.Uint16(cblc[entry:]), be.Uint16(cblc[entry+2:])
+			if glyph < first || glyph > last {
+				continue
+			}
+			sub := arrayOffset + int(be.Uint32(cblc[entry+4:]))
+			if len(cblc) < sub+8 {
+				break
+			}
+			indexFormat, imageFormat := be.Uint16(cblc[sub:]), be.Uint16(cblc[sub+2:])
+			base := int(be.Uint32(cblc[sub+4:]))
+			offset := -1
+			k := int(glyph - first)
+			switch indexFormat {
+			case 1:
+				if at := sub + 8 + 4*k; len(cblc) >= at+4 {
+					offset = int(be.Uint32(cblc[at:]))
+				}
+			case 3:
+				if at := sub + 8 + 2*k; len(cblc) >= at+2 {
+					offset = int(be.Uint16(cblc[at:]))
+				}
+			case 4:
+				if len(cblc) >= sub+12 {
+					for j := range int(be.Uint32(cblc[sub+8:])) {
+						if at := sub + 12 + 4*j; len(cblc) >= at+4 && be.Uint16(cblc[at:]) == glyph {
+							offset = int(be.Uint16(cblc[at+2:]))
+						}
+					}
+				}
+			}
+			if offset < 0 {
+				continue
+			}
+			at := base + offset
+			switch imageFormat {
+			case 17:
+				at += 5 // Small metrics
+			case 18:
+				at += 8 // Big metrics
+			case 19:
+			default:
+				continue
+			}
+			if len(cbdt) < at+4 {
+				continue
+			}
+			n := int(be.Uint32(cbdt[at:]))
+			if len(cbdt) >= at+4+n {
+				best, bestPPEM = cbdt[at+4:at+4+n], ppem
+			}
+		}
+	}
+	return best
+}
+
+// sbixPNG finds the glyph in the strike with the most pixels per em
+func sbixPNG(sbix []byte, numGlyphs int, glyph uint16) []byte {
+	be := binary.BigEndian
+	if len(sbix) < 8 || int(glyph) >= numGlyphs {
+		return nil
+	}
+	var best []byte
+	bestPPEM := -1
+	for i := range int(be.Uint32(sbix[4:])) {
+		if len(sbix) < 8+4*i+4 {
+			break
+		}
+		strike := int(be.Uint32(sbix[8+4*i:]))
+		at := strike + 4 + 4*int(glyph)
+		if len(sbix) < at+8 {
+			continue
+		}
+		ppem := int(be.Uint16(sbix[strike:]))
+		start, end := strike+int(be.Uint32(sbix[at:])), strike+int(be.Uint32(sbix[at+4:]))
+		// Glyph data is an origin, a graphic type and the image
+		if ppem <= bestPPEM || end-start <= 8 || end > len(sbix) || string(sbix[start+4:start+8]) != "png " {
+			continue
+		}
+		best, bestPPEM = sbix[start+8:end], ppem
+	}
+	return best
+}
+
+// glyphLayer is an outline glyph drawn in one color, a COLR layer or the whole glyph
+type glyphLayer struct {
+	index sfnt.GlyphIndex
+	color color.Color
+}
+
+// colorLayers returns the COLR version 0 layers of the glyph with colors from the first CPAL
+// palette, nil when the glyph has none
+func colorLayers(tables map[string][]byte, glyph uint16, fill color.Color) []glyphLayer {
+	be := binary.BigEndian
+	colr, cpal := tables["COLR"], tables["CPAL"]
+	if len(colr) < 14 || len(cpal) < 14 {
+		return nil
+	}
+	baseOffset, layerOffset := int(be.Uint32(colr[4:])), int(be.Uint32(colr[8:]))
+	var layers []glyphLayer
+	for i := range int(be.Uint16(colr[2:])) {
+		rec := baseOffset + 6*i
+		if len(colr) < rec+6 {
+			return nil
+		}
+		if be.Uint16(colr[rec:]) != glyph {
+			continue
+		}
+		first, n := int(be.Uint16(colr[rec+2:])), int(be.Uint16(colr[rec+4:]))
+		colors, palette := int(be.Uint32(cpal[8:])), int(be.Uint16(cpal[12:]))
+		for l := first; l < first+n; l++ {
+			at := layerOffset + 4*l
+			if len(colr) < at+4 {
+				return nil
+			}
+			layer := glyphLayer{sfnt.GlyphIndex(be.Uint16(colr[at:])), fill}
+			if slot := int(be.Uint16(colr[at+2:])); slot != foregroundSlot {
+				// Color records are blue, green, red and alpha
+				if c := colors + 4*(palette+slot); len(cpal) >= c+4 {
+					layer.color = color.NRGBA{cpal[c+2], cpal[c+1], cpal[c], cpal[c+3]}
+				}
+			}
+			layers = append(layers, layer)
+		}
+		return layers
+	}
+	return nil
+}
+
+// rasterize fills the outlines of the layers in order, scaled and centered to fill the
+// sprite
+func rasterize(f *sfnt.Font, buf *sfnt.Buffer, layers []glyphLayer) (image.Image, error) {
+	segments := make([]sfnt.Segments, len(layers))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i, layer := range layers {
+		s, err := f.LoadGlyph(buf, layer.index, fixed.I(glyphLoadPPEM), nil)
+		if err != nil {
+			return nil, err
+		}
+		// The buffer is reused by the next glyph, so keep a copy
+		segments[i] = append(sfnt.Segments(nil), s...)
+		for _, seg := range s {
+			for _, p := range seg.Args[:segmentPoints(seg.Op)] {
+				x, y := float64(p.X)/64, float64(p.Y)/64
+				minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if minX >= maxX || minY >= maxY {
+		return nil, errors.New("empty glyph")
+	}
+
+	size := float64(proceduralSpriteSize)
+	scale := glyphFill * size / max(maxX-minX, maxY-minY)
+	dx, dy := size/2-(minX+maxX)/2*scale, size/2-(minY+maxY)/2*scale
+	pt := func(p fixed.Point26_6) (float32, float32) {
+		return float32(float64(p.X)/64*scale + dx), float32(float64(p.Y)/64*scale + dy)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, proceduralSpriteSize, proceduralSpriteSize))
+	z := vector.NewRasterizer(proceduralSpriteSize, proceduralSpriteSize)
+	for i, layer := range layers {
+		z.Reset(proceduralSpriteSize, proceduralSpriteSize)
+		for _, seg := range segments[i] {
+			a, b, c := seg.Args[0], seg.Args[1], seg.Args[2]
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				z.MoveTo(pt(a))
+			case sfnt.SegmentOpLineTo:
+				z.LineTo(pt(a))
+			case sfnt.SegmentOpQuadTo:
+				bx, by := pt(a)
+				cx, cy := pt(b)
+				z.QuadTo(bx, by, cx, cy)
+			case sfnt.SegmentOpCubeTo:
+				bx, by := pt(a)
+				cx, cy := pt(b)
+				ex, ey := pt(c)
+				z.CubeTo(bx, by, cx, cy, ex, ey)
+			}
+		}
+		z.ClosePath()
+		z.Draw(dst, dst.Bounds(), image.NewUniform(layer.color), image.Point{})
+	}
+	return dst, nil
+}
+
+// segmentPoints returns the number of points used by a segment operation
+func segmentPoints(op sfnt.SegmentOp) int {
+	switch op {
+	case sfnt.SegmentOpQuadTo:
+		return 2
+	case sfnt.SegmentOpCubeTo:
+		return 3
+	}
+	return 1
+}
+
+// fitBitmap scales an emoji bitmap up to the sprite size, keeping its aspect ratio
+func fitBitmap(src image.Image) (image.Image, error) {
+	b := src.Bounds()
+	if b.Empty() {
+		return nil, errors.New("empty glyph bitmap")
+	}
+	size := proceduralSpriteSize
+	scale := float64(size) / float64(max(b.Dx(), b.Dy()))
+	w, h := int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	r := image.Rect((size-w)/2, (size-h)/2, (size+w)/2, (size+h)/2)
+	draw.CatmullRom.Scale(dst, r, src, b, draw.Over, nil)
+	return dst, nil
+}
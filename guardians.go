@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	guardiansMatch  = 90 * time.Second // Length of a match
+	guardiansDonuts = 10
+	guardianSpeed   = 6   // Pixels per frame
+	guardianSize    = 1.4 // Guardian diameter relative to a donut's
+)
+
+// guardianKeys are the up, down, left and right keys of each player
+var guardianKeys = [2][4]ebiten.Key{
+	{ebiten.KeyW, ebiten.KeyS, ebiten.KeyA, ebiten.KeyD},
+	{ebiten.KeyArrowUp, ebiten.KeyArrowDown, ebiten.KeyArrowLeft, ebiten.KeyArrowRight},
+}
+
+// guardianColors are the colors of each player's guardian
+var guardianColors = [2]color.RGBA{hsvColor(0.55, 0.7, 0.9), hsvColor(1, 0.7, 0.9)}
+
+// guardians is a versus game on one keyboard. Each player steers a guardian in their
+// half of the screen, WASD on the left and the arrows on the right, and scores by bumping
+// a donut over the middle into the other half. Guardians are colliders without a sprite,
+// so they push donuts through the regular collision code.
+type guardians struct {
+	players [2]ecs.Entity
+	radius  float64
+	scores  [2]int
+	frames  int
+	touched map[ecs.Entity]int // Player that last bumped each donut
+	side    map[ecs.Entity]int // Half each donut was in on the last frame
+}
+
+func (v *guardians) start(g *Game) {
+	s := g.currentSettings()
+	s.Donuts, s.Gravity, s.Behavior, s.Layers = guardiansDonuts, 0, defaultBehavior, 1
+	s.Lifetime, s.Chain, s.Magnets = 0, 0, false
+	g.applySettings(s)
+	g.resetDonuts()
+	g.edges = [4]edgeMode{edgeBounce, edgeBounce, edgeBounce, edgeBounce}
+
+	v.radius = g.donutWidth * guardianSize / 2
+	v.touched, v.side = map[ecs.Entity]int{}, map[ecs.Entity]int{}
+	for p := range v.players {
+		e := g.world.Spawn()
+		x := float64(g.screenWidth) * (0.25 + 0.5*float64(p))
+		g.world.positions.Add(e, position{x - v.radius, float64(g.screenHeight)/2 - v.radius})
+		g.world.velocities.Add(e, velocity{})
+		g.world.colliders.Add(e, collider{radius: v.radius})
+		v.players[p] = e
+	}
+}
+
+// stop removes the guardians
+func (v *guardians) stop(g *Game) {
+	for _, e := range v.players {
+		g.world.Despawn(e)
+	}
+}
+
+// half returns the player whose half x is in
+func (v *guardians) half(g *Game, x float64) int {
+	if x < float64(g.screenWidth)/2 {
+		return 0
+	}
+	return 1
+}
+
+func (v *guardians) update(g *Game) bool {
+	v.frames++
+	mid := float64(g.screenWidth) / 2
+	for p, e := range v.players {
+		keys := guardianKeys[p]
+		var dx, dy float64
+		if ebiten.IsKeyPressed(keys[0]) {
+			dy--
+		}
+		if ebiten.IsKeyPressed(keys[1]) {
+			dy++
+		}
+		if ebiten.IsKeyPressed(keys[2]) {
+			dx--
+		}
+		if ebiten.IsKeyPressed(keys[3]) {
+			dx++
+		}
+		if dx != 0 && dy != 0 {
+			dx, dy = dx/math.Sqrt2, dy/math.Sqrt2
+		}
+		// The velocity is set rather than added to, so a guardian only goes where it's
+		// steered however hard donuts hit it
+		vel := g.world.velocities.Get(e)
+		vel.x, vel.y = dx*guardianSpeed, dy*guardianSpeed
+
+		// Keep each guardian in its own half
+		pos := g.world.positions.Get(e)
+		left, right := 0.0, mid-2*v.radius
+		if p == 1 {
+			left, right = mid, float64(g.screenWidth)-2*v.radius
+		}
+		pos.x = max(left, min(right, pos.x))
+		pos.y = max(0, min(float64(g.screenHeight)-2*v.radius, pos.y))
+	}
+
+	for _, e := range g.world.donuts.Entities() {
+		pos, spr := g.world.positions.Get(e), g.world.sprites.Get(e)
+		r := spr.width / 2
+		cx, cy := pos.x+r, pos.y+r
+		for p, guardian := range v.players {
+			gp := g.world.positions.Get(guardian)
+			if math.Hypot(cx-gp.x-v.radius, cy-gp.y-v.radius) <= r+v.radius+1 {
+				v.touched[e] = p + 1
+			}
+		}
+
+		side := v.half(g, cx)
+		if last, ok := v.side[e]; ok && last != side {
+			// Crossing into a player's half scores for the other player, if they sent it
+			if scorer := 1 - side; v.touched[e] == scorer+1 {
+				v.scores[scorer]++
+				g.spawnFirework(cx, cy, 30)
+				delete(v.touched, e)
+			}
+		}
+		v.side[e] = side
+	}
+	return v.frames >= durationFrames(guardiansMatch)
+}
+
+func (v *guardians) draw(g *Game, screen *ebiten.Image) {
+	clr := g.theme.hud
+	mid := float32(g.screenWidth) / 2
+	for y := float32(0); y < float32(g.screenHeight); y += 30 {
+		drawFilledRect(screen, mid-2, y, 4, 15, color.RGBA{clr.R / 2, clr.G / 2, clr.B / 2, 255}, false)
+	}
+	for p, e := range v.players {
+		pos := g.world.positions.Get(e)
+		cx, cy, r := float32(pos.x+v.radius), float32(pos.y+v.radius), float32(v.radius)
+		drawFilledCircle(screen, cx, cy, r, guardianColors[p], true)
+		strokeCircle(screen, cx, cy, r, 3, clr, true)
+	}
+}
+
+func (v *guardians) scoreboard() string {
+	left := max(0, durationFrames(guardiansMatch)-v.frames) / ebiten.TPS()
+	return fmt.Sprintf("%d   %d:%02d   %d", v.scores[0], left/60, left%60, v.scores[1])
+}
+
+// score is the winning margin
+func (v *guardians) score() float64 {
+	return math.Abs(float64(v.scores[0] - v.scores[1]))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// pixelScale is the number of screen pixels per pixel of the simulation, the device scale
+// factor of the monitor the window is on. The simulation, the HUD layout and plugins work
+// in device independent pixels and drawing multiplies by it, so sprites and text are drawn
+// at the native resolution of retina and 4K displays instead of being scaled up. It's set
+// by Layout and only used on the game loop.
+var pixelScale = 1.0
+
+// deviceScale returns the scale factor of the monitor, 1 when it's unknown
+func deviceScale() float64 {
+	if s := ebiten.DeviceScaleFactor(); s > 0 {
+		return s
+	}
+	return 1
+}
+
+// toScreen converts a length in simulation pixels to screen pixels, rounding up so an
+// image of that size covers it
+func toScreen(v int) int {
+	return int(math.Ceil(float64(v) * pixelScale))
+}
+
+// logicalSize returns the size of dst in simulation pixels, for layouts of the whole screen
+func logicalSize(dst *ebiten.Image) (float64, float64) {
+	b := dst.Bounds()
+	return math.Floor(float64(b.Dx()) / pixelScale), math.Floor(float64(b.Dy()) / pixelScale)
+}
+
+// cursorPosition returns the cursor position in simulation pixels
+func cursorPosition() (int, int) {
+	x, y := ebiten.CursorPosition()
+	return int(float64(x) / pixelScale), int(float64(y) / pixelScale)
+}
+
+// The vector drawing functions with coordinates and widths in simulation pixels
+
+func drawFilledRect(dst *ebiten.Image, x, y, width, height float32, clr color.Color, antialias bool) {
+	s := float32(pixelScale)
+	vector.DrawFilledRect(dst, x*s, y*s, width*s, height*s, clr, antialias)
+}
+
+func strokeRect(dst *ebiten.Image, x, y, width, height, strokeWidth float32, clr color.Color, antialias bool) {
+	s := float32(pixelScale)
+	vector.StrokeRect(dst, x*s, y*s, width*s, height*s, strokeWidth*s, clr, antialias)
+}
+
+func strokeLine(dst *ebiten.Image, x0, y0, x1, y1, strokeWidth float32, clr color.Color, antialias bool) {
+	s := float32(pixelScale)
+	vector.StrokeLine(dst, x0*s, y0*s, x1*s, y1*s, strokeWidth*s, clr, antialias)
+}
+
+func drawFilledCircle(dst *ebiten.Image, cx, cy, r float32, clr color.Color, antialias bool) {
+	s := float32(pixelScale)
+	vector.DrawFilledCircle(dst, cx*s, cy*s, r*s, clr, antialias)
+}
+
+func strokeCircle(dst *ebiten.Image, cx, cy, r, strokeWidth float32, clr color.Color, antialias bool) {
+	s := float32(pixelScale)
+	vector.StrokeCircle(dst, cx*s, cy*s, r*s, strokeWidth*s, clr, antialias)
+}
+
+// drawnBody is a body seen by renderers, with its position and size in screen pixels
+type drawnBody struct {
+	body
+}
+
+func (b drawnBody) Position() (float64, float64) {
+	x, y := b.body.Position()
+	return x * pixelScale, y * pixelScale
+}
+
+func (b drawnBody) Size() (float64, float64) {
+	w, h := b.body.Size()
+	return w * pixelScale, h * pixelScale
+}
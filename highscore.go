@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	maxHighScores   = 10 // Scores kept for each game
+	initialsLength  = 3
+	keyboardColumns = 7
+	keyboardKeySize = 60 // Width and height of an on-screen key in pixels
+)
+
+// highScore is one entry of a game's high score table, kept in the state file
+type highScore struct {
+	Initials string    `json:"initials"`
+	Score    float64   `json:"score"`
+	Date     time.Time `json:"date"`
+}
+
+// isHighScore reports whether score makes it into scores
+func isHighScore(scores []highScore, score float64) bool {
+	return score > 0 && (len(scores) < maxHighScores || score > scores[len(scores)-1].Score)
+}
+
+// addHighScore stores hs in the named game's table and returns the table and the rank of
+// hs, counted from 0. A new score goes below equal ones already in the table.
+func addHighScore(game string, hs highScore) (table []highScore, rank int) {
+	updateState(func(st *appState) {
+		scores := st.HighScores[game]
+		rank = len(scores)
+		for i, s := range scores {
+			if hs.Score > s.Score {
+				rank = i
+				break
+			}
+		}
+		scores = slices.Insert(scores, rank, hs)
+		if st.HighScores == nil {
+			st.HighScores = map[string][]highScore{}
+		}
+		table = scores[:min(len(scores), maxHighScores)]
+		st.HighScores[game] = table
+	})
+	return table, rank
+}
+
+// drawHighScores draws the named game's table centered on the screen with the entry at
+// rank highlighted, -1 for none, and footer below it
+func drawHighScores(a *app, screen *ebiten.Image, game string, scores []highScore, rank int, footer string) {
+	const titleScale, lineScale = 5, 2
+	w, h := logicalSize(screen)
+	y := h/2 - float64(maxHighScores+5)*baseFontHeight*lineScale*0.75
+	title := trf("%s HIGH SCORES", strings.ToUpper(game))
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, y, titleScale, a.game.theme.hud)
+	y += baseFontHeight * titleScale * 1.5
+
+	if len(scores) == 0 {
+		line := tr("no scores yet")
+		drawText(screen, line, (w-textWidth(line, lineScale))/2, y, lineScale, a.game.theme.hud)
+		y += baseFontHeight * lineScale * 1.5
+	}
+	for i, s := range scores {
+		line := fmt.Sprintf("%2d. %-*s %10s  %s", i+1, initialsLength, s.Initials, formatScore(game, s.Score), s.Date.Format(time.DateOnly))
+		drawText(screen, line, (w-textWidth(line, lineScale))/2, y, lineScale, a.menuColor(i == rank))
+		y += baseFontHeight * lineScale * 1.5
+	}
+	y += baseFontHeight * lineScale
+	drawText(screen, footer, (w-textWidth(footer, lineScale))/2, y, lineScale, a.game.theme.hud)
+}
+
+// keyboardKeys are the keys of the on-screen keyboard, the letters then delete and done
+var keyboardKeys = append(strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZ", ""), "DEL", "OK")
+
+// initialsKeyboard enters initials with an on-screen keyboard, driven by the arrow keys
+// and Enter or the mouse. Letters can also be typed directly.
+type initialsKeyboard struct {
+	selected int
+	initials string
+}
+
+// update handles the input of a frame on a screen of the given size and reports whether
+// the initials are done
+func (k *initialsKeyboard) update(width, height int) bool {
+	n := len(keyboardKeys)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		k.selected = (k.selected + n - 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		k.selected = (k.selected + 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		k.selected = (k.selected + n - keyboardColumns) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		k.selected = (k.selected + keyboardColumns) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		return k.press("DEL")
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		return k.press(keyboardKeys[k.selected])
+	case inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft):
+		x, y := cursorPosition()
+		for i := range keyboardKeys {
+			kx, ky := keyboardKeyAt(i, width, height)
+			if float64(x) >= kx && float64(x) < kx+keyboardKeySize && float64(y) >= ky && float64(y) < ky+keyboardKeySize {
+				k.selected = i
+				return k.press(keyboardKeys[i])
+			}
+		}
+	}
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			k.press(string(unicode.ToUpper(r)))
+		}
+	}
+	return false
+}
+
+// press acts on a key and reports whether the initials are done. Once they're full the
+// selection moves to OK, so Enter finishes them.
+func (k *initialsKeyboard) press(key string) bool {
+	switch key {
+	case "OK":
+		return k.initials != ""
+	case "DEL":
+		if k.initials != "" {
+			k.initials = k.initials[:len(k.initials)-1]
+		}
+	default:
+		if len(k.initials) < initialsLength {
+			k.initials += key
+		}
+		if len(k.initials) == initialsLength {
+			k.selected = len(keyboardKeys) - 1
+		}
+	}
+	return false
+}
+
+// keyboardKeyAt returns the top left corner of key i on a screen of the given size
+func keyboardKeyAt(i, width, height int) (x, y float64) {
+	left := (float64(width) - keyboardColumns*keyboardKeySize) / 2
+	return left + float64(i%keyboardColumns*keyboardKeySize), float64(height)/2 + float64(i/keyboardColumns*keyboardKeySize)
+}
+
+// draw draws the score, the initials so far and the keyboard
+func (k *initialsKeyboard) draw(a *app, screen *ebiten.Image, score string) {
+	const titleScale, initialsScale, keyScale = 5, 6, 2
+	sw, sh := logicalSize(screen)
+	w, h := int(sw), int(sh)
+	clr := a.game.theme.hud
+	y := float64(h)/2 - baseFontHeight*(titleScale+initialsScale+keyScale)*1.5
+
+	title := trf("NEW HIGH SCORE %s", score)
+	drawText(screen, title, (float64(w)-textWidth(title, titleScale))/2, y, titleScale, clr)
+	y += baseFontHeight * titleScale * 1.5
+	initials := k.initials + strings.Repeat("_", initialsLength-len(k.initials))
+	initials = strings.Join(strings.Split(initials, ""), " ")
+	drawText(screen, initials, (float64(w)-textWidth(initials, initialsScale))/2, y, initialsScale, a.menuColor(true))
+
+	for i, key := range keyboardKeys {
+		x, y := keyboardKeyAt(i, w, h)
+		if i == k.selected {
+			drawFilledRect(screen, float32(x)+2, float32(y)+2, keyboardKeySize-4, keyboardKeySize-4, color.RGBA{clr.R / 3, clr.G / 3, clr.B / 3, 255}, false)
+		}
+		strokeRect(screen, float32(x)+2, float32(y)+2, keyboardKeySize-4, keyboardKeySize-4, 2, clr, false)
+		tx := x + (keyboardKeySize-textWidth(key, keyScale))/2
+		ty := y + (keyboardKeySize-baseFontHeight*keyScale)/2
+		drawText(screen, key, tx, ty, keyScale, a.menuColor(i == k.selected))
+	}
+}
+
+// highScoresScene shows the high score tables, one game at a time picked with the left and
+// right arrows
+type highScoresScene struct {
+	game   int
+	tables map[string][]highScore
+}
+
+func newHighScoresScene() *highScoresScene {
+	return &highScoresScene{tables: loadState().HighScores}
+}
+
+func (s *highScoresScene) Update(a *app) error {
+	n := len(arcadeGames)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		s.game = (s.game + n - 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		s.game = (s.game + 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyTab),
+		inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		a.switchTo(&menuScene{})
+	}
+	return nil
+}
+
+func (s *highScoresScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	name := arcadeGames[s.game].name
+	drawHighScores(a, screen, name, s.tables[name], -1, tr("Left and Right switch games, Esc goes back"))
+}
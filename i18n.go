@@ -0,0 +1,125 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// localeConfig selects the language of on-screen text and the fonts for characters Go
+// Mono doesn't have
+type localeConfig struct {
+	// Language is a tag like "de" or "pt_BR", the OS locale from LC_ALL, LC_MESSAGES or
+	// LANG when empty
+	Language string `json:"language"`
+
+	// Fonts are TrueType or OpenType files, collections included, tried in order for
+	// characters missing from Go Mono. Common system fonts are tried when empty.
+	Fonts []string `json:"fonts"`
+}
+
+// catalog maps English messages to their translation. Messages are the English text
+// itself, so a message missing from the catalog shows in English.
+var catalog = map[string]string{}
+
+// tr returns the translation of msg in the current language
+func tr(msg string) string {
+	if t, ok := catalog[msg]; ok {
+		return t
+	}
+	return msg
+}
+
+// trf formats the translation of format with args
+func trf(format string, args ...any) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// setupLocale loads the catalog of the configured or OS language. User catalogs in the
+// locales directory next to the config file are loaded over the built-in ones, so
+// translations can be fixed or added without a new build.
+func setupLocale(cfg localeConfig) {
+	language := cfg.Language
+	if language == "" {
+		language = osLanguage()
+	}
+	tags := languageTags(language)
+	if len(tags) == 0 {
+		setupFallbackFonts(cfg.Fonts, false)
+		return
+	}
+
+	found := false
+	userDir := filepath.Join(filepath.Dir(defaultConfigPath()), "locales")
+	// The general language first, so a regional catalog only needs what differs
+	for i := len(tags) - 1; i >= 0; i-- {
+		name := tags[i] + ".json"
+		if data, err := embeddedLocales.ReadFile("locales/" + name); err == nil {
+			found = loadCatalog(name, data) || found
+		}
+		data, err := os.ReadFile(filepath.Join(userDir, name))
+		if err == nil {
+			found = loadCatalog(filepath.Join(userDir, name), data) || found
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to read the catalog", "err", err)
+		}
+	}
+	if !found {
+		slog.Warn("no translation for the language, using English", "language", language)
+	}
+	setupFallbackFonts(cfg.Fonts, found)
+}
+
+// loadCatalog adds the messages of a catalog file to the current catalog
+func loadCatalog(name string, data []byte) bool {
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		slog.Warn("invalid catalog", "catalog", name, "err", err)
+		return false
+	}
+	for msg, t := range messages {
+		if t != "" {
+			catalog[msg] = t
+		}
+	}
+	slog.Debug("loaded catalog", "catalog", name, "messages", len(messages))
+	return true
+}
+
+// osLanguage returns the language of the POSIX locale environment variables, or else the
+// one set in the system, since Windows and macOS programs started from the desktop don't
+// get the variables
+func osLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return systemLanguage()
+}
+
+// languageTags returns the catalogs to look for, most specific first: "de_AT.UTF-8" gives
+// de_AT and de. English, C and POSIX give none since messages are written in English.
+func languageTags(language string) []string {
+	language, _, _ = strings.Cut(language, ".") // Encoding
+	language, _, _ = strings.Cut(language, "@") // Modifier
+	language = strings.ReplaceAll(language, "-", "_")
+	base, region, _ := strings.Cut(language, "_")
+	base = strings.ToLower(base)
+	if base == "" || base == "en" || base == "c" || base == "posix" {
+		return nil
+	}
+	if region == "" {
+		return []string{base}
+	}
+	return []string{base + "_" + strings.ToUpper(region), base}
+}
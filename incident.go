@@ -0,0 +1,91 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultIncidentLabel = "Days since last incident"
+
+// incidentConfig turns the timer into a "days since last incident" counter
+type incidentConfig struct {
+	Label     string `json:"label"`      // Shown above the day count
+	StateFile string `json:"state_file"` // Where the start time and reset log are kept, next to the config file by default
+}
+
+// incidentState is persisted in the state file
+type incidentState struct {
+	Start  time.Time   `json:"start"`  // Time of the last incident
+	Resets []time.Time `json:"resets"` // Every reset, oldest first
+}
+
+// incidentCounter counts the days since the last reset
+type incidentCounter struct {
+	label string
+	path  string
+	state incidentState
+}
+
+func defaultIncidentStatePath() string {
+	return filepath.Join(filepath.Dir(defaultConfigPath()), "incident.json")
+}
+
+// loadIncidentCounter reads the state file. Without one the counter starts at start.
+func loadIncidentCounter(cfg incidentConfig, start time.Time) (*incidentCounter, error) {
+	c := &incidentCounter{
+		label: cmp.Or(cfg.Label, tr(defaultIncidentLabel)),
+		path:  cmp.Or(cfg.StateFile, defaultIncidentStatePath()),
+		state: incidentState{Start: start},
+	}
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", c.path, err)
+	}
+	return c, nil
+}
+
+// reset restarts the count at now and logs the reset to the state file
+func (c *incidentCounter) reset(now time.Time) error {
+	c.state.Start = now
+	c.state.Resets = append(c.state.Resets, now)
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// lines returns the text shown instead of the timer
+func (c *incidentCounter) lines(elapsed time.Duration) []string {
+	days := int(elapsed.Hours() / 24)
+	if days == 1 {
+		return []string{c.label, tr("1 day")}
+	}
+	return []string{c.label, trf("%d days", days)}
+}
+
+// resetIncident restarts the counter, used after the reset key is confirmed
+func (g *Game) resetIncident() {
+	now := time.Now()
+	if err := g.incident.reset(now); err != nil {
+		slog.Error("failed to save incident state", "err", err)
+	}
+	g.restartTimer(now)
+	slog.Info("incident counter reset")
+	g.notifier.notify(eventReset, g.incident.label+" reset to 0", map[string]any{"resets": len(g.incident.state.Resets)})
+}
@@ -0,0 +1,22 @@
+package main
+
+import "log/slog"
+
+const inhibitReason = "Showing the donut timer"
+
+var inhibitIdleFlag = runFlags.Bool("inhibit-idle", false, "keep the OS screen saver and display sleep from starting while the donuts are shown")
+
+// startInhibitIdle keeps the screen on until the returned function is called. Failures
+// are logged, the screensaver runs either way.
+func startInhibitIdle() (release func()) {
+	if !*inhibitIdleFlag {
+		return func() {}
+	}
+	release, err := inhibitIdle()
+	if err != nil {
+		slog.Warn("can't keep the display awake", "err", err)
+		return func() {}
+	}
+	slog.Info("keeping the display awake")
+	return release
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// inhibitIdle runs caffeinate, which keeps the display awake until it's stopped or this
+// process exits
+func inhibitIdle() (release func(), err error) {
+	cmd := exec.Command("caffeinate", "-d", "-i", "-w", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// inhibitIdle asks the desktop over the session bus not to start the screen saver. The
+// freedesktop interface covers KDE, Xfce and most Wayland compositors' portals, GNOME
+// prefers its session manager. The inhibition ends with the connection, so it's kept
+// open until release.
+func inhibitIdle() (release func(), err error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var cookie uint32
+	saver := conn.Object("org.freedesktop.ScreenSaver", "/org/freedesktop/ScreenSaver")
+	if err := saver.Call("org.freedesktop.ScreenSaver.Inhibit", 0, "donut", inhibitReason).Store(&cookie); err == nil {
+		return func() {
+			saver.Call("org.freedesktop.ScreenSaver.UnInhibit", 0, cookie)
+			conn.Close()
+		}, nil
+	}
+
+	const gsmInhibitIdle = 8 // GSM_INHIBITOR_FLAG_IDLE
+	session := conn.Object("org.gnome.SessionManager", "/org/gnome/SessionManager")
+	if err := session.Call("org.gnome.SessionManager.Inhibit", 0, "donut", uint32(0), inhibitReason, uint32(gsmInhibitIdle)).Store(&cookie); err == nil {
+		return func() {
+			session.Call("org.gnome.SessionManager.Uninhibit", 0, cookie)
+			conn.Close()
+		}, nil
+	}
+	conn.Close()
+	return nil, errors.New("neither org.freedesktop.ScreenSaver nor org.gnome.SessionManager is available")
+}
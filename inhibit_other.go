@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+import "errors"
+
+// inhibitIdle isn't supported on this platform
+func inhibitIdle() (release func(), err error) {
+	return nil, errors.New("idle inhibition is unsupported on this platform")
+}
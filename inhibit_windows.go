@@ -0,0 +1,37 @@
+//go:build windows
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+var procSetThreadExecutionState = syscall.NewLazyDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// inhibitIdle keeps the display and system awake with SetThreadExecutionState. The state
+// belongs to the calling thread, so a goroutine locked to its thread holds it.
+func inhibitIdle() (release func(), err error) {
+	const (
+		esContinuous      = 0x80000000
+		esSystemRequired  = 0x00000001
+		esDisplayRequired = 0x00000002
+	)
+	done := make(chan struct{})
+	errs := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if r, _, err := procSetThreadExecutionState.Call(esContinuous | esSystemRequired | esDisplayRequired); r == 0 {
+			errs <- err
+			return
+		}
+		errs <- nil
+		<-done
+		procSetThreadExecutionState.Call(esContinuous)
+	}()
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return func() { close(done) }, nil
+}
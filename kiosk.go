@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+var (
+	kioskFlag   = runFlags.Bool("kiosk", false, "ignore all keyboard and mouse input except the -kiosk-unlock chord, hide the cursor and always restart after a crash")
+	kioskUnlock = runFlags.String("kiosk-unlock", "Control+Alt+Shift+U", "keys held together to unlock and lock the controls in -kiosk mode")
+)
+
+// kioskMode locks the controls for unattended public displays. Holding the unlock chord
+// gives the controls back for maintenance, holding it again locks them.
+type kioskMode struct {
+	chord    []ebiten.Key
+	unlocked bool
+}
+
+// newKioskMode returns a locked kiosk mode unlocked by chord, key names joined with "+"
+func newKioskMode(chord string) (*kioskMode, error) {
+	k := &kioskMode{}
+	for _, name := range strings.Split(chord, "+") {
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+			return nil, fmt.Errorf("invalid key %q in the unlock chord", name)
+		}
+		k.chord = append(k.chord, key)
+	}
+	if len(k.chord) < 2 {
+		return nil, fmt.Errorf("the unlock chord %q must have at least two keys", chord)
+	}
+	ebiten.SetWindowClosingHandled(true)
+	k.apply()
+	return k, nil
+}
+
+// locked reports whether input must be ignored, it's false when not in kiosk mode
+func (k *kioskMode) locked() bool { return k != nil && !k.unlocked }
+
+// update toggles the lock when the last key of the chord goes down while the others are
+// held
+func (k *kioskMode) update() {
+	pressed := false
+	for _, key := range k.chord {
+		if !ebiten.IsKeyPressed(key) {
+			return
+		}
+		pressed = pressed || inpututil.IsKeyJustPressed(key)
+	}
+	if pressed {
+		k.unlocked = !k.unlocked
+		slog.Info("kiosk controls toggled", "unlocked", k.unlocked)
+		k.apply()
+	}
+}
+
+// apply hides the cursor while locked
+func (k *kioskMode) apply() {
+	if k.unlocked {
+		ebiten.SetCursorMode(ebiten.CursorModeVisible)
+	} else {
+		ebiten.SetCursorMode(ebiten.CursorModeHidden)
+	}
+}
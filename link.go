@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	linkGeometryFrames = 30              // How often the screen geometry is sent to the peers
+	linkPeerTimeout    = 5 * time.Second // Peers not heard from for this long are ignored
+	linkQueueSize      = 64
+)
+
+// linkMessage is one UDP datagram between linked instances
+type linkMessage struct {
+	Geometry *windowGeometry `json:"geometry,omitempty"` // Sender's screen in shared coordinates
+	Donut    *linkDonut      `json:"donut,omitempty"`    // A donut handed off to the receiver
+	Ack      bool            `json:"ack,omitempty"`      // Reply to geometry from instances not linked back
+}
+
+// linkDonut is a donut crossing between instances, in shared coordinates
+type linkDonut struct {
+	X, Y      float64 // Top left corner
+	VX, VY    float64
+	Rotation  float64
+	SpinSpeed float64
+	Ring      bool // Y is a fraction of the height and the donut enters at the left edge, or the right one moving left
+
+	from *net.UDPAddr // Sender, where the donut bounces back to when the receiver is full
+}
+
+type linkPeer struct {
+	addr     *net.UDPAddr
+	geometry windowGeometry
+	seen     time.Time
+}
+
+// link connects instances over UDP, usually several windows on one machine. Each instance
+// shares where its screen is in a common coordinate space, the desktop's by default, and
+// donuts leaving the left or right edge towards another instance's screen are handed
+// over instead of bouncing.
+//
+// In a ring, usually machines across an office, screens have no shared coordinates.
+// Donuts leaving the right edge fly on to the next peer's left edge, and each instance
+// only lists its next peer.
+type link struct {
+	conn     *net.UDPConn
+	origin   *[2]int // Fixed position of this screen, the window position when nil
+	ring     bool
+	arrivals chan linkDonut
+
+	mu    sync.Mutex
+	peers []*linkPeer
+}
+
+// startLink listens on listen and links to the comma separated peer addresses
+func startLink(listen, peers string, origin *[2]int, ring bool) (*link, error) {
+	laddr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	l := &link{conn: conn, origin: origin, ring: ring, arrivals: make(chan linkDonut, linkQueueSize)}
+	for _, p := range strings.Split(peers, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		addr, err := net.ResolveUDPAddr("udp", p)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("peer %q: %w", p, err)
+		}
+		l.peers = append(l.peers, &linkPeer{addr: addr})
+	}
+	if ring && len(l.peers) == 0 {
+		conn.Close()
+		return nil, errors.New("a ring needs the next instance in -link-peers")
+	}
+	go l.receive()
+	return l, nil
+}
+
+func (l *link) receive() {
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := l.conn.ReadFromUDP(buf)
+		if err != nil {
+			slog.Error("link stopped", "err", err)
+			return
+		}
+		var msg linkMessage
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			continue
+		}
+		if msg.Geometry != nil || msg.Ack {
+			known := false
+			l.mu.Lock()
+			for _, p := range l.peers {
+				if p.addr.Port == from.Port && p.addr.IP.Equal(from.IP) {
+					known, p.seen = true, time.Now()
+					if msg.Geometry != nil {
+						p.geometry = *msg.Geometry
+					}
+				}
+			}
+			l.mu.Unlock()
+			// In a ring the previous instance only knows about this one, let it know this
+			// one is alive
+			if !known && msg.Geometry != nil {
+				l.send(from, linkMessage{Ack: true})
+			}
+		}
+		if msg.Donut != nil {
+			d := *msg.Donut
+			d.from = from
+			select {
+			case l.arrivals <- d:
+			default:
+			}
+		}
+	}
+}
+
+func (l *link) send(addr *net.UDPAddr, msg linkMessage) {
+	data, err := json.Marshal(msg)
+	if err == nil {
+		_, err = l.conn.WriteToUDP(data, addr)
+	}
+	if err != nil {
+		slog.Warn("link send failed", "peer", addr, "err", err)
+	}
+}
+
+// screen returns this instance's screen in shared coordinates
+func (l *link) screen(width, height int) windowGeometry {
+	if l.origin != nil {
+		return windowGeometry{X: l.origin[0], Y: l.origin[1], Width: width, Height: height}
+	}
+	x, y := ebiten.WindowPosition()
+	return windowGeometry{X: x, Y: y, Width: width, Height: height}
+}
+
+// updateLink shares the screen geometry and spawns the donuts that arrived. Donuts arriving
+// when there are already as many as allowed bounce back to their sender.
+func (g *Game) updateLink() {
+	l := g.link
+	me := l.screen(g.screenWidth, g.screenHeight)
+	if g.frame%linkGeometryFrames == 0 {
+		l.mu.Lock()
+		for _, p := range l.peers {
+			l.send(p.addr, linkMessage{Geometry: &me})
+		}
+		l.mu.Unlock()
+	}
+
+	for {
+		select {
+		case d := <-l.arrivals:
+			if g.world.donuts.Len() >= g.maxDonutCount() {
+				d.VX = -d.VX
+				l.send(d.from, linkMessage{Donut: &d})
+				continue
+			}
+			x := min(max(0, d.X-float64(me.X)), float64(g.screenWidth)-g.donutWidth)
+			y := min(max(0, d.Y-float64(me.Y)), float64(g.screenHeight)-g.donutHeight)
+			if d.Ring {
+				x, y = 0, d.Y*(float64(g.screenHeight)-g.donutHeight)
+				if d.VX < 0 {
+					x = float64(g.screenWidth) - g.donutWidth
+				}
+			}
+			e := g.world.spawnDonut(Donut{
+				x: x, y: y, vx: d.VX, vy: d.VY,
+				width: g.donutWidth, height: g.donutHeight,
+				rotation: d.Rotation, rotationSpeed: d.SpinSpeed,
+			}, g.donutImageFor(g.world.donuts.Len()))
+			g.world.layers.Add(e, layer{0})
+			g.numDonuts++
+		default:
+			return
+		}
+	}
+}
+
+// handOff sends e to the peer whose screen lies beyond the edge it is crossing, if any.
+// dir is -1 for the left edge and +1 for the right edge.
+func (g *Game) handOff(e ecs.Entity, dir int) bool {
+	l := g.link
+	w := g.world
+	pos, vel, spr, sp := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e), w.spins.Get(e)
+	me := l.screen(g.screenWidth, g.screenHeight)
+
+	// The point just beyond the edge at the donut's center height
+	cy := float64(me.Y) + pos.y + spr.height/2
+	cx := float64(me.X) - 1
+	if dir > 0 {
+		cx = float64(me.X+me.Width) + 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ring {
+		next := l.peers[0]
+		if dir < 0 || time.Since(next.seen) > linkPeerTimeout {
+			return false
+		}
+		d := linkDonut{Y: pos.y / max(1, float64(g.screenHeight)-spr.height), VX: vel.x, VY: vel.y, Ring: true}
+		if sp != nil {
+			d.Rotation, d.SpinSpeed = sp.angle, sp.speed
+		}
+		l.send(next.addr, linkMessage{Donut: &d})
+		return true
+	}
+	for _, p := range l.peers {
+		r := p.geometry
+		if time.Since(p.seen) > linkPeerTimeout || cx < float64(r.X) || cx >= float64(r.X+r.Width) || cy < float64(r.Y) || cy >= float64(r.Y+r.Height) {
+			continue
+		}
+		d := linkDonut{Y: cy - spr.height/2, VX: vel.x, VY: vel.y}
+		if dir > 0 {
+			d.X = float64(r.X)
+		} else {
+			d.X = float64(r.X+r.Width) - spr.width
+		}
+		if sp != nil {
+			d.Rotation, d.SpinSpeed = sp.angle, sp.speed
+		}
+		l.send(p.addr, linkMessage{Donut: &d})
+		return true
+	}
+	return false
+}
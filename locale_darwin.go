@@ -0,0 +1,25 @@
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// systemLanguage returns the first of the preferred languages set in System Settings, like
+// "de-AT", falling back to the region format's locale
+func systemLanguage() string {
+	// AppleLanguages is printed as a property list array: (\n    "de-AT",\n    en\n)
+	if out, err := exec.Command("defaults", "read", "-g", "AppleLanguages").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if language := strings.Trim(line, " \t\",()"); language != "" {
+				return language
+			}
+		}
+	}
+	if out, err := exec.Command("defaults", "read", "-g", "AppleLocale").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+	return ""
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package main
+
+// systemLanguage has nothing beyond the locale environment variables on this platform
+func systemLanguage() string {
+	return ""
+}
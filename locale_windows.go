@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetUserDefaultLocaleName = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// systemLanguage returns the user's locale name from Windows, like "de-AT"
+func systemLanguage() string {
+	const localeNameMaxLength = 85
+	var name [localeNameMaxLength]uint16
+	n, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&name[0])), localeNameMaxLength)
+	if n == 0 {
+		return ""
+	}
+	return windows.UTF16ToString(name[:])
+}
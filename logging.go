@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logging flags, shared by all commands that run the screensaver
+var (
+	logLevel  = runFlags.String("log-level", "info", "minimum level of logged messages: debug, info, warn or error")
+	logFormat = runFlags.String("log-format", "text", "log format: text or json")
+	logFile   = runFlags.String("log-file", "", "append logs to this file instead of stderr, reopened when rotated")
+)
+
+// setupLogging makes the default slog logger follow the logging flags. Messages of the
+// log package go through it too. The returned function closes the log file.
+func setupLogging() (closeLog func(), err error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level %q", *logLevel)
+	}
+
+	var w io.Writer = os.Stderr
+	closeLog = func() {}
+	if *logFile != "" {
+		f, err := openLogFile(*logFile)
+		if err != nil {
+			return nil, err
+		}
+		w, closeLog = f, func() { f.Close() }
+	}
+	w = io.MultiWriter(w, recentLogs)
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(*logFormat) {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		closeLog()
+		return nil, fmt.Errorf("invalid -log-format %q, want text or json", *logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return closeLog, nil
+}
+
+// fatal logs msg as an error and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+const (
+	logReopenInterval = time.Second
+	recentLogLines    = 100 // Log lines kept for crash reports
+)
+
+// recentLogs keeps the last log lines for crash reports
+var recentLogs = &logTail{}
+
+// logTail is an io.Writer keeping the last recentLogLines writes. Log handlers write one
+// record per call.
+type logTail struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (t *logTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.lines) == recentLogLines {
+		t.lines = append(t.lines[:0], t.lines[1:]...)
+	}
+	t.lines = append(t.lines, string(p))
+	return len(p), nil
+}
+
+// String returns the kept lines, oldest first
+func (t *logTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "")
+}
+
+// logFileWriter appends to a log file, reopening it when logrotate or a similar tool has
+// moved it away so the screensaver never has to be restarted or signaled
+type logFileWriter struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	checked time.Time
+}
+
+func openLogFile(path string) (*logFileWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &logFileWriter{path: path, f: f, checked: time.Now()}, nil
+}
+
+func (w *logFileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if time.Since(w.checked) > logReopenInterval {
+		w.checked = time.Now()
+		w.reopenIfMoved()
+	}
+	return w.f.Write(p)
+}
+
+// reopenIfMoved opens a new file at the path when the open file isn't there anymore.
+// Failing to reopen keeps writing to the old file.
+func (w *logFileWriter) reopenIfMoved() {
+	current, err := w.f.Stat()
+	if err != nil {
+		return
+	}
+	if onDisk, err := os.Stat(w.path); err == nil && os.SameFile(current, onDisk) {
+		return
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	w.f.Close()
+	w.f = f
+}
+
+func (w *logFileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
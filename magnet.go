@@ -0,0 +1,80 @@
+package main
+
+import (
+	"image/color"
+	"math"
+)
+
+const (
+	magnetRange    = 4    // Reach of a donut's magnet relative to its width
+	magnetStrength = 0.04 // Push or pull between touching donuts in pixels per frame²
+)
+
+var (
+	positivePole = color.RGBA{255, 170, 160, 255} // Tint of + donuts
+	negativePole = color.RGBA{160, 190, 255, 255} // Tint of − donuts
+)
+
+// polarity is the magnetic pole of a donut in magnet mode, +1 or -1
+type polarity struct{ sign float64 }
+
+// tint returns the color of the pole
+func (p polarity) tint() color.RGBA {
+	if p.sign > 0 {
+		return positivePole
+	}
+	return negativePole
+}
+
+// magnetSystem pushes like poles apart and pulls opposite poles together. Donuts without a
+// polarity, like ones spawned since magnet mode was turned on, get a random one first.
+func (g *Game) magnetSystem() {
+	w := g.world
+	donuts := w.donuts.Entities()
+	if len(donuts) < 1 {
+		return
+	}
+	for _, e := range donuts {
+		if !w.polarities.Has(e) {
+			sign := 1.0
+			if g.rng.Float64() < 0.5 {
+				sign = -1
+			}
+			w.polarities.Add(e, polarity{sign})
+		}
+	}
+
+	// Bucket the donuts by their centers in cells as wide as the magnet range
+	size := 1.0
+	for _, e := range donuts {
+		size = max(size, w.sprites.Get(e).width*magnetRange)
+	}
+	g.poles.build(len(donuts), size, g.screenWidth, g.screenHeight, func(i int) (x, y float64) {
+		pos, spr := w.positions.Get(donuts[i]), w.sprites.Get(donuts[i])
+		return pos.x + spr.width/2, pos.y + spr.height/2
+	})
+
+	for i, a := range donuts {
+		posA, sprA, velA := w.positions.Get(a), w.sprites.Get(a), w.velocities.Get(a)
+		layerA, signA := w.layerOf(a), w.polarities.Get(a).sign
+		reach := sprA.width * magnetRange
+		g.poles.near(i, func(j int32) {
+			b := donuts[j]
+			if int(j) == i || w.layerOf(b) != layerA {
+				return
+			}
+			posB, sprB := w.positions.Get(b), w.sprites.Get(b)
+			dx := posB.x + sprB.width/2 - posA.x - sprA.width/2
+			dy := posB.y + sprB.height/2 - posA.y - sprA.height/2
+			dist := math.Hypot(dx, dy)
+			if dist == 0 || dist >= reach {
+				return
+			}
+			// Each donut pushes itself away from a like pole and toward an opposite one,
+			// the other donut does the same in its own turn
+			force := magnetStrength * (1 - dist/reach) * g.step * signA * w.polarities.Get(b).sign
+			velA.x -= dx / dist * force
+			velA.y -= dy / dist * force
+		})
+	}
+}
@@ -1,338 +1,832 @@
 package main
 
 import (
-	"bytes"
+	"cmp"
 	_ "embed"
+	"errors"
+	"flag"
 	"fmt"
-	"image"
 	"image/color"
-	_ "image/png"
-	"log"
+	"io/fs"
+	"log/slog"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/text"
-	"golang.org/x/image/font/basicfont"
+	"github.com/mlctrez/donut/plugin"
+	_ "github.com/mlctrez/donut/plugins/builtin" // Registers the built-in behaviors, renderers, backgrounds and overlays
 )
 
 //go:embed donut.png
 var donutPNG []byte
 
 const (
-	donutScale    = 0.5 // Configuration: scale factor for the donut (1.0 = original size, 2.0 = double size, etc.)
-	initialDonuts = 6   // Configuration: initial number of donuts to display
-	maxDonuts     = 50  // Maximum number of donuts allowed
-	minDonuts     = 1   // Minimum number of donuts allowed
+	donutScale    = 0.5  // Configuration: scale factor for the donut (1.0 = original size, 2.0 = double size, etc.)
+	initialDonuts = 6    // Configuration: initial number of donuts to display
+	maxDonuts     = 5000 // Maximum number of donuts allowed
+	minDonuts     = 1    // Minimum number of donuts allowed
+	crowdedDonuts = 50   // Above this many donuts spawn all over the screen instead of in the middle
 
 	// Timer display configuration
 	timerFontSize = 64 // Configuration: font size for the timer display
-	timerPosX     = 30 // Configuration: X position of timer from left edge
-	timerPosY     = 30 // Configuration: Y position of timer from top edge
+	timerMargin   = 30 // Configuration: default distance of the timer from the screen edges
 )
 
 // Timer start time configuration - adjust these values to set the exact start time
 var (
 	// Configuration: Set the exact date and time when the timer started
 	// Format: time.Date(year, month, day, hour, minute, second, nanosecond, location)
+	// Overridden at launch by -timer-start-file or DONUT_TIMER_START, see resolveTimerStart
 	timerStartTime = time.Date(2025, 9, 9, 21, 5, 45, 0, time.UTC)
 )
 
-type Donut struct {
-	x, y          float64
-	vx, vy        float64
-	rotation      float64 // Rotation angle in radians
-	rotationSpeed float64 // Rotation speed in radians per frame
-}
+// Command line flags of the run and record commands
+var (
+	runFlags = flag.NewFlagSet("run", flag.ExitOnError)
+
+	pprofAddr     = runFlags.String("pprof", "", "serve net/http/pprof on this address (e.g. :6060), disabled when empty")
+	seedFlag      = runFlags.Int64("seed", 0, "random seed for a deterministic simulation, 0 picks one from the clock")
+	recordFlag    = runFlags.String("record", "", "record the initial state and all inputs to this .donutreplay file")
+	replayFlag    = runFlags.String("replay", "", "play back a .donutreplay file recorded with -record")
+	configFlag    = runFlags.String("config", defaultConfigPath(), "path to the JSON config file")
+	presetFlag    = runFlags.String("preset", "", "start with this preset, by name or number")
+	countFlag     = runFlags.Int("count", 0, fmt.Sprintf("start with this many donuts, up to %d, instead of the preset's count", maxDonuts))
+	scriptFlag    = runFlags.String("script", "", "run this Starlark script's on_frame callbacks every frame")
+	procFlag      = runFlags.Bool("procedural", false, "bounce generated donuts with random frosting and sprinkles")
+	imageFlag     = runFlags.String("image", "", "PNG or SVG image to bounce instead of the built-in donut")
+	squashFlag    = runFlags.Bool("squash", true, "squash and stretch donuts on impacts")
+	controlSocket = runFlags.String("control", "", "Unix socket for donutctl commands, \"default\" for the one donutctl uses, disabled when empty")
+	httpAddr      = runFlags.String("http", "", "serve the HTTP control API on this address (e.g. :8080), disabled when empty")
+	audioFlag     = runFlags.String("audio", "", "pulse the donuts with captured audio: size, speed or tint, disabled when empty")
+	audioDev      = runFlags.String("audio-device", "", "audio capture device, the monitor of the default output when empty")
+	musicFlag     = runFlags.String("music", "", "play background music from a directory of OGG/MP3/WAV files, or \"builtin\" for the built-in track")
+	midiFlag      = runFlags.String("midi", "", "send a note per collision to this raw MIDI device (e.g. /dev/snd/midiC1D0), disabled when empty")
+	midiChan      = runFlags.Int("midi-channel", 1, "MIDI channel for collision notes, 1-16")
+	transFlag     = runFlags.Bool("transparent", false, "run in a transparent, click-through window on top of the desktop")
+	wallFlag      = runFlags.Bool("wallpaper", false, "run as a live wallpaper behind the desktop icons (Windows and X11)")
+	trayFlag      = runFlags.Bool("tray", false, "show a system tray icon with quick controls")
+	windowFlag    = runFlags.Bool("windowed", false, "run in a window instead of fullscreen")
+	borderFlag    = runFlags.Bool("borderless", false, "hide the window title bar and borders, implies -windowed")
+	onTopFlag     = runFlags.Bool("on-top", false, "keep the window above other windows, implies -windowed")
+	posFlag       = runFlags.String("position", "", "fixed window position as x,y, implies -windowed")
+	linkListen    = runFlags.String("link", "", "link with other instances: UDP address to listen on, e.g. 127.0.0.1:7700")
+	linkPeers     = runFlags.String("link-peers", "", "comma separated UDP addresses of the linked instances")
+	linkRing      = runFlags.Bool("link-ring", false, "pass donuts leaving the right edge on to the next instance in a ring, the first of -link-peers")
+	linkOrigin    = runFlags.String("link-origin", "", "position x,y of this screen among the linked ones, the window position by default")
+	syncServe     = runFlags.String("sync-serve", "", "run the simulation for sync clients joining on this UDP address, e.g. :7710")
+	syncJoin      = runFlags.String("sync-join", "", "show the donuts and timer of the sync server at this UDP address")
+	menuFlag      = runFlags.Bool("menu", false, "start on the menu instead of the simulation")
+	attractArg    = runFlags.Duration("attract", 0, "cycle through built-in configurations at this interval (e.g. 30s), 0 disables")
+)
 
 type Game struct {
 	donutImage   *ebiten.Image
+	donutImages  []*ebiten.Image // Sprite variants, assigned to donuts in turn
 	donutWidth   float64
 	donutHeight  float64
-	donuts       []Donut
+	donutSize    float64 // Size setting, donutWidth and donutHeight relative to the sprite size
+	spriteWidth  float64 // Donut size at a Size of 1
+	spriteHeight float64
+	world        *world
 	screenWidth  int
 	screenHeight int
-	numDonuts    int // Current number of donuts
+	fixedSize    bool          // The screen size is set by -resolution instead of the window
+	filter       ebiten.Filter // Filter sprites are scaled with
+	antialias    bool          // Donuts are supersampled, see renderSystem
+	edges        [4]edgeMode   // What each screen edge does, by edge index
+	numDonuts    int           // Current number of donuts
+	spawner      spawner       // Places new donuts, see spawners
+
+	// Deterministic simulation state - all randomness must come from rng so replays match
+	rng       *rand.Rand
+	rngSource *countingSource // Source of rng, counting draws for snapshots
+	fx        *rand.Rand      // Randomness for visual effects that don't affect the simulation
+	frame     int             // Number of Update calls so far
+	recorder  *replayRecorder // Non-nil when recording inputs with -record
+	trace     *traceWriter    // Non-nil when logging trajectories with -trace
+
+	thumbnailSlot string        // Save slot that the next drawn frame is the thumbnail of
+	replay        *replayPlayer // Non-nil while playing back a -replay file
+	attract       *attractMode  // Non-nil when attract mode is cycling configurations
+	script        *scriptEngine // Non-nil when a -script is loaded
+
+	// Runtime settings, see applySettings
+	gravity        float64
+	themeName      string
+	theme          theme
+	behaviorName   string
+	behavior       plugin.Behavior
+	minSpeed       float64
+	maxSpeed       float64
+	minSpin        float64
+	maxSpin        float64
+	lifetime       float64 // Seconds donuts live before they're replaced, 0 for forever
+	lifetimeJitter float64
+	chainLength    int  // Donuts per spring chain, below 2 for none
+	chainRing      bool // Close the chains into rings
+	magnets        bool // Donuts attract and repel by their polarity
+	trails         bool
+	numLayers      int // Parallax depth layers in use
+	rainbow        bool
+	crumbsEnabled  bool
+	rainbowTimer   bool
+
+	squashEnabled bool   // Squash and stretch donuts on impacts
+	inputLocked   bool   // Keyboard input is ignored, set by kiosk mode and arcade games
+	scoreboard    string // Shown instead of the timer while playing an arcade game
+
+	presets     []preset      // Selectable with the number keys
+	trailLayer  *ebiten.Image // Accumulates donut trails when trails are enabled
+	layerImages layerImages   // Offscreen images for the far parallax layers
+	crumbs      crumbLayer    // Crumbs dropped by the donuts when enabled
+	collisions  collisionSolver
+	poles       spatialGrid // Finds nearby donuts in magnet mode
+	shake       screenShake // Offsets the frame after heavy impacts
+	camera      camera      // Zoomed and panned view, see camera.update
+	field       forceField  // Arrows painted with the mouse that push donuts
+	energy      energyMonitor
+
+	achievements *achievementTracker // Nil when achievements aren't tracked
+	stats        *simStats           // Nil unless the stats widget or -summary is on
+
+	renderer       plugin.Renderer   // Draws each donut
+	background     plugin.Background // Drawn behind the donuts, nil for the theme color
+	backgroundName string            // Selected with K, see backgroundNames
+	slideshow      plugin.Background // Configured slideshow, nil without one
+	overlays       []plugin.Overlay  // HUD widgets drawn after the timer
 
 	// Timer configuration - configurable start date/time for elapsed time display
-	timerStartTime time.Time // Configuration: the exact time when the timer started
+	timerStartTime time.Time   // Configuration: the exact time when the timer started
+	clock          *ntpClock   // Corrects the timer's clock, nil without NTP
+	svg            *svgSprite  // Donut sprite drawn from an SVG, rasterized again as donuts grow
+	timerStyle     timerConfig // Configuration: colors, panel, shadow and outline
+	milestones     *milestoneTracker
+	timerFlash     int              // Frames left of the milestone flash
+	highContrast   bool             // The high-contrast theme replaces the selected one
+	brightness     brightness       // Dims the finished frame
+	nightLight     *nightLight      // Warms the finished frame at night, nil when disabled
+	oled           *oledMode        // OLED-safe mode, nil when disabled
+	donutLimit     int              // Lower donut count limit than maxDonuts when set
+	reduceMotion   bool             // Half speed without shakes or flashes
+	incident       *incidentCounter // Replaces the timer with a day count when set
+	weather        *weatherWidget   // Weather reactive visuals, nil when disabled
+	audio          *audioReactive   // Audio reactive mode, nil when disabled
+	sound          *sound           // Music and sound effects
+	midi           *midiOutput      // Collision notes, nil when disabled
+	chat           *chat            // Stream chat integration, nil when disabled
+	transparent    bool             // Drawn over a transparent window without a background
+
+	wallpaperPending bool // Move the window behind the desktop icons on the next frame
+
+	windowed   bool            // Running in a normal window
+	lastWindow *windowGeometry // Geometry of the normal window, saved on exit
+
+	link *link // Instances donuts cross over to, nil when not linked
+
+	quality       *qualityGovernor // Lowers the visual quality under load, nil when disabled
+	lowPower      bool             // Running at a lower tick rate without effects, see setLowPower
+	sessionHidden bool             // The session is locked or asleep, see setSessionHidden
+	fps           int              // Configured frame rate, see setFPS
+	step          float64          // Movement per frame relative to 60 frames per second
+	updated       bool             // The simulation advanced since the last frame was drawn
+
+	// Reused every frame so updating and drawing don't allocate
+	scratchBody   body
+	scratchDrawn  drawnBody
+	scratchTint   color.RGBA
+	captionLayout captionLayout // Wrapped lines of the timer caption
+	drawOp        ebiten.DrawImageOptions
+	aaLayer       *ebiten.Image // Donuts drawn at a higher resolution for anti-aliasing
+	timerImage    timerImage
+
+	syncServer *syncServer // Sends the simulation to the sync clients, nil when not serving
+	syncClient *syncClient // Source of the donuts when showing a sync server, nil otherwise
+
+	commands chan command // Changes requested by the HTTP API, see runCommands
+	notifier *notifier    // Sends events to webhooks, nil when none are configured
 }
 
+// Update advances the simulation by one frame. Keys that switch scenes are handled by
+// the scenes in scenes.go.
 func (g *Game) Update() error {
-	// Check for the escape key to exit
-	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-		return ebiten.Termination
+	for _, ev := range g.pollInput() {
+		g.applyAction(ev)
 	}
 
-	// Handle plus key to add more donuts
-	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd) {
-		if g.numDonuts < maxDonuts {
-			g.numDonuts++
-			g.donuts = createDonuts(g.screenWidth, g.screenHeight, g.donutWidth, g.donutHeight, g.numDonuts)
+	// The background animates independently of the simulation, a failing one falls back to
+	// the theme color
+	if g.background != nil {
+		if err := g.background.Update(g); err != nil {
+			slog.Warn("background removed", "err", err)
+			g.background = nil
 		}
 	}
 
-	// Handle minus key to remove donuts
-	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract) {
-		if g.numDonuts > minDonuts {
-			g.numDonuts--
-			g.donuts = createDonuts(g.screenWidth, g.screenHeight, g.donutWidth, g.donutHeight, g.numDonuts)
-		}
+	if g.audio != nil {
+		g.audio.update()
+	}
+	g.brightness.update(g.now(), !g.inputLocked)
+	if !g.inputLocked {
+		g.sound.handleKeys()
+		g.camera.update(g)
+		g.pollBackground()
+		g.pollSnapshot()
 	}
 
-	// Update each donut
-	for i := range g.donuts {
-		donut := &g.donuts[i]
-
-		// Update position
-		donut.x += donut.vx
-		donut.y += donut.vy
+	// Run the simulation systems, or follow the sync server's
+	if g.syncClient != nil {
+		g.applySnapshot()
+	} else {
+		g.fieldSystem()
+		g.vortexSystem()
+		g.portalSystem()
+		g.springSystem()
+		if g.magnets {
+			g.magnetSystem()
+		}
+		g.movementSystem()
+		if g.link != nil {
+			g.updateLink()
+		}
+		g.boundsSystem()
+		if g.qualityTier() < qualityCoarse || g.frame%2 == 0 {
+			g.collisionSystem()
+		}
+		g.energySystem()
+		if g.midi != nil {
+			g.midi.update(g.frame)
+		}
+		g.statsSystem()
+	}
+	g.traceSystem()
+	g.squashSystem()
+	if g.qualityTier() < qualityNoEffects {
+		g.shakeSystem()
+	} else {
+		g.shake.x, g.shake.y = 0, 0
+	}
+	g.lifetimeSystem()
+	if g.crumbsEnabled && g.qualityTier() < qualityNoEffects {
+		g.dropCrumbs()
+	}
 
-		// Update rotation
-		donut.rotation += donut.rotationSpeed
+	// Timer milestones
+	g.checkMilestones()
+	g.achievementSystem()
+	g.sharpenSVG()
+	if g.timerFlash > 0 {
+		g.timerFlash--
+	}
 
-		// Bounce off edges
-		if donut.x <= 0 || donut.x >= float64(g.screenWidth)-g.donutWidth {
-			donut.vx = -donut.vx
-			if donut.x <= 0 {
-				donut.x = 0
-			} else {
-				donut.x = float64(g.screenWidth) - g.donutWidth
-			}
+	// Update the HUD widgets, a failing widget is removed rather than fatal
+	g.overlays = slices.DeleteFunc(g.overlays, func(o plugin.Overlay) bool {
+		if err := o.Update(g); err != nil {
+			slog.Warn("overlay removed", "err", err)
+			return true
 		}
-		if donut.y <= 0 || donut.y >= float64(g.screenHeight)-g.donutHeight {
-			donut.vy = -donut.vy
-			if donut.y <= 0 {
-				donut.y = 0
-			} else {
-				donut.y = float64(g.screenHeight) - g.donutHeight
-			}
+		return false
+	})
+
+	// Let the script adjust the donuts, a failing script is disabled rather than fatal
+	if g.script != nil {
+		if err := g.script.runFrame(g); err != nil {
+			slog.Warn("script disabled", "err", err)
+			g.script = nil
 		}
 	}
 
-	// Check for collisions between donuts
-	g.handleDonutCollisions()
+	if g.syncServer != nil {
+		g.syncServer.publish(g)
+	}
+	g.updateQuality()
+	g.cycleFPS()
+	g.updated = true
 
+	g.frame++
 	return nil
 }
 
-// handleDonutCollisions checks for and resolves collisions between donuts
-func (g *Game) handleDonutCollisions() {
-	radius := g.donutWidth / 2 // Assuming width == height for circular donuts
-
-	for i := 0; i < len(g.donuts); i++ {
-		for j := i + 1; j < len(g.donuts); j++ {
-			donut1 := &g.donuts[i]
-			donut2 := &g.donuts[j]
-
-			// Calculate center positions
-			center1X := donut1.x + radius
-			center1Y := donut1.y + radius
-			center2X := donut2.x + radius
-			center2Y := donut2.y + radius
+// pollInput returns the actions to apply this frame, either from the keyboard or from the
+// replay being played back. Keyboard actions are recorded when recording is enabled.
+func (g *Game) pollInput() []replayEvent {
+	if g.replay != nil {
+		events := g.replay.eventsFor(g.frame)
+		if g.replay.finished() {
+			slog.Info("replay finished", "frame", g.frame)
+			g.replay = nil
+		}
+		return events
+	}
+	var events []replayEvent
 
-			// Check if donuts are colliding
-			if g.areDonutsColliding(center1X, center1Y, center2X, center2Y, radius) {
-				g.resolveCollision(donut1, donut2, center1X, center1Y, center2X, center2Y)
-			}
+	// Attract mode switches configuration periodically, also while the input is locked
+	if g.attract != nil {
+		if s, ok := g.attract.next(g.frame); ok {
+			events = append(events, replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s})
 		}
 	}
-}
 
-// areDonutsColliding checks if two circular donuts are overlapping
-func (g *Game) areDonutsColliding(x1, y1, x2, y2, radius float64) bool {
-	dx := x2 - x1
-	dy := y2 - y1
-	distance := math.Sqrt(dx*dx + dy*dy)
-	return distance < (radius * 2) // Two circles collide when distance < sum of radii
+	if !g.inputLocked {
+		events = g.pollKeys(events)
+	}
+
+	for _, ev := range events {
+		g.recordEvent(ev)
+	}
+	return events
 }
 
-// resolveCollision handles the physics of two donuts colliding
-func (g *Game) resolveCollision(donut1, donut2 *Donut, center1X, center1Y, center2X, center2Y float64) {
-	// Calculate collision vector
-	dx := center2X - center1X
-	dy := center2Y - center1Y
-	distance := math.Sqrt(dx*dx + dy*dy)
+// pollKeys appends the actions of the keys and mouse buttons pressed this frame to events
+func (g *Game) pollKeys(events []replayEvent) []replayEvent {
+	// Plus and minus add or remove a donut, 10 with Shift and 100 with Ctrl
+	step := 1
+	if ebiten.IsKeyPressed(ebiten.KeyControl) {
+		step = 100
+	} else if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		step = 10
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyEqual) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadAdd) {
+		events = append(events, g.donutCountEvent(actionAddDonut, step))
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyMinus) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadSubtract) {
+		events = append(events, g.donutCountEvent(actionRemoveDonut, -step))
+	}
 
-	// Avoid division by zero
-	if distance == 0 {
-		dx = 1
-		dy = 0
-		distance = 1
+	// R toggles rainbow mode
+	if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		s := g.currentSettings()
+		s.Rainbow = !s.Rainbow
+		events = append(events, replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s})
 	}
 
-	// Normalize collision vector
-	nx := dx / distance
-	ny := dy / distance
+	// O toggles magnet mode
+	if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+		s := g.currentSettings()
+		s.Magnets = !s.Magnets
+		events = append(events, replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s})
+	}
 
-	// Separate the donuts so they don't overlap
-	radius := g.donutWidth / 2
-	overlap := (radius * 2) - distance
-	separationX := nx * overlap * 0.5
-	separationY := ny * overlap * 0.5
+	// V adds or removes a vortex at the cursor, Shift+V re-randomizes the velocities
+	events = g.pollVortex(events)
+	if inpututil.IsKeyJustPressed(ebiten.KeyV) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		events = append(events, replayEvent{Frame: g.frame, Action: actionRandomize})
+	}
 
-	donut1.x -= separationX
-	donut1.y -= separationY
-	donut2.x += separationX
-	donut2.y += separationY
+	// G toggles painting the force field with the mouse
+	events = g.pollField(events)
 
-	// Calculate relative velocity
-	dvx := donut2.vx - donut1.vx
-	dvy := donut2.vy - donut1.vy
+	// T cycles through the themes
+	events = g.pollTheme(events)
 
-	// Calculate relative velocity along collision normal
-	dvn := dvx*nx + dvy*ny
+	// Number keys select presets
+	for i, p := range g.presets[:min(len(g.presets), 9)] {
+		if inpututil.IsKeyJustPressed(ebiten.KeyDigit1+ebiten.Key(i)) || inpututil.IsKeyJustPressed(ebiten.KeyNumpad1+ebiten.Key(i)) {
+			s := p.settings
+			events = append(events, replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s})
+		}
+	}
+	return events
+}
 
-	// Don't resolve if velocities are separating
-	if dvn > 0 {
+// recordEvent appends ev to the recording, if one is active
+func (g *Game) recordEvent(ev replayEvent) {
+	if g.recorder == nil {
 		return
 	}
+	if err := g.recorder.record(ev); err != nil {
+		slog.Error("recording stopped", "err", err)
+		g.recorder.Close()
+		g.recorder = nil
+	}
+}
+
+// donutCountEvent returns the event changing the donut count by delta. Single donuts use
+// the add and remove actions, bigger steps set the count like the settings scene does.
+func (g *Game) donutCountEvent(single inputAction, delta int) replayEvent {
+	if delta == 1 || delta == -1 {
+		return replayEvent{Frame: g.frame, Action: single}
+	}
+	s := g.currentSettings()
+	s.Donuts = max(minDonuts, min(maxDonuts, s.Donuts+delta))
+	return replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s}
+}
+
+// maxDonutCount returns the most donuts there can be
+func (g *Game) maxDonutCount() int {
+	if g.donutLimit > 0 {
+		return min(maxDonuts, g.donutLimit)
+	}
+	return maxDonuts
+}
+
+// applyAction changes the simulation in response to a live or replayed input
+func (g *Game) applyAction(ev replayEvent) {
+	switch ev.Action {
+	case actionAddDonut:
+		if q := g.quality; q.fewerDonuts() {
+			q.donuts = min(q.donuts+1, g.maxDonutCount()) // Added when the quality is restored
+		} else if g.numDonuts < g.maxDonutCount() {
+			g.numDonuts++
+			g.resetDonuts()
+		}
+	case actionRemoveDonut:
+		if q := g.quality; q.fewerDonuts() {
+			q.donuts = max(q.donuts-1, minDonuts)
+		} else if g.numDonuts > minDonuts {
+			g.numDonuts--
+			g.resetDonuts()
+		}
+	case actionResize:
+		g.resizeWorld(ev.Width, ev.Height)
+	case actionSettings:
+		if ev.Settings != nil {
+			g.applySettings(*ev.Settings)
+		}
+	case actionChatDonut:
+		g.spawnChatDonut(ev.Name)
+	case actionChatPop:
+		g.popChatDonut()
+	case actionRandomize:
+		g.randomizeVelocities()
+	case actionVortex:
+		g.toggleVortex(ev.X, ev.Y)
+	case actionPaintField:
+		if ev.Stroke != nil {
+			g.field.paint(*ev.Stroke, g.screenWidth, g.screenHeight)
+		}
+	}
+}
 
-	// Collision impulse (assuming equal mass and elastic collision)
-	impulse := 2 * dvn / 2 // divided by 2 because we have 2 objects of equal mass
+// resetDonuts recreates all donuts for the current count and screen size
+func (g *Game) resetDonuts() {
+	g.world.clearDonuts()
+	for i, d := range createDonuts(g.rng, g.spawner, g.screenWidth, g.screenHeight, g.donutWidth, g.donutHeight, g.numDonuts, g.minSpeed, g.maxSpeed, g.minSpin, g.maxSpin) {
+		depth := i % max(1, g.numLayers)
+		e := g.world.spawnDonut(inDepthLayer(d, depth), g.donutImageFor(i))
+		g.world.layers.Add(e, layer{depth})
+		g.ageDonut(e, false)
+	}
+	g.linkChains()
+	g.energy.rebase()
+}
+
+// resizeWorld changes the screen size, moving every entity so it keeps its place relative
+// to the screen. Sprites are kept inside the new bounds, velocities don't change.
+func (g *Game) resizeWorld(width, height int) {
+	sx := float64(width) / float64(max(1, g.screenWidth))
+	sy := float64(height) / float64(max(1, g.screenHeight))
+	g.screenWidth, g.screenHeight = width, height
+
+	w := g.world
+	for i := range w.positions.Len() {
+		e, pos := w.positions.At(i)
+		var spriteWidth, spriteHeight float64
+		if spr := w.sprites.Get(e); spr != nil {
+			spriteWidth, spriteHeight = spr.width, spr.height
+		}
+		// Scale the center rather than the corner so sprites stay centered on their spot
+		pos.x = (pos.x+spriteWidth/2)*sx - spriteWidth/2
+		pos.y = (pos.y+spriteHeight/2)*sy - spriteHeight/2
+		if spriteWidth > 0 {
+			pos.x = max(0, min(float64(width)-spriteWidth, pos.x))
+			pos.y = max(0, min(float64(height)-spriteHeight, pos.y))
+		}
+	}
+}
 
-	// Update velocities
-	donut1.vx += impulse * nx
-	donut1.vy += impulse * ny
-	donut2.vx -= impulse * nx
-	donut2.vy -= impulse * ny
+// donutImageFor returns the sprite variant for the i-th donut. Variants are assigned in
+// turn rather than randomly so the simulation's random sequence doesn't depend on them.
+func (g *Game) donutImageFor(i int) *ebiten.Image {
+	if len(g.donutImages) < 2 {
+		return g.donutImage
+	}
+	return g.donutImages[i%len(g.donutImages)]
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	screen.Fill(color.RGBA{A: 255}) // Black background
+	if g.camera.zoomed() || g.shake.x != 0 || g.shake.y != 0 || g.oled != nil {
+		g.drawTransformed(screen)
+	} else {
+		g.drawScene(screen)
+	}
+	if g.thumbnailSlot != "" {
+		g.saveThumbnail(screen)
+	}
+}
 
-	// Draw each donut
-	for _, donut := range g.donuts {
-		op := &ebiten.DrawImageOptions{}
+// drawScene draws the frame onto screen
+func (g *Game) drawScene(screen *ebiten.Image) {
+	if !g.transparent {
+		screen.Fill(g.theme.background)
+	} else {
+		screen.Clear()
+	}
+	if g.background != nil {
+		g.background.Draw(screen, g)
+	}
 
-		// Apply transformations in the correct order for rotation around center:
-		// 1. Scale the image
-		op.GeoM.Scale(donutScale, donutScale)
+	// With trails enabled donuts are drawn onto a layer that is only partially cleared
+	effects := g.qualityTier() < qualityNoEffects
+	trails := g.trails && effects
+	target := screen
+	if trails {
+		target = g.fadeTrailLayer()
+	}
 
-		// 2. Translate to center the rotation point (move origin to center of scaled image)
-		op.GeoM.Translate(-g.donutWidth/2, -g.donutHeight/2)
+	if g.crumbsEnabled && effects {
+		g.crumbs.draw(target, g.frame)
+	}
 
-		// 3. Rotate around the origin (which is now at the center)
-		op.GeoM.Rotate(donut.rotation)
+	g.drawVortices(target)
+	g.drawPortals(target)
+	g.drawSprings(target)
 
-		// 4. Translate back and then to final position
-		op.GeoM.Translate(g.donutWidth/2, g.donutHeight/2)
-		op.GeoM.Translate(donut.x, donut.y)
+	// Draw each donut and the particles in front of them
+	g.renderSystem(target)
+	if effects {
+		g.drawParticles(target)
+	}
+	g.drawLabels(target)
+	g.field.draw(target)
 
-		screen.DrawImage(g.donutImage, op)
+	if trails {
+		screen.DrawImage(target, nil)
 	}
+	g.dimLowPower(screen)
 
-	// Draw the elapsed time timer in upper left corner
-	g.drawTimer(screen)
+	// Draw the elapsed time timer at its configured anchor, or the score of an arcade game
+	if g.scoreboard != "" {
+		g.drawScoreboard(screen)
+	} else {
+		g.drawTimer(screen)
+	}
+
+	for _, o := range g.overlays {
+		o.Draw(screen, g)
+	}
+	g.drawToast(screen)
 }
 
-// drawTimer renders the elapsed time timer in HHH:MM:SS format with configurable size
-func (g *Game) drawTimer(screen *ebiten.Image) {
-	// Calculate elapsed time since the configured start time
-	now := time.Now()
-	elapsed := now.Sub(g.timerStartTime)
+// Game implements plugin.World
+func (g *Game) Size() (int, int)       { return g.screenWidth, g.screenHeight }
+func (g *Game) Frame() int             { return g.frame }
+func (g *Game) Rand() *rand.Rand       { return g.rng }
+func (g *Game) NumBodies() int         { return g.world.donuts.Len() }
+func (g *Game) Body(i int) plugin.Body { return g.world.body(g.world.donut(i)) }
+func (g *Game) PixelScale() float64    { return pixelScale }
+
+// fadeTrailLayer returns the trail layer after fading its previous contents toward the
+// background, creating it when the screen size changed
+func (g *Game) fadeTrailLayer() *ebiten.Image {
+	const fade = 40 // Alpha of the background drawn over the trails each frame
+
+	width, height := toScreen(g.screenWidth), toScreen(g.screenHeight)
+	if g.trailLayer == nil || g.trailLayer.Bounds().Dx() != width || g.trailLayer.Bounds().Dy() != height {
+		if g.trailLayer != nil {
+			g.trailLayer.Dispose()
+		}
+		g.trailLayer = ebiten.NewImage(width, height)
+		if !g.clearBackground() {
+			g.trailLayer.Fill(g.theme.background)
+		}
+	}
 
-	// If start time is in the future, show 000:00:00
-	if elapsed < 0 {
-		elapsed = 0
+	// Over a background the trails fade to transparent so it shows through
+	if g.clearBackground() {
+		op := &ebiten.DrawImageOptions{Blend: ebiten.BlendDestinationOut}
+		op.GeoM.Scale(float64(width), float64(height))
+		op.ColorScale.ScaleAlpha(fade / 255.0)
+		g.trailLayer.DrawImage(whitePixel, op)
+		return g.trailLayer
 	}
 
-	// Convert to hours, minutes, and seconds
-	totalSeconds := int(elapsed.Seconds())
-	hours := totalSeconds / 3600
-	minutes := (totalSeconds % 3600) / 60
-	seconds := totalSeconds % 60
+	bg := g.theme.background
+	bg.R = uint8(uint16(bg.R) * fade / 255)
+	bg.G = uint8(uint16(bg.G) * fade / 255)
+	bg.B = uint8(uint16(bg.B) * fade / 255)
+	bg.A = fade
+	drawFilledRect(g.trailLayer, 0, 0, float32(g.screenWidth), float32(g.screenHeight), bg, false)
+	return g.trailLayer
+}
 
-	// Format as HHH:MM:SS (3-digit hours, 2-digit minutes and seconds)
-	timerText := fmt.Sprintf("%03d:%02d:%02d", hours, minutes, seconds)
+// now returns the current time, corrected by NTP when configured
+func (g *Game) now() time.Time {
+	if g.clock == nil {
+		return time.Now()
+	}
+	return time.Now().Add(g.clock.offset())
+}
+
+// elapsed returns the time since the configured start time, 0 if it is in the future
+func (g *Game) elapsed() time.Duration {
+	return max(0, g.now().Sub(g.timerStartTime))
+}
 
+// formatElapsed formats d as days, hours and minutes, e.g. "3d 4h 5m"
+func formatElapsed(d time.Duration) string {
 	// Calculate days, hours, and minutes for human-readable format
-	totalMinutes := int(elapsed.Minutes())
+	totalMinutes := int(d.Minutes())
 	days := totalMinutes / (24 * 60)
 	remainingMinutes := totalMinutes % (24 * 60)
 	displayHours := remainingMinutes / 60
 	displayMinutes := remainingMinutes % 60
 
-	// Format human-readable line
-	var humanText string
 	if days > 0 {
-		humanText = fmt.Sprintf("%dd %dh %dm", days, displayHours, displayMinutes)
+		return trf("%dd %dh %dm", days, displayHours, displayMinutes)
 	} else if displayHours > 0 {
-		humanText = fmt.Sprintf("%dh %dm", displayHours, displayMinutes)
-	} else {
-		humanText = fmt.Sprintf("%dm", displayMinutes)
-	}
-
-	// Calculate text dimensions with the base font
-	baseFontHeight := 13 // basicfont.Face7x13 height
-	baseFontWidth := 7   // basicfont.Face7x13 character width
-	
-	// Calculate dimensions for both lines
-	line1Width := len(timerText) * baseFontWidth
-	line2Width := len(humanText) * baseFontWidth
-	maxWidth := line1Width
-	if line2Width > maxWidth {
-		maxWidth = line2Width
-	}
-	
-	textHeight := baseFontHeight * 2 + 4 // Two lines plus some spacing
-
-	// Create a temporary image to draw both lines at base size
-	tempImg := ebiten.NewImage(maxWidth, textHeight+4)
-	tempImg.Fill(color.RGBA{0, 0, 0, 0}) // Transparent background
-
-	// Draw first line (HHH:MM:SS format)
-	text.Draw(tempImg, timerText, basicfont.Face7x13, 0, baseFontHeight, color.RGBA{50, 150, 50, 255})
-	
-	// Draw second line (human-readable format)
-	text.Draw(tempImg, humanText, basicfont.Face7x13, 0, baseFontHeight*2+2, color.RGBA{50, 150, 50, 255})
-
-	// Calculate scale factor based on desired font size
+		return trf("%dh %dm", displayHours, displayMinutes)
+	}
+	return trf("%dm", displayMinutes)
+}
+
+// timerLines returns the lines of text drawn by drawTimer
+func (g *Game) timerLines(elapsed time.Duration) []string {
+	if g.incident != nil {
+		return g.incident.lines(elapsed)
+	}
+
+	// Convert to hours, minutes, and seconds
+	totalSeconds := int(elapsed.Seconds())
+	hours := totalSeconds / 3600
+	minutes := (totalSeconds % 3600) / 60
+	seconds := totalSeconds % 60
+
+	// Format as HHH:MM:SS (3-digit hours, 2-digit minutes and seconds) above the
+	// human-readable line
+	return []string{fmt.Sprintf("%03d:%02d:%02d", hours, minutes, seconds), formatElapsed(elapsed)}
+}
+
+// drawTimer renders the elapsed time timer in HHH:MM:SS format with configurable size
+func (g *Game) drawTimer(screen *ebiten.Image) {
+	// Calculate elapsed time since the configured start time, if the start time is in the
+	// future this shows 000:00:00
+	elapsed := g.elapsed()
+
+	// Calculate scale factor based on desired font size, the text is rendered at that size
 	scaleFactor := float64(timerFontSize) / float64(baseFontHeight)
+	tempImg, maxWidth, textHeight := g.timerImage.render(g, elapsed, scaleFactor)
+	style := g.timerStyle
+
+	// Place the timer at its anchor, using the current screen size so it follows resizes
+	margin := float64(timerMargin)
+	if style.Margin != nil {
+		margin = float64(*style.Margin)
+	}
+	// The caption and the timer are placed together as one block, centered on each other
+	timerWidth, timerHeight := float64(maxWidth), float64(textHeight)
+	caption, captionScale, captionWidth, captionHeight := g.caption(timerWidth, scaleFactor)
+	blockWidth := max(timerWidth, captionWidth)
+	screenWidth, screenHeight := logicalSize(screen)
+	bx, by := style.Anchor.position(int(screenWidth), int(screenHeight), blockWidth, timerHeight+captionHeight, margin)
+	x, y := bx+(blockWidth-timerWidth)/2, by
+	captionY := by + timerHeight
+	if caption != nil && style.Caption.Position != captionBelow {
+		y, captionY = by+captionHeight, by
+	}
+
+	// Optional rounded panel behind the text
+	if style.Panel != nil {
+		pad := scaleFactor * 2
+		w := blockWidth + 2*pad
+		h := timerHeight + captionHeight + 2*pad
+		fillRoundedRect(screen, float32(bx-pad), float32(by-pad), float32(w), float32(h), float32(pad*2), *style.Panel)
+	}
+	if caption != nil {
+		g.drawCaption(screen, caption, captionScale, bx, captionY, blockWidth)
+	}
+
+	// drawPass draws the text offset by dx, dy in the given color
+	drawPass := func(dx, dy float64, clr color.Color) {
+		op := &g.drawOp
+		op.GeoM.Reset()
+		op.ColorScale.Reset()
+		// The image is already at screen resolution, only its position is scaled
+		op.GeoM.Translate(math.Round((x+dx)*pixelScale), math.Round((y+dy)*pixelScale))
+		op.ColorScale.ScaleWithColor(clr)
+		screen.DrawImage(tempImg, op)
+	}
+
+	if style.Shadow != nil {
+		offset := scaleFactor * 0.6
+		drawPass(offset, offset, style.Shadow)
+	}
+	if style.Outline != nil {
+		width := max(1, scaleFactor*0.25)
+		for _, d := range outlineOffsets {
+			drawPass(d[0]*width, d[1]*width, style.Outline)
+		}
+	}
+
+	// Draw the text to the screen
+	g.scratchTint = g.timerColor()
+	drawPass(0, 0, &g.scratchTint)
+}
 
-	// Draw the scaled text to the screen
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(scaleFactor, scaleFactor)
-	op.GeoM.Translate(float64(timerPosX), float64(timerPosY))
+// outlineOffsets are the directions the timer outline is drawn in
+var outlineOffsets = [][2]float64{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+
+// timerImage caches the timer text drawn at its final size in white, so each pass of
+// drawTimer can color it. It's redrawn only when the text can have changed, once a second.
+type timerImage struct {
+	img           *ebiten.Image
+	second        int64
+	start         time.Time
+	scale         float64
+	pixels        float64 // pixelScale the image is drawn at
+	width, height int
+	op            ebiten.DrawImageOptions
+}
+
+// render returns the image for elapsed at scale times the base font size, in screen
+// pixels, with the size of the text in it in simulation pixels
+func (t *timerImage) render(g *Game, elapsed time.Duration, scale float64) (*ebiten.Image, int, int) {
+	second := int64(elapsed / time.Second)
+	if t.img != nil && second == t.second && scale == t.scale && t.pixels == pixelScale && g.timerStartTime.Equal(t.start) {
+		return t.img, t.width, t.height
+	}
+	t.second, t.start, t.scale, t.pixels = second, g.timerStartTime, scale, pixelScale
 
-	screen.DrawImage(tempImg, op)
+	lines := g.timerLines(elapsed)
+	var width float64
+	for _, line := range lines {
+		width = max(width, textWidth(line, scale))
+	}
+	lineHeight := (baseFontHeight + 2) * scale // Lines plus some spacing
+	t.width = int(math.Ceil(width))
+	t.height = int(math.Ceil(float64(len(lines)) * lineHeight))
+	imgWidth := max(1, toScreen(t.width))
+	imgHeight := toScreen(t.height + int(math.Ceil(4*scale)))
+
+	if t.img == nil || t.img.Bounds().Dx() != imgWidth || t.img.Bounds().Dy() != imgHeight {
+		if t.img != nil {
+			t.img.Dispose()
+		}
+		t.img = ebiten.NewImage(imgWidth, imgHeight)
+	} else {
+		t.img.Clear()
+	}
+	for i, line := range lines {
+		drawGlyphs(t.img, line, 0, float64(i)*lineHeight, scale, &t.op, color.White)
+	}
+	return t.img, t.width, t.height
 }
 
+// Layout sizes the screen in device pixels, so it's drawn at the monitor's native
+// resolution. The simulation keeps working in the device independent pixels of the window.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	pixelScale = deviceScale()
+
+	// During playback or with a fixed resolution the size stays and ebiten scales it to the
+	// window, adding black bars when the aspect ratio differs
+	if g.replay != nil || g.fixedSize {
+		return toScreen(g.screenWidth), toScreen(g.screenHeight)
+	}
+
 	// Update screen dimensions when the window is resized
 	if g.screenWidth != outsideWidth || g.screenHeight != outsideHeight {
-		g.screenWidth = outsideWidth
-		g.screenHeight = outsideHeight
-		// Recreate donuts with new screen dimensions
-		g.donuts = createDonuts(outsideWidth, outsideHeight, g.donutWidth, g.donutHeight, g.numDonuts)
+		ev := replayEvent{Frame: g.frame, Action: actionResize, Width: outsideWidth, Height: outsideHeight}
+		g.recordEvent(ev)
+		g.applyAction(ev)
 	}
-	return outsideWidth, outsideHeight
+	return toScreen(g.screenWidth), toScreen(g.screenHeight)
 }
 
-func loadDonutImage() (*ebiten.Image, error) {
-	img, _, err := image.Decode(bytes.NewReader(donutPNG))
-	if err != nil {
-		return nil, err
+// randomizeVelocities gives every donut a new random speed and rotation speed in the
+// configured ranges, keeping the donuts where they are and their spin direction. Donuts
+// on far parallax layers stay slower like when they're created.
+func (g *Game) randomizeVelocities() {
+	for _, e := range g.world.donuts.Entities() {
+		if vel := g.world.velocities.Get(e); vel != nil {
+			speed := depthLayers[g.world.layerOf(e)].speed
+			vel.x = speed * (g.minSpeed + g.rng.Float64()*(g.maxSpeed-g.minSpeed))
+			vel.y = speed * (g.minSpeed + g.rng.Float64()*(g.maxSpeed-g.minSpeed))
+			if g.rng.Float64() < 0.5 {
+				vel.x = -vel.x
+			}
+			if g.rng.Float64() < 0.5 {
+				vel.y = -vel.y
+			}
+		}
+		if s := g.world.spins.Get(e); s != nil {
+			speed := g.minSpin + g.rng.Float64()*(g.maxSpin-g.minSpin)
+			s.speed = math.Copysign(speed, s.speed)
+		}
 	}
-	return ebiten.NewImageFromImage(img), nil
+	g.energy.rebase()
 }
 
-func createDonuts(screenWidth, screenHeight int, donutWidth, donutHeight float64, numDonuts int) []Donut {
+func createDonuts(rng *rand.Rand, spawn spawner, screenWidth, screenHeight int, donutWidth, donutHeight float64, numDonuts int, minSpeed, maxSpeed, minSpin, maxSpin float64) []Donut {
 	donuts := make([]Donut, numDonuts)
-
-	// Define the center area where donuts will spawn (middle 50% of screen)
-	centerX := float64(screenWidth) / 2
-	centerY := float64(screenHeight) / 2
-	spawnRadius := math.Min(float64(screenWidth), float64(screenHeight)) * 0.25
+	area := spawnArea{float64(screenWidth), float64(screenHeight), donutWidth, donutHeight}
 
 	for i := 0; i < numDonuts; i++ {
-		// Random position near center
-		angle := rand.Float64() * 2 * math.Pi
-		distance := rand.Float64() * spawnRadius
-		x := centerX + math.Cos(angle)*distance - donutWidth/2
-		y := centerY + math.Sin(angle)*distance - donutHeight/2
+		x, y, heading, aimed := spawn(rng, i, numDonuts, area)
 
 		// Ensure donuts stay within screen bounds
 		if x < 0 {
@@ -348,19 +842,23 @@ func createDonuts(screenWidth, screenHeight int, donutWidth, donutHeight float64
 
 		// Random velocity with consistent dx/dy components like the original
 		// Generate random vx and vy independently to ensure good movement in both directions
-		vx := 1.5 + rand.Float64()*3.0 // Between 1.5 and 4.5
-		vy := 1.5 + rand.Float64()*3.0 // Between 1.5 and 4.5
+		vx := minSpeed + rng.Float64()*(maxSpeed-minSpeed) // Between minSpeed and maxSpeed
+		vy := minSpeed + rng.Float64()*(maxSpeed-minSpeed) // Between minSpeed and maxSpeed
 
 		// Randomly make velocities negative to get different directions
-		if rand.Float64() < 0.5 {
+		if rng.Float64() < 0.5 {
 			vx = -vx
 		}
-		if rand.Float64() < 0.5 {
+		if rng.Float64() < 0.5 {
 			vy = -vy
 		}
+		if aimed {
+			speed := math.Hypot(vx, vy)
+			vx, vy = speed*math.Cos(heading), speed*math.Sin(heading)
+		}
 
 		// Alternating rotation direction (clockwise vs counter-clockwise)
-		rotationSpeed := 0.015 + rand.Float64()*0.02 // Base speed with some variation
+		rotationSpeed := minSpin + rng.Float64()*(maxSpin-minSpin) // Between minSpin and maxSpin
 		if i%2 == 1 {
 			rotationSpeed = -rotationSpeed // Counter-clockwise for every other donut
 		}
@@ -370,7 +868,9 @@ func createDonuts(screenWidth, screenHeight int, donutWidth, donutHeight float64
 			y:             y,
 			vx:            vx,
 			vy:            vy,
-			rotation:      rand.Float64() * 2 * math.Pi, // Random starting rotation
+			width:         donutWidth,
+			height:        donutHeight,
+			rotation:      rng.Float64() * 2 * math.Pi, // Random starting rotation
 			rotationSpeed: rotationSpeed,
 		}
 	}
@@ -379,39 +879,553 @@ func createDonuts(screenWidth, screenHeight int, donutWidth, donutHeight float64
 }
 
 func main() {
+	// donutctl is the same binary, run under that name or as "donut ctl"
+	if filepath.Base(strings.TrimSuffix(os.Args[0], ".exe")) == "donutctl" {
+		os.Exit(runControlClient(os.Args[1:]))
+	}
+	os.Exit(runCommand(os.Args[1:]))
+}
 
+// runScreensaver runs the screensaver with the parsed runFlags until it exits and returns
+// the process exit code
+func runScreensaver() int {
 	//fmt.Println(timerStartTime.Local().Format(time.RFC850))
 	//os.Exit(0)
 
-	donutImage, err := loadDonutImage()
+	closeLog, err := setupLogging()
 	if err != nil {
-		log.Fatal("Failed to load donut.png:", err)
+		fmt.Fprintln(os.Stderr, "donut:", err)
+		os.Exit(2)
+	}
+	defer closeLog()
+	defer reportPanic()
+	if err := applyProfile(); err != nil {
+		fatal("invalid -profile", "err", err)
+	}
+
+	if *pprofAddr != "" {
+		startProfiler(*pprofAddr)
+	}
+	if *updateCheck > 0 {
+		checkUpdates(*updateCheck, *autoUpdate)
 	}
 
+	cfg, err := loadConfig(*configFlag)
+	if err != nil {
+		fatal("failed to load config", "path", *configFlag, "err", err)
+	}
+	crashConfig = &cfg
+	setupLocale(cfg.Locale)
+	setupAccessibility(cfg.Accessibility)
+	if err := addThemes(cfg.Themes); err != nil {
+		fatal("invalid theme", "err", err)
+	}
+	presets := buildPresets(cfg.Presets, cfg.Velocity)
+	initial := cfg.Velocity.apply(builtinPresets[0].settings)
+	if *presetFlag != "" {
+		p, err := findPreset(presets, *presetFlag)
+		if err != nil {
+			fatal("invalid -preset", "err", err)
+		}
+		initial = p.settings
+	}
+	if *resumeFlag {
+		if saved := loadState().Settings; saved != nil {
+			initial = *saved
+		}
+	}
+	if *countFlag != 0 {
+		if *countFlag < minDonuts || *countFlag > maxDonuts {
+			fatal("invalid -count", "count", *countFlag, "min", minDonuts, "max", maxDonuts)
+		}
+		initial.Donuts = *countFlag
+	}
+
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var replay *replayPlayer
+	if *replayFlag != "" {
+		replay, err = loadReplay(*replayFlag)
+		if err != nil {
+			fatal("failed to load replay", "path", *replayFlag, "err", err)
+		}
+		seed = replay.header.Seed
+		initial = replay.header.Settings
+	}
+	spawnName := cmp.Or(cfg.Spawn, defaultSpawner)
+	if replay != nil {
+		spawnName = cmp.Or(replay.header.Spawn, defaultSpawner)
+	}
+	spawn, ok := spawners[spawnName]
+	if !ok {
+		fatal("unknown spawn", "spawn", spawnName, "available", spawnerNames())
+	}
+	edgesCfg := cfg.Edges
+	if replay != nil {
+		edgesCfg = ptrOr(replay.header.Edges)
+	}
+	edges, err := edgesCfg.modes()
+	if err != nil {
+		fatal("invalid edges", "err", err)
+	}
+	vortices, portals := cfg.Vortices, cfg.Portals
+	if replay != nil {
+		vortices, portals = replay.header.Vortices, replay.header.Portals
+	}
+	energy, err := parseEnergyMode(*energyFlag)
+	if err != nil {
+		fatal("invalid -energy", "err", err)
+	}
+	// Correcting changes the simulation, so a replay corrects exactly when its recording did
+	if replay != nil && replay.header.Energy == energyCorrect {
+		energy = energyCorrect
+	} else if replay != nil && energy == energyCorrect {
+		energy = energyWarn
+	}
+	slog.Info("starting", "seed", seed, "build", currentBuild().String())
+
+	var donutImages []*ebiten.Image
+	var svg *svgSprite
+	if *glyphFlag != "" || cfg.Glyph != nil {
+		glyph := ptrOr(cfg.Glyph)
+		glyph.Text = cmp.Or(*glyphFlag, glyph.Text)
+		img, err := glyphSprite(glyph)
+		if err != nil {
+			slog.Warn("can't render the glyph, using the built-in donut", "err", err)
+			img = loadDonutImage("")
+		}
+		donutImages = []*ebiten.Image{img}
+	} else if *procFlag || cfg.Procedural != nil {
+		procedural := ptrOr(cfg.Procedural)
+		if err := procedural.validate(); err != nil {
+			fatal("invalid procedural donuts", "err", err)
+		}
+		// Generated with their own source so the simulation's random sequence is unchanged
+		donutImages = proceduralVariants(procedural, rand.New(rand.NewSource(seed)))
+	} else if isSVG(*imageFlag) {
+		// Rasterized at the size donuts start at rather than the size of the file
+		svg, err = loadSVGSprite(*imageFlag, donutScale*max(1, initial.Size))
+		if err != nil {
+			slog.Warn("can't use image, using the built-in donut", "path", *imageFlag, "err", err)
+			donutImages = []*ebiten.Image{loadDonutImage("")}
+		} else {
+			donutImages = []*ebiten.Image{svg.img}
+		}
+	} else {
+		donutImages = []*ebiten.Image{loadDonutImage(*imageFlag)}
+	}
+	donutImage := donutImages[0]
+
 	// Calculate scaled dimensions
 	bounds := donutImage.Bounds()
 	donutWidth := float64(bounds.Dx()) * donutScale
 	donutHeight := float64(bounds.Dy()) * donutScale
+	if svg != nil {
+		// The size of an SVG comes from the document, its raster only sets the resolution
+		donutWidth, donutHeight = svg.doc.width*donutScale, svg.doc.height*donutScale
+	}
 
 	// Start with default dimensions - Layout method will update with actual window size
 	screenWidth, screenHeight := 800, 600 // Default dimensions
 
+	if replay != nil {
+		screenWidth, screenHeight = replay.header.Width, replay.header.Height
+	}
+
+	timerStart, err := resolveTimerStart()
+	if err != nil {
+		fatal("invalid timer start", "err", err)
+	}
+
+	rngSource := newCountingSource(seed)
+	rng := rand.New(rngSource)
+
 	game := &Game{
 		donutImage:     donutImage,
+		donutImages:    donutImages,
+		svg:            svg,
 		donutWidth:     donutWidth,
 		donutHeight:    donutHeight,
-		donuts:         createDonuts(screenWidth, screenHeight, donutWidth, donutHeight, initialDonuts),
+		spriteWidth:    donutWidth,
+		spriteHeight:   donutHeight,
 		screenWidth:    screenWidth,
 		screenHeight:   screenHeight,
-		numDonuts:      initialDonuts,
-		timerStartTime: timerStartTime,
+		world:          newWorld(),
+		spawner:        spawn,
+		edges:          edges,
+		energy:         energyMonitor{mode: energy},
+		rng:            rng,
+		rngSource:      rngSource,
+		fx:             rand.New(rand.NewSource(seed + 1)),
+		replay:         replay,
+		presets:        presets,
+		squashEnabled:  *squashFlag,
+		shake:          screenShake{enabled: (cfg.Shake == nil || *cfg.Shake) && !cfg.Accessibility.ReduceMotion},
+		highContrast:   cfg.Accessibility.HighContrast,
+		reduceMotion:   cfg.Accessibility.ReduceMotion,
+		timerStartTime: timerStart,
+		timerStyle:     cfg.Timer,
+		commands:       make(chan command, commandQueueSize),
+	}
+	if err := game.setupDisplay(cfg.Display); err != nil {
+		fatal("invalid display settings", "err", err)
+	}
+	if err := cfg.Brightness.validate(); err != nil {
+		fatal("invalid brightness", "err", err)
+	}
+	game.brightness = newBrightness(cfg.Brightness)
+	if cfg.NightLight != nil {
+		if err := cfg.NightLight.validate(); err != nil {
+			fatal("invalid night light", "err", err)
+		}
+		game.nightLight = newNightLight(*cfg.NightLight)
+	}
+	if *oledFlag || cfg.OLED != nil {
+		oled := ptrOr(cfg.OLED)
+		if err := oled.validate(); err != nil {
+			fatal("invalid OLED settings", "err", err)
+		}
+		game.setupOLED(newOLEDMode(oled))
+	}
+	if replay != nil {
+		// The simulation runs as it was recorded
+		game.reduceMotion = replay.header.ReduceMotion
+		game.donutLimit = replay.header.MaxDonuts
+		game.setFPS(cmp.Or(replay.header.FPS, ebiten.DefaultTPS))
+	}
+	game.applySettings(initial)
+	for _, v := range vortices {
+		game.addVortex(v.X*float64(game.screenWidth), v.Y*float64(game.screenHeight), v)
+	}
+	for i, p := range portals {
+		game.addPortals(i, p)
+	}
+	if cfg.Incident != nil {
+		game.incident, err = loadIncidentCounter(*cfg.Incident, timerStart)
+		if err != nil {
+			fatal("failed to load incident state", "err", err)
+		}
+		game.timerStartTime = game.incident.state.Start
+	}
+	// -resume continues the simulation saved on exit, or starts fresh with its settings
+	if *resumeFlag && *recordFlag == "" && replay == nil {
+		if err := game.restoreSnapshot(snapshotPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("not resuming the simulation", "err", err)
+		}
+	}
+	if cfg.NTP != nil {
+		if err := cfg.NTP.validate(); err != nil {
+			fatal("invalid NTP settings", "err", err)
+		}
+		game.clock = newNTPClock(*cfg.NTP)
+	}
+	if cfg.Milestones != nil {
+		game.milestones = newMilestoneTracker(*cfg.Milestones, game.elapsed())
 	}
 
-	// Don't set a specific window size - let it use the system default or fullscreen
-	ebiten.SetWindowTitle("Donut Screensaver")
-	ebiten.SetFullscreen(true)
+	rendererName := cmp.Or(cfg.Renderer, "sprite")
+	renderer, ok := plugin.LookupRenderer(rendererName)
+	if !ok {
+		fatal("unknown renderer", "renderer", rendererName, "available", plugin.Renderers())
+	}
+	game.renderer = renderer
+	if steps := *rotationStepsFlag; steps != 0 {
+		if steps < 0 || steps > maxRotationSteps {
+			fatal("invalid rotation steps, must be between 0 and the maximum", "steps", steps, "max", maxRotationSteps)
+		}
+		if rendererName != "sprite" {
+			slog.Warn("rotation steps only apply to the sprite renderer", "renderer", rendererName)
+		} else {
+			cache := newRotationCache(renderer, steps, game.spriteWidth, game.spriteHeight)
+			cache.prepare(append([]*ebiten.Image{game.donutImage}, game.donutImages...)...)
+			game.renderer = cache
+		}
+	} else if *batchFlag && rendererName == "sprite" {
+		game.renderer = newBatchRenderer(renderer, append([]*ebiten.Image{game.donutImage}, game.donutImages...)...)
+	}
+	setRendererFilter(game.renderer, game.filter)
+	if cfg.Slideshow != nil {
+		game.slideshow = newSlideshow(*cfg.Slideshow)
+	}
+	game.backgroundName = themeBackground
+	switch {
+	case game.oled != nil:
+		if cfg.Background != "" || cfg.Slideshow != nil {
+			slog.Warn("OLED-safe mode keeps the background black, ignoring the background")
+		}
+	case cfg.Slideshow != nil:
+		game.setBackground(slideshowBackground)
+	case cfg.Background != "":
+		if !game.setBackground(cfg.Background) {
+			fatal("unknown background", "background", cfg.Background, "available", plugin.Backgrounds())
+		}
+	}
+	for _, name := range cfg.Overlays {
+		overlay, ok := plugin.LookupOverlay(name)
+		if !ok {
+			fatal("unknown overlay", "overlay", name, "available", plugin.Overlays())
+		}
+		game.overlays = append(game.overlays, overlay)
+	}
+	if cfg.SystemStats != nil {
+		game.overlays = append(game.overlays, newSysStatsWidget(game, *cfg.SystemStats))
+	}
+	if cfg.Stats != nil || *summaryFlag != "" {
+		game.stats = newSimStats()
+	}
+	if cfg.Stats != nil {
+		game.overlays = append(game.overlays, &simStatsWidget{game, *cfg.Stats})
+	}
+	if cfg.NowPlaying != nil {
+		widget, err := newNowPlayingWidget(game, *cfg.NowPlaying)
+		if err != nil {
+			fatal("failed to start the now playing widget", "err", err)
+		}
+		game.overlays = append(game.overlays, widget)
+	}
+	if cfg.Weather != nil {
+		game.weather = newWeatherWidget(game, *cfg.Weather)
+		game.overlays = append(game.overlays, game.weather)
+	}
+	for _, countdown := range cfg.Countdowns {
+		widget, err := newCountdownWidget(game, countdown)
+		if err != nil {
+			fatal("failed to start the countdown widget", "err", err)
+		}
+		game.overlays = append(game.overlays, widget)
+	}
+	if cfg.Ticker != nil {
+		game.overlays = append(game.overlays, newTickerOverlay(game, *cfg.Ticker))
+	}
+	if cfg.WorldClock != nil {
+		var bottom float64
+		if cfg.Ticker != nil {
+			bottom = cfg.Ticker.barHeight()
+		}
+		widget, err := newWorldClockWidget(game, *cfg.WorldClock, bottom)
+		if err != nil {
+			fatal("failed to start the world clock", "err", err)
+		}
+		game.overlays = append(game.overlays, widget)
+	}
+
+	if *scriptFlag != "" {
+		game.script, err = loadScript(*scriptFlag)
+		if err != nil {
+			fatal("failed to load script", "path", *scriptFlag, "err", err)
+		}
+	}
+
+	if *audioFlag != "" {
+		game.audio, err = newAudioReactive(*audioFlag, *audioDev)
+		if err != nil {
+			fatal("failed to start audio capture", "err", err)
+		}
+		defer game.audio.close()
+	}
+
+	game.sound = newSound(loadState().Volumes)
+	if *musicFlag != "" {
+		if err := game.sound.startMusic(*musicFlag); err != nil {
+			fatal("failed to start music", "err", err)
+		}
+	}
+
+	if *midiFlag != "" {
+		game.midi, err = openMIDI(*midiFlag, *midiChan)
+		if err != nil {
+			fatal("failed to open MIDI device", "device", *midiFlag, "err", err)
+		}
+		defer game.midi.close()
+	}
+
+	if *adaptiveFlag {
+		game.quality = &qualityGovernor{}
+	}
+	startSessionWatch(game)
+	if err := startPowerWatch(game, *lowPowerFlag); err != nil {
+		fatal("invalid flag", "err", err)
+	}
+
+	if *attractArg > 0 {
+		game.attract = newAttractMode(*attractArg)
+	}
+
+	if *recordFlag != "" {
+		header := replayHeader{Seed: seed, Settings: game.currentSettings(), Width: game.screenWidth, Height: game.screenHeight, FPS: game.fps, Spawn: spawnName, ReduceMotion: game.reduceMotion, MaxDonuts: game.donutLimit}
+		if edgesCfg != (edgesConfig{}) {
+			header.Edges = &edgesCfg
+		}
+		header.Vortices, header.Portals = vortices, portals
+		if energy == energyCorrect {
+			header.Energy = energy
+		}
+		game.recorder, err = newReplayRecorder(*recordFlag, header)
+		if err != nil {
+			fatal("failed to start recording", "path", *recordFlag, "err", err)
+		}
+		defer game.recorder.Close()
+	}
+	if *traceFlag != "" {
+		game.trace, err = newTraceWriter(*traceFlag, *traceEveryFlag)
+		if err != nil {
+			fatal("failed to start the trace", "path", *traceFlag, "err", err)
+		}
+		defer func() {
+			if game.trace != nil {
+				game.trace.Close()
+			}
+		}()
+	}
+
+	// Fullscreen by default, a transparent window covers the screen without being fullscreen
+	ebiten.SetWindowTitle(windowTitle)
+	options := &ebiten.RunGameOptions{}
+	windowCfg := cfg.Window
+	windowCfg.Borderless = windowCfg.Borderless || *borderFlag
+	windowCfg.AlwaysOnTop = windowCfg.AlwaysOnTop || *onTopFlag
+	windowCfg.Windowed = windowCfg.Windowed || *windowFlag || windowCfg.Borderless || windowCfg.AlwaysOnTop
+	if *posFlag != "" {
+		x, y, err := parsePosition(*posFlag)
+		if err != nil {
+			fatal("invalid -position", "err", err)
+		}
+		windowCfg.X, windowCfg.Y, windowCfg.Windowed = &x, &y, true
+	}
+	if *transFlag {
+		if err := setupTransparentWindow(); err != nil {
+			fatal("transparent mode unavailable", "err", err)
+		}
+		game.transparent = true
+		options.ScreenTransparent = true
+		options.SkipTaskbar = true
+	} else if *wallFlag {
+		setupWallpaperWindow()
+		game.wallpaperPending = true
+		options.SkipTaskbar = true
+	} else if windowCfg.Windowed {
+		setupWindow(windowCfg, loadState().Window)
+		game.windowed = true
+	} else {
+		ebiten.SetFullscreen(true)
+	}
+
+	if len(cfg.Webhooks) > 0 {
+		game.notifier = newNotifier(cfg.Webhooks)
+	}
+	game.achievements = loadAchievements()
+	stopAPI := func() {}
+	if *httpAddr != "" {
+		stopAPI = startAPI(*httpAddr, game)
+	}
+	if cfg.Chat != nil {
+		if err := cfg.Chat.validate(); err != nil {
+			fatal("invalid chat settings", "err", err)
+		}
+		game.chat = startChat(game, *cfg.Chat)
+	}
+	if *linkListen != "" {
+		var origin *[2]int
+		if *linkOrigin != "" {
+			x, y, err := parsePosition(*linkOrigin)
+			if err != nil {
+				fatal("invalid -link-origin", "err", err)
+			}
+			origin = &[2]int{x, y}
+		}
+		game.link, err = startLink(*linkListen, *linkPeers, origin, *linkRing)
+		if err != nil {
+			fatal("failed to link", "err", err)
+		}
+		if game.recorder != nil {
+			slog.Warn("linked donuts come and go over the network, the recording won't replay exactly")
+		}
+	}
+	if *syncServe != "" {
+		game.syncServer, err = startSyncServer(*syncServe)
+		if err != nil {
+			fatal("failed to serve sync clients", "err", err)
+		}
+	}
+	if *syncJoin != "" {
+		game.syncClient, err = joinSyncServer(*syncJoin)
+		if err != nil {
+			fatal("failed to join the sync server", "err", err)
+		}
+	}
+
+	a := &app{game: game, scene: simulationScene{}, commands: make(chan appCommand, commandQueueSize)}
+	if *menuFlag {
+		a.scene = &menuScene{}
+	}
+	if *gameFlag != "" {
+		if _, ok := newArcadeGame(*gameFlag); !ok {
+			fatal("unknown game", "game", *gameFlag, "available", arcadeNames())
+		}
+		a.startArcade(*gameFlag)
+	}
+	if *soakFlag > 0 {
+		a.soak = newSoakMonitor(*soakFlag)
+	}
+	if *hideCursorFlag > 0 && !*transFlag && !*wallFlag { // Those windows sit under the desktop cursor
+		a.cursor = &cursorHider{delay: *hideCursorFlag}
+	}
+	if *kioskFlag {
+		if a.kiosk, err = newKioskMode(*kioskUnlock); err != nil {
+			fatal("invalid -kiosk-unlock", "err", err)
+		}
+	}
+	if *trayFlag {
+		stopTray := startTray(a)
+		defer stopTray()
+	}
+	if *controlSocket != "" {
+		path := *controlSocket
+		if path == "default" {
+			path = defaultControlSocket()
+		}
+		if stopControl, err := startControl(path, a); err != nil {
+			slog.Warn("control socket disabled", "err", err)
+		} else {
+			defer stopControl()
+		}
+	}
+
+	stopSignals := handleSignals(a)
+	defer stopSignals()
+	releaseIdle := startInhibitIdle()
+	defer releaseIdle()
 
-	if err := ebiten.RunGame(game); err != nil {
-		log.Fatal(err)
+	game.notifier.notify(eventStart, "Donut screensaver started", nil)
+	err = ebiten.RunGameWithOptions(a, options)
+	if s, ok := a.scene.(*arcadeScene); ok {
+		s.stop(a) // Save the screensaver's settings rather than the game's
+	}
+	game.saveWindow()
+	if game.replay == nil {
+		saved := game.currentSettings()
+		updateState(func(st *appState) { st.Settings = &saved })
+		if err := game.saveSnapshot(snapshotPath()); err != nil {
+			slog.Error("failed to save the simulation", "err", err)
+		}
+	}
+	if game.achievements != nil {
+		game.achievements.save()
+	}
+	if *summaryFlag != "" {
+		game.stats.writeSummary(*summaryFlag)
+	}
+	stopAPI() // Before closing the notifier, requests in progress can still notify
+	game.notifier.notify(eventStop, "Donut screensaver stopped", nil)
+	game.notifier.close(notifyTimeout)
+	if err != nil {
+		fatal("screensaver failed", "err", err)
+	}
+	if a.soak != nil && a.soak.err != nil {
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	midiNoteFrames  = 20 // How long a collision note sounds, in frames at 60 per second
+	midiQueueSize   = 256
+	midiLowestNote  = 48 // C3
+	midiScaleOctave = 3  // Octaves spanned across the screen width
+)
+
+// midiPentatonic is the major pentatonic scale, so any mix of collisions sounds consonant
+var midiPentatonic = []int{0, 2, 4, 7, 9}
+
+// midiOutput turns donut collisions into notes written as raw MIDI bytes to a device file,
+// e.g. an ALSA raw MIDI port like /dev/snd/midiC1D0 or a virtual port from snd-virmidi.
+// Writes happen in the background so a slow device never stalls the simulation.
+type midiOutput struct {
+	channel byte
+	queue   chan []byte
+	done    chan struct{}
+	playing []midiNote
+}
+
+type midiNote struct {
+	note byte
+	off  int // Frame the note is released
+}
+
+func openMIDI(path string, channel int) (*midiOutput, error) {
+	if channel < 1 || channel > 16 {
+		return nil, fmt.Errorf("MIDI channel %d out of range 1-16", channel)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	m := &midiOutput{channel: byte(channel - 1), queue: make(chan []byte, midiQueueSize), done: make(chan struct{})}
+	go m.write(f)
+	return m, nil
+}
+
+func (m *midiOutput) write(w io.WriteCloser) {
+	defer close(m.done)
+	defer w.Close()
+	for msg := range m.queue {
+		if _, err := w.Write(msg); err != nil {
+			slog.Warn("MIDI output stopped", "err", err)
+			for range m.queue {
+				// Drain so send never blocks
+			}
+			return
+		}
+	}
+}
+
+func (m *midiOutput) send(msg ...byte) {
+	select {
+	case m.queue <- msg:
+	default:
+		// Drop notes rather than stall when the device can't keep up
+	}
+}
+
+// collision plays a note for an impact at x, y on a width x height screen. The pitch rises
+// from left to right and the velocity follows the impact speed.
+func (m *midiOutput) collision(x, y, speed float64, width, height, frame int) {
+	steps := len(midiPentatonic) * midiScaleOctave
+	step := min(steps-1, max(0, int(x/float64(width)*float64(steps))))
+	note := byte(midiLowestNote + 12*(step/len(midiPentatonic)) + midiPentatonic[step%len(midiPentatonic)])
+	if y < float64(height)/2 {
+		note += 12 // An octave higher in the top half
+	}
+	velocity := byte(min(127, 30+speed*12))
+	m.send(0x90|m.channel, note, velocity)
+	m.playing = append(m.playing, midiNote{note: note, off: frame + ticks(midiNoteFrames)})
+}
+
+// update releases notes that have sounded long enough
+func (m *midiOutput) update(frame int) {
+	kept := m.playing[:0]
+	for _, n := range m.playing {
+		if frame >= n.off {
+			m.send(0x80|m.channel, n.note, 0)
+		} else {
+			kept = append(kept, n)
+		}
+	}
+	m.playing = kept
+}
+
+// close releases all notes and waits for the output to finish
+func (m *midiOutput) close() {
+	for _, n := range m.playing {
+		m.send(0x80|m.channel, n.note, 0)
+	}
+	m.playing = nil
+	close(m.queue)
+	<-m.done
+}
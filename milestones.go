@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	celebrationFrames = 180 // How long the timer flashes after a milestone, in frames at 60 per second
+	celebrationDonuts = 12  // Extra donuts bursting out at a milestone
+	burstDonutFrames  = 300 // Lifetime of the extra donuts
+)
+
+// milestoneConfig defines when the timer celebrates
+type milestoneConfig struct {
+	Every duration   `json:"every"` // Celebrate every multiple of this duration, e.g. "24h" or "7d"
+	At    []duration `json:"at"`    // Celebrate once at each of these durations
+}
+
+// duration is a time.Duration written as a string in the config file. Besides the units
+// accepted by time.ParseDuration it accepts days, e.g. "7d".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := parseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// parseDuration is time.ParseDuration with support for a "d" (24h) suffix
+func parseDuration(str string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(str, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", str)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(str)
+}
+
+// milestoneTracker detects when the elapsed time crosses a milestone
+type milestoneTracker struct {
+	cfg  milestoneConfig
+	last time.Duration // Elapsed time at the previous check
+}
+
+func newMilestoneTracker(cfg milestoneConfig, elapsed time.Duration) *milestoneTracker {
+	// Start from the current elapsed time so milestones already passed don't fire
+	return &milestoneTracker{cfg: cfg, last: elapsed}
+}
+
+// check returns the milestone crossed since the previous check, if any
+func (m *milestoneTracker) check(elapsed time.Duration) (time.Duration, bool) {
+	last := m.last
+	m.last = elapsed
+	if elapsed <= last {
+		return 0, false
+	}
+	for _, at := range m.cfg.At {
+		if d := time.Duration(at); last < d && d <= elapsed {
+			return d, true
+		}
+	}
+	if every := time.Duration(m.cfg.Every); every > 0 && last/every < elapsed/every {
+		return elapsed / every * every, true
+	}
+	return 0, false
+}
+
+// checkMilestones starts a celebration when the timer reaches a milestone
+func (g *Game) checkMilestones() {
+	if g.milestones == nil {
+		return
+	}
+	if reached, ok := g.milestones.check(g.elapsed()); ok {
+		slog.Info("milestone reached", "milestone", reached)
+		g.celebrate()
+		g.notifier.notify(eventMilestone, fmt.Sprintf("Timer reached %s", formatElapsed(reached)), map[string]any{
+			"milestone": reached.String(),
+			"seconds":   int64(reached.Seconds()),
+		})
+	}
+}
+
+// celebrate chimes, flashes the timer and bursts fireworks and extra donuts from the screen center.
+// Everything uses the effects random source and has no collider, so the simulation is
+// unaffected. The burst only fills the room left under the donut limit.
+func (g *Game) celebrate() {
+	if !g.reduceMotion {
+		g.timerFlash = ticks(celebrationFrames)
+	}
+	g.sound.playEffect(g.sound.chime)
+	cx, cy := float64(g.screenWidth)/2, float64(g.screenHeight)/2
+	for range 3 {
+		g.spawnFirework(cx+(g.fx.Float64()-0.5)*float64(g.screenWidth)*0.6, cy+(g.fx.Float64()-0.5)*float64(g.screenHeight)*0.6, 80)
+	}
+	for i := range min(celebrationDonuts, g.maxDonutCount()-g.world.donuts.Len()) {
+		sin, cos := math.Sincos(float64(i) / celebrationDonuts * 2 * math.Pi)
+		d := Donut{
+			x: cx - g.donutWidth/4, y: cy - g.donutHeight/4,
+			vx: 6 * cos, vy: 6 * sin,
+			width: g.donutWidth / 2, height: g.donutHeight / 2,
+			rotationSpeed: 0.1,
+		}
+		e := g.world.spawnDonutSprite(d, g.donutImageFor(i))
+		life := ticks(burstDonutFrames)
+		g.world.lifetimes.Add(e, lifetime{left: life, total: life})
+	}
+}
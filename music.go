@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/mp3"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+//go:embed music.wav
+var musicWAV []byte
+
+const builtinMusic = "builtin" // -music value that plays the embedded track
+
+var musicExtensions = []string{".ogg", ".mp3", ".wav"}
+
+// musicPlayer plays the embedded track in a loop, or the files of a directory in turn
+type musicPlayer struct {
+	ctx    *audio.Context
+	tracks []string // Playlist files, empty for the embedded track
+	index  int      // Current track
+	file   io.Closer
+	player *audio.Player
+	volume float64
+}
+
+// newMusicPlayer plays source, either builtinMusic or a directory of OGG, MP3 and WAV
+// files, at volume. A directory without music falls back to the embedded track.
+func newMusicPlayer(ctx *audio.Context, source string, volume float64) (*musicPlayer, error) {
+	m := &musicPlayer{ctx: ctx, volume: volume}
+	if source != builtinMusic {
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && slices.Contains(musicExtensions, strings.ToLower(filepath.Ext(e.Name()))) {
+				m.tracks = append(m.tracks, filepath.Join(source, e.Name()))
+			}
+		}
+		if len(m.tracks) == 0 {
+			slog.Warn("no music found, playing the built-in track", "dir", source)
+		}
+	}
+	if err := m.play(0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// play starts track i of the playlist, skipping tracks that can't be decoded
+func (m *musicPlayer) play(i int) error {
+	m.stop()
+	if len(m.tracks) == 0 {
+		stream, err := wav.DecodeWithSampleRate(audioContextRate, bytes.NewReader(musicWAV))
+		if err != nil {
+			return err
+		}
+		player, err := m.ctx.NewPlayer(audio.NewInfiniteLoop(stream, stream.Length()))
+		if err != nil {
+			return err
+		}
+		m.start(player)
+		return nil
+	}
+
+	for range m.tracks {
+		m.index = i % len(m.tracks)
+		player, file, err := m.open(m.tracks[m.index])
+		if err == nil {
+			m.file = file
+			m.start(player)
+			return nil
+		}
+		slog.Warn("skipping track", "err", err)
+		i++
+	}
+	return fmt.Errorf("none of the %d tracks could be played", len(m.tracks))
+}
+
+// open decodes the file at path by its extension, the file stays open while it streams
+func (m *musicPlayer) open(path string) (*audio.Player, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stream io.ReadSeeker
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ogg":
+		stream, err = vorbis.DecodeWithSampleRate(audioContextRate, f)
+	case ".mp3":
+		stream, err = mp3.DecodeWithSampleRate(audioContextRate, f)
+	default:
+		stream, err = wav.DecodeWithSampleRate(audioContextRate, f)
+	}
+	if err == nil {
+		var player *audio.Player
+		if player, err = m.ctx.NewPlayer(stream); err == nil {
+			return player, f, nil
+		}
+	}
+	f.Close()
+	return nil, nil, fmt.Errorf("%s: %w", path, err)
+}
+
+func (m *musicPlayer) start(player *audio.Player) {
+	m.player = player
+	m.player.SetVolume(m.volume)
+	m.player.Play()
+}
+
+func (m *musicPlayer) stop() {
+	if m.player != nil {
+		m.player.Close()
+		m.player = nil
+	}
+	if m.file != nil {
+		m.file.Close()
+		m.file = nil
+	}
+}
+
+// update moves on to the next track when one ends. It runs in every scene so the playlist
+// continues while paused.
+func (m *musicPlayer) update() {
+	if len(m.tracks) > 0 && m.player != nil && !m.player.IsPlaying() {
+		m.next()
+	}
+}
+
+func (m *musicPlayer) next() {
+	if err := m.play(m.index + 1); err != nil {
+		slog.Warn("music stopped", "err", err)
+		m.tracks = nil
+	}
+}
+
+func (m *musicPlayer) setVolume(v float64) {
+	m.volume = v
+	if m.player != nil {
+		m.player.SetVolume(m.volume)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	defaultNightTemperature = 3400 // Kelvin, about a halogen lamp
+	neutralTemperature      = 6600 // Kelvin drawn unchanged
+	minNightTemperature     = 1000
+	defaultNightFade        = 30 * time.Minute
+)
+
+// nightLightConfig shifts the colors of the whole scene warmer in the evening, like the
+// night light of the OS
+type nightLightConfig struct {
+	// Temperature is the color temperature at night in Kelvin, from 1000 (candle) to 6600
+	// (unchanged), 3400 by default
+	Temperature int `json:"temperature"`
+
+	// From and To are the local times of the night, 20:00 to 07:00 by default
+	From *clockTime `json:"from"`
+	To   *clockTime `json:"to"`
+
+	// Fade is how long the shift takes after From and before To, 30m by default
+	Fade *duration `json:"fade"`
+}
+
+func (cfg nightLightConfig) validate() error {
+	if t := cfg.Temperature; t != 0 && (t < minNightTemperature || t > neutralTemperature) {
+		return fmt.Errorf("temperature must be from %d to %d", minNightTemperature, neutralTemperature)
+	}
+	if cfg.From != nil && cfg.To != nil && *cfg.From == *cfg.To {
+		return fmt.Errorf("from and to must differ")
+	}
+	if cfg.Fade != nil && *cfg.Fade < 0 {
+		return fmt.Errorf("fade must not be negative")
+	}
+	return nil
+}
+
+// nightLight is a post pass multiplying the red, green and blue of the finished frame by
+// the white point of the color temperature
+type nightLight struct {
+	night       clockPeriod
+	temperature float64
+	fade        time.Duration
+	image       *ebiten.Image // Copy of the frame drawn back tinted
+}
+
+func newNightLight(cfg nightLightConfig) *nightLight {
+	n := &nightLight{
+		night:       clockPeriod{From: 20 * 60, To: 7 * 60},
+		temperature: defaultNightTemperature,
+		fade:        defaultNightFade,
+	}
+	if cfg.From != nil {
+		n.night.From = *cfg.From
+	}
+	if cfg.To != nil {
+		n.night.To = *cfg.To
+	}
+	if cfg.Temperature != 0 {
+		n.temperature = float64(cfg.Temperature)
+	}
+	if cfg.Fade != nil {
+		n.fade = time.Duration(*cfg.Fade)
+	}
+	return n
+}
+
+// strength returns how far the shift is at now, 0 during the day and 1 at night, easing
+// in for the fade after From and out for the fade before To
+func (n *nightLight) strength(now time.Time) float64 {
+	minute := clockTime(now.Hour()*60 + now.Minute())
+	if !n.night.contains(minute) {
+		return 0
+	}
+	if n.fade <= 0 {
+		return 1
+	}
+	// Time since the night started and until it ends, across midnight
+	const day = 24 * time.Hour
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	of := now.Sub(midnight)
+	from, to := time.Duration(n.night.From)*time.Minute, time.Duration(n.night.To)*time.Minute
+	since := (of - from + day) % day
+	until := (to - of + day) % day
+	return min(1, since.Seconds()/n.fade.Seconds(), until.Seconds()/n.fade.Seconds())
+}
+
+// draw tints the frame on screen for the time now
+func (n *nightLight) draw(screen *ebiten.Image, now time.Time) {
+	s := n.strength(now)
+	if s <= 0 {
+		return
+	}
+	size := screen.Bounds().Size()
+	if n.image == nil || n.image.Bounds().Size() != size {
+		if n.image != nil {
+			n.image.Dispose()
+		}
+		n.image = ebiten.NewImage(size.X, size.Y)
+	}
+	n.image.Clear()
+	n.image.DrawImage(screen, nil)
+
+	r, g, b := whitePoint(neutralTemperature - s*(neutralTemperature-n.temperature))
+	op := &ebiten.DrawImageOptions{Blend: ebiten.BlendCopy}
+	op.ColorScale.Scale(float32(r), float32(g), float32(b), 1)
+	screen.DrawImage(n.image, op)
+}
+
+// whitePoint returns the color of a black body at kelvin, with each channel from 0 to 1,
+// using Tanner Helland's fit of the blackbody data. It's white at 6600 K.
+func whitePoint(kelvin float64) (r, g, b float64) {
+	t := kelvin / 100
+	r, g, b = 255, 255, 255
+	if t > 66 {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	} else {
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+		switch {
+		case t <= 19:
+			b = 0
+		case t < 66:
+			b = 138.5177312231*math.Log(t-10) - 305.0447927307
+		}
+	}
+	clamp := func(v float64) float64 { return max(0, min(255, v)) / 255 }
+	return clamp(r), clamp(g), clamp(b)
+}
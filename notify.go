@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	notifyQueueSize = 64
+	notifyTimeout   = 10 * time.Second
+)
+
+// Notification event types
+const (
+	eventStart       = "start"       // The screensaver started
+	eventStop        = "stop"        // The screensaver is exiting
+	eventMilestone   = "milestone"   // The timer reached a milestone
+	eventDonuts      = "donuts"      // The donut count was changed through the HTTP API
+	eventReset       = "reset"       // The incident counter was reset
+	eventAchievement = "achievement" // An achievement was unlocked
+)
+
+// webhookConfig is an outbound webhook in the config file
+type webhookConfig struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // Event types to send, all when empty
+	Format string   `json:"format"` // "json" (default) or "slack" for Slack incoming webhooks
+}
+
+// notification is one event sent to the webhooks
+type notification struct {
+	Event   string         `json:"event"`
+	Time    time.Time      `json:"time"`
+	Message string         `json:"message"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// notifier delivers notifications to webhooks from a background goroutine so the game loop
+// never waits on the network
+type notifier struct {
+	hooks  []webhookConfig
+	queue  chan notification
+	done   chan struct{}
+	client http.Client
+
+	mu     sync.Mutex
+	closed bool // Set by close, later events are dropped
+}
+
+func newNotifier(hooks []webhookConfig) *notifier {
+	n := &notifier{
+		hooks:  hooks,
+		queue:  make(chan notification, notifyQueueSize),
+		done:   make(chan struct{}),
+		client: http.Client{Timeout: notifyTimeout},
+	}
+	go n.run()
+	return n
+}
+
+// notify queues an event, dropping it if the queue is full or the notifier is closed. A
+// nil notifier ignores events.
+func (n *notifier) notify(event, message string, data map[string]any) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		slog.Debug("notifier closed, dropping", "event", event)
+		return
+	}
+	select {
+	case n.queue <- notification{Event: event, Time: time.Now(), Message: message, Data: data}:
+	default:
+		slog.Warn("notification queue full, dropping", "event", event)
+	}
+}
+
+// close delivers the queued notifications, waiting at most timeout
+func (n *notifier) close(timeout time.Duration) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+	select {
+	case <-n.done:
+	case <-time.After(timeout):
+		slog.Warn("gave up delivering notifications")
+	}
+}
+
+func (n *notifier) run() {
+	defer close(n.done)
+	for ev := range n.queue {
+		for _, hook := range n.hooks {
+			if len(hook.Events) > 0 && !slices.Contains(hook.Events, ev.Event) {
+				continue
+			}
+			if err := n.post(hook, ev); err != nil {
+				slog.Warn("webhook failed", "url", hook.URL, "err", err)
+			}
+		}
+	}
+}
+
+func (n *notifier) post(hook webhookConfig, ev notification) error {
+	var payload any = ev
+	if hook.Format == "slack" {
+		payload = map[string]string{"text": ev.Message}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(hook.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	defaultNowPlayingInterval = 3 * time.Second
+	defaultNowPlayingWidth    = 40 // Characters shown before the title scrolls
+	marqueeFramesPerChar      = 8
+	marqueeGap                = "   "
+
+	mprisPrefix = "org.mpris.MediaPlayer2."
+	mprisPath   = "/org/mpris/MediaPlayer2"
+)
+
+// nowPlayingConfig shows the currently playing track in a HUD widget
+type nowPlayingConfig struct {
+	widgetConfig
+	Source   string   `json:"source"`   // "mpris" (default) or the URL of a JSON document, see httpTrackSource
+	Interval duration `json:"interval"` // Time between polls, 3s by default
+	Width    int      `json:"width"`    // Characters shown, longer titles scroll
+}
+
+func (cfg nowPlayingConfig) validate() error {
+	if cfg.Width < 0 {
+		return fmt.Errorf("width must be positive, or 0 for the default")
+	}
+	if cfg.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	return nil
+}
+
+// track is the playing song
+type track struct {
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Playing bool   `json:"playing"`
+}
+
+func (t track) String() string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Artist + " - " + t.Title
+}
+
+// trackSource reports the playing track, ok is false when nothing is playing
+type trackSource interface {
+	current() (t track, ok bool, err error)
+}
+
+// mprisTrackSource asks media players on the D-Bus session bus, preferring one that is
+// playing
+type mprisTrackSource struct {
+	conn *dbus.Conn
+}
+
+func (m *mprisTrackSource) current() (track, bool, error) {
+	if m.conn == nil {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			return track{}, false, err
+		}
+		m.conn = conn
+	}
+	var names []string
+	if err := m.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return track{}, false, err
+	}
+	var found []track
+	for _, name := range names {
+		if !strings.HasPrefix(name, mprisPrefix) {
+			continue
+		}
+		player := m.conn.Object(name, mprisPath)
+		status, err := player.GetProperty("org.mpris.MediaPlayer2.Player.PlaybackStatus")
+		if err != nil {
+			continue
+		}
+		metadata, err := player.GetProperty("org.mpris.MediaPlayer2.Player.Metadata")
+		if err != nil {
+			continue
+		}
+		fields, _ := metadata.Value().(map[string]dbus.Variant)
+		var t track
+		t.Title, _ = fields["xesam:title"].Value().(string)
+		artists, _ := fields["xesam:artist"].Value().([]string)
+		t.Artist = strings.Join(artists, ", ")
+		t.Playing = status.Value() == "Playing"
+		if t.Title != "" {
+			found = append(found, t)
+		}
+	}
+	if i := slices.IndexFunc(found, func(t track) bool { return t.Playing }); i >= 0 {
+		return found[i], true, nil
+	}
+	return track{}, false, nil
+}
+
+// httpTrackSource polls a URL returning a JSON track, e.g.
+// {"title": "...", "artist": "...", "playing": true}
+type httpTrackSource struct {
+	url    string
+	client http.Client
+}
+
+func (h *httpTrackSource) current() (track, bool, error) {
+	resp, err := h.client.Get(h.url)
+	if err != nil {
+		return track{}, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return track{}, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var t track
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return track{}, false, err
+	}
+	return t, t.Playing && t.Title != "", nil
+}
+
+// nowPlayingWidget polls the track source in the background and scrolls long titles
+type nowPlayingWidget struct {
+	g   *Game
+	cfg nowPlayingConfig
+
+	mu      sync.Mutex
+	current string // Empty when nothing is playing
+	since   int    // Frame the current title appeared, so it scrolls from the start
+}
+
+func newNowPlayingWidget(g *Game, cfg nowPlayingConfig) (*nowPlayingWidget, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	var source trackSource
+	switch src := cmp.Or(cfg.Source, "mpris"); {
+	case src == "mpris":
+		source = &mprisTrackSource{}
+	case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		source = &httpTrackSource{url: src, client: http.Client{Timeout: 5 * time.Second}}
+	default:
+		return nil, fmt.Errorf("unknown now playing source %q, want \"mpris\" or a URL", src)
+	}
+	w := &nowPlayingWidget{g: g, cfg: cfg}
+	go w.poll(source)
+	return w, nil
+}
+
+func (w *nowPlayingWidget) poll(source trackSource) {
+	interval := time.Duration(w.cfg.Interval)
+	if interval <= 0 {
+		interval = defaultNowPlayingInterval
+	}
+	var lastErr error
+	for ; ; time.Sleep(interval) {
+		t, ok, err := source.current()
+		// Log errors once instead of every poll, e.g. while no session bus is running
+		if err != nil && (lastErr == nil || err.Error() != lastErr.Error()) {
+			slog.Warn("now playing", "err", err)
+		}
+		lastErr = err
+		title := ""
+		if ok {
+			title = t.String()
+		}
+		w.mu.Lock()
+		if title != w.current {
+			w.current = title
+			w.since = -1
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *nowPlayingWidget) Update(world plugin.World) error {
+	w.mu.Lock()
+	if w.since < 0 {
+		w.since = world.Frame()
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *nowPlayingWidget) Draw(dst *ebiten.Image, world plugin.World) {
+	w.mu.Lock()
+	title, since := w.current, max(w.since, 0)
+	w.mu.Unlock()
+	if title == "" {
+		return
+	}
+	width := cmp.Or(w.cfg.Width, defaultNowPlayingWidth)
+	drawWidget(dst, w.cfg.widgetConfig, []string{tr("Now playing"), marquee(title, width, world.Frame()-since)}, w.g.theme.hud)
+}
+
+// marquee returns the width characters of str visible after scrolling for frames. Strings
+// that fit are returned unchanged.
+func marquee(str string, width, frames int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(str)
+	if len(runes) <= width {
+		return str
+	}
+	loop := slices.Concat(runes, []rune(marqueeGap))
+	offset := frames / ticks(marqueeFramesPerChar) % len(loop)
+	return string(slices.Concat(loop[offset:], loop[:offset])[:width])
+}
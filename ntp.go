@@ -0,0 +1,144 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNTPServer   = "pool.ntp.org"
+	defaultNTPInterval = time.Hour
+	minNTPInterval     = time.Minute // Public pools ask clients not to query more often
+	ntpTimeout         = 5 * time.Second
+	ntpSamples         = 8    // Offsets the median is taken of
+	ntpMaxSlew         = 0.01 // Most the applied offset changes per second of real time
+)
+
+// ntpEpoch is the start of NTP time, 1900-01-01
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ntpConfig corrects the timer with the time of an NTP server, for machines whose clocks
+// drift
+type ntpConfig struct {
+	Server   string   `json:"server"`   // Host or host:port, pool.ntp.org by default
+	Interval duration `json:"interval"` // Time between queries, an hour by default
+}
+
+func (cfg ntpConfig) validate() error {
+	if i := time.Duration(cfg.Interval); i != 0 && i < minNTPInterval {
+		return fmt.Errorf("interval must be at least %s", minNTPInterval)
+	}
+	return nil
+}
+
+// ntpClock tracks the offset of the local clock from an NTP server. The median of the
+// last few queries smooths out network jitter. The applied offset only steps forward, once
+// for the first query, and otherwise slews toward it slower than time passes, so the timer
+// never runs backwards.
+type ntpClock struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	target  time.Duration // Median offset of the samples
+	synced  bool          // The first sample arrived
+	applied time.Duration // Offset in use, slewed toward target when read
+	stepped bool          // The first offset was applied
+	last    time.Time     // When applied was last slewed
+}
+
+func newNTPClock(cfg ntpConfig) *ntpClock {
+	c := &ntpClock{}
+	go c.run(cmp.Or(cfg.Server, defaultNTPServer), cmp.Or(time.Duration(cfg.Interval), defaultNTPInterval))
+	return c
+}
+
+// run queries the server every interval
+func (c *ntpClock) run(server string, interval time.Duration) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	failing := false
+	for ; ; time.Sleep(interval) {
+		offset, err := queryNTP(server)
+		if err != nil {
+			if !failing {
+				slog.Warn("ntp query failed, keeping the last offset", "server", server, "err", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+
+		c.mu.Lock()
+		c.samples = append(c.samples, offset)
+		if len(c.samples) > ntpSamples {
+			c.samples = c.samples[1:]
+		}
+		sorted := slices.Sorted(slices.Values(c.samples))
+		c.target, c.synced = sorted[len(sorted)/2], true
+		c.mu.Unlock()
+		slog.Debug("ntp offset", "server", server, "offset", offset)
+	}
+}
+
+// offset returns the correction to add to the local clock
+func (c *ntpClock) offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	switch {
+	case !c.synced:
+	case !c.stepped:
+		// A clock far behind after boot is stepped forward at once, one ahead is slewed back
+		c.applied, c.stepped = max(c.target, 0), true
+		slog.Info("timer synced with ntp", "offset", c.target)
+	default:
+		limit := time.Duration(float64(now.Sub(c.last)) * ntpMaxSlew)
+		c.applied += max(-limit, min(limit, c.target-c.applied))
+	}
+	c.last = now
+	return c.applied
+}
+
+// queryNTP asks server for the time and returns the offset of the local clock from it,
+// compensating for the round trip as in RFC 5905
+func queryNTP(server string) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	req := make([]byte, 48)
+	req[0] = 0<<6 | 4<<3 | 3 // No leap warning, version 4, client mode
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	received := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 || resp[0]&0x7 != 4 || resp[1] == 0 {
+		return 0, errors.New("invalid ntp response")
+	}
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes a 64 bit NTP timestamp
+func ntpTime(b []byte) time.Time {
+	seconds := binary.BigEndian.Uint32(b)
+	fraction := binary.BigEndian.Uint32(b[4:])
+	return ntpEpoch.Add(time.Duration(seconds)*time.Second + time.Duration(uint64(fraction)*uint64(time.Second)>>32))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+var oledFlag = runFlags.Bool("oled", false, "OLED-safe mode: pure black background, fewer and dimmer donuts and a drifting HUD against burn-in, see the oled config")
+
+const (
+	defaultOLEDDonuts     = 12
+	defaultOLEDBrightness = 0.7
+	defaultOLEDDrift      = 8                // Pixels
+	oledDriftPeriod       = 17 * time.Minute // Slow enough not to be noticed
+)
+
+// oledConfig tunes OLED-safe mode, meant for OLED TVs used as lobby displays. Most of the
+// screen stays true black so its pixels are off, and nothing stays lit in the same place:
+// the HUD drifts and nothing is inverted or flashed as a full screen.
+type oledConfig struct {
+	MaxDonuts     int      `json:"max_donuts"`     // Donut count limit, 12 by default
+	MaxBrightness *float64 `json:"max_brightness"` // Brightness limit from 0.05 to 1, 0.7 by default
+	Drift         *int     `json:"drift"`          // How far everything drifts in pixels, 8 by default
+}
+
+func (cfg oledConfig) validate() error {
+	if n := cfg.MaxDonuts; n != 0 && (n < minDonuts || n > maxDonuts) {
+		return fmt.Errorf("max_donuts must be from %d to %d", minDonuts, maxDonuts)
+	}
+	if b := cfg.MaxBrightness; b != nil && (*b < minBrightness || *b > 1) {
+		return fmt.Errorf("max_brightness must be from %v to 1", minBrightness)
+	}
+	if d := cfg.Drift; d != nil && *d < 0 {
+		return fmt.Errorf("drift must not be negative")
+	}
+	return nil
+}
+
+// oledMode holds the limits of OLED-safe mode
+type oledMode struct {
+	maxDonuts     int
+	maxBrightness float64
+	drift         float64
+	start         time.Time
+}
+
+func newOLEDMode(cfg oledConfig) *oledMode {
+	o := &oledMode{
+		maxDonuts:     defaultOLEDDonuts,
+		maxBrightness: defaultOLEDBrightness,
+		drift:         defaultOLEDDrift,
+		start:         time.Now(),
+	}
+	if cfg.MaxDonuts > 0 {
+		o.maxDonuts = cfg.MaxDonuts
+	}
+	if cfg.MaxBrightness != nil {
+		o.maxBrightness = *cfg.MaxBrightness
+	}
+	if cfg.Drift != nil {
+		o.drift = float64(*cfg.Drift)
+	}
+	return o
+}
+
+// setupOLED applies the limits of OLED-safe mode to g and drops the timer panel, a lit
+// block that never moves. The background is black, see applySettings.
+func (g *Game) setupOLED(o *oledMode) {
+	g.oled = o
+	g.donutLimit = o.maxDonuts
+	g.brightness.limit = o.maxBrightness
+	g.brightness.level = min(g.brightness.level, o.maxBrightness)
+	g.timerStyle.Panel = nil
+}
+
+// offset returns how far the frame is moved at now. It follows a Lissajous curve so every
+// pixel of the HUD covers a small area over time instead of one spot.
+func (o *oledMode) offset(now time.Time) (float64, float64) {
+	t := now.Sub(o.start).Seconds() / oledDriftPeriod.Seconds() * 2 * math.Pi
+	return math.Round(o.drift * math.Sin(t)), math.Round(o.drift * math.Sin(t*1.5+math.Pi/4))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const maxLayers = 3 // Number of depth layers available for parallax
+
+// depthLayers describes each parallax layer from front (depth 0) to back
+var depthLayers = [maxLayers]struct {
+	scale      float64 // Sprite size relative to the front layer
+	speed      float64 // Speed relative to the front layer
+	brightness float32 // Color multiplier, far layers are dimmer
+	blur       bool    // Far layers are drawn slightly out of focus
+}{
+	{scale: 1, speed: 1, brightness: 1},
+	{scale: 0.65, speed: 0.65, brightness: 0.7, blur: true},
+	{scale: 0.4, speed: 0.4, brightness: 0.45, blur: true},
+}
+
+// layerOf returns the depth of e, entities without a layer component are in front
+func (w *world) layerOf(e ecs.Entity) int {
+	if l := w.layers.Get(e); l != nil {
+		return l.depth
+	}
+	return 0
+}
+
+// inDepthLayer scales a new donut for the given depth
+func inDepthLayer(d Donut, depth int) Donut {
+	l := depthLayers[depth]
+	// Keep the donut centered where it was placed
+	d.x += d.width * (1 - l.scale) / 2
+	d.y += d.height * (1 - l.scale) / 2
+	d.width *= l.scale
+	d.height *= l.scale
+	d.vx *= l.speed
+	d.vy *= l.speed
+	return d
+}
+
+// layerImages holds the offscreen images used to dim and blur the far layers
+type layerImages struct {
+	full [maxLayers]*ebiten.Image // Full resolution layer
+	half [maxLayers]*ebiten.Image // Half resolution copy, scaled back up for the blur
+}
+
+// image returns the cleared full resolution image for depth, recreated on resize
+func (li *layerImages) image(depth, width, height int) *ebiten.Image {
+	img := li.full[depth]
+	if img == nil || img.Bounds().Dx() != width || img.Bounds().Dy() != height {
+		if img != nil {
+			img.Dispose()
+			li.half[depth].Dispose()
+		}
+		img = ebiten.NewImage(width, height)
+		li.full[depth] = img
+		li.half[depth] = ebiten.NewImage(max(1, width/2), max(1, height/2))
+	}
+	img.Clear()
+	return img
+}
+
+// composite draws the layer image for depth onto dst, dimmed and blurred as configured
+func (li *layerImages) composite(dst *ebiten.Image, depth int) {
+	l := depthLayers[depth]
+	src := li.full[depth]
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	if l.blur && !noLayerBlur {
+		half := li.half[depth]
+		half.Clear()
+		down := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+		down.GeoM.Scale(0.5, 0.5)
+		half.DrawImage(src, down)
+		src = half
+		op.GeoM.Scale(2, 2)
+	}
+	op.ColorScale.Scale(l.brightness, l.brightness, l.brightness, 1)
+	dst.DrawImage(src, op)
+}
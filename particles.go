@@ -0,0 +1,93 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+// Components for short-lived visual effects
+type (
+	// particle is a small colored dot that fades out over its life
+	particle struct {
+		radius float32
+		clr    color.RGBA
+	}
+
+	// lifetime despawns an entity after a number of frames, fading it out near the end
+	lifetime struct {
+		left, total int
+		fadeIn      bool // Fade in over the first frames too
+	}
+)
+
+const fadeOutFrames = 30 // Entities with a lifetime fade out over their last frames, at 60 per second
+
+// alpha returns the opacity of an entity with this lifetime
+func (l *lifetime) alpha() float32 {
+	fade := float32(ticks(fadeOutFrames))
+	a := min(1, float32(l.left)/min(fade, float32(l.total)))
+	if l.fadeIn {
+		a = min(a, float32(l.total-l.left)/fade)
+	}
+	return a
+}
+
+// spawnParticle adds a particle at x, y moving with vx, vy for life frames
+func (w *world) spawnParticle(x, y, vx, vy float64, radius float32, clr color.RGBA, life int) ecs.Entity {
+	e := w.Spawn()
+	w.positions.Add(e, position{x, y})
+	w.velocities.Add(e, velocity{vx, vy})
+	w.particles.Add(e, particle{radius: radius, clr: clr})
+	w.lifetimes.Add(e, lifetime{left: life, total: life})
+	return e
+}
+
+// spawnFirework bursts count particles of random colors outward from x, y
+func (g *Game) spawnFirework(x, y float64, count int) {
+	base := g.fx.Float64()
+	for range count {
+		angle := g.fx.Float64() * 2 * math.Pi
+		speed := 2 + g.fx.Float64()*5
+		clr := g.particleColor(base)
+		life := ticks(45 + g.fx.Intn(45))
+		g.world.spawnParticle(x, y, math.Cos(angle)*speed, math.Sin(angle)*speed, float32(2+g.fx.Float64()*2), clr, life)
+	}
+}
+
+// lifetimeSystem counts down lifetimes and despawns expired entities. Aged out donuts,
+// which unlike chat donuts have no label, are replaced by fresh ones.
+func (g *Game) lifetimeSystem() {
+	w := g.world
+	for i := w.lifetimes.Len() - 1; i >= 0; i-- {
+		e, l := w.lifetimes.At(i)
+		l.left--
+		if l.left > 0 {
+			continue
+		}
+		if w.donuts.Has(e) && !w.labels.Has(e) {
+			img, depth := w.sprites.Get(e).image, w.layerOf(e)
+			w.Despawn(e)
+			g.respawnDonut(img, depth) // Appended, so the loop doesn't visit it
+			continue
+		}
+		w.Despawn(e)
+	}
+}
+
+// drawParticles draws every particle as a fading dot
+func (g *Game) drawParticles(dst *ebiten.Image) {
+	w := g.world
+	for i := range w.particles.Len() {
+		e, p := w.particles.At(i)
+		pos := w.positions.Get(e)
+		clr := p.clr
+		if l := w.lifetimes.Get(e); l != nil {
+			a := l.alpha()
+			clr = color.RGBA{uint8(float32(clr.R) * a), uint8(float32(clr.G) * a), uint8(float32(clr.B) * a), uint8(float32(clr.A) * a)}
+		}
+		drawFilledCircle(dst, float32(pos.x), float32(pos.y), p.radius, clr, true)
+	}
+}
@@ -0,0 +1,157 @@
+// Package plugin defines the extension points of the donut screensaver. Behaviors steer
+// donuts, renderers draw them, backgrounds draw behind them and overlays draw HUD widgets
+// on top of the scene.
+//
+// Plugins register themselves by name from an init function and are enabled from the
+// config file, so a new plugin package only has to be imported by the main package:
+//
+//	func init() {
+//		plugin.RegisterBehavior("zigzag", plugin.BehaviorFunc(func(w plugin.World, b plugin.Body) {
+//			...
+//		}))
+//	}
+package plugin
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+	"slices"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Body is a simulated object that plugins can inspect and steer. A Body passed to a
+// plugin is only valid during the call, it's reused for the next one.
+type Body interface {
+	Position() (x, y float64) // Top left corner in pixels
+	SetPosition(x, y float64)
+	Velocity() (vx, vy float64) // Pixels per frame
+	SetVelocity(vx, vy float64)
+	Rotation() float64    // Radians
+	Size() (w, h float64) // Scaled size in pixels
+
+	// Deformation is a transient scale applied around the center of the body, along the
+	// direction angle and perpendicular to it. Both scales are 1 when undeformed.
+	Deformation() (angle, along, across float64)
+}
+
+// World is the read-only view of the simulation passed to plugins. The screensaver's world
+// also has a QualityTier() int method, 0 at full quality and higher while it lowers the
+// quality under load, and a PixelScale() float64 method, the device pixels per pixel of
+// the simulation on hi-DPI displays, which plugins can look for with a type assertion.
+type World interface {
+	Size() (width, height int) // Screen size in device independent pixels
+	Frame() int                // Number of simulation steps so far
+	Rand() *rand.Rand          // Seeded source, use it instead of math/rand so replays match
+	NumBodies() int
+	Body(i int) Body
+}
+
+// Behavior adjusts a body's velocity once per frame before it moves
+type Behavior interface {
+	Apply(w World, b Body)
+}
+
+// BehaviorFunc adapts a function to the Behavior interface
+type BehaviorFunc func(w World, b Body)
+
+func (f BehaviorFunc) Apply(w World, b Body) { f(w, b) }
+
+// Renderer draws a single body using the sprite image, tinted with tint. The body's
+// position and size are in the pixels of dst.
+type Renderer interface {
+	DrawBody(dst *ebiten.Image, sprite *ebiten.Image, b Body, tint color.Color)
+}
+
+// BatchRenderer is a Renderer that may hold bodies back to draw them together. Flush is
+// called with the same dst once every body of a layer has been passed to DrawBody.
+type BatchRenderer interface {
+	Renderer
+	Flush(dst *ebiten.Image)
+}
+
+// Overlay is a HUD widget drawn on top of the scene. Like a Background's, its dst is in
+// device pixels, PixelScale times the world's Size on hi-DPI displays.
+type Overlay interface {
+	Update(w World) error
+	Draw(dst *ebiten.Image, w World)
+}
+
+// Background draws the scene behind the donuts instead of the theme's background color
+type Background interface {
+	Update(w World) error
+	Draw(dst *ebiten.Image, w World)
+}
+
+var (
+	mu          sync.RWMutex
+	behaviors   = map[string]Behavior{}
+	renderers   = map[string]Renderer{}
+	overlays    = map[string]Overlay{}
+	backgrounds = map[string]Background{}
+)
+
+// RegisterBehavior makes a behavior available by name. It panics if the name is taken.
+func RegisterBehavior(name string, b Behavior) { register(behaviors, "behavior", name, b) }
+
+// RegisterRenderer makes a renderer available by name. It panics if the name is taken.
+func RegisterRenderer(name string, r Renderer) { register(renderers, "renderer", name, r) }
+
+// RegisterOverlay makes an overlay available by name. It panics if the name is taken.
+func RegisterOverlay(name string, o Overlay) { register(overlays, "overlay", name, o) }
+
+// RegisterBackground makes a background available by name. It panics if the name is taken.
+func RegisterBackground(name string, b Background) { register(backgrounds, "background", name, b) }
+
+// LookupBehavior returns the behavior registered as name
+func LookupBehavior(name string) (Behavior, bool) { return lookup(behaviors, name) }
+
+// LookupRenderer returns the renderer registered as name
+func LookupRenderer(name string) (Renderer, bool) { return lookup(renderers, name) }
+
+// LookupOverlay returns the overlay registered as name
+func LookupOverlay(name string) (Overlay, bool) { return lookup(overlays, name) }
+
+// LookupBackground returns the background registered as name
+func LookupBackground(name string) (Background, bool) { return lookup(backgrounds, name) }
+
+// Behaviors returns the names of all registered behaviors in sorted order
+func Behaviors() []string { return names(behaviors) }
+
+// Renderers returns the names of all registered renderers in sorted order
+func Renderers() []string { return names(renderers) }
+
+// Overlays returns the names of all registered overlays in sorted order
+func Overlays() []string { return names(overlays) }
+
+func register[T any](registry map[string]T, kind, name string, v T) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("plugin: %s %q registered twice", kind, name))
+	}
+	registry[name] = v
+}
+
+func lookup[T any](registry map[string]T, name string) (T, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	v, ok := registry[name]
+	return v, ok
+}
+
+// Backgrounds returns the names of all registered backgrounds in sorted order
+func Backgrounds() []string { return names(backgrounds) }
+
+func names[T any](registry map[string]T) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]string, 0, len(registry))
+	for name := range registry {
+		list = append(list, name)
+	}
+	slices.Sort(list)
+	return list
+}
@@ -0,0 +1,188 @@
+package builtin
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/mlctrez/donut/plugin"
+	"golang.org/x/image/font/basicfont"
+)
+
+// pixelScale returns the device pixels per pixel of the world
+func pixelScale(w plugin.World) float64 {
+	if s, ok := w.(interface{ PixelScale() float64 }); ok {
+		return s.PixelScale()
+	}
+	return 1
+}
+
+// textDrawer is the world's text drawing, with coordinates in the pixels of the world
+type textDrawer interface {
+	DrawText(dst *ebiten.Image, str string, x, y, scale float64, clr color.Color)
+}
+
+const (
+	rainCell     = 14 // Width and height of a character in pixels
+	rainMinSpeed = 0.15
+	rainMaxSpeed = 0.6 // Cells per frame
+	rainMinTrail = 6
+	rainMaxTrail = 28 // Characters
+	rainGlyphs   = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ:.=*+-<>|$#@%&"
+)
+
+// matrixRain drops columns of green characters down the screen, brightest at the head.
+// Like the starfield it animates with its own random source, the world's is for the
+// simulation and using it would change what replays play back.
+type matrixRain struct {
+	rng     *rand.Rand
+	columns []rainColumn
+	rows    int
+}
+
+type rainColumn struct {
+	head   float64 // Row of the leading character, negative while it's above the screen
+	speed  float64
+	trail  int
+	glyphs []byte // Character of each row
+}
+
+func newMatrixRain() *matrixRain {
+	return &matrixRain{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (m *matrixRain) Update(w plugin.World) error {
+	width, height := w.Size()
+	columns, rows := (width+rainCell-1)/rainCell, (height+rainCell-1)/rainCell
+	if columns != len(m.columns) || rows != m.rows {
+		m.columns, m.rows = make([]rainColumn, columns), rows
+		for i := range m.columns {
+			m.columns[i].glyphs = make([]byte, rows)
+			for r := range rows {
+				m.columns[i].glyphs[r] = m.glyph()
+			}
+			m.drop(&m.columns[i])
+			// Start anywhere so the screen doesn't fill from the top
+			m.columns[i].head = m.rng.Float64() * float64(rows+m.columns[i].trail)
+		}
+	}
+	for i := range m.columns {
+		c := &m.columns[i]
+		c.head += c.speed
+		if int(c.head)-c.trail > rows {
+			m.drop(c)
+		}
+		// One character of each column changes every frame
+		if rows > 0 {
+			c.glyphs[m.rng.Intn(rows)] = m.glyph()
+		}
+	}
+	return nil
+}
+
+// drop starts a new drop above the screen
+func (m *matrixRain) drop(c *rainColumn) {
+	c.speed = rainMinSpeed + m.rng.Float64()*(rainMaxSpeed-rainMinSpeed)
+	c.trail = rainMinTrail + m.rng.Intn(rainMaxTrail-rainMinTrail+1)
+	c.head = -m.rng.Float64() * float64(m.rows)
+}
+
+func (m *matrixRain) glyph() byte {
+	return rainGlyphs[m.rng.Intn(len(rainGlyphs))]
+}
+
+func (m *matrixRain) Draw(dst *ebiten.Image, w plugin.World) {
+	dst.Fill(color.Black)
+	td, _ := w.(textDrawer)
+	scale := pixelScale(w)
+	var buf [1]byte
+	for i, c := range m.columns {
+		head := int(c.head)
+		for n := range c.trail {
+			row := head - n
+			if row < 0 || row >= m.rows {
+				continue
+			}
+			fade := 1 - float64(n)/float64(c.trail)
+			clr := color.RGBA{0, uint8(255 * fade), uint8(70 * fade), uint8(255 * fade)}
+			if n == 0 {
+				clr = color.RGBA{200, 255, 210, 255}
+			}
+			buf[0] = c.glyphs[row]
+			x, y := float64(i*rainCell+3), float64(row*rainCell)
+			if td != nil {
+				td.DrawText(dst, string(buf[:]), x, y, 1, clr)
+			} else {
+				text.Draw(dst, string(buf[:]), basicfont.Face7x13, int(x*scale), int((y+11)*scale), clr)
+			}
+		}
+	}
+}
+
+const (
+	starCount    = 400
+	starSpeed    = 0.006 // Depth covered per frame, stars start at depth 1
+	starNear     = 0.02  // Stars closer than this are replaced
+	starMaxSize  = 2.5   // Radius in pixels of the nearest stars
+	starStreak   = 3     // Frames of motion a star's streak covers
+	starFadeFrom = 0.8   // Depth at which stars start to fade in
+)
+
+// starfield flies through stars coming out of the center of the screen
+type starfield struct {
+	rng   *rand.Rand
+	stars []star
+}
+
+// star is a point at x, y from -1 to 1 across the screen at depth z from 0 to 1
+type star struct{ x, y, z float64 }
+
+func newStarfield() *starfield {
+	return &starfield{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (s *starfield) Update(w plugin.World) error {
+	if s.stars == nil {
+		s.stars = make([]star, starCount)
+		for i := range s.stars {
+			s.place(&s.stars[i])
+			s.stars[i].z = starNear + s.rng.Float64()*(1-starNear)
+		}
+	}
+	for i := range s.stars {
+		st := &s.stars[i]
+		st.z -= starSpeed
+		if st.z < starNear || math.Abs(st.x/st.z) > 1 || math.Abs(st.y/st.z) > 1 {
+			s.place(st)
+		}
+	}
+	return nil
+}
+
+// place puts a star far away at a random position
+func (s *starfield) place(st *star) {
+	st.x, st.y, st.z = s.rng.Float64()*2-1, s.rng.Float64()*2-1, 1
+}
+
+func (s *starfield) Draw(dst *ebiten.Image, w plugin.World) {
+	dst.Fill(color.Black)
+	size := dst.Bounds().Size()
+	cx, cy := float64(size.X)/2, float64(size.Y)/2
+	scale := pixelScale(w)
+	for _, st := range s.stars {
+		near := 1 - st.z
+		alpha := min(1, (1-st.z)/(1-starFadeFrom))
+		clr := color.RGBA{uint8(255 * alpha), uint8(255 * alpha), uint8(255 * alpha), uint8(255 * alpha)}
+		x, y := cx+st.x/st.z*cx, cy+st.y/st.z*cy
+		// Streak from where the star was a few frames ago
+		z0 := min(1, st.z+starSpeed*starStreak)
+		x0, y0 := cx+st.x/z0*cx, cy+st.y/z0*cy
+		r := float32(max(0.5, near*near*starMaxSize) * scale)
+		vector.StrokeLine(dst, float32(x0), float32(y0), float32(x), float32(y), 2*r, clr, true)
+		vector.DrawFilledCircle(dst, float32(x), float32(y), r, clr, true)
+	}
+}
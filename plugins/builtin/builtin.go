@@ -0,0 +1,111 @@
+// Package builtin registers the behaviors, renderers, backgrounds and overlays that ship
+// with donut
+package builtin
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/mlctrez/donut/plugin"
+	"golang.org/x/image/font/basicfont"
+)
+
+func init() {
+	plugin.RegisterBehavior("bounce", plugin.BehaviorFunc(bounce))
+	plugin.RegisterBehavior("swirl", plugin.BehaviorFunc(swirl))
+	plugin.RegisterBehavior("wander", plugin.BehaviorFunc(wander))
+
+	plugin.RegisterRenderer("sprite", &spriteRenderer{})
+
+	plugin.RegisterBackground("matrix", newMatrixRain())
+	plugin.RegisterBackground("starfield", newStarfield())
+
+	plugin.RegisterOverlay("fps", &fpsOverlay{})
+}
+
+// bounce keeps straight-line motion between collisions
+func bounce(w plugin.World, b plugin.Body) {}
+
+// swirl slowly turns every donut's heading so they travel in wide curves
+func swirl(w plugin.World, b plugin.Body) {
+	const turn = 0.01 // Radians per frame
+	sin, cos := math.Sincos(turn)
+	vx, vy := b.Velocity()
+	b.SetVelocity(vx*cos-vy*sin, vx*sin+vy*cos)
+}
+
+// wander nudges velocities randomly so paths meander
+func wander(w plugin.World, b plugin.Body) {
+	const jitter = 0.1 // Maximum change in pixels per frame
+	vx, vy := b.Velocity()
+	b.SetVelocity(vx+(w.Rand().Float64()*2-1)*jitter, vy+(w.Rand().Float64()*2-1)*jitter)
+}
+
+// spriteRenderer draws the sprite scaled to the body size and rotated around its center
+type spriteRenderer struct {
+	op ebiten.DrawImageOptions // Reused for every donut, renderers only run on the game loop
+}
+
+// SetFilter sets the filter sprites are scaled with
+func (r *spriteRenderer) SetFilter(filter ebiten.Filter) {
+	r.op.Filter = filter
+}
+
+func (r *spriteRenderer) DrawBody(dst *ebiten.Image, sprite *ebiten.Image, b plugin.Body, tint color.Color) {
+	w, h := b.Size()
+	x, y := b.Position()
+	bounds := sprite.Bounds()
+
+	op := &r.op
+	op.GeoM.Reset()
+	op.ColorScale.Reset()
+
+	// Apply transformations in the correct order for rotation around center:
+	// 1. Scale the image
+	op.GeoM.Scale(w/float64(bounds.Dx()), h/float64(bounds.Dy()))
+
+	// 2. Translate to center the rotation point (move origin to center of scaled image)
+	op.GeoM.Translate(-w/2, -h/2)
+
+	// 3. Rotate around the origin (which is now at the center)
+	op.GeoM.Rotate(b.Rotation())
+
+	// Squash or stretch along the deformation direction
+	if angle, along, across := b.Deformation(); along != 1 || across != 1 {
+		op.GeoM.Rotate(-angle)
+		op.GeoM.Scale(along, across)
+		op.GeoM.Rotate(angle)
+	}
+
+	// 4. Translate back and then to final position
+	op.GeoM.Translate(w/2, h/2)
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(tint)
+
+	dst.DrawImage(sprite, op)
+}
+
+// fpsOverlay shows the actual ticks and frames per second in the lower left corner, with
+// the quality tier when the world lowers quality under load
+type fpsOverlay struct{}
+
+func (*fpsOverlay) Update(w plugin.World) error { return nil }
+
+func (*fpsOverlay) Draw(dst *ebiten.Image, w plugin.World) {
+	_, height := w.Size()
+	msg := fmt.Sprintf("TPS %.1f  FPS %.1f  donuts %d", ebiten.ActualTPS(), ebiten.ActualFPS(), w.NumBodies())
+	if q, ok := w.(interface{ QualityTier() int }); ok {
+		msg += fmt.Sprintf("  quality -%d", q.QualityTier())
+	}
+	clr := color.RGBA{200, 200, 200, 255}
+	if t, ok := w.(interface {
+		DrawText(dst *ebiten.Image, str string, x, y, scale float64, clr color.Color)
+	}); ok {
+		t.DrawText(dst, msg, 10, float64(height-20), 1, clr) // Top left corner of the same baseline
+		return
+	}
+	text.Draw(dst, msg, basicfont.Face7x13, 10, height-10, clr)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	pongWinScore     = 11
+	pongPaddleWidth  = 14
+	pongPaddleMargin = 30   // Distance of the paddles from the screen edges
+	pongPaddleSpeed  = 8    // Pixels per frame
+	pongAISpeed      = 5    // The computer's paddle is slower so it can be beaten
+	pongSpeedUp      = 1.05 // Ball speed gained on every hit
+	pongSpin         = 3    // Sideways speed added when hitting with a paddle's end
+)
+
+// pong is a single donut bouncing between two paddles. The left paddle moves with W and S,
+// the right one with the arrow keys, played by the computer until they're pressed.
+type pong struct {
+	left, right   float64 // Top of each paddle
+	humanRight    bool
+	scores        [2]int
+	paddleHeight  float64
+	width, height float64 // Screen size, followed on every update
+}
+
+func (p *pong) start(g *Game) {
+	s := g.currentSettings()
+	s.Donuts, s.Gravity, s.Behavior, s.Layers = 1, 0, defaultBehavior, 1
+	s.Lifetime, s.Chain, s.Magnets = 0, 0, false
+	g.applySettings(s)
+	g.resetDonuts() // Even when the settings didn't change, for a fresh serve
+	// The sides are goals, the ball leaves through them instead of bouncing
+	g.edges = [4]edgeMode{edgeWrap, edgeWrap, edgeBounce, edgeBounce}
+
+	p.width, p.height = float64(g.screenWidth), float64(g.screenHeight)
+	p.paddleHeight = p.height / 6
+	p.left = (p.height - p.paddleHeight) / 2
+	p.right = p.left
+	p.serve(g, 1)
+}
+
+// serve puts the ball in the middle, moving toward the given side, -1 for left
+func (p *pong) serve(g *Game, side float64) {
+	e := g.world.donut(0)
+	pos, vel, spr := g.world.positions.Get(e), g.world.velocities.Get(e), g.world.sprites.Get(e)
+	pos.x, pos.y = (p.width-spr.width)/2, (p.height-spr.height)/2
+	angle := (g.rng.Float64() - 0.5) * math.Pi / 3
+	speed := (g.minSpeed + g.maxSpeed) / 2 * math.Sqrt2
+	vel.x, vel.y = side*math.Cos(angle)*speed, math.Sin(angle)*speed
+}
+
+func (p *pong) update(g *Game) bool {
+	p.width, p.height = float64(g.screenWidth), float64(g.screenHeight)
+	step := pongPaddleSpeed * g.step
+	if ebiten.IsKeyPressed(ebiten.KeyW) {
+		p.left -= step
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyS) {
+		p.left += step
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) || ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
+		p.humanRight = true
+	}
+
+	e := g.world.donut(0)
+	pos, vel, spr := g.world.positions.Get(e), g.world.velocities.Get(e), g.world.sprites.Get(e)
+	radius := spr.width / 2
+	cx, cy := pos.x+radius, pos.y+radius
+
+	switch {
+	case !p.humanRight:
+		// Follow the ball, only once it's coming this way
+		target := p.height/2 - p.paddleHeight/2
+		if vel.x > 0 {
+			target = cy - p.paddleHeight/2
+		}
+		p.right += max(-pongAISpeed*g.step, min(pongAISpeed*g.step, target-p.right))
+	case ebiten.IsKeyPressed(ebiten.KeyArrowUp):
+		p.right -= step
+	case ebiten.IsKeyPressed(ebiten.KeyArrowDown):
+		p.right += step
+	}
+	p.left = max(0, min(p.height-p.paddleHeight, p.left))
+	p.right = max(0, min(p.height-p.paddleHeight, p.right))
+
+	// Hit a paddle when the ball reaches it moving toward it
+	leftFace, rightFace := float64(pongPaddleMargin+pongPaddleWidth), p.width-pongPaddleMargin-pongPaddleWidth
+	hit := func(top float64) bool {
+		return cy+radius >= top && cy-radius <= top+p.paddleHeight
+	}
+	switch {
+	case vel.x < 0 && cx-radius <= leftFace && cx-radius >= leftFace-pongPaddleWidth && hit(p.left):
+		p.bounce(g, e, vel, cy-p.left)
+		pos.x = leftFace
+	case vel.x > 0 && cx+radius >= rightFace && cx+radius <= rightFace+pongPaddleWidth && hit(p.right):
+		p.bounce(g, e, vel, cy-p.right)
+		pos.x = rightFace - spr.width
+	}
+
+	// Score when the ball's center leaves the screen
+	switch {
+	case cx < 0:
+		p.scores[1]++
+		p.serve(g, -1)
+	case cx > p.width:
+		p.scores[0]++
+		p.serve(g, 1)
+	}
+	return max(p.scores[0], p.scores[1]) >= pongWinScore
+}
+
+// bounce sends the ball back, faster and angled by where on the paddle it hit
+func (p *pong) bounce(g *Game, e ecs.Entity, vel *velocity, at float64) {
+	g.addSquash(e, 1, 0, math.Abs(vel.x))
+	vel.x = -vel.x * pongSpeedUp
+	vel.y += (at/p.paddleHeight - 0.5) * 2 * pongSpin
+	// Keep the ball from getting faster than the paddles can follow
+	if speed, limit := math.Hypot(vel.x, vel.y), 3*g.maxSpeed; speed > limit {
+		vel.x, vel.y = vel.x/speed*limit, vel.y/speed*limit
+	}
+}
+
+func (p *pong) draw(g *Game, screen *ebiten.Image) {
+	clr := g.theme.hud
+	for y := float32(0); y < float32(p.height); y += 30 {
+		drawFilledRect(screen, float32(p.width/2)-2, y, 4, 15, color.RGBA{clr.R / 2, clr.G / 2, clr.B / 2, 255}, false)
+	}
+	h := float32(p.paddleHeight)
+	drawFilledRect(screen, pongPaddleMargin, float32(p.left), pongPaddleWidth, h, clr, false)
+	drawFilledRect(screen, float32(p.width)-pongPaddleMargin-pongPaddleWidth, float32(p.right), pongPaddleWidth, h, clr, false)
+}
+
+func (p *pong) scoreboard() string {
+	return fmt.Sprintf("%d   %d", p.scores[0], p.scores[1])
+}
+
+// score is the winning margin
+func (p *pong) score() float64 {
+	return math.Abs(float64(p.scores[0] - p.scores[1]))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	defaultPortalRadius = 40
+	portalCooldown      = 30 // Frames at 60 per second before a donut can go through a portal again
+)
+
+// portalColors are used in turn by portal pairs without a color
+var portalColors = []color.RGBA{{255, 140, 0, 255}, {0, 150, 255, 255}, {180, 80, 255, 255}, {80, 220, 120, 255}}
+
+type (
+	// portal is one end of a portal pair, a ring around its position. Donuts moving into
+	// it come out of the exit portal, turned to leave in the exit's direction.
+	portal struct {
+		exit   ecs.Entity
+		angle  float64 // Direction donuts leave in, radians
+		radius float64
+		clr    color.RGBA
+	}
+
+	// cooldown keeps a donut that just went through a portal from going through again
+	cooldown struct{ frames int }
+)
+
+// portalConfig places a pair of portals
+type portalConfig struct {
+	A      portalEndConfig `json:"a"`
+	B      portalEndConfig `json:"b"`
+	Radius float64         `json:"radius"` // Size of the rings in pixels, 40 by default
+	Color  *hexColor       `json:"color"`  // Ring color, picked from a palette by default
+}
+
+// portalEndConfig is one end of a portal pair. Positions are relative to the screen size.
+type portalEndConfig struct {
+	X     float64 `json:"x"`     // Horizontal position, 0 is the left edge and 1 the right
+	Y     float64 `json:"y"`     // Vertical position, 0 is the top edge and 1 the bottom
+	Angle float64 `json:"angle"` // Direction donuts leave in, degrees clockwise from right
+}
+
+// validate checks that both ends are on the screen
+func (c portalConfig) validate() error {
+	for _, end := range []portalEndConfig{c.A, c.B} {
+		if end.X < 0 || end.X > 1 || end.Y < 0 || end.Y > 1 {
+			return fmt.Errorf("position %.2f, %.2f is outside the screen, want 0 to 1", end.X, end.Y)
+		}
+	}
+	if c.Radius < 0 {
+		return fmt.Errorf("radius must not be negative")
+	}
+	return nil
+}
+
+// addPortals adds the i-th configured portal pair
+func (g *Game) addPortals(i int, c portalConfig) {
+	w := g.world
+	clr := portalColors[i%len(portalColors)]
+	if c.Color != nil {
+		clr = color.RGBAModel.Convert(*c.Color).(color.RGBA)
+	}
+	radius := cmp.Or(c.Radius, defaultPortalRadius)
+	a, b := w.Spawn(), w.Spawn()
+	for _, end := range []struct {
+		e, exit ecs.Entity
+		cfg     portalEndConfig
+	}{{a, b, c.A}, {b, a, c.B}} {
+		w.positions.Add(end.e, position{end.cfg.X * float64(g.screenWidth), end.cfg.Y * float64(g.screenHeight)})
+		w.portals.Add(end.e, portal{exit: end.exit, angle: end.cfg.Angle * math.Pi / 180, radius: radius, clr: clr})
+	}
+}
+
+// portalSystem moves donuts entering a portal to its exit and counts down cooldowns
+func (g *Game) portalSystem() {
+	w := g.world
+	for i := w.cooldowns.Len() - 1; i >= 0; i-- {
+		e, c := w.cooldowns.At(i)
+		c.frames--
+		if c.frames <= 0 {
+			w.cooldowns.Remove(e)
+		}
+	}
+
+	for i := range w.portals.Len() {
+		pe, p := w.portals.At(i)
+		center := w.positions.Get(pe)
+		exit, exitPos := w.portals.Get(p.exit), w.positions.Get(p.exit)
+		nx, ny := math.Cos(p.angle), math.Sin(p.angle)
+		for _, e := range w.donuts.Entities() {
+			if w.cooldowns.Has(e) {
+				continue
+			}
+			pos, vel, spr := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e)
+			dx, dy := pos.x+spr.width/2-center.x, pos.y+spr.height/2-center.y
+			// Only donuts overlapping the ring and moving into it, against its direction
+			if math.Hypot(dx, dy) >= p.radius || vel.x*nx+vel.y*ny >= 0 {
+				continue
+			}
+
+			// Turn the velocity so moving straight in means leaving straight out
+			turn := exit.angle - p.angle + math.Pi
+			sin, cos := math.Sincos(turn)
+			vel.x, vel.y = vel.x*cos-vel.y*sin, vel.x*sin+vel.y*cos
+			ex, ey := math.Cos(exit.angle), math.Sin(exit.angle)
+			pos.x = exitPos.x + ex*exit.radius - spr.width/2
+			pos.y = exitPos.y + ey*exit.radius - spr.height/2
+			w.cooldowns.Add(e, cooldown{ticks(portalCooldown)})
+		}
+	}
+}
+
+// drawPortals draws each portal as a glowing ring with a notch on the side donuts leave
+func (g *Game) drawPortals(dst *ebiten.Image) {
+	w := g.world
+	for i := range w.portals.Len() {
+		e, p := w.portals.At(i)
+		pos := w.positions.Get(e)
+		x, y, r := float32(pos.x), float32(pos.y), float32(p.radius)
+		glow := p.clr
+		glow.R, glow.G, glow.B, glow.A = glow.R/3, glow.G/3, glow.B/3, glow.A/3
+		strokeCircle(dst, x, y, r+3, 8, glow, true)
+		strokeCircle(dst, x, y, r, 3, p.clr, true)
+		nx, ny := float32(math.Cos(p.angle)), float32(math.Sin(p.angle))
+		strokeLine(dst, x+nx*r, y+ny*r, x+nx*(r+10), y+ny*(r+10), 3, p.clr, true)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log/slog"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	lowPowerTPS        = 30
+	lowPowerDim        = 90 // Alpha of the black drawn over the scene in low-power mode
+	powerCheckInterval = 30 * time.Second
+)
+
+var lowPowerFlag = runFlags.String("low-power", "auto", "low-power mode: on, off, or auto to follow the battery")
+
+// startPowerWatch applies the -low-power mode. In auto mode the power source is checked
+// periodically and low-power mode follows it.
+func startPowerWatch(g *Game, mode string) error {
+	switch mode {
+	case "on":
+		g.setLowPower(true)
+	case "off":
+	case "auto":
+		go func() {
+			for onBattery := false; ; time.Sleep(powerCheckInterval) {
+				battery, err := onBatteryPower()
+				if err != nil {
+					slog.Debug("power source unknown, low-power mode stays off", "err", err)
+					return
+				}
+				if battery != onBattery {
+					onBattery = battery
+					select {
+					case g.commands <- func(g *Game) { g.setLowPower(battery) }:
+					default:
+					}
+				}
+			}
+		}()
+	default:
+		return fmt.Errorf("invalid -low-power %q, want on, off or auto", mode)
+	}
+	return nil
+}
+
+// setLowPower switches low-power mode, which lowers the frame rate, disables the effects
+// and dims the scene
+func (g *Game) setLowPower(on bool) {
+	if on == g.lowPower {
+		return
+	}
+	g.lowPower = on
+	g.applyTPS()
+	slog.Info("low-power mode", "on", on)
+}
+
+// dimLowPower darkens the scene in low-power mode
+func (g *Game) dimLowPower(screen *ebiten.Image) {
+	if g.lowPower {
+		w, h := logicalSize(screen)
+		drawFilledRect(screen, 0, 0, float32(w), float32(h), color.RGBA{A: lowPowerDim}, false)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// onBatteryPower reports whether the machine runs on battery, asking pmset. Machines
+// without a battery report an error.
+func onBatteryPower() (bool, error) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false, err
+	}
+	switch s := string(out); {
+	case strings.Contains(s, "'Battery Power'"):
+		return true, nil
+	case strings.Contains(s, "InternalBattery"):
+		return false, nil
+	}
+	return false, errors.New("no battery")
+}
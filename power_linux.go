@@ -0,0 +1,40 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// onBatteryPower reports whether the machine runs on battery, from the power supplies in
+// sysfs. Machines without a battery report an error.
+func onBatteryPower() (bool, error) {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+	if err != nil {
+		return false, err
+	}
+	read := func(dir, name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		return strings.TrimSpace(string(data))
+	}
+	hasBattery := false
+	for _, dir := range supplies {
+		switch read(dir, "type") {
+		case "Mains", "USB":
+			if read(dir, "online") == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if read(dir, "scope") != "Device" { // Skip mice and headsets
+				hasBattery = true
+			}
+		}
+	}
+	if !hasBattery {
+		return false, errors.New("no battery")
+	}
+	return true, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !windows && !darwin
+
+package main
+
+import "errors"
+
+// onBatteryPower isn't supported on this platform
+func onBatteryPower() (bool, error) {
+	return false, errors.New("power source detection is unsupported on this platform")
+}
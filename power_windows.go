@@ -0,0 +1,33 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+var procGetSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// onBatteryPower reports whether the machine runs on battery. Machines without a battery
+// report an error.
+func onBatteryPower() (bool, error) {
+	// SYSTEM_POWER_STATUS
+	var status struct {
+		ACLineStatus        byte
+		BatteryFlag         byte
+		BatteryLifePercent  byte
+		SystemStatusFlag    byte
+		BatteryLifeTime     uint32
+		BatteryFullLifeTime uint32
+	}
+	if r, _, err := procGetSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return false, err
+	}
+	const noBattery, unknown = 128, 255
+	if status.BatteryFlag == noBattery || status.BatteryFlag == unknown {
+		return false, errors.New("no battery")
+	}
+	return status.ACLineStatus == 0, nil
+}
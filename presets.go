@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// preset is a named bundle of settings selectable with -preset or the number keys
+type preset struct {
+	name     string
+	settings settings
+}
+
+// builtinPresets are always available, in number key order
+var builtinPresets = []preset{
+	{"default", settings{Donuts: initialDonuts, Theme: "classic", Behavior: "bounce"}},
+	{"calm", settings{Donuts: 4, MinSpeed: 0.5, MaxSpeed: 1.5, Theme: "ice", Behavior: "bounce", Crumbs: true}},
+	{"chaos", settings{Donuts: 50, MinSpeed: 4, MaxSpeed: 9, Theme: "neon", Behavior: "wander", Trails: true}},
+	{"zero-g", settings{Donuts: 10, MinSpeed: 0.3, MaxSpeed: 1, Theme: "classic", Behavior: "wander", Layers: 3}},
+	{"rainstorm", settings{Donuts: 40, Gravity: 0.25, Theme: "ice", Behavior: "bounce", Trails: true}},
+	{"stress", settings{Donuts: 2000, Size: 0.1, Theme: "classic", Behavior: "bounce"}}, // For performance testing
+}
+
+// velocityConfig is the speed and spin range used by presets that leave them unset, see
+// the settings fields of the same names
+type velocityConfig struct {
+	MinSpeed float64 `json:"min_speed"`
+	MaxSpeed float64 `json:"max_speed"`
+	MinSpin  float64 `json:"min_spin"`
+	MaxSpin  float64 `json:"max_spin"`
+}
+
+// apply fills in the zero speed and spin fields of s
+func (v velocityConfig) apply(s settings) settings {
+	if s.MinSpeed == 0 && s.MaxSpeed == 0 {
+		s.MinSpeed, s.MaxSpeed = v.MinSpeed, v.MaxSpeed
+	}
+	if s.MinSpin == 0 && s.MaxSpin == 0 {
+		s.MinSpin, s.MaxSpin = v.MinSpin, v.MaxSpin
+	}
+	return s
+}
+
+// buildPresets merges the user presets from the config file into the built-in list and
+// applies the configured velocity range. User presets that don't replace a built-in are
+// appended in name order.
+func buildPresets(user map[string]settings, velocity velocityConfig) []preset {
+	presets := slices.Clone(builtinPresets)
+	names := make([]string, 0, len(user))
+	for name := range user {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		i := slices.IndexFunc(presets, func(p preset) bool { return p.name == name })
+		if i >= 0 {
+			presets[i].settings = user[name]
+		} else {
+			presets = append(presets, preset{name, user[name]})
+		}
+	}
+	for i := range presets {
+		presets[i].settings = velocity.apply(presets[i].settings)
+	}
+	return presets
+}
+
+// findPreset looks up a preset by name or by its 1-based number
+func findPreset(presets []preset, nameOrNumber string) (preset, error) {
+	for _, p := range presets {
+		if p.name == nameOrNumber {
+			return p, nil
+		}
+	}
+	if n, err := strconv.Atoi(nameOrNumber); err == nil && n >= 1 && n <= len(presets) {
+		return presets[n-1], nil
+	}
+	return preset{}, fmt.Errorf("unknown preset %q", nameOrNumber)
+}
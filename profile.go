@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+)
+
+// profile tunes the defaults for a kind of hardware. Flags and environment variables set
+// explicitly always win over the profile.
+type profile struct {
+	flags  map[string]string // Run flag defaults
+	env    map[string]string // Environment variables read by ebiten when the game starts
+	noBlur bool              // Dim far parallax layers without blurring them
+}
+
+var profiles = map[string]profile{
+	// pi suits a Raspberry Pi driving a TV: 30 frames per second, cached rotations instead
+	// of rotating the full size sprite, OpenGL ES, no multi-pass effects and no blanking
+	"pi": {
+		flags: map[string]string{
+			"fps":              "30",
+			"vsync":            "on",
+			"rotation-steps":   "64",
+			"adaptive-quality": "true",
+			"inhibit-idle":     "true",
+		},
+		env: map[string]string{
+			"EBITENGINE_GRAPHICS_LIBRARY": "opengl",
+			"EBITENGINE_OPENGL":           "es",
+		},
+		noBlur: true,
+	},
+}
+
+var profileFlag = runFlags.String("profile", defaultProfile, fmt.Sprintf("tune the defaults for this hardware: %v, none when empty", slices.Sorted(maps.Keys(profiles))))
+
+// noLayerBlur skips the extra pass blurring far parallax layers, set by the profile
+var noLayerBlur bool
+
+// applyProfile applies the -profile defaults to the run flags that weren't set on the
+// command line and to the environment. It must run before the game starts.
+func applyProfile() error {
+	if *profileFlag == "" {
+		return nil
+	}
+	p, ok := profiles[*profileFlag]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, available: %v", *profileFlag, slices.Sorted(maps.Keys(profiles)))
+	}
+
+	set := map[string]bool{}
+	runFlags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, value := range p.flags {
+		if !set[name] {
+			if err := runFlags.Set(name, value); err != nil {
+				return fmt.Errorf("profile %s: %w", *profileFlag, err)
+			}
+		}
+	}
+	for name, value := range p.env {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+	noLayerBlur = p.noBlur
+	slog.Info("using profile", "profile", *profileFlag)
+	return nil
+}
@@ -0,0 +1,6 @@
+//go:build !pi
+
+package main
+
+// defaultProfile is the -profile default, the pi build tag changes it
+const defaultProfile = ""
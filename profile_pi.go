@@ -0,0 +1,6 @@
+//go:build pi
+
+package main
+
+// defaultProfile is the -profile default of builds made with -tags pi
+const defaultProfile = "pi"
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	_ "net/http/pprof" // Registers the /debug/pprof handlers on http.DefaultServeMux
+)
+
+// startProfiler serves the net/http/pprof endpoints on addr in the background so CPU and
+// heap profiles can be collected from a long-running instance, e.g.
+//
+//	go tool pprof http://localhost:6060/debug/pprof/heap
+func startProfiler(addr string) {
+	go func() {
+		slog.Info("pprof listening", "addr", addr)
+		if err := http.ListenAndServe(addr, nil); err != nil {
+			slog.Error("pprof server stopped", "err", err)
+		}
+	}()
+}
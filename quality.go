@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Quality tiers, each includes the reductions of the ones before it
+const (
+	qualityFull        = iota
+	qualityNoEffects   // No trails, particles or crumbs
+	qualityCoarse      // Collisions are checked every other frame
+	qualityFewerDonuts // The donut count is halved
+)
+
+const (
+	qualityWindow      = 60   // Updates averaged per frame time measurement
+	qualitySlow        = 1.25 // Frame time over the target that counts as struggling
+	qualityFast        = 1.05 // Frame time under the target that counts as headroom
+	qualityDegradeWait = 2    // Slow windows in a row before lowering the quality
+	qualityRestoreWait = 10   // Fast windows in a row before raising it again
+)
+
+var adaptiveFlag = runFlags.Bool("adaptive-quality", false, "lower visual quality when frames take too long and restore it when they don't")
+
+// qualityGovernor measures the time between updates and steps the quality tier down
+// while the machine can't keep up, and back up once it has headroom again
+type qualityGovernor struct {
+	tier       int
+	last       time.Time
+	total      time.Duration // Sum of the frame times in the current window
+	count      int
+	slow, fast int // Consecutive slow and fast windows
+	donuts     int // The user's donut count while qualityFewerDonuts halves it
+}
+
+// fewerDonuts reports whether q is halving the donut count, false for a nil governor
+func (q *qualityGovernor) fewerDonuts() bool { return q != nil && q.tier == qualityFewerDonuts }
+
+// userDonutCount returns the donut count the user chose, which is what the settings show
+// and save even while the quality governor runs fewer
+func (g *Game) userDonutCount() int {
+	if g.quality.fewerDonuts() {
+		return g.quality.donuts
+	}
+	return g.numDonuts
+}
+
+// updateQuality measures the frame time and changes the tier when needed. It's skipped
+// while recording, replaying or following a sync server, where the simulation must not
+// depend on how fast the machine is.
+func (g *Game) updateQuality() {
+	q := g.quality
+	if q == nil || g.recorder != nil || g.replay != nil || g.syncClient != nil {
+		return
+	}
+	now := time.Now()
+	if q.last.IsZero() {
+		q.last = now
+		return
+	}
+	elapsed := now.Sub(q.last)
+	q.last = now
+	if elapsed > time.Second {
+		// Paused or in a menu, start the window over
+		q.total, q.count = 0, 0
+		return
+	}
+	q.total += elapsed
+	if q.count++; q.count < qualityWindow {
+		return
+	}
+
+	target := time.Second / time.Duration(ebiten.TPS())
+	frame := q.total / time.Duration(q.count)
+	q.total, q.count = 0, 0
+	switch {
+	case float64(frame) > float64(target)*qualitySlow:
+		q.slow, q.fast = q.slow+1, 0
+	case float64(frame) < float64(target)*qualityFast:
+		q.slow, q.fast = 0, q.fast+1
+	default:
+		q.slow, q.fast = 0, 0
+	}
+
+	if q.slow >= qualityDegradeWait && q.tier < qualityFewerDonuts {
+		g.setQuality(q.tier+1, frame)
+	} else if q.fast >= qualityRestoreWait && q.tier > qualityFull {
+		g.setQuality(q.tier-1, frame)
+	}
+}
+
+// setQuality switches to tier. Entering or leaving qualityFewerDonuts applies the user's
+// donut count again, which applySettings halves at that tier.
+func (g *Game) setQuality(tier int, frame time.Duration) {
+	q := g.quality
+	q.slow, q.fast = 0, 0
+	slog.Info("quality changed", "tier", tier, "frame_time", frame)
+	donuts := g.userDonutCount()
+	changed := (tier == qualityFewerDonuts) != q.fewerDonuts()
+	q.tier = tier
+	if changed {
+		g.setDonutCount(donuts)
+	}
+}
+
+// qualityTier returns the current tier, qualityFull without adaptive quality. Low-power
+// mode has no effects either.
+func (g *Game) qualityTier() int {
+	tier := qualityFull
+	if g.quality != nil {
+		tier = g.quality.tier
+	}
+	if g.lowPower {
+		tier = max(tier, qualityNoEffects)
+	}
+	return tier
+}
+
+// QualityTier implements the optional quality reporting of plugin.World for the debug HUD
+func (g *Game) QualityTier() int { return g.qualityTier() }
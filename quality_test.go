@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+// TestQualityRestoresDonuts checks that halving the donuts under load keeps the user's count
+// in the settings and restores it exactly, including changes made while it was halved
+func TestQualityRestoresDonuts(t *testing.T) {
+	g := newBenchGame(settings{Donuts: 51}, 1)
+	g.quality = &qualityGovernor{tier: qualityCoarse}
+
+	g.setQuality(qualityFewerDonuts, 0)
+	if g.numDonuts != 25 || g.currentSettings().Donuts != 51 {
+		t.Fatalf("%d donuts with %d in the settings, want 25 with 51", g.numDonuts, g.currentSettings().Donuts)
+	}
+	g.applyAction(replayEvent{Action: actionAddDonut})
+	g.setDonutCount(g.currentSettings().Donuts + 10)
+	if g.numDonuts != 31 || g.currentSettings().Donuts != 62 {
+		t.Fatalf("%d donuts with %d in the settings, want 31 with 62", g.numDonuts, g.currentSettings().Donuts)
+	}
+
+	g.setQuality(qualityCoarse, 0)
+	if g.numDonuts != 62 || g.world.donuts.Len() != 62 || g.currentSettings().Donuts != 62 {
+		t.Errorf("%d donuts, %d entities and %d in the settings, want 62", g.numDonuts, g.world.donuts.Len(), g.currentSettings().Donuts)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	rainbowCycleFrames = 600   // Frames at 60 per second for a full trip around the color wheel
+	rainbowPhaseStep   = 0.618 // Golden ratio offset between donuts so neighbors differ
+)
+
+// rainbowHue returns the hue in [0, 1) for the given phase at the current frame
+func (g *Game) rainbowHue(phase float64) float64 {
+	_, frac := math.Modf(phase + float64(g.frame)/float64(ticks(rainbowCycleFrames)))
+	return frac
+}
+
+// donutTint returns the tint for e, cycling through the spectrum in rainbow mode, coloring
+// it with the music in audio tint mode, cooling it in cold weather, showing its pole in
+// magnet mode and fading out entities at the end of their lifetime
+func (g *Game) donutTint(e ecs.Entity) color.RGBA {
+	tint := g.theme.tintFor(e)
+	if g.rainbow {
+		tint = multiplyColor(tint, hsvColor(g.rainbowHue(float64(e)*rainbowPhaseStep), 0.6, 1))
+	}
+	if g.audio != nil && g.audio.mode == audioTint {
+		tint = multiplyColor(tint, hsvColor(g.rainbowHue(0), 0.8*g.audio.pulse, 1))
+	}
+	if g.weather != nil {
+		tint = multiplyColor(tint, g.weather.tint())
+	}
+	if p := g.world.polarities.Get(e); g.magnets && p != nil {
+		tint = multiplyColor(tint, p.tint())
+	}
+	if l := g.world.lifetimes.Get(e); l != nil {
+		a := uint8(255 * l.alpha())
+		tint = multiplyColor(tint, color.RGBA{a, a, a, a})
+	}
+	return tint
+}
+
+// timerColor returns the timer color, cycling through the spectrum if enabled and
+// flashing while celebrating a milestone
+func (g *Game) timerColor() color.RGBA {
+	switch {
+	case g.timerFlash > 0 && g.timerFlash/ticks(10)%2 == 0:
+		return color.RGBA{255, 255, 255, 255}
+	case g.rainbowTimer:
+		return hsvColor(g.rainbowHue(0), 0.7, 1)
+	case g.timerStyle.Color != nil:
+		return g.timerStyle.Color.premultiplied()
+	}
+	return g.theme.timer
+}
+
+// hsvColor converts hue, saturation and value in [0, 1] to an opaque color
+func hsvColor(h, s, v float64) color.RGBA {
+	h = math.Mod(h, 1) * 6
+	i := math.Floor(h)
+	f := h - i
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch int(i) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return color.RGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 255}
+}
+
+// multiplyColor multiplies two colors channel by channel
+func multiplyColor(a, b color.RGBA) color.RGBA {
+	return color.RGBA{
+		uint8(uint16(a.R) * uint16(b.R) / 255),
+		uint8(uint16(a.G) * uint16(b.G) / 255),
+		uint8(uint16(a.B) * uint16(b.B) / 255),
+		uint8(uint16(a.A) * uint16(b.A) / 255),
+	}
+}
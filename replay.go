@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+const replayVersion = 1 // Bump when the replay file format changes
+
+// inputAction is a user input that affects the simulation. Actions are recorded to and
+// played back from replay files, so everything that changes the simulation state must go
+// through one.
+type inputAction string
+
+const (
+	actionAddDonut    inputAction = "add"
+	actionRemoveDonut inputAction = "remove"
+	actionResize      inputAction = "resize"
+	actionSettings    inputAction = "settings"
+	actionChatDonut   inputAction = "chat"      // A chat message spawned a named donut
+	actionChatPop     inputAction = "chat-pop"  // Chat popped the oldest chat donut
+	actionRandomize   inputAction = "randomize" // New random velocities for every donut
+	actionPaintField  inputAction = "field"     // A mouse stroke painted the force field
+	actionVortex      inputAction = "vortex"    // Added or removed the vortex at X, Y
+)
+
+// replayHeader is the first line of a .donutreplay file and holds the initial state
+type replayHeader struct {
+	Version  int            `json:"version"`
+	Seed     int64          `json:"seed"`
+	Settings settings       `json:"settings"`
+	Width    int            `json:"width"`
+	Height   int            `json:"height"`
+	FPS      int            `json:"fps,omitempty"`      // Frame rate, 60 when unset
+	Spawn    string         `json:"spawn,omitempty"`    // Spawner name, center when unset
+	Edges    *edgesConfig   `json:"edges,omitempty"`    // Edge modes, all bounce when unset
+	Vortices []vortexConfig `json:"vortices,omitempty"` // Vortices placed at the start
+	Portals  []portalConfig `json:"portals,omitempty"`  // Portal pairs
+	Energy   energyMode     `json:"energy,omitempty"`   // Energy mode when it corrected drift
+
+	ReduceMotion bool `json:"reduce_motion,omitempty"` // Recorded at half speed
+	MaxDonuts    int  `json:"max_donuts,omitempty"`    // Donut count limit of OLED-safe mode
+}
+
+// replayEvent is a single action applied at the start of the given frame
+type replayEvent struct {
+	Frame  int         `json:"frame"`
+	Action inputAction `json:"action"`
+	Width  int         `json:"width,omitempty"`
+	Height int         `json:"height,omitempty"`
+
+	Settings *settings    `json:"settings,omitempty"` // For actionSettings
+	Name     string       `json:"name,omitempty"`     // For actionChatDonut
+	Stroke   *fieldStroke `json:"stroke,omitempty"`   // For actionPaintField
+	X        float64      `json:"x,omitempty"`        // For actionVortex
+	Y        float64      `json:"y,omitempty"`
+}
+
+// replayRecorder writes the header and events as JSON lines so a recording survives a crash
+type replayRecorder struct {
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+}
+
+func newReplayRecorder(path string, header replayHeader) (*replayRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	r := &replayRecorder{file: f, w: w, enc: json.NewEncoder(w)}
+	header.Version = replayVersion
+	if err := r.enc.Encode(header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, r.w.Flush()
+}
+
+// record appends an event and flushes it to disk
+func (r *replayRecorder) record(ev replayEvent) error {
+	if err := r.enc.Encode(ev); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+func (r *replayRecorder) Close() error {
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// replayPlayer feeds recorded events back into the game in frame order
+type replayPlayer struct {
+	header replayHeader
+	events []replayEvent
+	next   int
+}
+
+func loadReplay(path string) (*replayPlayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	p := &replayPlayer{}
+	if err := dec.Decode(&p.header); err != nil {
+		return nil, fmt.Errorf("reading replay header: %w", err)
+	}
+	if p.header.Version != replayVersion {
+		return nil, fmt.Errorf("unsupported replay version %d", p.header.Version)
+	}
+	for {
+		var ev replayEvent
+		if err := dec.Decode(&ev); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading replay event %d: %w", len(p.events), err)
+		}
+		p.events = append(p.events, ev)
+	}
+	return p, nil
+}
+
+// eventsFor returns the events recorded for frame, in the order they were recorded
+func (p *replayPlayer) eventsFor(frame int) []replayEvent {
+	start := p.next
+	for p.next < len(p.events) && p.events[p.next].Frame <= frame {
+		p.next++
+	}
+	return p.events[start:p.next]
+}
+
+// finished reports whether every recorded event has been played back
+func (p *replayPlayer) finished() bool {
+	return p.next >= len(p.events)
+}
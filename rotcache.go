@@ -0,0 +1,145 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log/slog"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	maxRotationSteps = 360 // Most rotation steps accepted by -rotation-steps
+	maxCachedSprites = 16  // Sprites with cached rotations before the cache is emptied
+)
+
+var rotationStepsFlag = runFlags.Int("rotation-steps", 0, "pre-render this many rotation steps of each sprite and draw the nearest one instead of rotating, 0 to rotate every frame")
+
+// rotationCache draws donuts from pre-rendered rotations of their sprite, already scaled
+// to the donut size, instead of scaling and rotating the full size sprite every frame.
+// It trades memory for drawing time on weak GPUs. Deformed donuts, which the cache has no
+// frames for, are drawn by the wrapped renderer.
+type rotationCache struct {
+	fallback      plugin.Renderer
+	steps         int
+	width, height float64 // Donut size the frames are rendered at in simulation pixels
+	pixels        float64 // pixelScale the frames are rendered at
+	cell          int     // Width and height of a frame in the atlas, fits any rotation
+	sprites       map[*ebiten.Image]*rotationAtlas
+	op            ebiten.DrawImageOptions
+}
+
+// rotationAtlas holds the frames of one sprite. The frames are sub-images of a single
+// atlas image, created once so drawing doesn't allocate.
+type rotationAtlas struct {
+	atlas  *ebiten.Image
+	frames []*ebiten.Image
+}
+
+// newRotationCache returns a renderer caching steps rotations of donuts sized width by
+// height. The number of steps is lowered when the atlas would exceed maxSpriteSize.
+func newRotationCache(fallback plugin.Renderer, steps int, width, height float64) *rotationCache {
+	c := &rotationCache{
+		fallback: fallback,
+		steps:    steps,
+		width:    width,
+		height:   height,
+		sprites:  map[*ebiten.Image]*rotationAtlas{},
+	}
+	c.setScale(pixelScale)
+	return c
+}
+
+// setScale empties the cache and sizes the frames for the screen pixels per simulation
+// pixel, lowering the number of steps when the atlas would exceed maxSpriteSize
+func (c *rotationCache) setScale(pixels float64) {
+	for _, a := range c.sprites {
+		a.atlas.Dispose()
+	}
+	clear(c.sprites)
+	c.pixels = pixels
+	c.cell = int(math.Ceil(math.Hypot(c.width, c.height)*pixels)) + 2
+	perRow := max(1, maxSpriteSize/c.cell)
+	if c.steps > perRow*perRow {
+		slog.Warn("too many rotation steps for the donut size", "steps", c.steps, "using", perRow*perRow)
+		c.steps = perRow * perRow
+	}
+}
+
+// prepare renders the frames of sprites ahead of the first draw
+func (c *rotationCache) prepare(sprites ...*ebiten.Image) {
+	for _, sprite := range sprites {
+		c.atlasFor(sprite)
+	}
+}
+
+// atlasFor returns the frames of sprite, rendering them on first use. The cache is emptied
+// when it holds too many sprites, for example when a slideshow keeps swapping them.
+func (c *rotationCache) atlasFor(sprite *ebiten.Image) *rotationAtlas {
+	if c.pixels != pixelScale {
+		c.setScale(pixelScale)
+	}
+	if a, ok := c.sprites[sprite]; ok {
+		return a
+	}
+	if len(c.sprites) >= maxCachedSprites {
+		for _, a := range c.sprites {
+			a.atlas.Dispose()
+		}
+		clear(c.sprites)
+	}
+
+	perRow := int(math.Ceil(math.Sqrt(float64(c.steps))))
+	rows := (c.steps + perRow - 1) / perRow
+	a := &rotationAtlas{atlas: ebiten.NewImage(perRow*c.cell, rows*c.cell)}
+	bounds := sprite.Bounds()
+	width, height := c.width*c.pixels, c.height*c.pixels
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	for i := range c.steps {
+		x, y := i%perRow*c.cell, i/perRow*c.cell
+		op.GeoM.Reset()
+		op.GeoM.Scale(width/float64(bounds.Dx()), height/float64(bounds.Dy()))
+		op.GeoM.Translate(-width/2, -height/2)
+		op.GeoM.Rotate(2 * math.Pi * float64(i) / float64(c.steps))
+		op.GeoM.Translate(float64(x)+float64(c.cell)/2, float64(y)+float64(c.cell)/2)
+		a.atlas.DrawImage(sprite, op)
+		a.frames = append(a.frames, a.atlas.SubImage(image.Rect(x, y, x+c.cell, y+c.cell)).(*ebiten.Image))
+	}
+	c.sprites[sprite] = a
+	return a
+}
+
+// replaceSprite drops the frames of old, those of img are rendered on first use
+func (c *rotationCache) replaceSprite(old, img *ebiten.Image) {
+	if a, ok := c.sprites[old]; ok {
+		a.atlas.Dispose()
+		delete(c.sprites, old)
+	}
+}
+
+// DrawBody draws the frame nearest to the body's rotation, scaled when the body isn't the
+// size the frames were rendered at
+func (c *rotationCache) DrawBody(dst *ebiten.Image, sprite *ebiten.Image, b plugin.Body, tint color.Color) {
+	if _, along, across := b.Deformation(); along != 1 || across != 1 {
+		c.fallback.DrawBody(dst, sprite, b, tint)
+		return
+	}
+	w, h := b.Size()
+	x, y := b.Position()
+
+	a := c.atlasFor(sprite) // First, it can lower the number of steps
+	turns := b.Rotation() / (2 * math.Pi)
+	step := int(math.Round((turns-math.Floor(turns))*float64(c.steps))) % c.steps
+	frame := a.frames[step]
+
+	op := &c.op
+	op.GeoM.Reset()
+	op.ColorScale.Reset()
+	op.GeoM.Translate(-float64(c.cell)/2, -float64(c.cell)/2)
+	op.GeoM.Scale(w/(c.width*c.pixels), h/(c.height*c.pixels)) // The body is in screen pixels
+	op.GeoM.Translate(x+w/2, y+h/2)
+	op.ColorScale.ScaleWithColor(tint)
+	dst.DrawImage(frame, op)
+}
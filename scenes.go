@@ -0,0 +1,516 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mlctrez/donut/plugin"
+)
+
+// scene is one state of the application with its own input handling and drawing. The
+// simulation itself lives in Game; scenes decide whether it advances and what is drawn
+// on top of it.
+type scene interface {
+	Update(a *app) error
+	Draw(a *app, screen *ebiten.Image)
+}
+
+// closingScene is a scene with resources to release when another scene replaces it
+type closingScene interface {
+	close()
+}
+
+// app is the ebiten.Game that forwards to the current scene
+type app struct {
+	game     *Game
+	scene    scene
+	commands chan appCommand // Requests from the tray, see runAppCommands
+	crashes  int             // Panics turned into the crash scene, see recoverCrash
+	soak     *soakMonitor    // Non-nil while running a -soak test
+	kiosk    *kioskMode      // Non-nil in -kiosk mode
+	cursor   *cursorHider    // Non-nil when the cursor hides after a while
+}
+
+// Update runs the hooks that work in every scene, then the current scene
+func (a *app) Update() error {
+	defer a.recoverCrash()
+	a.game.attachWallpaperOnce()
+	a.game.trackWindow()
+	a.game.runCommands()
+	if err := a.runAppCommands(); err != nil {
+		return err
+	}
+	a.game.sound.update()
+	if a.cursor != nil && !a.kiosk.locked() {
+		a.cursor.update()
+	}
+	if a.soak != nil && a.soak.update() {
+		return ebiten.Termination
+	}
+	if a.game.sessionHidden {
+		return nil // Nobody is watching, see setSessionHidden
+	}
+	if a.kiosk != nil {
+		return a.updateKiosk()
+	}
+	return a.scene.Update(a)
+}
+
+// updateKiosk runs the scenes in kiosk mode. While locked only the simulation runs, with
+// no input, and errors restart it through the crash scene instead of exiting.
+func (a *app) updateKiosk() error {
+	a.kiosk.update()
+	a.game.inputLocked = a.kiosk.locked()
+	if !a.kiosk.locked() {
+		if ebiten.IsWindowBeingClosed() {
+			return ebiten.Termination
+		}
+		return a.scene.Update(a)
+	}
+	if _, crashed := a.scene.(*crashScene); crashed {
+		return a.scene.Update(a)
+	}
+	if s, ok := a.scene.(*arcadeScene); ok {
+		s.stop(a)
+	}
+	if _, ok := a.scene.(simulationScene); !ok {
+		a.switchTo(simulationScene{})
+	}
+	if err := a.game.Update(); err != nil {
+		a.crash(err, nil)
+	}
+	return nil
+}
+
+func (a *app) Draw(screen *ebiten.Image) {
+	defer a.recoverCrash()
+	if a.game.sessionHidden {
+		return // The screen isn't cleared, it keeps the last frame
+	}
+	a.scene.Draw(a, screen)
+	// Tinting and dimming the finished frame covers the menus and the crash screen too
+	if a.game.nightLight != nil {
+		a.game.nightLight.draw(screen, a.game.now())
+	}
+	a.game.brightness.draw(screen)
+}
+
+func (a *app) Layout(w, h int) (int, int) { return a.game.Layout(w, h) }
+
+// switchTo replaces the current scene with s, closing the current one when it needs it
+func (a *app) switchTo(s scene) {
+	if c, ok := a.scene.(closingScene); ok {
+		c.close()
+	}
+	a.scene = s
+}
+
+func (a *app) menuColor(selected bool) color.RGBA {
+	if selected {
+		return a.game.theme.highlight
+	}
+	return a.game.theme.hud
+}
+
+// simulationScene runs the simulation: Escape exits, P pauses, Tab opens the menu and F1
+// shows the help. Backspace asks to reset the incident counter when it is enabled.
+type simulationScene struct{}
+
+func (simulationScene) Update(a *app) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		return ebiten.Termination
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		a.switchTo(pauseScene{})
+		return nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		a.switchTo(&menuScene{})
+		return nil
+	case inpututil.IsKeyJustPressed(ebiten.KeyF1):
+		a.switchTo(helpScene{})
+		return nil
+	case !a.game.inputLocked && ebiten.IsKeyPressed(ebiten.KeyControl) && ebiten.IsKeyPressed(ebiten.KeyShift) &&
+		inpututil.IsKeyJustPressed(ebiten.KeyS):
+		a.switchTo(&saveSlotScene{})
+		return nil
+	case a.game.incident != nil && inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		a.switchTo(confirmScene{prompt: "Reset the counter?", confirm: (*Game).resetIncident})
+		return nil
+	}
+	return a.game.Update()
+}
+
+func (simulationScene) Draw(a *app, screen *ebiten.Image) {
+	// Without an update the screen, which isn't cleared between frames, already shows the
+	// current state
+	if !a.game.updated {
+		return
+	}
+	a.game.updated = false
+	a.game.Draw(screen)
+}
+
+// pauseScene freezes the simulation until P is pressed again
+type pauseScene struct{}
+
+func (pauseScene) Update(a *app) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyP):
+		a.switchTo(simulationScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		a.switchTo(&menuScene{})
+	}
+	return nil
+}
+
+func (pauseScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	const scale = 6
+	w, h := logicalSize(screen)
+	msg := tr("PAUSED")
+	drawText(screen, msg, (w-textWidth(msg, scale))/2, h/2-baseFontHeight*scale/2, scale, a.game.theme.hud)
+}
+
+// helpScene lists the keys over the paused simulation, with the build for bug reports.
+// Any key closes it.
+type helpScene struct{}
+
+// helpLines are the keys and what they do, the descriptions are translated
+var helpLines = [][2]string{
+	{"+ / -", "add or remove a donut"},
+	{"Shift/Ctrl + / -", "10 or 100 donuts"},
+	{"1-9", "presets"},
+	{"R", "rainbow"},
+	{"T / Shift+T", "next or previous theme"},
+	{"B / Shift+B", "dim or brighten"},
+	{"K / Shift+K", "next or previous background"},
+	{"O", "magnets"},
+	{"V", "add or remove a vortex"},
+	{"Shift+V", "new random velocities"},
+	{"Wheel", "zoom, middle-drag to pan"},
+	{"Home", "reset the zoom"},
+	{"G", "paint force fields, drag the mouse"},
+	{"Ctrl+S / L", "save or restore the simulation"},
+	{"Ctrl+Shift+S", "save to a named slot"},
+	{"F", "frame rate"},
+	{"P", "pause"},
+	{"Tab", "menu"},
+	{"[ / ]", "volume"},
+	{"M", "mute"},
+	{"N", "next track"},
+	{"Esc", "exit"},
+}
+
+func (helpScene) Update(a *app) error {
+	if len(inpututil.AppendJustPressedKeys(nil)) > 0 {
+		a.switchTo(simulationScene{})
+	}
+	return nil
+}
+
+func (helpScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	const titleScale, lineScale = 6, 2
+	w, h := logicalSize(screen)
+	keys, width := 0, 0.0
+	for _, line := range helpLines {
+		keys = max(keys, len(line[0]))
+	}
+	lines := make([]string, len(helpLines))
+	for i, line := range helpLines {
+		lines[i] = fmt.Sprintf("%-*s  %s", keys, line[0], tr(line[1]))
+		width = max(width, textWidth(lines[i], lineScale))
+	}
+
+	y := h/2 - float64(len(helpLines)+4)*baseFontHeight*lineScale*0.75
+	title := tr("HELP")
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, y, titleScale, a.game.theme.hud)
+	y += baseFontHeight * titleScale * 1.5
+	for _, line := range lines {
+		drawText(screen, line, (w-width)/2, y, lineScale, a.game.theme.hud)
+		y += baseFontHeight * lineScale * 1.5
+	}
+	build := currentBuild().String()
+	drawText(screen, build, (w-textWidth(build, 1))/2, h-baseFontHeight*3, 1, a.game.theme.hud)
+}
+
+// confirmScene asks a yes or no question over the paused simulation, Y or Enter runs
+// confirm and N or Escape cancels
+type confirmScene struct {
+	prompt  string
+	confirm func(g *Game)
+}
+
+func (c confirmScene) Update(a *app) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyY), inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		c.confirm(a.game)
+		a.switchTo(simulationScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyN), inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		a.switchTo(simulationScene{})
+	}
+	return nil
+}
+
+func (c confirmScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	drawMenu(a, screen, c.prompt, []string{"Y / N"}, -1)
+}
+
+// menuScene is the start menu shown with Tab or -menu
+type menuScene struct {
+	selected int
+}
+
+var menuItems = []string{"Start", "Settings", "Sound", "Load", "Games", "Scores", "Achievements", "Quit"}
+
+func (m *menuScene) Update(a *app) error {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		m.selected = (m.selected + len(menuItems) - 1) % len(menuItems)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		m.selected = (m.selected + 1) % len(menuItems)
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		a.switchTo(simulationScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		switch menuItems[m.selected] {
+		case "Start":
+			a.switchTo(simulationScene{})
+		case "Settings":
+			a.switchTo(&settingsScene{})
+		case "Sound":
+			a.switchTo(&soundScene{})
+		case "Load":
+			a.switchTo(newLoadSlotScene())
+		case "Games":
+			a.switchTo(&gamesScene{})
+		case "Scores":
+			a.switchTo(newHighScoresScene())
+		case "Achievements":
+			a.switchTo(achievementsScene{})
+		case "Quit":
+			return ebiten.Termination
+		}
+	}
+	return nil
+}
+
+func (m *menuScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	drawMenu(a, screen, "DONUT", menuItems, m.selected)
+}
+
+// drawMenu draws a title and a list of items centered on the screen. The title and items
+// are translated, so scenes can keep comparing the English items.
+func drawMenu(a *app, screen *ebiten.Image, title string, items []string, selected int) {
+	const titleScale, itemScale = 6, 3
+	w, h := logicalSize(screen)
+	y := h/2 - float64(len(items)+2)*baseFontHeight*itemScale
+
+	title = tr(title)
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, y, titleScale, a.game.theme.hud)
+	y += baseFontHeight * titleScale * 1.5
+	for i, item := range items {
+		item = tr(item)
+		if i == selected {
+			item = "> " + item + " <"
+		}
+		drawText(screen, item, (w-textWidth(item, itemScale))/2, y, itemScale, a.menuColor(i == selected))
+		y += baseFontHeight * itemScale * 1.5
+	}
+}
+
+// labelWidth returns the length in characters of the longest translated label of rows, so
+// the values after them line up in every language
+func labelWidth[T any](rows []T, label func(T) string) int {
+	width := 0
+	for _, row := range rows {
+		width = max(width, utf8.RuneCountInString(tr(label(row))))
+	}
+	return width
+}
+
+// settingsScene edits the current settings with the arrow keys. Every change is applied
+// as an actionSettings event so it is recorded like any other input.
+type settingsScene struct {
+	selected int
+}
+
+// settingsRow is one editable line of the settings scene
+type settingsRow struct {
+	label  string
+	value  func(s settings) string
+	adjust func(s *settings, dir int) // dir is -1 or +1
+}
+
+var settingsRows = []settingsRow{
+	{"Donuts", func(s settings) string { return fmt.Sprint(s.Donuts) },
+		func(s *settings, dir int) { s.Donuts += dir }},
+	{"Gravity", func(s settings) string { return fmt.Sprintf("%.2f", s.Gravity) },
+		func(s *settings, dir int) { s.Gravity = max(0, s.Gravity+0.05*float64(dir)) }},
+	{"Min speed", func(s settings) string { return fmt.Sprintf("%.1f", s.MinSpeed) },
+		func(s *settings, dir int) { s.MinSpeed = max(0.1, s.MinSpeed+0.5*float64(dir)) }},
+	{"Max speed", func(s settings) string { return fmt.Sprintf("%.1f", s.MaxSpeed) },
+		func(s *settings, dir int) { s.MaxSpeed = max(s.MinSpeed, s.MaxSpeed+0.5*float64(dir)) }},
+	{"Min spin", func(s settings) string { return fmt.Sprintf("%.3f", s.MinSpin) },
+		func(s *settings, dir int) { s.MinSpin = max(0.005, s.MinSpin+0.005*float64(dir)) }},
+	{"Max spin", func(s settings) string { return fmt.Sprintf("%.3f", s.MaxSpin) },
+		func(s *settings, dir int) { s.MaxSpin = max(s.MinSpin, s.MaxSpin+0.005*float64(dir)) }},
+	{"Theme", func(s settings) string { return s.Theme },
+		func(s *settings, dir int) { s.Theme = cycle(themeNames(), s.Theme, dir) }},
+	{"Behavior", func(s settings) string { return s.Behavior },
+		func(s *settings, dir int) { s.Behavior = cycle(plugin.Behaviors(), s.Behavior, dir) }},
+	{"Trails", func(s settings) string { return onOff(s.Trails) },
+		func(s *settings, dir int) { s.Trails = !s.Trails }},
+	{"Layers", func(s settings) string { return fmt.Sprint(s.Layers) },
+		func(s *settings, dir int) { s.Layers += dir }},
+	{"Size", func(s settings) string { return fmt.Sprintf("%.2f", s.Size) },
+		func(s *settings, dir int) { s.Size = max(minDonutSize, s.Size+0.05*float64(dir)) }},
+	{"Lifetime", func(s settings) string {
+		if s.Lifetime == 0 {
+			return tr("forever")
+		}
+		return fmt.Sprint(time.Duration(s.Lifetime) * time.Second)
+	},
+		func(s *settings, dir int) { s.Lifetime = max(0, s.Lifetime+60*float64(dir)) }},
+	{"Chains", func(s settings) string {
+		switch {
+		case s.Chain < 2:
+			return tr("off")
+		case s.ChainRing:
+			return trf("rings of %d", s.Chain)
+		}
+		return trf("%d long", s.Chain)
+	},
+		func(s *settings, dir int) { s.Chain = max(0, min(maxChainLength, max(1, s.Chain)+dir)) }},
+	{"Magnets", func(s settings) string { return onOff(s.Magnets) },
+		func(s *settings, dir int) { s.Magnets = !s.Magnets }},
+	{"Crumbs", func(s settings) string { return onOff(s.Crumbs) },
+		func(s *settings, dir int) { s.Crumbs = !s.Crumbs }},
+	{"Rainbow", func(s settings) string { return onOff(s.Rainbow) },
+		func(s *settings, dir int) { s.Rainbow = !s.Rainbow }},
+	{"Rainbow timer", func(s settings) string { return onOff(s.RainbowTimer) },
+		func(s *settings, dir int) { s.RainbowTimer = !s.RainbowTimer }},
+}
+
+func (m *settingsScene) Update(a *app) error {
+	dir := 0
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		m.selected = (m.selected + len(settingsRows) - 1) % len(settingsRows)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		m.selected = (m.selected + 1) % len(settingsRows)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		dir = -1
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		dir = 1
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		a.switchTo(&menuScene{selected: slices.Index(menuItems, "Settings")})
+	}
+
+	if dir != 0 {
+		g := a.game
+		s := g.currentSettings()
+		settingsRows[m.selected].adjust(&s, dir)
+		ev := replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s}
+		g.recordEvent(ev)
+		g.applyAction(ev)
+	}
+	return nil
+}
+
+func (m *settingsScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	s := a.game.currentSettings()
+	items := make([]string, len(settingsRows))
+	width := labelWidth(settingsRows, func(row settingsRow) string { return row.label })
+	for i, row := range settingsRows {
+		items[i] = fmt.Sprintf("%-*s %8s", width, tr(row.label), row.value(s))
+	}
+	drawMenu(a, screen, "SETTINGS", items, m.selected)
+}
+
+// soundScene edits the volumes with the arrow keys. Sound isn't part of the simulation, so
+// changes aren't recorded.
+type soundScene struct {
+	selected int
+}
+
+// soundRow is one editable line of the sound scene
+type soundRow struct {
+	label  string
+	value  func(v volumes) string
+	adjust func(v *volumes, dir int) // dir is -1 or +1
+}
+
+func percent(x float64) string { return fmt.Sprintf("%.0f%%", x*100) }
+
+var soundRows = []soundRow{
+	{"Master", func(v volumes) string { return percent(v.Master) },
+		func(v *volumes, dir int) { v.Master += volumeStep * float64(dir) }},
+	{"Music", func(v volumes) string { return percent(v.Music) },
+		func(v *volumes, dir int) { v.Music += volumeStep * float64(dir) }},
+	{"Effects", func(v volumes) string { return percent(v.SFX) },
+		func(v *volumes, dir int) { v.SFX += volumeStep * float64(dir) }},
+	{"Mute", func(v volumes) string { return onOff(v.Muted) },
+		func(v *volumes, dir int) { v.Muted = !v.Muted }},
+}
+
+func (m *soundScene) Update(a *app) error {
+	dir := 0
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		m.selected = (m.selected + len(soundRows) - 1) % len(soundRows)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		m.selected = (m.selected + 1) % len(soundRows)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		dir = -1
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		dir = 1
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		a.switchTo(&menuScene{selected: slices.Index(menuItems, "Sound")})
+	}
+
+	if dir != 0 {
+		v := a.game.sound.volumes
+		soundRows[m.selected].adjust(&v, dir)
+		a.game.sound.setVolumes(v)
+	}
+	return nil
+}
+
+func (m *soundScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	items := make([]string, len(soundRows))
+	width := labelWidth(soundRows, func(row soundRow) string { return row.label })
+	for i, row := range soundRows {
+		items[i] = fmt.Sprintf("%-*s %8s", width, tr(row.label), row.value(a.game.sound.volumes))
+	}
+	drawMenu(a, screen, "SOUND", items, m.selected)
+}
+
+// cycle returns the entry dir steps away from current in names, wrapping around
+func cycle(names []string, current string, dir int) string {
+	if len(names) == 0 {
+		return current
+	}
+	i := slices.Index(names, current)
+	return names[((i+dir)%len(names)+len(names))%len(names)]
+}
+
+func onOff(b bool) string {
+	if b {
+		return tr("on")
+	}
+	return tr("off")
+}
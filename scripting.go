@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// scriptEngine runs a Starlark script loaded with -script. The script registers per-frame
+// callbacks and manipulates the donuts through these builtins:
+//
+//	on_frame(fn)                          call fn(frame) after the physics step of every frame
+//	screen()                              (width, height) of the screen in pixels
+//	donuts()                              list of structs with index, x, y, vx, vy, rotation
+//	                                      (indexes change when donuts are removed)
+//	set_donut(i, x=, y=, vx=, vy=)        change any of the given fields of donut i
+//	spawn(x, y, vx=0, vy=0)               add a donut, returns its index or -1 at the cap
+//	remove(i)                             remove donut i, returns False at the minimum count
+//
+// For example, to pull every donut toward the middle of the screen:
+//
+//	def pull(frame):
+//	    w, h = screen()
+//	    for d in donuts():
+//	        set_donut(d.index, vx = d.vx + (w / 2 - d.x) * 0.0005, vy = d.vy + (h / 2 - d.y) * 0.0005)
+//
+//	on_frame(pull)
+//
+// Loading the script and each callback run with a step limit, so a runaway loop stops the
+// script instead of freezing the screensaver.
+type scriptEngine struct {
+	thread    *starlark.Thread
+	callbacks []starlark.Callable
+	game      *Game // The game being updated, only valid during runFrame
+}
+
+// number unpacks a script argument that may be either an int or a float, recording
+// whether an optional argument was passed at all
+type number struct {
+	value float64
+	set   bool
+}
+
+func (n *number) Unpack(v starlark.Value) error {
+	f, ok := starlark.AsFloat(v)
+	if !ok {
+		return fmt.Errorf("got %s, want number", v.Type())
+	}
+	n.value, n.set = f, true
+	return nil
+}
+
+// scriptMaxSteps is the number of Starlark steps loading the script or one callback may take
+const scriptMaxSteps = 10_000_000
+
+// loadScript executes the script at path, which registers its callbacks
+func loadScript(path string) (*scriptEngine, error) {
+	s := &scriptEngine{
+		thread: &starlark.Thread{
+			Name:  path,
+			Print: func(_ *starlark.Thread, msg string) { slog.Info(msg, "source", "script") },
+		},
+	}
+	s.thread.SetMaxExecutionSteps(scriptMaxSteps)
+	predeclared := starlark.StringDict{
+		"on_frame":  starlark.NewBuiltin("on_frame", s.onFrame),
+		"screen":    starlark.NewBuiltin("screen", s.screen),
+		"donuts":    starlark.NewBuiltin("donuts", s.donuts),
+		"set_donut": starlark.NewBuiltin("set_donut", s.setDonut),
+		"spawn":     starlark.NewBuiltin("spawn", s.spawn),
+		"remove":    starlark.NewBuiltin("remove", s.remove),
+	}
+	if _, err := starlark.ExecFile(s.thread, path, nil, predeclared); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// runFrame calls every registered callback for the current frame
+func (s *scriptEngine) runFrame(g *Game) error {
+	s.game = g
+	defer func() { s.game = nil }()
+
+	args := starlark.Tuple{starlark.MakeInt(g.frame)}
+	for _, fn := range s.callbacks {
+		s.thread.Steps = 0
+		if _, err := starlark.Call(s.thread, fn, args, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGame returns an error for builtins that need the game when called at load time
+func (s *scriptEngine) checkGame(b *starlark.Builtin) error {
+	if s.game == nil {
+		return fmt.Errorf("%s: can only be called from an on_frame callback", b.Name())
+	}
+	return nil
+}
+
+func (s *scriptEngine) onFrame(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn starlark.Callable
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "fn", &fn); err != nil {
+		return nil, err
+	}
+	s.callbacks = append(s.callbacks, fn)
+	return starlark.None, nil
+}
+
+func (s *scriptEngine) screen(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	if err := s.checkGame(b); err != nil {
+		return nil, err
+	}
+	return starlark.Tuple{starlark.MakeInt(s.game.screenWidth), starlark.MakeInt(s.game.screenHeight)}, nil
+}
+
+func (s *scriptEngine) donuts(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	if err := s.checkGame(b); err != nil {
+		return nil, err
+	}
+	w := s.game.world
+	list := make([]starlark.Value, w.donuts.Len())
+	for i, e := range w.donuts.Entities() {
+		d := w.body(e)
+		x, y := d.Position()
+		vx, vy := d.Velocity()
+		list[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"index":    starlark.MakeInt(i),
+			"x":        starlark.Float(x),
+			"y":        starlark.Float(y),
+			"vx":       starlark.Float(vx),
+			"vy":       starlark.Float(vy),
+			"rotation": starlark.Float(d.Rotation()),
+		})
+	}
+	return starlark.NewList(list), nil
+}
+
+func (s *scriptEngine) setDonut(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var i int
+	var x, y, vx, vy number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "i", &i, "x?", &x, "y?", &y, "vx?", &vx, "vy?", &vy); err != nil {
+		return nil, err
+	}
+	if err := s.checkGame(b); err != nil {
+		return nil, err
+	}
+	w := s.game.world
+	if i < 0 || i >= w.donuts.Len() {
+		return nil, fmt.Errorf("%s: donut index %d out of range", b.Name(), i)
+	}
+	e := w.donut(i)
+	pos, vel := w.positions.Get(e), w.velocities.Get(e)
+	for _, field := range []struct {
+		arg number
+		dst *float64
+	}{{x, &pos.x}, {y, &pos.y}, {vx, &vel.x}, {vy, &vel.y}} {
+		if field.arg.set {
+			*field.dst = field.arg.value
+		}
+	}
+	return starlark.None, nil
+}
+
+func (s *scriptEngine) spawn(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x, y, vx, vy number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "x", &x, "y", &y, "vx?", &vx, "vy?", &vy); err != nil {
+		return nil, err
+	}
+	if err := s.checkGame(b); err != nil {
+		return nil, err
+	}
+	g := s.game
+	if g.world.donuts.Len() >= g.maxDonutCount() {
+		return starlark.MakeInt(-1), nil
+	}
+	g.world.spawnDonut(Donut{
+		x: x.value, y: y.value, vx: vx.value, vy: vy.value,
+		width: g.donutWidth, height: g.donutHeight,
+	}, g.donutImageFor(g.world.donuts.Len()))
+	g.numDonuts = g.world.donuts.Len()
+	return starlark.MakeInt(g.numDonuts - 1), nil
+}
+
+func (s *scriptEngine) remove(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var i int
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "i", &i); err != nil {
+		return nil, err
+	}
+	if err := s.checkGame(b); err != nil {
+		return nil, err
+	}
+	w := s.game.world
+	if i < 0 || i >= w.donuts.Len() {
+		return nil, fmt.Errorf("%s: donut index %d out of range", b.Name(), i)
+	}
+	if w.donuts.Len() <= minDonuts {
+		return starlark.False, nil
+	}
+	w.Despawn(w.donut(i))
+	s.game.numDonuts = w.donuts.Len()
+	return starlark.True, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const serviceName = "donut"
+
+// serviceEnv are the environment variables copied into the service when they're set, the
+// display of the session, the ebiten graphics settings and the timer start
+var serviceEnv = []string{"DISPLAY", "WAYLAND_DISPLAY", "XAUTHORITY", "EBITENGINE_GRAPHICS_LIBRARY", "EBITENGINE_OPENGL", timerStartEnv}
+
+var resumeFlag = runFlags.Bool("resume", false, "continue the simulation saved when the screensaver last stopped")
+
+// runInstallService is the install-service command: it starts the screensaver in kiosk
+// mode at login, with a systemd user unit on Linux and a Task Scheduler task on Windows.
+// Arguments after the flags are passed on to donut run.
+func runInstallService(args []string) int {
+	fs := flag.NewFlagSet("install-service", flag.ExitOnError)
+	config := fs.String("config", defaultConfigPath(), "config file of the service")
+	kiosk := fs.Bool("kiosk", true, "run in kiosk mode")
+	uninstall := fs.Bool("uninstall", false, "remove the service instead")
+	printUnit := fs.Bool("print", false, "print the systemd unit instead of installing it")
+	var env []string
+	fs.Func("env", "extra KEY=VALUE environment variable of the service, repeatable", func(s string) error {
+		if !strings.Contains(s, "=") {
+			return fmt.Errorf("want KEY=VALUE")
+		}
+		env = append(env, s)
+		return nil
+	})
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: donut install-service [flags] [-- run flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donut install-service:", err)
+		return 1
+	}
+	cfg, err := filepath.Abs(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donut install-service:", err)
+		return 1
+	}
+	command := []string{exe, "run", "-resume", "-config", cfg}
+	if *kiosk {
+		command = append(command, "-kiosk")
+	}
+	command = append(command, fs.Args()...)
+	for _, name := range serviceEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append([]string{name + "=" + value}, env...)
+		}
+	}
+
+	switch {
+	case *printUnit:
+		fmt.Print(systemdUnit(command, env))
+	case runtime.GOOS == "windows":
+		err = installTask(command, env, *uninstall)
+	case runtime.GOOS == "linux":
+		err = installUnit(command, env, *uninstall)
+	default:
+		err = fmt.Errorf("services aren't supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donut install-service:", err)
+		return 1
+	}
+	return 0
+}
+
+// systemdUnit returns a user unit running command in the graphical session
+func systemdUnit(command, env []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=Donut screensaver\nPartOf=graphical-session.target\nAfter=graphical-session.target\n\n[Service]\n")
+	for _, e := range env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(e))
+	}
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\nRestart=on-failure\nRestartSec=5\n\n[Install]\nWantedBy=graphical-session.target\n", strings.Join(quoted, " "))
+	return b.String()
+}
+
+// systemdQuote quotes s for a unit file when it has characters systemd treats specially
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`).Replace(s) + `"`
+}
+
+// installUnit writes the user unit and enables it with systemctl
+func installUnit(command, env []string, uninstall bool) error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "systemd", "user", serviceName+".service")
+	if uninstall {
+		systemctl("disable", "--now", serviceName+".service")
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Println("removed", path)
+		return systemctl("daemon-reload")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(systemdUnit(command, env)), 0o644); err != nil {
+		return err
+	}
+	fmt.Println("wrote", path)
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl("enable", serviceName+".service"); err != nil {
+		return err
+	}
+	fmt.Printf("the screensaver starts at the next login, or now with: systemctl --user start %s\n", serviceName)
+	return nil
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("systemctl --user %s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// installTask creates a Task Scheduler task running command at logon. Tasks have no
+// environment of their own and the command of a task is limited to 261 characters, so the
+// task runs a script in the config directory that sets the variables and starts command.
+func installTask(command, env []string, uninstall bool) error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, serviceName, serviceName+"-service.cmd")
+	if uninstall {
+		if err := schtasks("/Delete", "/TN", serviceName, "/F"); err != nil {
+			return err
+		}
+		os.Remove(path)
+		return nil
+	}
+
+	script, err := taskScript(command, env)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		return err
+	}
+	fmt.Println("wrote", path)
+	return schtasks("/Create", "/TN", serviceName, "/TR", windowsQuote(path), "/SC", "ONLOGON", "/RL", "LIMITED", "/F")
+}
+
+// taskScript returns a batch script that sets env and starts command without keeping its
+// console open. Every value is quoted, so cmd takes & | < > and ^ literally, and percent
+// signs are doubled. Quotes and line breaks can't be escaped in a batch script and are
+// refused.
+func taskScript(command, env []string) (string, error) {
+	var b strings.Builder
+	b.WriteString("@echo off\r\n")
+	for _, e := range env {
+		if strings.ContainsAny(e, "\"\r\n") {
+			return "", fmt.Errorf("environment variable %q has quotes or line breaks", e)
+		}
+		fmt.Fprintf(&b, "set \"%s\"\r\n", strings.ReplaceAll(e, "%", "%%"))
+	}
+	b.WriteString(`start ""`)
+	for _, arg := range command {
+		if strings.ContainsAny(arg, "\"\r\n") {
+			return "", fmt.Errorf("argument %q has quotes or line breaks", arg)
+		}
+		// Backslashes before the closing quote would escape it for the program
+		trailing := len(arg) - len(strings.TrimRight(arg, `\`))
+		fmt.Fprintf(&b, ` "%s%s"`, strings.ReplaceAll(arg, "%", "%%"), strings.Repeat(`\`, trailing))
+	}
+	b.WriteString("\r\n")
+	return b.String(), nil
+}
+
+func schtasks(args ...string) error {
+	cmd := exec.Command("schtasks", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("schtasks %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// windowsQuote quotes an argument for a Windows command line
+func windowsQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// handleSignals stops the screensaver through the game loop on SIGTERM or an interrupt,
+// so the window, recording and state are saved as when quitting with Escape. A second
+// signal exits right away in case the game loop is stuck.
+func handleSignals(a *app) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			slog.Info("stopping", "signal", sig)
+			a.send(func(a *app) error { return ebiten.Termination })
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-signals:
+			slog.Error("stopped before saving", "signal", sig)
+			os.Exit(1)
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
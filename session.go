@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// idleTPS is the tick rate while nobody can see the screen, just enough to notice when
+// the session comes back
+const idleTPS = 2
+
+var errNoSessionSignals = errors.New("no session lock signals to watch")
+
+var pauseHiddenFlag = runFlags.Bool("pause-when-locked", true, "pause the simulation and drawing while the session is locked, the screen saver is active or the machine sleeps (Linux)")
+
+// startSessionWatch follows the desktop session so nothing runs while the screen is locked
+// or off. Watching is best effort, it's logged and skipped where it's unsupported.
+func startSessionWatch(g *Game) {
+	if !*pauseHiddenFlag {
+		return
+	}
+	err := watchSession(func(hidden bool) {
+		select {
+		case g.commands <- func(g *Game) { g.setSessionHidden(hidden) }:
+		default:
+		}
+	})
+	if err != nil {
+		slog.Debug("session lock detection unavailable", "err", err)
+	}
+}
+
+// setSessionHidden pauses the simulation and drawing while the screen can't be seen and
+// lowers the tick rate to save power
+func (g *Game) setSessionHidden(hidden bool) {
+	if hidden == g.sessionHidden {
+		return
+	}
+	g.sessionHidden = hidden
+	g.applyTPS()
+	slog.Info("session visibility changed", "hidden", hidden)
+}
+
+// applyTPS sets the tick rate for the frame rate cap, low-power mode and session state
+func (g *Game) applyTPS() {
+	tps := g.fps
+	if g.lowPower {
+		tps = min(tps, lowPowerTPS)
+	}
+	if g.sessionHidden {
+		tps = idleTPS
+	}
+	ebiten.SetTPS(tps)
+	g.step = float64(ebiten.DefaultTPS) / float64(ebiten.TPS())
+	if g.reduceMotion {
+		g.step *= reducedMotion
+	}
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	login1Dest    = "org.freedesktop.login1"
+	login1Manager = "org.freedesktop.login1.Manager"
+	login1Session = "org.freedesktop.login1.Session"
+)
+
+// screenSaverInterfaces announce the screen saver turning on and off with ActiveChanged
+var screenSaverInterfaces = []string{"org.freedesktop.ScreenSaver", "org.gnome.ScreenSaver", "org.mate.ScreenSaver"}
+
+// watchSession calls hidden with true when the screen saver starts, the logind session is
+// locked or the machine is about to sleep, and with false once none of them holds. The
+// desktop's screen saver is on the session bus, logind on the system bus, either may be
+// missing.
+func watchSession(hidden func(bool)) error {
+	signals := make(chan *dbus.Signal, 16)
+	var watching int
+
+	if conn, err := dbus.ConnectSessionBus(); err == nil {
+		for _, iface := range screenSaverInterfaces {
+			if conn.AddMatchSignal(dbus.WithMatchInterface(iface), dbus.WithMatchMember("ActiveChanged")) == nil {
+				watching++
+			}
+		}
+		conn.Signal(signals)
+	}
+
+	conn, err := dbus.ConnectSystemBus()
+	if err == nil {
+		var session dbus.ObjectPath
+		manager := conn.Object(login1Dest, "/org/freedesktop/login1")
+		if err = manager.Call(login1Manager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&session); err == nil {
+			for _, member := range []string{"Lock", "Unlock"} {
+				if conn.AddMatchSignal(dbus.WithMatchObjectPath(session), dbus.WithMatchInterface(login1Session), dbus.WithMatchMember(member)) == nil {
+					watching++
+				}
+			}
+		}
+		if conn.AddMatchSignal(dbus.WithMatchInterface(login1Manager), dbus.WithMatchMember("PrepareForSleep")) == nil {
+			watching++
+		}
+		conn.Signal(signals)
+	}
+	if watching == 0 {
+		if err == nil {
+			err = errNoSessionSignals
+		}
+		return err
+	}
+
+	go func() {
+		var saver, locked, sleeping bool
+		for sig := range signals {
+			on, _ := firstBool(sig.Body)
+			switch sig.Name {
+			case login1Session + ".Lock":
+				locked = true
+			case login1Session + ".Unlock":
+				locked = false
+			case login1Manager + ".PrepareForSleep":
+				sleeping = on
+			default:
+				if !strings.HasSuffix(sig.Name, ".ActiveChanged") {
+					continue // Bus notices like NameAcquired
+				}
+				saver = on
+			}
+			hidden(saver || locked || sleeping)
+		}
+	}()
+	return nil
+}
+
+// firstBool returns the first signal argument when it's a bool
+func firstBool(body []any) (bool, bool) {
+	if len(body) == 0 {
+		return false, false
+	}
+	b, ok := body[0].(bool)
+	return b, ok
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package main
+
+// watchSession isn't supported on this platform
+func watchSession(hidden func(bool)) error { return errNoSessionSignals }
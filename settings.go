@@ -0,0 +1,162 @@
+package main
+
+import (
+	"image/color"
+	"log/slog"
+
+	"github.com/mlctrez/donut/plugin"
+)
+
+// settings is a bundle of simulation and visual options that can be switched at runtime
+type settings struct {
+	Donuts   int     `json:"donuts"`    // Number of donuts
+	Gravity  float64 `json:"gravity"`   // Downward acceleration in pixels per frame², 0 disables gravity
+	Theme    string  `json:"theme"`     // Name of a color theme in themes
+	Behavior string  `json:"behavior"`  // Name of a movement behavior in behaviors
+	MinSpeed float64 `json:"min_speed"` // Minimum initial speed per axis in pixels per frame
+	MaxSpeed float64 `json:"max_speed"` // Maximum initial speed per axis in pixels per frame
+	MinSpin  float64 `json:"min_spin"`  // Minimum rotation speed in radians per frame
+	MaxSpin  float64 `json:"max_spin"`  // Maximum rotation speed in radians per frame
+	Trails   bool    `json:"trails"`    // Leave fading trails behind the donuts
+	Layers   int     `json:"layers"`    // Number of parallax depth layers, 1 disables parallax
+	Crumbs   bool    `json:"crumbs"`    // Donuts drop crumbs that slowly fade
+	Size     float64 `json:"size"`      // Donut size relative to the default, 0 for the default
+
+	Lifetime       float64 `json:"lifetime"`        // Seconds before a donut fades out and a fresh one spawns, 0 to live forever
+	LifetimeJitter float64 `json:"lifetime_jitter"` // Random variation of Lifetime in seconds, plus or minus
+	Chain          int     `json:"chain"`           // Donuts linked by springs into each chain, 0 for none
+	ChainRing      bool    `json:"chain_ring"`      // Close the chains into rings
+	Magnets        bool    `json:"magnets"`         // Donuts get a random pole, like poles repel and opposite poles attract
+
+	Rainbow      bool `json:"rainbow"`       // Cycle each donut's tint through the spectrum
+	RainbowTimer bool `json:"rainbow_timer"` // Cycle the timer color too
+}
+
+// Defaults for settings fields left at zero
+const (
+	defaultMinSpeed = 1.5
+	defaultMaxSpeed = 4.5
+	defaultMinSpin  = 0.015
+	defaultMaxSpin  = 0.035
+	minDonutSize    = 0.05
+	maxDonutSize    = 2
+)
+
+// withDefaults fills in zero fields and clamps the donut count to the allowed range
+func (s settings) withDefaults() settings {
+	if s.Donuts <= 0 {
+		s.Donuts = initialDonuts
+	}
+	s.Donuts = max(minDonuts, min(maxDonuts, s.Donuts))
+	if s.MinSpeed <= 0 {
+		s.MinSpeed = defaultMinSpeed
+	}
+	if s.MaxSpeed < s.MinSpeed {
+		s.MaxSpeed = max(s.MinSpeed, defaultMaxSpeed)
+	}
+	if s.MinSpin <= 0 {
+		s.MinSpin = defaultMinSpin
+	}
+	if s.MaxSpin < s.MinSpin {
+		s.MaxSpin = max(s.MinSpin, defaultMaxSpin)
+	}
+	s.Layers = max(1, min(maxLayers, s.Layers))
+	if s.Size <= 0 {
+		s.Size = 1
+	}
+	s.Size = max(minDonutSize, min(maxDonutSize, s.Size))
+	s.Lifetime = max(0, s.Lifetime)
+	s.LifetimeJitter = max(0, min(s.Lifetime, s.LifetimeJitter))
+	s.Chain = max(0, min(maxChainLength, s.Chain))
+	return s
+}
+
+const defaultBehavior = "bounce"
+
+// currentSettings returns the settings the game is running with
+func (g *Game) currentSettings() settings {
+	return settings{
+		Donuts:   g.userDonutCount(),
+		Gravity:  g.gravity,
+		Theme:    g.themeName,
+		Behavior: g.behaviorName,
+		MinSpeed: g.minSpeed,
+		MaxSpeed: g.maxSpeed,
+		MinSpin:  g.minSpin,
+		MaxSpin:  g.maxSpin,
+		Trails:   g.trails,
+		Layers:   g.numLayers,
+		Crumbs:   g.crumbsEnabled,
+		Size:     g.donutSize,
+
+		Lifetime:       g.lifetime,
+		LifetimeJitter: g.lifetimeJitter,
+		Chain:          g.chainLength,
+		ChainRing:      g.chainRing,
+		Magnets:        g.magnets,
+
+		Rainbow:      g.rainbow,
+		RainbowTimer: g.rainbowTimer,
+	}
+}
+
+// applySettings switches the game to s, recreating the donuts if the count, speed or spin
+// range, layers, size, lifetime or chains changed. Unknown theme and behavior names fall back to the defaults.
+func (g *Game) applySettings(s settings) {
+	s = s.withDefaults()
+	s.Donuts = min(s.Donuts, g.maxDonutCount())
+	if q := g.quality; q.fewerDonuts() {
+		q.donuts = s.Donuts
+		s.Donuts = max(minDonuts, s.Donuts/2)
+	}
+	if _, ok := themes[s.Theme]; !ok {
+		if s.Theme != "" {
+			slog.Warn("unknown theme, using the default", "theme", s.Theme, "default", defaultTheme)
+		}
+		s.Theme = defaultTheme
+	}
+	behavior, ok := plugin.LookupBehavior(s.Behavior)
+	if !ok {
+		if s.Behavior != "" {
+			slog.Warn("unknown behavior, using the default", "behavior", s.Behavior, "default", defaultBehavior)
+		}
+		s.Behavior = defaultBehavior
+		behavior, _ = plugin.LookupBehavior(defaultBehavior)
+	}
+	reset := s.Donuts != g.numDonuts || s.MinSpeed != g.minSpeed || s.MaxSpeed != g.maxSpeed ||
+		s.MinSpin != g.minSpin || s.MaxSpin != g.maxSpin || s.Layers != g.numLayers || s.Size != g.donutSize ||
+		s.Lifetime != g.lifetime || s.LifetimeJitter != g.lifetimeJitter ||
+		s.Chain != g.chainLength || s.ChainRing != g.chainRing
+
+	g.gravity = s.Gravity
+	g.themeName = s.Theme
+	g.theme = themes[s.Theme]
+	if g.highContrast {
+		g.theme = themes[highContrastTheme]
+	}
+	if g.oled != nil {
+		// Pixels that are off can't burn in
+		g.theme.background = color.RGBA{A: 255}
+	}
+	g.behaviorName = s.Behavior
+	g.behavior = behavior
+	g.trails = s.Trails
+	if g.crumbsEnabled && !s.Crumbs {
+		g.crumbs.clear()
+	}
+	g.crumbsEnabled = s.Crumbs
+	g.rainbow = s.Rainbow
+	g.magnets = s.Magnets
+	g.rainbowTimer = s.RainbowTimer
+	if reset {
+		g.numDonuts = s.Donuts
+		g.minSpeed, g.maxSpeed = s.MinSpeed, s.MaxSpeed
+		g.minSpin, g.maxSpin = s.MinSpin, s.MaxSpin
+		g.numLayers = s.Layers
+		g.donutSize = s.Size
+		g.lifetime, g.lifetimeJitter = s.Lifetime, s.LifetimeJitter
+		g.chainLength, g.chainRing = s.Chain, s.ChainRing
+		g.donutWidth, g.donutHeight = g.spriteWidth*s.Size, g.spriteHeight*s.Size
+		g.resetDonuts()
+	}
+}
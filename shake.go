@@ -0,0 +1,51 @@
+package main
+
+import "math"
+
+const (
+	shakeFrames    = 12  // Length of a shake at 60 frames per second
+	minShakeImpact = 8   // Impacts slower than this, in pixels per frame, don't shake
+	shakePerImpact = 0.5 // Offset in pixels per pixel/frame of impact speed above the minimum
+	maxShakeOffset = 6   // Offset limit in pixels so the shake stays subtle
+)
+
+// screenShake offsets everything drawn for a few frames after a heavy impact. It's only
+// an effect: the offsets come from the effects random source and the simulation doesn't
+// see them.
+type screenShake struct {
+	enabled  bool
+	strength float64 // Offset at the start of the current shake
+	frame    int     // Frames since the shake started
+	x, y     float64 // Offset of the current frame
+}
+
+// addShake starts a shake for an impact at speed, unless a stronger one is playing
+func (g *Game) addShake(speed float64) {
+	s := &g.shake
+	if !s.enabled || speed < minShakeImpact {
+		return
+	}
+	strength := min(maxShakeOffset, (speed-minShakeImpact)*shakePerImpact)
+	if s.frame < ticks(shakeFrames) && s.current() > strength {
+		return
+	}
+	s.strength, s.frame = strength, 0
+}
+
+// current returns the offset size of the shake, easing out from strength to 0
+func (s *screenShake) current() float64 {
+	p := float64(s.frame) / float64(ticks(shakeFrames))
+	return s.strength * (1 - p) * (1 - p)
+}
+
+// shakeSystem picks the offset of the next frame
+func (g *Game) shakeSystem() {
+	s := &g.shake
+	if s.frame >= ticks(shakeFrames) {
+		s.x, s.y = 0, 0
+		return
+	}
+	angle := g.fx.Float64() * 2 * math.Pi
+	s.x, s.y = math.Cos(angle)*s.current(), math.Sin(angle)*s.current()
+	s.frame++
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"cmp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultSlideInterval = 30 * time.Second
+	defaultSlideFade     = 2 * time.Second
+)
+
+var photoExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// slideshowConfig cycles photos from a directory behind the donuts
+type slideshowConfig struct {
+	Dir      string   `json:"dir"`      // Directory with the photos, re-read every cycle so it can change
+	Interval duration `json:"interval"` // Time each photo is shown, 30s by default
+	Fade     duration `json:"fade"`     // Crossfade duration, 2s by default
+	Shuffle  bool     `json:"shuffle"`  // Random order instead of sorted by name
+}
+
+// slideshow is a plugin.Background. Photos are decoded and scaled down to the screen size
+// in the background, one photo ahead, so large files don't stall frames.
+type slideshow struct {
+	cfg    slideshowConfig
+	loaded chan image.Image
+
+	// Screen size used to scale photos, written by Update and read by the loader
+	width, height atomic.Int32
+
+	current, previous *ebiten.Image
+	shown             int // Frames since the current photo appeared
+}
+
+func newSlideshow(cfg slideshowConfig) *slideshow {
+	s := &slideshow{cfg: cfg, loaded: make(chan image.Image)}
+	go s.load()
+	return s
+}
+
+// load decodes the photos in turn and hands them to Update, which blocks it until the
+// next photo is due
+func (s *slideshow) load() {
+	interval := cmp.Or(time.Duration(s.cfg.Interval), defaultSlideInterval)
+	for {
+		paths, err := s.photos()
+		if err != nil || len(paths) == 0 {
+			slog.Warn("slideshow: no photos", "dir", s.cfg.Dir, "err", err)
+			time.Sleep(interval)
+			continue
+		}
+		for _, path := range paths {
+			img, err := decodePhoto(path, int(s.width.Load()), int(s.height.Load()))
+			if err != nil {
+				slog.Warn("slideshow", "err", err)
+				continue
+			}
+			s.loaded <- img
+		}
+	}
+}
+
+// photos lists the images in the directory in display order
+func (s *slideshow) photos() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && slices.Contains(photoExtensions, strings.ToLower(filepath.Ext(e.Name()))) {
+			paths = append(paths, filepath.Join(s.cfg.Dir, e.Name()))
+		}
+	}
+	if s.cfg.Shuffle {
+		rand.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	}
+	return paths, nil
+}
+
+// decodePhoto reads the image at path, scaled down to cover width x height
+func decodePhoto(path string, width, height int) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	scale := max(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	if width == 0 || height == 0 || scale >= 1 {
+		return img, nil
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, b, draw.Src, nil)
+	return scaled, nil
+}
+
+func (s *slideshow) Update(w plugin.World) error {
+	width, height := w.Size()
+	s.width.Store(int32(width))
+	s.height.Store(int32(height))
+
+	s.shown++
+	due := s.current == nil || s.shown >= durationFrames(cmp.Or(time.Duration(s.cfg.Interval), defaultSlideInterval))
+	if !due {
+		return nil
+	}
+	select {
+	case img := <-s.loaded:
+		if s.previous != nil {
+			s.previous.Dispose()
+		}
+		s.previous, s.current = s.current, ebiten.NewImageFromImage(img)
+		s.shown = 0
+	default:
+	}
+	return nil
+}
+
+func (s *slideshow) Draw(dst *ebiten.Image, w plugin.World) {
+	fade := float32(1)
+	if frames := durationFrames(cmp.Or(time.Duration(s.cfg.Fade), defaultSlideFade)); s.shown < frames {
+		fade = float32(s.shown) / float32(frames)
+	}
+	if s.previous != nil && fade < 1 {
+		drawCover(dst, s.previous, 1)
+	}
+	if s.current != nil {
+		drawCover(dst, s.current, fade)
+	}
+}
+
+// drawCover draws img scaled to cover dst, centered and cropped, with the given opacity
+func drawCover(dst, img *ebiten.Image, alpha float32) {
+	db, ib := dst.Bounds(), img.Bounds()
+	scale := max(float64(db.Dx())/float64(ib.Dx()), float64(db.Dy())/float64(ib.Dy()))
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Scale(scale, scale)
+	op.GeoM.Translate((float64(db.Dx())-float64(ib.Dx())*scale)/2, (float64(db.Dy())-float64(ib.Dy())*scale)/2)
+	op.ColorScale.ScaleAlpha(alpha)
+	dst.DrawImage(img, op)
+}
+
+// durationFrames converts d to a number of simulation steps
+func durationFrames(d time.Duration) int {
+	return int(d.Seconds() * float64(ebiten.TPS()))
+}
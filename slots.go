@@ -0,0 +1,276 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"image"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	thumbnailWidth = 240 // Width of the slot thumbnails, the height follows the screen
+	maxSlotName    = 24
+	slotColumns    = 3
+)
+
+// slotsDir holds the named save slots, a snapshot and a thumbnail each
+func slotsDir() string {
+	return filepath.Join(filepath.Dir(statePath()), "slots")
+}
+
+// saveSlot is a named snapshot on disk
+type saveSlot struct {
+	name      string
+	saved     time.Time
+	thumbnail *ebiten.Image // Nil when the thumbnail is missing
+}
+
+// slotName turns what was typed into a name that is safe as a file name
+func slotName(typed string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == ' ' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(typed))
+	return cmp.Or(name, time.Now().Format("2006-01-02 15.04.05"))
+}
+
+// saveToSlot writes the simulation to the named slot. The thumbnail is taken from the
+// next frame drawn.
+func (g *Game) saveToSlot(name string) error {
+	if err := g.saveSnapshot(filepath.Join(slotsDir(), name+".json")); err != nil {
+		return err
+	}
+	g.thumbnailSlot = name
+	slog.Info("saved the simulation", "slot", name)
+	return nil
+}
+
+// saveThumbnail writes screen scaled down as the thumbnail of the slot being saved
+func (g *Game) saveThumbnail(screen *ebiten.Image) {
+	name := g.thumbnailSlot
+	g.thumbnailSlot = ""
+	b := screen.Bounds()
+	scale := float64(thumbnailWidth) / float64(b.Dx())
+	w, h := thumbnailWidth, max(1, int(float64(b.Dy())*scale))
+
+	thumb := ebiten.NewImage(w, h)
+	defer thumb.Dispose()
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	op.GeoM.Scale(scale, scale)
+	thumb.DrawImage(screen, op)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	thumb.ReadPixels(img.Pix)
+
+	f, err := os.Create(filepath.Join(slotsDir(), name+".png"))
+	if err == nil {
+		err = png.Encode(f, img)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		slog.Warn("failed to save the slot thumbnail", "slot", name, "err", err)
+	}
+}
+
+// loadSlots lists the save slots, the latest first, with their thumbnails
+func loadSlots() []saveSlot {
+	entries, err := os.ReadDir(slotsDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("failed to list the save slots", "err", err)
+	}
+	var slots []saveSlot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		s := saveSlot{name: name}
+		if info, err := entry.Info(); err == nil {
+			s.saved = info.ModTime()
+		}
+		if f, err := os.Open(filepath.Join(slotsDir(), name+".png")); err == nil {
+			if img, err := png.Decode(f); err == nil {
+				s.thumbnail = ebiten.NewImageFromImage(img)
+			}
+			f.Close()
+		}
+		slots = append(slots, s)
+	}
+	slices.SortFunc(slots, func(a, b saveSlot) int { return b.saved.Compare(a.saved) })
+	return slots
+}
+
+// disposeThumbnails releases the thumbnail images of slots
+func disposeThumbnails(slots []saveSlot) {
+	for _, s := range slots {
+		if s.thumbnail != nil {
+			s.thumbnail.Dispose()
+		}
+	}
+}
+
+// slotExists reports whether a slot with the name was saved
+func slotExists(name string) bool {
+	_, err := os.Stat(filepath.Join(slotsDir(), name+".json"))
+	return err == nil
+}
+
+// deleteSlot removes the named slot and its thumbnail
+func deleteSlot(name string) error {
+	os.Remove(filepath.Join(slotsDir(), name+".png"))
+	return os.Remove(filepath.Join(slotsDir(), name+".json"))
+}
+
+// saveSlotScene asks for the name of a new save slot over the paused simulation. Saving
+// over an existing slot takes a second Enter.
+type saveSlotScene struct {
+	name      string
+	overwrite string // Existing slot that the next Enter overwrites
+}
+
+func (s *saveSlotScene) Update(a *app) error {
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if len(s.name) < maxSlotName && unicode.IsPrint(r) && r < unicode.MaxASCII {
+			s.name += string(r)
+			s.overwrite = ""
+		}
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && s.name != "":
+		s.name = s.name[:len(s.name)-1]
+		s.overwrite = ""
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		a.switchTo(simulationScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		name := slotName(s.name)
+		if name != s.overwrite && slotExists(name) {
+			s.overwrite = name
+			return nil
+		}
+		if err := a.game.saveToSlot(name); err != nil {
+			slog.Error("failed to save the simulation", "err", err)
+		}
+		a.switchTo(simulationScene{})
+	}
+	return nil
+}
+
+func (s *saveSlotScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	hint := "Enter saves, Esc cancels"
+	if s.overwrite != "" {
+		hint = trf("%s exists, Enter overwrites it, Esc cancels", s.overwrite)
+	}
+	drawMenu(a, screen, "SAVE", []string{trf("Name: %s", s.name+"_"), hint}, -1)
+}
+
+// loadSlotScene browses the save slots by thumbnail. Enter restores the selected slot and
+// Delete removes it. The thumbnails are disposed when the scene closes.
+type loadSlotScene struct {
+	slots    []saveSlot
+	selected int
+}
+
+func newLoadSlotScene() *loadSlotScene {
+	return &loadSlotScene{slots: loadSlots()}
+}
+
+func (s *loadSlotScene) close() {
+	disposeThumbnails(s.slots)
+	s.slots = nil
+}
+
+func (s *loadSlotScene) Update(a *app) error {
+	n := len(s.slots)
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyTab):
+		a.switchTo(&menuScene{})
+	case n == 0:
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		s.selected = (s.selected + n - 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		s.selected = (s.selected + 1) % n
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		s.selected = max(0, s.selected-slotColumns)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		s.selected = min(n-1, s.selected+slotColumns)
+	case inpututil.IsKeyJustPressed(ebiten.KeyDelete):
+		if err := deleteSlot(s.slots[s.selected].name); err != nil {
+			slog.Error("failed to delete the slot", "err", err)
+		}
+		disposeThumbnails(s.slots)
+		s.slots = loadSlots()
+		s.selected = min(s.selected, max(0, len(s.slots)-1))
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter):
+		slot := s.slots[s.selected]
+		if err := a.game.restoreSnapshot(filepath.Join(slotsDir(), slot.name+".json")); err != nil {
+			slog.Error("failed to restore the simulation", "slot", slot.name, "err", err)
+			return nil
+		}
+		a.switchTo(simulationScene{})
+	}
+	return nil
+}
+
+func (s *loadSlotScene) Draw(a *app, screen *ebiten.Image) {
+	a.game.Draw(screen)
+	dimScreen(screen)
+	const titleScale, nameScale = 5, 2
+	clr := a.game.theme.hud
+	w, h := logicalSize(screen)
+	title := "LOAD"
+	drawText(screen, title, (w-textWidth(title, titleScale))/2, 40, titleScale, clr)
+	if len(s.slots) == 0 {
+		msg := tr("No saved slots, Ctrl+Shift+S saves one")
+		drawText(screen, msg, (w-textWidth(msg, nameScale))/2, h/2, nameScale, clr)
+		return
+	}
+
+	cellW := float64(thumbnailWidth + 40)
+	cellH := float64(thumbnailWidth)*h/w + baseFontHeight*nameScale*3
+	left := (w - cellW*slotColumns) / 2
+	// Scroll by rows so the selected slot is on screen
+	top := float64(40 + baseFontHeight*titleScale*2)
+	visibleRows := max(1, int((h-top-60)/cellH))
+	firstRow := max(0, s.selected/slotColumns-visibleRows+1)
+
+	op := &ebiten.DrawImageOptions{Filter: ebiten.FilterLinear}
+	for i := firstRow * slotColumns; i < len(s.slots) && i < (firstRow+visibleRows)*slotColumns; i++ {
+		slot := s.slots[i]
+		x := left + float64(i%slotColumns)*cellW + 20
+		y := top + float64(i/slotColumns-firstRow)*cellH
+		thumbH := float64(thumbnailWidth) * h / w
+		if slot.thumbnail != nil {
+			tb := slot.thumbnail.Bounds()
+			op.GeoM.Reset()
+			op.GeoM.Scale(thumbnailWidth/float64(tb.Dx()), thumbH/float64(tb.Dy()))
+			op.GeoM.Translate(x, y)
+			op.GeoM.Scale(pixelScale, pixelScale)
+			screen.DrawImage(slot.thumbnail, op)
+		}
+		if i == s.selected {
+			strokeRect(screen, float32(x)-3, float32(y)-3, thumbnailWidth+6, float32(thumbH)+6, 3, a.menuColor(true), false)
+		}
+		name := slot.name
+		if len(name) > thumbnailWidth/(baseFontWidth*nameScale) {
+			name = name[:thumbnailWidth/(baseFontWidth*nameScale)]
+		}
+		drawText(screen, name, x, y+thumbH+6, nameScale, a.menuColor(i == s.selected))
+	}
+	footer := tr("Enter loads, Delete removes, Esc goes back")
+	drawText(screen, footer, (w-textWidth(footer, nameScale))/2, h-40, nameScale, clr)
+}
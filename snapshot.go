@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	snapshotVersion  = 1
+	maxSnapshotSize  = 1 << 14 // Largest screen width or height of a snapshot
+	maxSnapshotDraws = 1 << 32 // Random numbers drawn, redrawing them on restore takes seconds
+)
+
+// countingSource is the simulation's random source. It counts the numbers drawn so the
+// state of the generator can be saved as the seed and the count, and restored by drawing
+// as many again.
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.seed, s.draws = seed, 0
+}
+
+// restore puts the source back to where it was after draws numbers from seed, up to
+// maxSnapshotDraws so a bad count can't hang the game loop
+func (s *countingSource) restore(seed int64, draws uint64) {
+	draws = min(draws, maxSnapshotDraws)
+	s.Seed(seed)
+	for range draws {
+		s.src.Uint64()
+	}
+	s.draws = draws
+}
+
+// simSnapshot is the full state of the simulation, saved with Ctrl+S and on exit and
+// restored with Ctrl+L or -resume. Effects like particles and squashes aren't kept.
+type simSnapshot struct {
+	Version    int              `json:"version"`
+	Saved      time.Time        `json:"saved"`
+	Frame      int              `json:"frame"`
+	TimerStart time.Time        `json:"timerStart"`
+	Seed       int64            `json:"seed"`
+	Draws      uint64           `json:"draws"` // Numbers drawn from the seed so far
+	Settings   settings         `json:"settings"`
+	Width      int              `json:"width"`
+	Height     int              `json:"height"`
+	Edges      [4]edgeMode      `json:"edges"`
+	Donuts     []snapshotDonut  `json:"donuts"`
+	Springs    []snapshotSpring `json:"springs,omitempty"`
+	Vortices   []snapshotVortex `json:"vortices,omitempty"`
+}
+
+type snapshotDonut struct {
+	X        float64 `json:"x"` // Top left corner
+	Y        float64 `json:"y"`
+	VX       float64 `json:"vx"`
+	VY       float64 `json:"vy"`
+	Angle    float64 `json:"angle"`
+	Spin     float64 `json:"spin"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Sprite   int     `json:"sprite"` // Index of the sprite variant, -1 for the main sprite
+	Layer    int     `json:"layer,omitempty"`
+	Life     int     `json:"life,omitempty"` // Frames left, 0 for donuts that live forever
+	Lifetime int     `json:"lifetime,omitempty"`
+	FadeIn   bool    `json:"fadeIn,omitempty"`
+	Label    string  `json:"label,omitempty"`
+	Pole     float64 `json:"pole,omitempty"`
+}
+
+// snapshotSpring links two donuts by their index in simSnapshot.Donuts
+type snapshotSpring struct {
+	A    int     `json:"a"`
+	B    int     `json:"b"`
+	Rest float64 `json:"rest"`
+}
+
+type snapshotVortex struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Radius   float64 `json:"radius"`
+	Strength float64 `json:"strength"`
+	Teleport bool    `json:"teleport,omitempty"`
+}
+
+// snapshotPath is where Ctrl+S and exiting save the simulation
+func snapshotPath() string {
+	return filepath.Join(filepath.Dir(statePath()), "snapshot.json")
+}
+
+// capture returns the state of the simulation
+func (g *Game) capture() simSnapshot {
+	w := g.world
+	s := simSnapshot{
+		Version:    snapshotVersion,
+		Saved:      time.Now(),
+		Frame:      g.frame,
+		TimerStart: g.timerStartTime,
+		Seed:       g.rngSource.seed,
+		Draws:      g.rngSource.draws,
+		Settings:   g.currentSettings(),
+		Width:      g.screenWidth,
+		Height:     g.screenHeight,
+		Edges:      g.edges,
+	}
+	index := map[ecs.Entity]int{}
+	for _, e := range w.donuts.Entities() {
+		pos, vel, spn, spr := w.positions.Get(e), w.velocities.Get(e), w.spins.Get(e), w.sprites.Get(e)
+		d := snapshotDonut{
+			X: pos.x, Y: pos.y, VX: vel.x, VY: vel.y, Angle: spn.angle, Spin: spn.speed,
+			Width: spr.width, Height: spr.height,
+			Sprite: slices.Index(g.donutImages, spr.image),
+			Layer:  w.layerOf(e),
+		}
+		if l := w.lifetimes.Get(e); l != nil {
+			d.Life, d.Lifetime, d.FadeIn = l.left, l.total, l.fadeIn
+		}
+		if l := w.labels.Get(e); l != nil {
+			d.Label = l.text
+		}
+		if p := w.polarities.Get(e); p != nil {
+			d.Pole = p.sign
+		}
+		index[e] = len(s.Donuts)
+		s.Donuts = append(s.Donuts, d)
+	}
+	for i := range w.springs.Len() {
+		_, sp := w.springs.At(i)
+		a, okA := index[sp.a]
+		b, okB := index[sp.b]
+		if okA && okB {
+			s.Springs = append(s.Springs, snapshotSpring{a, b, sp.rest})
+		}
+	}
+	for i := range w.vortices.Len() {
+		e, v := w.vortices.At(i)
+		pos := w.positions.Get(e)
+		s.Vortices = append(s.Vortices, snapshotVortex{pos.x, pos.y, v.radius, v.strength, v.teleport})
+	}
+	return s
+}
+
+// restore replaces the simulation with s, scaled to the current screen size
+func (g *Game) restore(s simSnapshot) {
+	g.applySettings(s.Settings)
+	w := g.world
+	w.clearDonuts()
+	for w.springs.Len() > 0 {
+		e, _ := w.springs.At(w.springs.Len() - 1)
+		w.Despawn(e)
+	}
+	for w.vortices.Len() > 0 {
+		e, _ := w.vortices.At(w.vortices.Len() - 1)
+		w.Despawn(e)
+	}
+
+	entities := make([]ecs.Entity, len(s.Donuts))
+	for i, d := range s.Donuts {
+		img := g.donutImage
+		if d.Sprite >= 0 && d.Sprite < len(g.donutImages) {
+			img = g.donutImages[d.Sprite]
+		}
+		e := w.spawnDonut(Donut{
+			x: d.X, y: d.Y, vx: d.VX, vy: d.VY, width: d.Width, height: d.Height,
+			rotation: d.Angle, rotationSpeed: d.Spin,
+		}, img)
+		w.layers.Add(e, layer{d.Layer})
+		if d.Lifetime > 0 {
+			w.lifetimes.Add(e, lifetime{left: d.Life, total: d.Lifetime, fadeIn: d.FadeIn})
+		}
+		if d.Label != "" {
+			w.labels.Add(e, label{d.Label})
+		}
+		if d.Pole != 0 {
+			w.polarities.Add(e, polarity{d.Pole})
+		}
+		entities[i] = e
+	}
+	for _, sp := range s.Springs {
+		e := w.Spawn()
+		w.springs.Add(e, spring{a: entities[sp.A], b: entities[sp.B], rest: sp.Rest})
+	}
+	for _, v := range s.Vortices {
+		e := w.Spawn()
+		w.positions.Add(e, position{v.X, v.Y})
+		w.vortices.Add(e, vortex{radius: v.Radius, strength: v.Strength, teleport: v.Teleport})
+	}
+
+	g.numDonuts = w.donuts.Len()
+	g.edges = s.Edges
+	g.frame = s.Frame
+	g.timerStartTime = s.TimerStart
+	g.rngSource.restore(s.Seed, s.Draws)
+	g.energy.rebase()
+
+	// Entities are where they were on the saved screen, move them onto this one
+	width, height := g.screenWidth, g.screenHeight
+	g.screenWidth, g.screenHeight = s.Width, s.Height
+	g.resizeWorld(width, height)
+}
+
+// saveSnapshot writes the simulation to path
+func (g *Game) saveSnapshot(path string) error {
+	data, err := json.MarshalIndent(g.capture(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// loadSnapshot reads a snapshot file
+func loadSnapshot(path string) (simSnapshot, error) {
+	var s simSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
+	}
+	if s.Version != snapshotVersion {
+		return s, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	edges := edgesConfig{Left: s.Edges[edgeLeft], Right: s.Edges[edgeRight], Top: s.Edges[edgeTop], Bottom: s.Edges[edgeBottom]}
+	if s.Edges, err = edges.modes(); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// validate checks that every count and index of s is in range, so a damaged or edited
+// file can't crash or hang the game loop
+func (s simSnapshot) validate() error {
+	switch {
+	case s.Width < 1 || s.Width > maxSnapshotSize || s.Height < 1 || s.Height > maxSnapshotSize:
+		return fmt.Errorf("invalid screen size %dx%d", s.Width, s.Height)
+	case s.Draws > maxSnapshotDraws:
+		return fmt.Errorf("%d random numbers drawn, at most %d can be restored", s.Draws, uint64(maxSnapshotDraws))
+	case len(s.Donuts) > maxDonuts:
+		return fmt.Errorf("%d donuts, at most %d", len(s.Donuts), maxDonuts)
+	case len(s.Vortices) > maxVortices:
+		return fmt.Errorf("%d vortices, at most %d", len(s.Vortices), maxVortices)
+	}
+	for i, d := range s.Donuts {
+		switch {
+		case d.Layer < 0 || d.Layer >= len(depthLayers):
+			return fmt.Errorf("donut %d: layer %d, want 0 to %d", i, d.Layer, len(depthLayers)-1)
+		case d.Width <= 0 || d.Height <= 0 || d.Width > maxSnapshotSize || d.Height > maxSnapshotSize:
+			return fmt.Errorf("donut %d: invalid size %vx%v", i, d.Width, d.Height)
+		case d.Lifetime < 0 || d.Life < 0 || d.Life > d.Lifetime:
+			return fmt.Errorf("donut %d: %d frames left of a lifetime of %d", i, d.Life, d.Lifetime)
+		}
+	}
+	for i, sp := range s.Springs {
+		if sp.A < 0 || sp.A >= len(s.Donuts) || sp.B < 0 || sp.B >= len(s.Donuts) || sp.A == sp.B {
+			return fmt.Errorf("spring %d: links donuts %d and %d of %d", i, sp.A, sp.B, len(s.Donuts))
+		}
+	}
+	for i, v := range s.Vortices {
+		if v.Radius <= 0 {
+			return fmt.Errorf("vortex %d: radius %v", i, v.Radius)
+		}
+	}
+	return nil
+}
+
+// restoreSnapshot restores the snapshot file at path. A restored simulation doesn't
+// follow from the recorded inputs, so it's refused while recording or replaying.
+func (g *Game) restoreSnapshot(path string) error {
+	if g.recorder != nil || g.replay != nil {
+		return errors.New("snapshots can't be restored while recording or replaying")
+	}
+	s, err := loadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	g.restore(s)
+	slog.Info("restored the simulation", "path", path, "saved", s.Saved.Format(time.DateTime), "donuts", len(s.Donuts))
+	return nil
+}
+
+// pollSnapshot saves the simulation on Ctrl+S and restores it on Ctrl+L
+func (g *Game) pollSnapshot() {
+	// Ctrl+Shift+S saves to a named slot instead, see saveSlotScene
+	if !ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyShift) {
+		return
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyS):
+		if err := g.saveSnapshot(snapshotPath()); err != nil {
+			slog.Error("failed to save the simulation", "err", err)
+			return
+		}
+		slog.Info("saved the simulation", "path", snapshotPath())
+	case inpututil.IsKeyJustPressed(ebiten.KeyL):
+		if err := g.restoreSnapshot(snapshotPath()); err != nil {
+			slog.Error("failed to restore the simulation", "err", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnapshotValidate(t *testing.T) {
+	g := newBenchGame(settings{Donuts: 5}, 1)
+	g.rngSource = newCountingSource(1)
+	if err := g.capture().validate(); err != nil {
+		t.Fatalf("captured snapshot: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *simSnapshot)
+		want   string
+	}{
+		{"no screen", func(s *simSnapshot) { s.Width = 0 }, "screen size"},
+		{"too many draws", func(s *simSnapshot) { s.Draws = maxSnapshotDraws + 1 }, "random numbers"},
+		{"too many donuts", func(s *simSnapshot) { s.Donuts = make([]snapshotDonut, maxDonuts+1) }, "donuts"},
+		{"negative layer", func(s *simSnapshot) { s.Donuts[1].Layer = -1 }, "donut 1: layer"},
+		{"layer too deep", func(s *simSnapshot) { s.Donuts[2].Layer = len(depthLayers) }, "donut 2: layer"},
+		{"no size", func(s *simSnapshot) { s.Donuts[0].Width = 0 }, "donut 0: invalid size"},
+		{"life over lifetime", func(s *simSnapshot) { s.Donuts[0].Life = 10 }, "frames left"},
+		{"negative spring end", func(s *simSnapshot) { s.Springs = []snapshotSpring{{A: -1, B: 0}} }, "spring 0"},
+		{"spring past the donuts", func(s *simSnapshot) { s.Springs = []snapshotSpring{{A: 0, B: 5}} }, "spring 0"},
+		{"vortex without radius", func(s *simSnapshot) { s.Vortices = []snapshotVortex{{}} }, "vortex 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := g.capture()
+			tt.change(&s)
+			if err := s.validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validate = %v, want an error about %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"time"
+)
+
+const (
+	soakSampleInterval = 10 * time.Second
+	soakHeapTolerance  = 1.25    // Allowed growth factor of the live heap
+	soakHeapSlack      = 4 << 20 // Allowed growth of the live heap in bytes, on top of the factor
+	soakGoroutineSlack = 2       // Allowed growth of the goroutine count
+)
+
+var soakFlag = runFlags.Duration("soak", 0, "run for this long while watching memory and goroutines, exiting with status 1 on growth")
+
+// soakSample is one measurement of the resources that must stay flat
+type soakSample struct {
+	heap       uint64 // Live heap after a GC in bytes
+	goroutines int
+}
+
+// soakMonitor runs the screensaver for a fixed duration, sampling the live heap and the
+// goroutine count. Both must stay flat: the minimum of the last third of the samples is
+// compared with the minimum of the first third, skipping the first sample while caches
+// warm up. Minimums ignore garbage that happens to be alive at a sample.
+type soakMonitor struct {
+	duration time.Duration
+	start    time.Time
+	next     time.Time
+	samples  []soakSample
+	err      error // Set when the soak test failed
+}
+
+func newSoakMonitor(d time.Duration) *soakMonitor {
+	now := time.Now()
+	return &soakMonitor{duration: d, start: now, next: now}
+}
+
+// update samples when due and reports whether the soak test is over
+func (s *soakMonitor) update() bool {
+	now := time.Now()
+	if now.Before(s.next) {
+		return false
+	}
+	s.next = now.Add(soakSampleInterval)
+
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	sample := soakSample{heap: m.HeapAlloc, goroutines: runtime.NumGoroutine()}
+	s.samples = append(s.samples, sample)
+	slog.Debug("soak sample", "heap", sample.heap, "goroutines", sample.goroutines)
+
+	if now.Sub(s.start) < s.duration {
+		return false
+	}
+	s.err = s.check()
+	if s.err != nil {
+		slog.Error("soak test failed", "err", s.err, "duration", s.duration)
+	} else {
+		slog.Info("soak test passed", "duration", s.duration, "samples", len(s.samples))
+	}
+	return true
+}
+
+// check compares the start and the end of the run
+func (s *soakMonitor) check() error {
+	samples := s.samples[1:]
+	if len(samples) < 3 {
+		return fmt.Errorf("only %d samples, soak for at least %s", len(s.samples), 4*soakSampleInterval)
+	}
+	third := len(samples) / 3
+	first, last := samples[:third], samples[len(samples)-third:]
+	minOf := func(ss []soakSample) soakSample {
+		return soakSample{
+			heap:       slices.MinFunc(ss, func(a, b soakSample) int { return cmp.Compare(a.heap, b.heap) }).heap,
+			goroutines: slices.MinFunc(ss, func(a, b soakSample) int { return a.goroutines - b.goroutines }).goroutines,
+		}
+	}
+	before, after := minOf(first), minOf(last)
+
+	if float64(after.heap) > float64(before.heap)*soakHeapTolerance+soakHeapSlack {
+		return fmt.Errorf("live heap grew from %s to %s", formatBytes(float64(before.heap)), formatBytes(float64(after.heap)))
+	}
+	if after.goroutines > before.goroutines+soakGoroutineSlack {
+		return fmt.Errorf("goroutines grew from %d to %d", before.goroutines, after.goroutines)
+	}
+	return nil
+}
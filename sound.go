@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	audioContextRate = 44100
+	volumeStep       = 0.1
+)
+
+// volumes are the sound levels between 0 and 1, remembered in the state file
+type volumes struct {
+	Master float64 `json:"master"`
+	Music  float64 `json:"music"`
+	SFX    float64 `json:"sfx"`
+	Muted  bool    `json:"muted"`
+}
+
+var defaultVolumes = volumes{Master: 0.8, Music: 0.6, SFX: 0.8}
+
+func (v volumes) clamped() volumes {
+	clamp := func(x float64) float64 { return min(1, max(0, x)) }
+	return volumes{Master: clamp(v.Master), Music: clamp(v.Music), SFX: clamp(v.SFX), Muted: v.Muted}
+}
+
+func (v volumes) effective(category float64) float64 {
+	if v.Muted {
+		return 0
+	}
+	return v.Master * category
+}
+
+// sound owns the audio context and mixes the music and sound effects. [ and ] change the
+// master volume, M mutes and N skips to the next track.
+type sound struct {
+	ctx     *audio.Context
+	volumes volumes
+	music   *musicPlayer    // nil without music
+	effects []*audio.Player // Sound effects still playing
+	chime   []byte          // Milestone sound, 16-bit stereo PCM
+}
+
+func newSound(v *volumes) *sound {
+	s := &sound{ctx: audio.NewContext(audioContextRate), volumes: defaultVolumes}
+	if v != nil {
+		s.volumes = v.clamped()
+	}
+	s.chime = synthChime(audioContextRate)
+	return s
+}
+
+// startMusic plays source, see newMusicPlayer
+func (s *sound) startMusic(source string) error {
+	m, err := newMusicPlayer(s.ctx, source, s.volumes.effective(s.volumes.Music))
+	s.music = m
+	return err
+}
+
+// setVolumes applies and remembers new volumes
+func (s *sound) setVolumes(v volumes) {
+	s.volumes = v.clamped()
+	if s.music != nil {
+		s.music.setVolume(s.volumes.effective(s.volumes.Music))
+	}
+	for _, p := range s.effects {
+		p.SetVolume(s.volumes.effective(s.volumes.SFX))
+	}
+	saved := s.volumes
+	updateState(func(st *appState) { st.Volumes = &saved })
+}
+
+// playEffect plays 16-bit stereo PCM at the sound effects volume
+func (s *sound) playEffect(pcm []byte) {
+	if s.volumes.effective(s.volumes.SFX) == 0 {
+		return
+	}
+	p := s.ctx.NewPlayerFromBytes(pcm)
+	p.SetVolume(s.volumes.effective(s.volumes.SFX))
+	p.Play()
+	s.effects = append(s.effects, p)
+}
+
+// update advances the playlist and releases finished effects. It runs in every scene.
+func (s *sound) update() {
+	if s.music != nil {
+		s.music.update()
+	}
+	s.effects = slices.DeleteFunc(s.effects, func(p *audio.Player) bool {
+		if p.IsPlaying() {
+			return false
+		}
+		p.Close()
+		return true
+	})
+}
+
+// handleKeys handles the sound keys while the simulation runs
+func (s *sound) handleKeys() {
+	v := s.volumes
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketLeft):
+		v.Master -= volumeStep
+	case inpututil.IsKeyJustPressed(ebiten.KeyBracketRight):
+		v.Master += volumeStep
+	case inpututil.IsKeyJustPressed(ebiten.KeyM):
+		v.Muted = !v.Muted
+	case inpututil.IsKeyJustPressed(ebiten.KeyN) && s.music != nil && len(s.music.tracks) > 0:
+		s.music.next()
+		return
+	default:
+		return
+	}
+	s.setVolumes(v)
+}
+
+// synthChime generates a short two-note bell as 16-bit stereo PCM
+func synthChime(rate int) []byte {
+	const seconds = 0.8
+	n := int(seconds * float64(rate))
+	pcm := make([]byte, n*4)
+	for i := range n {
+		t := float64(i) / float64(rate)
+		v := 0.0
+		for j, freq := range []float64{1046.5, 1568} { // C6, then G6
+			start := float64(j) * 0.12
+			if t >= start {
+				dt := t - start
+				v += 0.3 * math.Exp(-dt*6) * math.Sin(2*math.Pi*freq*dt)
+			}
+		}
+		sample := uint16(int16(v * math.MaxInt16))
+		binary.LittleEndian.PutUint16(pcm[i*4:], sample)
+		binary.LittleEndian.PutUint16(pcm[i*4+2:], sample)
+	}
+	return pcm
+}
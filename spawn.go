@@ -0,0 +1,90 @@
+package main
+
+import (
+	"maps"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+const defaultSpawner = "center"
+
+// spawnArea is the screen and donut size a spawner places donuts in
+type spawnArea struct {
+	width, height           float64 // Screen size
+	donutWidth, donutHeight float64
+}
+
+// spawner places the i-th of n new donuts, returning its top left corner. When aimed is
+// set the donut flies in the direction heading, in radians, otherwise in a random
+// diagonal direction. Spawners draw from rng only, so replays spawn the same donuts.
+type spawner func(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool)
+
+// spawners are selectable by name with the spawn config setting
+var spawners = map[string]spawner{
+	"center":   spawnCenter,
+	"uniform":  spawnUniform,
+	"edges":    spawnEdges,
+	"fountain": spawnFountain,
+	"grid":     spawnGrid,
+}
+
+// spawnerNames returns the names of all spawners in sorted order
+func spawnerNames() []string {
+	return slices.Sorted(maps.Keys(spawners))
+}
+
+// spawnCenter spawns in a circle in the middle of the screen, or anywhere when there are
+// too many donuts to fit there
+func spawnCenter(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool) {
+	spawnRadius := math.Min(a.width, a.height) * 0.25
+	angle := rng.Float64() * 2 * math.Pi
+	distance := rng.Float64() * spawnRadius
+	if n > crowdedDonuts {
+		// Too many to fit in the middle, use the two random numbers for a spot anywhere
+		return angle / (2 * math.Pi) * (a.width - a.donutWidth), distance / spawnRadius * (a.height - a.donutHeight), 0, false
+	}
+	x = a.width/2 + math.Cos(angle)*distance - a.donutWidth/2
+	y = a.height/2 + math.Sin(angle)*distance - a.donutHeight/2
+	return x, y, 0, false
+}
+
+// spawnUniform spawns anywhere on the screen
+func spawnUniform(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool) {
+	return rng.Float64() * (a.width - a.donutWidth), rng.Float64() * (a.height - a.donutHeight), 0, false
+}
+
+// spawnEdges spawns along the screen edges, flying inward
+func spawnEdges(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool) {
+	const spread = math.Pi / 3 // Range of headings around straight inward
+	along := rng.Float64()
+	heading = (rng.Float64() - 0.5) * spread
+	switch rng.Intn(4) {
+	case 0: // Left, heading right
+		return 0, along * (a.height - a.donutHeight), heading, true
+	case 1: // Right
+		return a.width - a.donutWidth, along * (a.height - a.donutHeight), math.Pi + heading, true
+	case 2: // Top, heading down
+		return along * (a.width - a.donutWidth), 0, math.Pi/2 + heading, true
+	default: // Bottom
+		return along * (a.width - a.donutWidth), a.height - a.donutHeight, -math.Pi/2 + heading, true
+	}
+}
+
+// spawnFountain spawns at the bottom center, spraying upward
+func spawnFountain(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool) {
+	const spread = math.Pi / 4
+	x = (a.width-a.donutWidth)/2 + (rng.Float64()-0.5)*a.donutWidth
+	y = a.height - a.donutHeight
+	return x, y, -math.Pi/2 + (rng.Float64()-0.5)*spread, true
+}
+
+// spawnGrid spawns in evenly spaced rows and columns, filled row by row
+func spawnGrid(rng *rand.Rand, i, n int, a spawnArea) (x, y, heading float64, aimed bool) {
+	cols := max(1, int(math.Ceil(math.Sqrt(float64(n)*a.width/a.height))))
+	rows := (n + cols - 1) / cols
+	cellW, cellH := a.width/float64(cols), a.height/float64(rows)
+	x = (float64(i%cols)+0.5)*cellW - a.donutWidth/2
+	y = (float64(i/cols)+0.5)*cellH - a.donutHeight/2
+	return x, y, 0, false
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	maxChainLength  = 20
+	springStiffness = 0.02 // Pull per pixel of stretch, per frame
+	springDamping   = 0.05 // Share of the stretching speed removed per frame
+	springSlack     = 1.2  // Rest length relative to the donut width, keeps linked donuts apart
+)
+
+// spring pulls two donuts toward being rest pixels apart, center to center. Springs are
+// entities of their own so a donut can have several.
+type spring struct {
+	a, b ecs.Entity
+	rest float64
+}
+
+// linkChains joins the donuts into chains of chainLength donuts with springs, closed into
+// rings when chainRing is set. Only donuts of the same depth layer are linked since the
+// layers don't collide with each other.
+func (g *Game) linkChains() {
+	w := g.world
+	for w.springs.Len() > 0 {
+		e, _ := w.springs.At(w.springs.Len() - 1)
+		w.Despawn(e)
+	}
+	if g.chainLength < 2 {
+		return
+	}
+	for depth := range max(1, g.numLayers) {
+		var chain []ecs.Entity
+		link := func() {
+			for i := 1; i < len(chain); i++ {
+				g.addSpring(chain[i-1], chain[i])
+			}
+			if g.chainRing && len(chain) >= 3 {
+				g.addSpring(chain[len(chain)-1], chain[0])
+			}
+			chain = chain[:0]
+		}
+		for _, e := range w.donuts.Entities() {
+			if w.layerOf(e) != depth || w.labels.Has(e) {
+				continue
+			}
+			chain = append(chain, e)
+			if len(chain) == g.chainLength {
+				link()
+			}
+		}
+		link()
+	}
+}
+
+// addSpring links donuts a and b
+func (g *Game) addSpring(a, b ecs.Entity) {
+	e := g.world.Spawn()
+	g.world.springs.Add(e, spring{a: a, b: b, rest: g.world.sprites.Get(a).width * springSlack})
+}
+
+// springSystem pulls linked donuts together or pushes them apart along the spring, damping
+// their speed along it so chains wobble rather than oscillate forever. Springs of donuts
+// that are gone, for example aged out, are removed.
+func (g *Game) springSystem() {
+	w := g.world
+	for i := w.springs.Len() - 1; i >= 0; i-- {
+		e, s := w.springs.At(i)
+		posA, posB := w.positions.Get(s.a), w.positions.Get(s.b)
+		if posA == nil || posB == nil {
+			w.Despawn(e)
+			continue
+		}
+		sprA, sprB := w.sprites.Get(s.a), w.sprites.Get(s.b)
+		velA, velB := w.velocities.Get(s.a), w.velocities.Get(s.b)
+		dx := posB.x + sprB.width/2 - posA.x - sprA.width/2
+		dy := posB.y + sprB.height/2 - posA.y - sprA.height/2
+		dist := math.Hypot(dx, dy)
+		if dist == 0 {
+			continue
+		}
+		nx, ny := dx/dist, dy/dist
+		stretching := (velB.x-velA.x)*nx + (velB.y-velA.y)*ny
+		force := (springStiffness*(dist-s.rest) + springDamping*stretching) * g.step
+		velA.x += nx * force / 2
+		velA.y += ny * force / 2
+		velB.x -= nx * force / 2
+		velB.y -= ny * force / 2
+	}
+}
+
+// drawSprings draws a rope between the centers of linked donuts
+func (g *Game) drawSprings(dst *ebiten.Image) {
+	w := g.world
+	tint := g.theme.timer
+	clr := color.RGBA{tint.R / 2, tint.G / 2, tint.B / 2, 200}
+	for i := range w.springs.Len() {
+		_, s := w.springs.At(i)
+		posA, posB := w.positions.Get(s.a), w.positions.Get(s.b)
+		if posA == nil || posB == nil {
+			continue
+		}
+		sprA, sprB := w.sprites.Get(s.a), w.sprites.Get(s.b)
+		strokeLine(dst,
+			float32(posA.x+sprA.width/2), float32(posA.y+sprA.height/2),
+			float32(posB.x+sprB.width/2), float32(posB.y+sprB.height/2),
+			3, clr, true)
+	}
+}
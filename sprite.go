@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+const (
+	maxSpriteSize         = 4096 // Largest accepted sprite width or height in pixels
+	proceduralSpriteSize  = 500  // Size of generated donuts, matching the built-in donut.png
+	maxProceduralVariants = 100
+	defaultSprinkles      = 40
+	maxSprinkles          = 1000
+)
+
+// loadDonutImage returns the sprite to bounce. It prefers the user-supplied image at path,
+// then the embedded donut.png, and finally draws a donut procedurally so the screensaver
+// still runs. Every fallback is logged.
+func loadDonutImage(path string) *ebiten.Image {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var img *ebiten.Image
+			if img, err = decodeSprite(data); err == nil {
+				return img
+			}
+		}
+		slog.Warn("can't use image, using the built-in donut", "path", path, "err", err)
+	}
+
+	img, err := decodeSprite(donutPNG)
+	if err == nil {
+		return img
+	}
+	slog.Warn("can't decode donut.png, drawing a procedural donut", "err", err)
+	return proceduralDonut(proceduralSpriteSize, defaultDough, defaultFrosting[0], 0, nil)
+}
+
+// decodeSprite decodes and validates an image for use as a sprite
+func decodeSprite(data []byte) (*ebiten.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSprite(img); err != nil {
+		return nil, err
+	}
+	return ebiten.NewImageFromImage(img), nil
+}
+
+// validateSprite rejects images that would be invisible or unreasonably large
+func validateSprite(img image.Image) error {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return errors.New("image is empty")
+	}
+	if b.Dx() > maxSpriteSize || b.Dy() > maxSpriteSize {
+		return fmt.Errorf("image is %dx%d, the maximum is %dx%d", b.Dx(), b.Dy(), maxSpriteSize, maxSpriteSize)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0 {
+				return nil
+			}
+		}
+	}
+	return errors.New("image is fully transparent")
+}
+
+// Default colors of procedural donuts
+var (
+	defaultDough    = color.RGBA{200, 140, 70, 255}
+	defaultFrosting = []color.RGBA{
+		{240, 120, 170, 255}, // Strawberry
+		{90, 50, 30, 255},    // Chocolate
+		{250, 240, 220, 255}, // Vanilla
+		{150, 210, 120, 255}, // Pistachio
+	}
+	sprinkleColors = []color.RGBA{
+		{255, 80, 80, 255},
+		{255, 220, 60, 255},
+		{90, 200, 255, 255},
+		{120, 230, 120, 255},
+		{255, 255, 255, 255},
+	}
+)
+
+// proceduralDonut draws a frosted donut of the given size with vector graphics. Sprinkles
+// are scattered over the frosting using rng, which may be nil when sprinkles is 0.
+func proceduralDonut(size int, dough, frosting color.RGBA, sprinkles int, rng *rand.Rand) *ebiten.Image {
+	img := ebiten.NewImage(size, size)
+	c := float32(size) / 2
+	outer, inner := c*0.88, c*0.45
+
+	fillRing(img, c, c, c, c*0.35, dough)
+	fillRing(img, c, c, outer, inner, frosting)
+
+	for range sprinkles {
+		// Pick a point on the frosting, keeping the whole sprinkle inside the ring
+		length := c * 0.08
+		angle := rng.Float64() * 2 * math.Pi
+		dist := float64(inner+length) + rng.Float64()*float64(outer-inner-2*length)
+		x := c + float32(math.Cos(angle)*dist)
+		y := c + float32(math.Sin(angle)*dist)
+		dx, dy := math.Sincos(rng.Float64() * math.Pi)
+		clr := sprinkleColors[rng.Intn(len(sprinkleColors))]
+		vector.StrokeLine(img, x-float32(dx)*length/2, y-float32(dy)*length/2, x+float32(dx)*length/2, y+float32(dy)*length/2, c*0.03, clr, true)
+	}
+	return img
+}
+
+// proceduralVariants generates variants different donut sprites from cfg
+func proceduralVariants(cfg proceduralConfig, rng *rand.Rand) []*ebiten.Image {
+	dough := defaultDough
+	if cfg.Dough != nil {
+		dough = color.RGBAModel.Convert(*cfg.Dough).(color.RGBA)
+	}
+	frostings := defaultFrosting
+	if len(cfg.Frosting) > 0 {
+		frostings = make([]color.RGBA, len(cfg.Frosting))
+		for i, f := range cfg.Frosting {
+			frostings[i] = color.RGBAModel.Convert(f).(color.RGBA)
+		}
+	}
+	variants := cmp.Or(cfg.Variants, len(frostings))
+	sprinkles := defaultSprinkles
+	if cfg.Sprinkles != nil {
+		sprinkles = *cfg.Sprinkles
+	}
+
+	images := make([]*ebiten.Image, variants)
+	for i := range images {
+		// Cycle through the frostings so every color is used when there are enough variants
+		images[i] = proceduralDonut(proceduralSpriteSize, dough, frostings[i%len(frostings)], sprinkles, rng)
+	}
+	return images
+}
+
+// whitePixel is the source image for drawing solid colored triangles
+var whitePixel = func() *ebiten.Image {
+	img := ebiten.NewImage(3, 3)
+	img.Fill(color.White)
+	return img.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+}()
+
+// fillRing fills the area between two concentric circles, leaving the hole transparent
+func fillRing(dst *ebiten.Image, cx, cy, outer, inner float32, clr color.Color) {
+	var path vector.Path
+	path.MoveTo(cx+outer, cy)
+	path.Arc(cx, cy, outer, 0, 2*math.Pi, vector.Clockwise)
+	path.Close()
+	path.MoveTo(cx+inner, cy)
+	path.Arc(cx, cy, inner, 0, 2*math.Pi, vector.Clockwise)
+	path.Close()
+	fillPath(dst, &path, clr, ebiten.EvenOdd)
+}
+
+// fillRoundedRect fills a rectangle with corners rounded to radius, in simulation pixels
+func fillRoundedRect(dst *ebiten.Image, x, y, width, height, radius float32, clr color.Color) {
+	s := float32(pixelScale)
+	x, y, width, height, radius = x*s, y*s, width*s, height*s, radius*s
+	radius = min(radius, width/2, height/2)
+	var path vector.Path
+	path.MoveTo(x+radius, y)
+	path.ArcTo(x+width, y, x+width, y+height, radius)
+	path.ArcTo(x+width, y+height, x, y+height, radius)
+	path.ArcTo(x, y+height, x, y, radius)
+	path.ArcTo(x, y, x+width, y, radius)
+	path.Close()
+	fillPath(dst, &path, clr, ebiten.FillAll)
+}
+
+// fillPath fills path with a solid color
+func fillPath(dst *ebiten.Image, path *vector.Path, clr color.Color, rule ebiten.FillRule) {
+	c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	vs, is := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	for i := range vs {
+		vs[i].SrcX, vs[i].SrcY = 1, 1
+		vs[i].ColorR = float32(c.R) / 255
+		vs[i].ColorG = float32(c.G) / 255
+		vs[i].ColorB = float32(c.B) / 255
+		vs[i].ColorA = float32(c.A) / 255
+	}
+	dst.DrawTriangles(vs, is, whitePixel, &ebiten.DrawTrianglesOptions{FillRule: rule, AntiAlias: true})
+}
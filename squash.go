@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	squashFrames      = 15   // Length of the squash animation at 60 frames per second
+	squashPerSpeed    = 0.04 // Compression per pixel/frame of impact speed
+	maxSquashStrength = 0.35 // Compression limit so fast impacts stay readable
+	minSquashSpeed    = 0.5  // Impacts slower than this don't squash
+)
+
+// amount returns the current compression, easing out from strength to 0
+func (s *squash) amount() float64 {
+	p := float64(s.frame) / float64(ticks(squashFrames))
+	return s.strength * (1 - p) * (1 - p)
+}
+
+// addSquash starts a squash on e for an impact along the normal nx, ny at speed
+func (g *Game) addSquash(e ecs.Entity, nx, ny, speed float64) {
+	if !g.squashEnabled || speed < minSquashSpeed || !g.world.sprites.Has(e) {
+		return
+	}
+	strength := min(maxSquashStrength, speed*squashPerSpeed)
+	if s := g.world.squashes.Get(e); s != nil && s.amount() > strength {
+		return // Keep the stronger squash already playing
+	}
+	g.world.squashes.Add(e, squash{angle: math.Atan2(ny, nx), strength: strength})
+}
+
+// squashSystem advances squash animations and removes finished ones
+func (g *Game) squashSystem() {
+	w := g.world
+	length := ticks(squashFrames)
+	for i := w.squashes.Len() - 1; i >= 0; i-- {
+		e, s := w.squashes.At(i)
+		s.frame++
+		if s.frame >= length {
+			w.squashes.Remove(e)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// appState is what the screensaver remembers between runs, kept apart from the config
+// file so the config file is never rewritten
+type appState struct {
+	Volumes      *volumes               `json:"volumes,omitempty"`
+	Window       *windowGeometry        `json:"window,omitempty"`       // Last normal window geometry
+	Settings     *settings              `json:"settings,omitempty"`     // Settings when the screensaver stopped, for -resume
+	BestDodge    *float64               `json:"best_dodge,omitempty"`   // Longest survival in the dodge game, in seconds
+	HighScores   map[string][]highScore `json:"high_scores,omitempty"`  // Best first, by arcade game
+	Achievements *achievementState      `json:"achievements,omitempty"` // Statistics and unlocked achievements
+}
+
+func statePath() string {
+	return filepath.Join(filepath.Dir(defaultConfigPath()), "state.json")
+}
+
+// loadState reads the state file, a missing or broken file results in an empty state
+func loadState() appState {
+	var st appState
+	data, err := os.ReadFile(statePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return st
+	} else if err == nil {
+		err = json.Unmarshal(data, &st)
+	}
+	if err != nil {
+		slog.Warn("ignoring state file", "err", err)
+	}
+	return st
+}
+
+// updateState applies update to the stored state and writes it back
+func updateState(update func(st *appState)) {
+	st := loadState()
+	update(&st)
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(statePath()), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(statePath(), data, 0o644)
+	}
+	if err != nil {
+		slog.Error("failed to save state", "err", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+	"github.com/mlctrez/donut/plugin"
+)
+
+var summaryFlag = runFlags.String("summary", "", "write statistics of the run as JSON to this file on exit")
+
+// simStatsConfig shows the statistics of the run in a HUD widget
+type simStatsConfig struct {
+	widgetConfig
+}
+
+// simStats adds up what happened in the simulation over a run. Distances are kept per
+// donut while it's alive, and folded into the totals once it's gone.
+type simStats struct {
+	start      time.Time
+	frames     int
+	collisions int64
+	peak       int     // Most donuts at once
+	speedSum   float64 // Speed of every donut on every frame, for the average
+	samples    int64
+
+	distance    map[ecs.Entity]float64 // Distance of each live donut
+	donuts      int                    // Donuts seen, live or gone
+	gone        float64                // Distance of the donuts that are gone
+	maxDistance float64                // Longest distance of a donut that's gone
+}
+
+// simStatsSummary is the JSON summary written on exit
+type simStatsSummary struct {
+	Duration     string  `json:"duration"`
+	Frames       int     `json:"frames"`
+	Collisions   int64   `json:"collisions"`
+	Donuts       int     `json:"donuts"`        // Donuts that were part of the run
+	PeakDonuts   int     `json:"peak_donuts"`   // Most donuts at once
+	Distance     float64 `json:"distance"`      // Pixels traveled by all donuts together
+	MeanDistance float64 `json:"mean_distance"` // Pixels traveled per donut
+	MaxDistance  float64 `json:"max_distance"`  // Pixels traveled by the donut that went farthest
+	AverageSpeed float64 `json:"average_speed"` // Pixels per second
+}
+
+func newSimStats() *simStats {
+	return &simStats{start: time.Now(), distance: map[ecs.Entity]float64{}}
+}
+
+// statsSystem adds the frame's movement to the statistics
+func (g *Game) statsSystem() {
+	s := g.stats
+	if s == nil {
+		return
+	}
+	w := g.world
+	s.frames++
+	s.peak = max(s.peak, w.donuts.Len())
+	for _, e := range w.donuts.Entities() {
+		vel := w.velocities.Get(e)
+		speed := math.Hypot(vel.x, vel.y)
+		if _, ok := s.distance[e]; !ok {
+			s.donuts++
+		}
+		s.distance[e] += speed * g.step
+		s.speedSum += speed
+		s.samples++
+	}
+
+	// Fold the donuts that are gone into the totals now and then
+	if s.frames%ebiten.TPS() == 0 {
+		for e, d := range s.distance {
+			if !w.donuts.Has(e) {
+				s.gone += d
+				s.maxDistance = max(s.maxDistance, d)
+				delete(s.distance, e)
+			}
+		}
+	}
+}
+
+// summary returns the statistics so far
+func (s *simStats) summary() simStatsSummary {
+	sum := simStatsSummary{
+		Duration:    time.Since(s.start).Round(time.Second).String(),
+		Frames:      s.frames,
+		Collisions:  s.collisions,
+		Donuts:      s.donuts,
+		PeakDonuts:  s.peak,
+		Distance:    s.gone,
+		MaxDistance: s.maxDistance,
+	}
+	for _, d := range s.distance {
+		sum.Distance += d
+		sum.MaxDistance = max(sum.MaxDistance, d)
+	}
+	if s.donuts > 0 {
+		sum.MeanDistance = sum.Distance / float64(s.donuts)
+	}
+	if s.samples > 0 {
+		sum.AverageSpeed = s.speedSum / float64(s.samples) * float64(ebiten.TPS())
+	}
+	return sum
+}
+
+// writeSummary writes the summary as JSON to path
+func (s *simStats) writeSummary(path string) {
+	data, err := json.MarshalIndent(s.summary(), "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		slog.Error("failed to write the summary", "path", path, "err", err)
+		return
+	}
+	slog.Info("wrote the summary", "path", path)
+}
+
+// simStatsWidget draws the statistics
+type simStatsWidget struct {
+	g   *Game
+	cfg simStatsConfig
+}
+
+func (w *simStatsWidget) Update(plugin.World) error { return nil }
+
+func (w *simStatsWidget) Draw(dst *ebiten.Image, _ plugin.World) {
+	sum := w.g.stats.summary()
+	drawWidget(dst, w.cfg.widgetConfig, []string{
+		trf("Collisions %d", sum.Collisions),
+		trf("Distance %.0fpx, %.0fpx per donut", sum.Distance, sum.MeanDistance),
+		trf("Speed %.0fpx/s average", sum.AverageSpeed),
+		trf("Donuts %d, peak %d", w.g.world.donuts.Len(), sum.PeakDonuts),
+	}, w.g.theme.hud)
+}
@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/vector"
+)
+
+const (
+	svgDefaultSize  = proceduralSpriteSize // Size of SVGs without width, height or viewBox
+	svgCurveSteps   = 16                   // Line segments per curve when stroking
+	svgJoinSides    = 12                   // Sides of the polygons rounding stroke joins
+	svgGrowth       = 1.25                 // Resolution headroom when the sprite is rasterized again
+	svgResampleFrom = 1.05                 // Rasterize again when donuts are this much larger than the sprite
+)
+
+// svgSprite is a donut sprite drawn from an SVG file. It's rasterized at the size donuts
+// are drawn at, and again at a higher resolution when they grow, so it stays crisp.
+type svgSprite struct {
+	doc *svgDoc
+	img *ebiten.Image
+}
+
+// isSVG reports whether path names an SVG file
+func isSVG(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".svg")
+}
+
+// loadSVGSprite parses the SVG at path and rasterizes it at scale times its size
+func loadSVGSprite(path string, scale float64) (*svgSprite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseSVG(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	s := &svgSprite{doc: doc}
+	width := min(doc.width*scale, doc.maxWidth())
+	s.img = ebiten.NewImageFromImage(doc.rasterize(width))
+	return s, nil
+}
+
+// spriteReplacer is implemented by the renderers keeping data per sprite image, which is
+// stale once sharpenSVG swaps the image
+type spriteReplacer interface {
+	replaceSprite(old, img *ebiten.Image)
+}
+
+// sharpenSVG rasterizes the SVG sprite again when donuts are drawn larger than it, and
+// swaps the new image in everywhere the old one is used
+func (g *Game) sharpenSVG() {
+	s := g.svg
+	if s == nil {
+		return
+	}
+	// Donuts can be drawn larger than the others, so the widest one decides
+	needed := g.donutWidth
+	for i := range g.world.sprites.Len() {
+		if _, spr := g.world.sprites.At(i); spr.image == s.img {
+			needed = max(needed, spr.width)
+		}
+	}
+	needed *= pixelScale
+	current := float64(s.img.Bounds().Dx())
+	if needed <= current*svgResampleFrom || current >= s.doc.maxWidth() {
+		return
+	}
+	width := min(needed*svgGrowth, s.doc.maxWidth())
+	old := s.img
+	s.img = ebiten.NewImageFromImage(s.doc.rasterize(width))
+	slog.Debug("rasterized the svg sprite again", "from", current, "to", s.img.Bounds().Dx())
+
+	if g.donutImage == old {
+		g.donutImage = s.img
+	}
+	for i, img := range g.donutImages {
+		if img == old {
+			g.donutImages[i] = s.img
+		}
+	}
+	for i := range g.world.sprites.Len() {
+		if _, spr := g.world.sprites.At(i); spr.image == old {
+			spr.image = s.img
+		}
+	}
+	if r, ok := g.renderer.(spriteReplacer); ok {
+		r.replaceSprite(old, s.img)
+	}
+	old.Dispose()
+}
+
+// svgDoc is the parsed subset of SVG: filled and stroked paths, circles, ellipses,
+// rectangles, lines, polylines and polygons, in groups with transforms. Gradients are
+// drawn with the average of their stops.
+type svgDoc struct {
+	width, height float64
+	viewBox       [4]float64
+	shapes        []svgShape
+}
+
+// svgShape is one element as an absolute path with its paint
+type svgShape struct {
+	path        []svgSegment
+	transform   svgMatrix
+	fill        color.NRGBA // Alpha 0 for none
+	stroke      color.NRGBA
+	strokeWidth float64
+}
+
+// svgSegment is a move, line, cubic curve or close with its points
+type svgSegment struct {
+	op  byte // M, L, C or Z
+	pts [3][2]float64
+}
+
+// svgMatrix is an affine transform a b c d e f as in SVG
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m followed by n applied inside it, like nested SVG transforms
+func (m svgMatrix) mul(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1], m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3], m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4], m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(p [2]float64) [2]float64 {
+	return [2]float64{m[0]*p[0] + m[2]*p[1] + m[4], m[1]*p[0] + m[3]*p[1] + m[5]}
+}
+
+// svgStyle is the inherited paint of a group
+type svgStyle struct {
+	transform   svgMatrix
+	fill        string
+	stroke      string
+	strokeWidth float64
+	opacity     float64
+	fillOpacity float64
+}
+
+// parseSVG reads the shapes of an SVG document
+func parseSVG(data []byte) (*svgDoc, error) {
+	doc := &svgDoc{}
+	gradients := map[string]color.NRGBA{}
+	var stops []color.NRGBA
+	gradientID := ""
+	styles := []svgStyle{{transform: svgIdentity, fill: "black", stroke: "none", strokeWidth: 1, opacity: 1, fillOpacity: 1}}
+	seenRoot := false
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := svgAttrs(t.Attr)
+			style := styles[len(styles)-1].inherit(attrs)
+			styles = append(styles, style)
+			switch t.Name.Local {
+			case "svg":
+				if !seenRoot {
+					seenRoot = true
+					doc.setSize(attrs)
+				}
+			case "linearGradient", "radialGradient":
+				gradientID, stops = attrs["id"], nil
+			case "stop":
+				c, ok := parseSVGColor(attrs["stop-color"])
+				if ok {
+					c.A = uint8(float64(c.A) * svgNumber(attrs["stop-opacity"], 1))
+					stops = append(stops, c)
+				}
+			default:
+				if path := svgElementPath(t.Name.Local, attrs); path != nil {
+					doc.shapes = append(doc.shapes, style.shape(path, gradients))
+				}
+			}
+		case xml.EndElement:
+			styles = styles[:len(styles)-1]
+			if (t.Name.Local == "linearGradient" || t.Name.Local == "radialGradient") && gradientID != "" {
+				gradients[gradientID] = averageColor(stops)
+				gradientID = ""
+			}
+		}
+	}
+	if !seenRoot {
+		return nil, errors.New("no svg element")
+	}
+	return doc, nil
+}
+
+// svgAttrs returns the attributes of an element with its style attribute merged in
+func svgAttrs(attrs []xml.Attr) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		m[a.Name.Local] = strings.TrimSpace(a.Value)
+	}
+	for decl := range strings.SplitSeq(m["style"], ";") {
+		if key, value, ok := strings.Cut(decl, ":"); ok {
+			m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return m
+}
+
+// inherit returns the style of a child element with attrs
+func (s svgStyle) inherit(attrs map[string]string) svgStyle {
+	if v, ok := attrs["transform"]; ok {
+		s.transform = s.transform.mul(parseSVGTransform(v))
+	}
+	if v, ok := attrs["fill"]; ok {
+		s.fill = v
+	}
+	if v, ok := attrs["stroke"]; ok {
+		s.stroke = v
+	}
+	s.strokeWidth = svgNumber(attrs["stroke-width"], s.strokeWidth)
+	s.opacity *= svgNumber(attrs["opacity"], 1)
+	s.fillOpacity = svgNumber(attrs["fill-opacity"], s.fillOpacity)
+	return s
+}
+
+// shape returns the path painted with the style
+func (s svgStyle) shape(path []svgSegment, gradients map[string]color.NRGBA) svgShape {
+	paint := func(v string, opacity float64) color.NRGBA {
+		c, ok := parseSVGColor(v)
+		if id, isURL := strings.CutPrefix(v, "url(#"); isURL {
+			c, ok = gradients[strings.TrimSuffix(id, ")")]
+		}
+		if !ok {
+			return color.NRGBA{}
+		}
+		c.A = uint8(float64(c.A) * opacity)
+		return c
+	}
+	return svgShape{
+		path:        path,
+		transform:   s.transform,
+		fill:        paint(s.fill, s.opacity*s.fillOpacity),
+		stroke:      paint(s.stroke, s.opacity),
+		strokeWidth: s.strokeWidth,
+	}
+}
+
+// setSize reads the intrinsic size and view box of the root element
+func (d *svgDoc) setSize(attrs map[string]string) {
+	fields := strings.FieldsFunc(attrs["viewBox"], func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 4 {
+		for i, f := range fields {
+			d.viewBox[i], _ = strconv.ParseFloat(f, 64)
+		}
+	}
+	d.width, d.height = svgLength(attrs["width"]), svgLength(attrs["height"])
+	vw, vh := d.viewBox[2], d.viewBox[3]
+	switch {
+	case d.width > 0 && d.height > 0:
+	case d.width > 0 && vw > 0 && vh > 0:
+		d.height = d.width * vh / vw
+	case d.height > 0 && vw > 0 && vh > 0:
+		d.width = d.height * vw / vh
+	case vw > 0 && vh > 0:
+		d.width, d.height = vw, vh
+	default:
+		d.width, d.height = svgDefaultSize, svgDefaultSize
+	}
+	if vw <= 0 || vh <= 0 {
+		d.viewBox = [4]float64{0, 0, d.width, d.height}
+	}
+}
+
+// svgLength parses a length in user units or pixels, 0 for percentages and other units
+func svgLength(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "px"), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v
+}
+
+// svgNumber parses a number, def when it's missing or invalid
+func svgNumber(s string, def float64) float64 {
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		if v, err := strconv.ParseFloat(pct, 64); err == nil {
+			return v / 100
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// maxWidth returns the widest raster that fits in maxSpriteSize
+func (d *svgDoc) maxWidth() float64 {
+	return math.Floor(maxSpriteSize * d.width / max(d.width, d.height))
+}
+
+// rasterize draws the document width pixels wide, keeping its aspect ratio
+func (d *svgDoc) rasterize(width float64) *image.RGBA {
+	scale := width / d.width
+	w, h := max(1, int(math.Ceil(width))), max(1, int(math.Ceil(d.height*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// The view box is fitted into the image and centered, like preserveAspectRatio's default
+	vb := d.viewBox
+	fit := min(float64(w)/vb[2], float64(h)/vb[3])
+	view := svgMatrix{fit, 0, 0, fit, (float64(w)-vb[2]*fit)/2 - vb[0]*fit, (float64(h)-vb[3]*fit)/2 - vb[1]*fit}
+
+	z := vector.NewRasterizer(w, h)
+	for _, s := range d.shapes {
+		m := view.mul(s.transform)
+		if s.fill.A > 0 {
+			z.Reset(w, h)
+			fillSVGPath(z, s.path, m)
+			z.Draw(dst, dst.Bounds(), image.NewUniform(s.fill), image.Point{})
+		}
+		if s.stroke.A > 0 && s.strokeWidth > 0 {
+			z.Reset(w, h)
+			strokeSVGPath(z, s.path, m, s.strokeWidth*math.Sqrt(math.Abs(m[0]*m[3]-m[1]*m[2])))
+			z.Draw(dst, dst.Bounds(), image.NewUniform(s.stroke), image.Point{})
+		}
+	}
+	return dst
+}
+
+// fillSVGPath adds the transformed path to the rasterizer
+func fillSVGPath(z *vector.Rasterizer, path []svgSegment, m svgMatrix) {
+	pt := func(p [2]float64) (float32, float32) {
+		q := m.apply(p)
+		return float32(q[0]), float32(q[1])
+	}
+	open := false
+	for _, s := range path {
+		switch s.op {
+		case 'M':
+			if open {
+				z.ClosePath()
+			}
+			z.MoveTo(pt(s.pts[0]))
+			open = true
+		case 'L':
+			z.LineTo(pt(s.pts[0]))
+		case 'C':
+			ax, ay := pt(s.pts[0])
+			bx, by := pt(s.pts[1])
+			cx, cy := pt(s.pts[2])
+			z.CubeTo(ax, ay, bx, by, cx, cy)
+		case 'Z':
+			z.ClosePath()
+			open = false
+		}
+	}
+	if open {
+		z.ClosePath()
+	}
+}
+
+// strokeSVGPath adds the outline of the transformed path, width pixels wide, as a quad per
+// flattened segment and a polygon at every point for round joins and caps. Every polygon
+// winds the same way so overlaps add up instead of cancelling.
+func strokeSVGPath(z *vector.Rasterizer, path []svgSegment, m svgMatrix, width float64) {
+	r := width / 2
+	polygon := func(pts ...[2]float64) {
+		// Shoelace area, negative when counter-clockwise in screen coordinates
+		area := 0.0
+		for i, p := range pts {
+			q := pts[(i+1)%len(pts)]
+			area += p[0]*q[1] - q[0]*p[1]
+		}
+		if area < 0 {
+			for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+				pts[i], pts[j] = pts[j], pts[i]
+			}
+		}
+		z.MoveTo(float32(pts[0][0]), float32(pts[0][1]))
+		for _, p := range pts[1:] {
+			z.LineTo(float32(p[0]), float32(p[1]))
+		}
+		z.ClosePath()
+	}
+	join := func(p [2]float64) {
+		pts := make([][2]float64, svgJoinSides)
+		for i := range pts {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / svgJoinSides)
+			pts[i] = [2]float64{p[0] + r*cos, p[1] + r*sin}
+		}
+		polygon(pts...)
+	}
+	line := func(a, b [2]float64) {
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		l := math.Hypot(dx, dy)
+		if l == 0 {
+			return
+		}
+		nx, ny := -dy/l*r, dx/l*r
+		polygon([2]float64{a[0] + nx, a[1] + ny}, [2]float64{b[0] + nx, b[1] + ny}, [2]float64{b[0] - nx, b[1] - ny}, [2]float64{a[0] - nx, a[1] - ny})
+		join(b)
+	}
+
+	var start, cur [2]float64
+	for _, s := range path {
+		switch s.op {
+		case 'M':
+			start, cur = m.apply(s.pts[0]), m.apply(s.pts[0])
+			join(cur)
+		case 'L':
+			next := m.apply(s.pts[0])
+			line(cur, next)
+			cur = next
+		case 'C':
+			p0, p1, p2, p3 := cur, m.apply(s.pts[0]), m.apply(s.pts[1]), m.apply(s.pts[2])
+			for i := 1; i <= svgCurveSteps; i++ {
+				t := float64(i) / svgCurveSteps
+				u := 1 - t
+				next := [2]float64{
+					u*u*u*p0[0] + 3*u*u*t*p1[0] + 3*u*t*t*p2[0] + t*t*t*p3[0],
+					u*u*u*p0[1] + 3*u*u*t*p1[1] + 3*u*t*t*p2[1] + t*t*t*p3[1],
+				}
+				line(cur, next)
+				cur = next
+			}
+		case 'Z':
+			line(cur, start)
+			cur = start
+		}
+	}
+}
+
+// svgElementPath returns the outline of a basic shape or path element, nil for other
+// elements
+func svgElementPath(name string, a map[string]string) []svgSegment {
+	n := func(key string) float64 { return svgNumber(a[key], 0) }
+	switch name {
+	case "path":
+		return parseSVGPath(a["d"])
+	case "rect":
+		x, y, w, h := n("x"), n("y"), n("width"), n("height")
+		rx, ry := n("rx"), n("ry")
+		if rx == 0 {
+			rx = ry
+		} else if ry == 0 {
+			ry = rx
+		}
+		rx, ry = min(rx, w/2), min(ry, h/2)
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		if rx <= 0 {
+			return []svgSegment{{op: 'M', pts: [3][2]float64{{x, y}}}, {op: 'L', pts: [3][2]float64{{x + w, y}}},
+				{op: 'L', pts: [3][2]float64{{x + w, y + h}}}, {op: 'L', pts: [3][2]float64{{x, y + h}}}, {op: 'Z'}}
+		}
+		return parseSVGPath(fmt.Sprintf("M%g,%g H%g A%g,%g 0 0 1 %g,%g V%g A%g,%g 0 0 1 %g,%g H%g A%g,%g 0 0 1 %g,%g V%g A%g,%g 0 0 1 %g,%g Z",
+			x+rx, y, x+w-rx, rx, ry, x+w, y+ry, y+h-ry, rx, ry, x+w-rx, y+h, x+rx, rx, ry, x, y+h-ry, y+ry, rx, ry, x+rx, y))
+	case "circle":
+		return svgEllipse(n("cx"), n("cy"), n("r"), n("r"))
+	case "ellipse":
+		return svgEllipse(n("cx"), n("cy"), n("rx"), n("ry"))
+	case "line":
+		return []svgSegment{{op: 'M', pts: [3][2]float64{{n("x1"), n("y1")}}}, {op: 'L', pts: [3][2]float64{{n("x2"), n("y2")}}}}
+	case "polyline", "polygon":
+		path := parseSVGPath("M" + a["points"])
+		if name == "polygon" && path != nil {
+			path = append(path, svgSegment{op: 'Z'})
+		}
+		return path
+	}
+	return nil
+}
+
+// svgEllipse returns an ellipse as four cubic curves
+func svgEllipse(cx, cy, rx, ry float64) []svgSegment {
+	if rx <= 0 || ry <= 0 {
+		return nil
+	}
+	return parseSVGPath(fmt.Sprintf("M%g,%g A%g,%g 0 0 1 %g,%g A%g,%g 0 0 1 %g,%g A%g,%g 0 0 1 %g,%g A%g,%g 0 0 1 %g,%g Z",
+		cx+rx, cy, rx, ry, cx, cy+ry, rx, ry, cx-rx, cy, rx, ry, cx, cy-ry, rx, ry, cx+rx, cy))
+}
+
+// parseSVGPath converts path data to absolute moves, lines and cubic curves. Parsing stops
+// at the first error like browsers do, keeping what came before.
+func parseSVGPath(d string) []svgSegment {
+	p := svgPathParser{s: d}
+	var path []svgSegment
+	var cur, start, ctrl [2]float64 // ctrl is the last control point, for S and T
+	var cmd, prev byte
+	for {
+		p.skipSpace()
+		if p.done() {
+			return path
+		}
+		if c := p.s[p.i]; isSVGCommand(c) {
+			cmd = c
+			p.i++
+		} else if cmd == 0 {
+			return path
+		}
+		rel := cmd >= 'a'
+		abs := func(x, y float64) [2]float64 {
+			if rel {
+				return [2]float64{cur[0] + x, cur[1] + y}
+			}
+			return [2]float64{x, y}
+		}
+		upper := cmd &^ 0x20
+		nums, ok := p.numbers(svgArgs[upper])
+		if !ok {
+			return path
+		}
+		switch upper {
+		case 'M':
+			cur = abs(nums[0], nums[1])
+			start = cur
+			path = append(path, svgSegment{op: 'M', pts: [3][2]float64{cur}})
+			// Pairs after a move are lines
+			cmd = 'L' | cmd&0x20
+		case 'L':
+			cur = abs(nums[0], nums[1])
+			path = append(path, svgSegment{op: 'L', pts: [3][2]float64{cur}})
+		case 'H':
+			cur[0] = abs(nums[0], 0)[0]
+			path = append(path, svgSegment{op: 'L', pts: [3][2]float64{cur}})
+		case 'V':
+			cur[1] = abs(0, nums[0])[1]
+			path = append(path, svgSegment{op: 'L', pts: [3][2]float64{cur}})
+		case 'C', 'S':
+			c1 := cur
+			if upper == 'C' {
+				c1, nums = abs(nums[0], nums[1]), nums[2:]
+			} else if prev == 'C' || prev == 'S' {
+				c1 = [2]float64{2*cur[0] - ctrl[0], 2*cur[1] - ctrl[1]}
+			}
+			c2, end := abs(nums[0], nums[1]), abs(nums[2], nums[3])
+			path = append(path, svgSegment{op: 'C', pts: [3][2]float64{c1, c2, end}})
+			ctrl, cur = c2, end
+		case 'Q', 'T':
+			q := cur
+			if upper == 'Q' {
+				q, nums = abs(nums[0], nums[1]), nums[2:]
+			} else if prev == 'Q' || prev == 'T' {
+				q = [2]float64{2*cur[0] - ctrl[0], 2*cur[1] - ctrl[1]}
+			}
+			end := abs(nums[0], nums[1])
+			// Quadratic to cubic: control points two thirds of the way to q
+			c1 := [2]float64{cur[0] + 2.0/3*(q[0]-cur[0]), cur[1] + 2.0/3*(q[1]-cur[1])}
+			c2 := [2]float64{end[0] + 2.0/3*(q[0]-end[0]), end[1] + 2.0/3*(q[1]-end[1])}
+			path = append(path, svgSegment{op: 'C', pts: [3][2]float64{c1, c2, end}})
+			ctrl, cur = q, end
+		case 'A':
+			end := abs(nums[5], nums[6])
+			path = appendSVGArc(path, cur, end, nums[0], nums[1], nums[2], nums[3] != 0, nums[4] != 0)
+			cur = end
+		case 'Z':
+			path = append(path, svgSegment{op: 'Z'})
+			cur = start
+		}
+		prev = upper
+	}
+}
+
+// svgArgs is the number of arguments of each path command
+var svgArgs = map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7, 'Z': 0}
+
+func isSVGCommand(c byte) bool {
+	_, ok := svgArgs[c&^0x20]
+	return ok && c != 'e' && c != 'E'
+}
+
+// svgPathParser reads numbers from path data
+type svgPathParser struct {
+	s string
+	i int
+}
+
+func (p *svgPathParser) done() bool { return p.i >= len(p.s) }
+
+func (p *svgPathParser) skipSpace() {
+	for !p.done() && strings.IndexByte(" \t\r\n,", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// numbers reads n numbers, which may be separated by nothing but a sign or a second dot
+func (p *svgPathParser) numbers(n int) ([]float64, bool) {
+	nums := make([]float64, n)
+	for k := range n {
+		p.skipSpace()
+		start := p.i
+		if !p.done() && (p.s[p.i] == '-' || p.s[p.i] == '+') {
+			p.i++
+		}
+		dot, exp := false, false
+		for !p.done() {
+			c := p.s[p.i]
+			switch {
+			case c >= '0' && c <= '9':
+			case c == '.' && !dot && !exp:
+				dot = true
+			case (c == 'e' || c == 'E') && !exp:
+				exp = true
+				if p.i+1 < len(p.s) && (p.s[p.i+1] == '-' || p.s[p.i+1] == '+') {
+					p.i++
+				}
+			default:
+				goto parsed
+			}
+			p.i++
+		}
+	parsed:
+		v, err := strconv.ParseFloat(p.s[start:p.i], 64)
+		if err != nil {
+			return nil, false
+		}
+		nums[k] = v
+	}
+	return nums, true
+}
+
+// appendSVGArc appends an elliptical arc from a to b as cubic curves, converting the
+// endpoint parameters to a center as described in the SVG implementation notes
+func appendSVGArc(path []svgSegment, a, b [2]float64, rx, ry, angle float64, large, sweep bool) []svgSegment {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 || a == b {
+		return append(path, svgSegment{op: 'L', pts: [3][2]float64{b}})
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	dx, dy := (a[0]-b[0])/2, (a[1]-b[1])/2
+	x1, y1 := cos*dx+sin*dy, -sin*dx+cos*dy
+	// Radii too small to reach b are scaled up
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	f := math.Sqrt(max(0, num/den))
+	if large == sweep {
+		f = -f
+	}
+	cx1, cy1 := f*rx*y1/ry, -f*ry*x1/rx
+	cx, cy := cos*cx1-sin*cy1+(a[0]+b[0])/2, sin*cx1+cos*cy1+(a[1]+b[1])/2
+
+	vecAngle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := vecAngle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := vecAngle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// One cubic per quarter turn at most
+	n := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(t float64) ([2]float64, [2]float64) {
+		st, ct := math.Sincos(t)
+		p := [2]float64{cx + rx*ct*cos - ry*st*sin, cy + rx*ct*sin + ry*st*cos}
+		d := [2]float64{-rx*st*cos - ry*ct*sin, -rx*st*sin + ry*ct*cos} // Derivative
+		return p, d
+	}
+	for i := range n {
+		t0, t1 := theta+float64(i)*step, theta+float64(i+1)*step
+		p0, d0 := point(t0)
+		p1, d1 := point(t1)
+		path = append(path, svgSegment{op: 'C', pts: [3][2]float64{
+			{p0[0] + k*d0[0], p0[1] + k*d0[1]},
+			{p1[0] - k*d1[0], p1[1] - k*d1[1]},
+			p1,
+		}})
+	}
+	path[len(path)-1].pts[2] = b
+	return path
+}
+
+// parseSVGTransform parses a transform list like "translate(10 20) rotate(45)"
+func parseSVGTransform(s string) svgMatrix {
+	m := svgIdentity
+	for {
+		name, rest, ok := strings.Cut(s, "(")
+		if !ok {
+			return m
+		}
+		args, after, _ := strings.Cut(rest, ")")
+		s = after
+		var v []float64
+		for f := range strings.FieldsFuncSeq(args, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if x, err := strconv.ParseFloat(f, 64); err == nil {
+				v = append(v, x)
+			}
+		}
+		arg := func(i int, def float64) float64 {
+			if i < len(v) {
+				return v[i]
+			}
+			return def
+		}
+		var t svgMatrix
+		switch strings.TrimSpace(strings.TrimLeft(name, ", ")) {
+		case "matrix":
+			if len(v) != 6 {
+				continue
+			}
+			t = svgMatrix(v)
+		case "translate":
+			t = svgMatrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			t = svgMatrix{arg(0, 1), 0, 0, arg(1, arg(0, 1)), 0, 0}
+		case "rotate":
+			sin, cos := math.Sincos(arg(0, 0) * math.Pi / 180)
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = svgMatrix{1, 0, 0, 1, cx, cy}.mul(svgMatrix{cos, sin, -sin, cos, 0, 0}).mul(svgMatrix{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = svgMatrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = svgMatrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.mul(t)
+	}
+}
+
+// svgColors are the named colors most used in SVG files
+var svgColors = map[string]color.NRGBA{
+	"black": {0, 0, 0, 255}, "white": {255, 255, 255, 255}, "red": {255, 0, 0, 255},
+	"green": {0, 128, 0, 255}, "blue": {0, 0, 255, 255}, "yellow": {255, 255, 0, 255},
+	"orange": {255, 165, 0, 255}, "pink": {255, 192, 203, 255}, "brown": {165, 42, 42, 255},
+	"gray": {128, 128, 128, 255}, "grey": {128, 128, 128, 255}, "purple": {128, 0, 128, 255},
+	"gold": {255, 215, 0, 255}, "chocolate": {210, 105, 30, 255}, "hotpink": {255, 105, 180, 255},
+	"tan": {210, 180, 140, 255}, "wheat": {245, 222, 179, 255}, "currentcolor": {0, 0, 0, 255},
+}
+
+// parseSVGColor parses #rgb, #rrggbb, rgb() and rgba() colors and the common names. None
+// and unknown colors aren't ok.
+func parseSVGColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := svgColors[s]; ok {
+		return c, true
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return color.NRGBA{}, false
+		}
+		return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+	}
+	if args, ok := strings.CutPrefix(s, "rgb"); ok {
+		args = strings.Trim(strings.TrimPrefix(args, "a"), "()")
+		parts := strings.FieldsFunc(args, func(r rune) bool { return r == ' ' || r == ',' || r == '/' })
+		if len(parts) < 3 {
+			return color.NRGBA{}, false
+		}
+		var c [4]uint8
+		c[3] = 255
+		for i, part := range parts[:min(4, len(parts))] {
+			scale := 255.0
+			if i == 3 {
+				scale = 1 // Alpha is a fraction
+			}
+			v := svgNumber(part, 0)
+			if strings.HasSuffix(part, "%") {
+				v *= 255
+			} else {
+				v *= 255 / scale
+			}
+			c[i] = uint8(max(0, min(255, math.Round(v))))
+		}
+		return color.NRGBA{c[0], c[1], c[2], c[3]}, true
+	}
+	return color.NRGBA{}, false
+}
+
+// averageColor returns the mean of the gradient stops, drawn instead of the gradient
+func averageColor(stops []color.NRGBA) color.NRGBA {
+	if len(stops) == 0 {
+		return color.NRGBA{}
+	}
+	var r, g, b, a int
+	for _, s := range stops {
+		r, g, b, a = r+int(s.R), g+int(s.G), b+int(s.B), a+int(s.A)
+	}
+	n := len(stops)
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)}
+}
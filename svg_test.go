@@ -0,0 +1,173 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// svgTolerance is the difference allowed between computed and expected coordinates
+const svgTolerance = 1e-9
+
+func sameSegments(got, want []svgSegment) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].op != want[i].op {
+			return false
+		}
+		for j := range got[i].pts {
+			for k := range 2 {
+				if math.Abs(got[i].pts[j][k]-want[i].pts[j][k]) > svgTolerance {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func moveTo(x, y float64) svgSegment { return svgSegment{op: 'M', pts: [3][2]float64{{x, y}}} }
+func lineTo(x, y float64) svgSegment { return svgSegment{op: 'L', pts: [3][2]float64{{x, y}}} }
+func cubicTo(x1, y1, x2, y2, x, y float64) svgSegment {
+	return svgSegment{op: 'C', pts: [3][2]float64{{x1, y1}, {x2, y2}, {x, y}}}
+}
+
+var closePath = svgSegment{op: 'Z'}
+
+func TestParseSVGPath(t *testing.T) {
+	tests := []struct {
+		name string
+		d    string
+		want []svgSegment
+	}{
+		{"empty", "", nil},
+		{"absolute", "M10,20 L30,40", []svgSegment{moveTo(10, 20), lineTo(30, 40)}},
+		{"lines after a move", "M0 0 10 0 10 10", []svgSegment{moveTo(0, 0), lineTo(10, 0), lineTo(10, 10)}},
+		{"repeated line", "M0 0 L1 1 2 2", []svgSegment{moveTo(0, 0), lineTo(1, 1), lineTo(2, 2)}},
+		{"relative", "m10 10 5 0 0 5z", []svgSegment{moveTo(10, 10), lineTo(15, 10), lineTo(15, 15), closePath}},
+		{"horizontal and vertical", "M1 2 h3 v4 H0 V0", []svgSegment{moveTo(1, 2), lineTo(4, 2), lineTo(4, 6), lineTo(0, 6), lineTo(0, 0)}},
+		{"move after close", "M5 5 l5 0 z m1 1", []svgSegment{moveTo(5, 5), lineTo(10, 5), closePath, moveTo(6, 6)}},
+		{"compact numbers", "M.5.5-1-1", []svgSegment{moveTo(0.5, 0.5), lineTo(-1, -1)}},
+		{"exponent", "M1e1 2E-1", []svgSegment{moveTo(10, 0.2)}},
+		{"quadratic", "M0 0 Q3 3 6 0", []svgSegment{moveTo(0, 0), cubicTo(2, 2, 4, 2, 6, 0)}},
+		{"smooth cubic", "M0 0 C1 1 2 1 3 0 S5 -1 6 0", []svgSegment{moveTo(0, 0), cubicTo(1, 1, 2, 1, 3, 0), cubicTo(4, -1, 5, -1, 6, 0)}},
+		{"smooth cubic alone", "M0 0 S1 1 2 0", []svgSegment{moveTo(0, 0), cubicTo(0, 0, 1, 1, 2, 0)}},
+		{"relative smooth quadratic", "M0 0 q3 3 6 0 t6 0", []svgSegment{moveTo(0, 0), cubicTo(2, 2, 4, 2, 6, 0), cubicTo(8, -2, 10, -2, 12, 0)}},
+		{"zero radius arc", "M0 0 A0 5 0 0 1 10 0", []svgSegment{moveTo(0, 0), lineTo(10, 0)}},
+		{"relative zero radius arc", "M1 1 a5 0 0 0 1 10 0", []svgSegment{moveTo(1, 1), lineTo(11, 1)}},
+		{"no command", "10 10 L5 5", nil},
+		{"missing number", "M0 0 L10", []svgSegment{moveTo(0, 0)}},
+		{"unknown command", "M0 0 X5 5 L1 1", []svgSegment{moveTo(0, 0)}},
+		{"bad number", "M0 0 L1e 2", []svgSegment{moveTo(0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSVGPath(tt.d); !sameSegments(got, tt.want) {
+				t.Errorf("parseSVGPath(%q) = %v, want %v", tt.d, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendSVGArc(t *testing.T) {
+	k := 4.0 / 3 * math.Tan(math.Pi/8) // Control point distance of a quarter turn
+	upper := []svgSegment{cubicTo(0, -5*k, 5-5*k, -5, 5, -5), cubicTo(5+5*k, -5, 10, -5*k, 10, 0)}
+	lower := []svgSegment{cubicTo(0, 5*k, 5-5*k, 5, 5, 5), cubicTo(5+5*k, 5, 10, 5*k, 10, 0)}
+	tests := []struct {
+		name         string
+		a, b         [2]float64
+		rx, ry       float64
+		angle        float64
+		large, sweep bool
+		want         []svgSegment
+	}{
+		{"zero x radius", [2]float64{0, 0}, [2]float64{10, 0}, 0, 5, 0, false, true, []svgSegment{lineTo(10, 0)}},
+		{"zero y radius", [2]float64{0, 0}, [2]float64{10, 0}, 5, 0, 0, false, true, []svgSegment{lineTo(10, 0)}},
+		{"same end", [2]float64{3, 4}, [2]float64{3, 4}, 5, 5, 0, false, true, []svgSegment{lineTo(3, 4)}},
+		{"half circle sweeping", [2]float64{0, 0}, [2]float64{10, 0}, 5, 5, 0, false, true, upper},
+		{"half circle", [2]float64{0, 0}, [2]float64{10, 0}, 5, 5, 0, false, false, lower},
+		{"negative radii", [2]float64{0, 0}, [2]float64{10, 0}, -5, -5, 0, false, true, upper},
+		{"radii too small", [2]float64{0, 0}, [2]float64{10, 0}, 1, 1, 0, false, true, upper},
+		{"rotated circle", [2]float64{0, 0}, [2]float64{10, 0}, 5, 5, 90, true, true, upper},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := []svgSegment{moveTo(tt.a[0], tt.a[1])}
+			got := appendSVGArc(start, tt.a, tt.b, tt.rx, tt.ry, tt.angle, tt.large, tt.sweep)
+			if want := append(start, tt.want...); !sameSegments(got, want) {
+				t.Errorf("appendSVGArc = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseSVGTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want svgMatrix
+	}{
+		{"empty", "", svgIdentity},
+		{"translate", "translate(10 20)", svgMatrix{1, 0, 0, 1, 10, 20}},
+		{"translate x", "translate(5)", svgMatrix{1, 0, 0, 1, 5, 0}},
+		{"scale", "scale(2)", svgMatrix{2, 0, 0, 2, 0, 0}},
+		{"scale x and y", "scale(2,3)", svgMatrix{2, 0, 0, 3, 0, 0}},
+		{"rotate", "rotate(90)", svgMatrix{0, 1, -1, 0, 0, 0}},
+		{"rotate around a point", "rotate(90 10 10)", svgMatrix{0, 1, -1, 0, 20, 0}},
+		{"skew x", "skewX(45)", svgMatrix{1, 0, 1, 1, 0, 0}},
+		{"skew y", "skewY(45)", svgMatrix{1, 1, 0, 1, 0, 0}},
+		{"matrix", "matrix(1 2 3 4 5 6)", svgMatrix{1, 2, 3, 4, 5, 6}},
+		{"list", "translate(10,0) scale(2)", svgMatrix{2, 0, 0, 2, 10, 0}},
+		{"list in the other order", "scale(2), translate(10,0)", svgMatrix{2, 0, 0, 2, 20, 0}},
+		{"short matrix", "matrix(1 2 3) translate(1 2)", svgMatrix{1, 0, 0, 1, 1, 2}},
+		{"unknown", "foo(1) translate(1 2)", svgMatrix{1, 0, 0, 1, 1, 2}},
+		{"unclosed", "translate(10 20", svgMatrix{1, 0, 0, 1, 10, 20}},
+		{"bad numbers", "translate(a b)", svgIdentity},
+		{"no parentheses", "translate 10 20", svgIdentity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseSVGTransform(tt.s)
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > svgTolerance {
+					t.Errorf("parseSVGTransform(%q) = %v, want %v", tt.s, got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseSVGColor(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.NRGBA
+		ok   bool
+	}{
+		{"#f00", color.NRGBA{255, 0, 0, 255}, true},
+		{"#00FF80", color.NRGBA{0, 255, 128, 255}, true},
+		{" Red ", color.NRGBA{255, 0, 0, 255}, true},
+		{"currentColor", color.NRGBA{0, 0, 0, 255}, true},
+		{"rgb(255, 128, 0)", color.NRGBA{255, 128, 0, 255}, true},
+		{"rgb(100%, 50%, 0%)", color.NRGBA{255, 128, 0, 255}, true},
+		{"rgba(0,0,255,0.5)", color.NRGBA{0, 0, 255, 128}, true},
+		{"rgb(0 0 255 / 25%)", color.NRGBA{0, 0, 255, 64}, true},
+		{"rgb(300,-5,0)", color.NRGBA{255, 0, 0, 255}, true},
+		{"none", color.NRGBA{}, false},
+		{"", color.NRGBA{}, false},
+		{"#ff", color.NRGBA{}, false},
+		{"#ffff", color.NRGBA{}, false},
+		{"#gggggg", color.NRGBA{}, false},
+		{"rgb(1,2)", color.NRGBA{}, false},
+		{"hsl(0, 0%, 0%)", color.NRGBA{}, false},
+		{"url(#gradient)", color.NRGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSVGColor(tt.s)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseSVGColor(%q) = %v, %v, want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	syncJoinInterval = time.Second     // How often clients ask the server for snapshots
+	syncClientExpiry = 5 * time.Second // Clients not heard from for this long are dropped
+	syncChunkDonuts  = 4000            // Donuts per datagram, keeping each within the UDP limit
+	syncMagic        = 0x444e5432      // "DNT2", also the snapshot format version
+)
+
+// syncHeader starts every snapshot datagram. Snapshots with more donuts than fit in one
+// datagram are split, each part carrying the donuts from Offset on.
+type syncHeader struct {
+	Magic         uint32
+	Frame         uint32
+	Start         int64  // Timer start time in Unix nanoseconds
+	Width, Height uint16 // Server screen size, donut positions are relative to it
+	Total         uint16 // Donuts in the whole snapshot
+	Offset        uint16 // Index of the first donut in this datagram
+	Count         uint16 // Donuts in this datagram
+}
+
+// syncDonut is one donut of a snapshot
+type syncDonut struct {
+	X, Y, Angle float32
+}
+
+// syncServer sends the authoritative simulation to the clients that joined it
+type syncServer struct {
+	conn *net.UDPConn
+	buf  bytes.Buffer
+
+	mu      sync.Mutex
+	clients map[string]*syncClientAddr
+}
+
+type syncClientAddr struct {
+	addr *net.UDPAddr
+	seen time.Time
+}
+
+// syncClient renders the donuts of a server instead of simulating its own
+type syncClient struct {
+	conn *net.UDPConn
+
+	mu     sync.Mutex
+	header syncHeader
+	donuts []syncDonut
+	fresh  bool // A snapshot arrived since the last one applied
+
+	// The snapshot being put together from its datagrams, only used by receive
+	partial  syncHeader
+	parts    []syncDonut
+	offsets  map[uint16]bool // Parts received, by offset
+	received int
+}
+
+// startSyncServer listens on addr for clients to join
+func startSyncServer(addr string) (*syncServer, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	s := &syncServer{conn: conn, clients: map[string]*syncClientAddr{}}
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			_, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				slog.Error("sync server stopped", "err", err)
+				return
+			}
+			s.mu.Lock()
+			if _, ok := s.clients[from.String()]; !ok {
+				slog.Info("sync client joined", "addr", from)
+			}
+			s.clients[from.String()] = &syncClientAddr{addr: from, seen: time.Now()}
+			s.mu.Unlock()
+		}
+	}()
+	return s, nil
+}
+
+// publish sends a snapshot of g to every client, in as many datagrams as it takes
+func (s *syncServer) publish(g *Game) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, c := range s.clients {
+		if time.Since(c.seen) > syncClientExpiry {
+			slog.Info("sync client left", "addr", c.addr)
+			delete(s.clients, key)
+		}
+	}
+	if len(s.clients) == 0 {
+		return
+	}
+
+	w := g.world
+	donuts := w.donuts.Entities()
+	h := syncHeader{
+		Magic:  syncMagic,
+		Frame:  uint32(g.frame),
+		Start:  g.timerStartTime.UnixNano(),
+		Width:  uint16(g.screenWidth),
+		Height: uint16(g.screenHeight),
+		Total:  uint16(len(donuts)),
+	}
+	for offset := 0; offset == 0 || offset < len(donuts); offset += syncChunkDonuts {
+		chunk := donuts[offset:min(len(donuts), offset+syncChunkDonuts)]
+		h.Offset, h.Count = uint16(offset), uint16(len(chunk))
+		s.buf.Reset()
+		binary.Write(&s.buf, binary.LittleEndian, h)
+		for _, e := range chunk {
+			pos, sp := w.positions.Get(e), w.spins.Get(e)
+			binary.Write(&s.buf, binary.LittleEndian, syncDonut{float32(pos.x), float32(pos.y), float32(sp.angle)})
+		}
+		for _, c := range s.clients {
+			if _, err := s.conn.WriteToUDP(s.buf.Bytes(), c.addr); err != nil {
+				slog.Warn("sync send failed", "addr", c.addr, "err", err)
+			}
+		}
+	}
+}
+
+// joinSyncServer connects to the server at addr and keeps asking it for snapshots
+func joinSyncServer(addr string) (*syncClient, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, err
+	}
+	c := &syncClient{conn: conn}
+	go func() {
+		for {
+			if _, err := conn.Write([]byte("join")); err != nil {
+				slog.Warn("sync join failed", "err", err)
+			}
+			time.Sleep(syncJoinInterval)
+		}
+	}()
+	go c.receive()
+	return c, nil
+}
+
+func (c *syncClient) receive() {
+	buf := make([]byte, 65536)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			// The server not listening yet shows up as a refused connection, keep waiting
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			time.Sleep(syncJoinInterval)
+			continue
+		}
+		r := bytes.NewReader(buf[:n])
+		var h syncHeader
+		if binary.Read(r, binary.LittleEndian, &h) != nil || h.Magic != syncMagic || int(h.Offset)+int(h.Count) > int(h.Total) {
+			continue
+		}
+		c.assemble(h, r)
+	}
+}
+
+// assemble adds the donuts of one datagram to the snapshot of its frame, publishing the
+// snapshot once every part has arrived. Datagrams may arrive out of order or not at all,
+// parts of older snapshots are dropped.
+func (c *syncClient) assemble(h syncHeader, r *bytes.Reader) {
+	if h.Frame != c.partial.Frame || h.Total != c.partial.Total || c.parts == nil {
+		if h.Frame < c.partial.Frame && h.Frame >= c.partial.Frame/2 {
+			return
+		}
+		c.partial, c.parts, c.received = h, make([]syncDonut, h.Total), 0
+		c.offsets = map[uint16]bool{}
+	}
+	if c.offsets[h.Offset] || binary.Read(r, binary.LittleEndian, c.parts[h.Offset:h.Offset+h.Count]) != nil {
+		return
+	}
+	c.offsets[h.Offset] = true
+	c.received += int(h.Count)
+	if c.received < int(h.Total) {
+		return
+	}
+
+	c.mu.Lock()
+	c.header, c.donuts, c.fresh = c.partial, c.parts, true
+	c.mu.Unlock()
+	c.parts = nil
+}
+
+// applySnapshot replaces the local donuts with the latest snapshot from the server,
+// scaling positions from the server screen to this one
+func (g *Game) applySnapshot() {
+	c := g.syncClient
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.fresh {
+		return
+	}
+	c.fresh = false
+
+	w := g.world
+	for w.donuts.Len() > len(c.donuts) {
+		e, _ := w.donuts.At(w.donuts.Len() - 1)
+		w.Despawn(e)
+	}
+	for i := w.donuts.Len(); i < len(c.donuts); i++ {
+		e := w.spawnDonut(Donut{width: g.donutWidth, height: g.donutHeight}, g.donutImageFor(i))
+		w.layers.Add(e, layer{0})
+	}
+	g.numDonuts = len(c.donuts)
+
+	sx := float64(g.screenWidth) / float64(max(1, c.header.Width))
+	sy := float64(g.screenHeight) / float64(max(1, c.header.Height))
+	for i, e := range w.donuts.Entities() {
+		d := c.donuts[i]
+		pos, vel, sp := w.positions.Get(e), w.velocities.Get(e), w.spins.Get(e)
+		pos.x, pos.y = float64(d.X)*sx, float64(d.Y)*sy
+		vel.x, vel.y = 0, 0
+		sp.angle, sp.speed = float64(d.Angle), 0
+	}
+	g.timerStartTime = time.Unix(0, c.header.Start)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSyncSplitsLargeSnapshots checks that a snapshot with more donuts than fit in one
+// datagram reaches the client whole
+func TestSyncSplitsLargeSnapshots(t *testing.T) {
+	s, err := startSyncServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close()
+	c, err := joinSyncServer(s.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.conn.Close()
+
+	g := newBenchGame(settings{Donuts: maxDonuts}, 1)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		s.publish(g) // Nothing is sent until the client has joined
+		c.mu.Lock()
+		donuts, fresh := c.donuts, c.fresh
+		c.mu.Unlock()
+		if !fresh {
+			continue
+		}
+		if len(donuts) != maxDonuts {
+			t.Fatalf("%d donuts arrived, want %d", len(donuts), maxDonuts)
+		}
+		for i, e := range g.world.donuts.Entities() {
+			pos := g.world.positions.Get(e)
+			if d := donuts[i]; d.X != float32(pos.x) || d.Y != float32(pos.y) {
+				t.Fatalf("donut %d arrived at %v, %v, want %v, %v", i, d.X, d.Y, pos.x, pos.y)
+			}
+		}
+		return
+	}
+	t.Fatal("no snapshot arrived")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+const defaultStatsInterval = 2 * time.Second
+
+// sysStatsConfig shows host CPU, memory and network usage in a HUD widget
+type sysStatsConfig struct {
+	widgetConfig
+	Interval duration `json:"interval"` // Time between samples, 2s by default
+}
+
+// sysStats is one sample of the host usage
+type sysStats struct {
+	cpu      float64 // Percent of all CPUs
+	memUsed  uint64  // Bytes
+	memTotal uint64  // Bytes
+	rx, tx   float64 // Network bytes per second
+}
+
+// sysStatsWidget samples the host in the background since gopsutil calls can block, and
+// draws the latest sample
+type sysStatsWidget struct {
+	g   *Game
+	cfg sysStatsConfig
+
+	mu    sync.Mutex
+	stats sysStats
+	ok    bool // A sample has been taken
+}
+
+func newSysStatsWidget(g *Game, cfg sysStatsConfig) *sysStatsWidget {
+	w := &sysStatsWidget{g: g, cfg: cfg}
+	go w.sample()
+	return w
+}
+
+func (w *sysStatsWidget) sample() {
+	interval := time.Duration(w.cfg.Interval)
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+
+	var lastRx, lastTx uint64
+	var lastTime time.Time
+	warned := false
+	for ; ; time.Sleep(interval) {
+		var s sysStats
+		if percent, err := cpu.Percent(0, false); err == nil && len(percent) > 0 {
+			s.cpu = percent[0]
+		}
+		if vm, err := mem.VirtualMemory(); err == nil {
+			s.memUsed, s.memTotal = vm.Used, vm.Total
+		}
+		counters, err := net.IOCounters(false)
+		if err != nil || len(counters) == 0 {
+			if !warned {
+				slog.Warn("sysstats: network counters unavailable", "err", err)
+				warned = true
+			}
+		} else {
+			now := time.Now()
+			rx, tx := counters[0].BytesRecv, counters[0].BytesSent
+			if !lastTime.IsZero() {
+				seconds := now.Sub(lastTime).Seconds()
+				s.rx, s.tx = float64(rx-lastRx)/seconds, float64(tx-lastTx)/seconds
+			}
+			lastRx, lastTx, lastTime = rx, tx, now
+		}
+
+		w.mu.Lock()
+		w.stats, w.ok = s, true
+		w.mu.Unlock()
+	}
+}
+
+func (w *sysStatsWidget) Update(plugin.World) error { return nil }
+
+func (w *sysStatsWidget) Draw(dst *ebiten.Image, _ plugin.World) {
+	w.mu.Lock()
+	s, ok := w.stats, w.ok
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+	drawWidget(dst, w.cfg.widgetConfig, []string{
+		fmt.Sprintf("CPU %5.1f%%", s.cpu),
+		fmt.Sprintf("RAM %s / %s", formatBytes(float64(s.memUsed)), formatBytes(float64(s.memTotal))),
+		fmt.Sprintf("NET down %s/s up %s/s", formatBytes(s.rx), formatBytes(s.tx)),
+	}, w.g.theme.hud)
+}
+
+// formatBytes formats n with a binary unit, e.g. "1.5G"
+func formatBytes(n float64) string {
+	const units = "BKMGTP"
+	i := 0
+	for ; n >= 1024 && i < len(units)-1; i++ {
+		n /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%c", n, units[i])
+	}
+	return fmt.Sprintf("%.1f%c", n, units[i])
+}
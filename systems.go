@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/ecs"
+	"github.com/mlctrez/donut/plugin"
+)
+
+// movementSystem applies the donut behavior and gravity, then moves and spins entities
+func (g *Game) movementSystem() {
+	w := g.world
+
+	// Apply the movement behavior to donuts, through a reused body so no interface value
+	// is allocated per donut
+	for _, e := range w.donuts.Entities() {
+		g.scratchBody = w.body(e)
+		g.behavior.Apply(g, &g.scratchBody)
+	}
+
+	// Apply gravity and update positions, scaled to the frame rate
+	speed := g.audio.speed() * g.step
+	for i := range w.velocities.Len() {
+		e, vel := w.velocities.At(i)
+		vel.y += g.gravity * g.step
+		if pos := w.positions.Get(e); pos != nil {
+			pos.x += vel.x * speed
+			pos.y += vel.y * speed
+		}
+	}
+
+	// Update rotations
+	for i := range w.spins.Len() {
+		_, s := w.spins.At(i)
+		s.angle += s.speed * g.step
+	}
+}
+
+// boundsSystem bounces entities with a collider off the screen edges, or whatever the
+// edge modes say. Linked donuts leaving towards another instance's screen are handed over
+// instead.
+func (g *Game) boundsSystem() {
+	w := g.world
+	var departed []ecs.Entity
+	for _, e := range w.colliders.Entities() {
+		pos, vel, spr := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e)
+		if pos == nil || vel == nil || spr == nil {
+			continue
+		}
+
+		if g.link != nil && w.donuts.Has(e) {
+			if pos.x <= 0 && vel.x < 0 && g.handOff(e, -1) || pos.x >= float64(g.screenWidth)-spr.width && vel.x > 0 && g.handOff(e, 1) {
+				departed = append(departed, e)
+				continue
+			}
+		}
+
+		g.edgeSystem(e, pos, vel, spr)
+	}
+
+	for _, e := range departed {
+		w.Despawn(e)
+		g.numDonuts--
+	}
+}
+
+// areCirclesColliding checks if two circles are overlapping
+func areCirclesColliding(x1, y1, r1, x2, y2, r2 float64) bool {
+	dx := x2 - x1
+	dy := y2 - y1
+	distance := math.Sqrt(dx*dx + dy*dy)
+	return distance < r1+r2 // Two circles collide when distance < sum of radii
+}
+
+// resolveCollision handles the physics of two colliding circles whose radii add up to
+// minDistance. It returns the collision normal and the impulse applied along it, which is
+// zero or negative.
+func resolveCollision(pos1 *position, vel1 *velocity, pos2 *position, vel2 *velocity, center1X, center1Y, center2X, center2Y, minDistance float64) (nx, ny, impulse float64) {
+	// Calculate collision vector
+	dx := center2X - center1X
+	dy := center2Y - center1Y
+	distance := math.Sqrt(dx*dx + dy*dy)
+
+	// Avoid division by zero
+	if distance == 0 {
+		dx = 1
+		dy = 0
+		distance = 1
+	}
+
+	// Normalize collision vector
+	nx = dx / distance
+	ny = dy / distance
+
+	// Separate the entities so they don't overlap
+	overlap := minDistance - distance
+	separationX := nx * overlap * 0.5
+	separationY := ny * overlap * 0.5
+
+	pos1.x -= separationX
+	pos1.y -= separationY
+	pos2.x += separationX
+	pos2.y += separationY
+
+	// Calculate relative velocity
+	dvx := vel2.x - vel1.x
+	dvy := vel2.y - vel1.y
+
+	// Calculate relative velocity along collision normal
+	dvn := dvx*nx + dvy*ny
+
+	// Don't resolve if velocities are separating
+	if dvn > 0 {
+		return nx, ny, 0
+	}
+
+	// Collision impulse (assuming equal mass and elastic collision)
+	impulse = 2 * dvn / 2 // divided by 2 because we have 2 objects of equal mass
+
+	// Update velocities
+	vel1.x += impulse * nx
+	vel1.y += impulse * ny
+	vel2.x -= impulse * nx
+	vel2.y -= impulse * ny
+	return nx, ny, impulse
+}
+
+// renderSystem draws the donuts onto target. With anti-aliasing they're drawn onto a layer
+// supersampling times the size of target, by raising pixelScale meanwhile, and scaled
+// down onto it, averaging the pixels along the edges of rotated sprites.
+func (g *Game) renderSystem(target *ebiten.Image) {
+	size := target.Bounds().Size().Mul(supersampling)
+	if !g.antialias || max(size.X, size.Y) > maxSupersampledSize {
+		g.drawDonutLayers(target)
+		return
+	}
+	if g.aaLayer == nil || g.aaLayer.Bounds().Size() != size {
+		if g.aaLayer != nil {
+			g.aaLayer.Dispose()
+		}
+		g.aaLayer = ebiten.NewImage(size.X, size.Y)
+	}
+	g.aaLayer.Clear()
+
+	screenScale := pixelScale
+	pixelScale *= supersampling
+	g.drawDonutLayers(g.aaLayer)
+	pixelScale = screenScale
+
+	op := &g.drawOp
+	op.GeoM.Reset()
+	op.ColorScale.Reset()
+	op.GeoM.Scale(1.0/supersampling, 1.0/supersampling)
+	op.Filter = ebiten.FilterLinear
+	target.DrawImage(g.aaLayer, op)
+	op.Filter = ebiten.FilterNearest
+}
+
+// drawDonutLayers draws every entity with a sprite using the configured renderer, back to
+// front by parallax layer. Far layers go through an offscreen image to dim and blur them.
+func (g *Game) drawDonutLayers(target *ebiten.Image) {
+	w := g.world
+	for depth := g.numLayers - 1; depth >= 0; depth-- {
+		dst := target
+		if depth > 0 {
+			dst = g.layerImages.image(depth, toScreen(g.screenWidth), toScreen(g.screenHeight))
+		}
+		for i := range w.sprites.Len() {
+			e, spr := w.sprites.At(i)
+			if w.layerOf(e) == depth {
+				g.scratchDrawn.body = w.body(e)
+				g.scratchDrawn.scale = g.audio.scale()
+				g.scratchTint = g.donutTint(e)
+				g.renderer.DrawBody(dst, spr.image, &g.scratchDrawn, &g.scratchTint)
+			}
+		}
+		if batch, ok := g.renderer.(plugin.BatchRenderer); ok {
+			batch.Flush(dst)
+		}
+		if depth > 0 {
+			g.layerImages.composite(target, depth)
+		}
+	}
+}
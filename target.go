@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+const (
+	targetRound     = time.Minute // Length of a round
+	targetDonuts    = 8           // Donuts on screen the respawns keep up to
+	targetCrosshair = 12          // Radius of the crosshair
+)
+
+var targetRateFlag = runFlags.Float64("target-rate", 1, "donuts per second coming in from the edges to replace the ones shot in the target game")
+
+// target is a shooting gallery: clicking shoots at the crosshair and pops the donut under
+// it. Popped donuts are replaced from the edges at -target-rate, and the round ends with
+// the accuracy and hits per minute.
+type target struct {
+	frames      int
+	shots, hits int
+	pending     float64 // Donuts owed by the respawn rate, a fraction until the next one is due
+	x, y        float64 // Crosshair in world coordinates
+}
+
+func (t *target) start(g *Game) {
+	s := g.currentSettings()
+	s.Donuts, s.Gravity, s.Behavior, s.Layers = targetDonuts, 0, defaultBehavior, 1
+	s.Lifetime, s.Chain, s.Magnets = 0, 0, false
+	g.spawner = spawners["edges"]
+	g.applySettings(s)
+	g.resetDonuts()
+	// Donuts drift through, one leaving lets another in elsewhere
+	g.edges = [4]edgeMode{edgeOpen, edgeOpen, edgeOpen, edgeOpen}
+}
+
+func (t *target) update(g *Game) bool {
+	t.x, t.y = g.camera.toWorld(cursorPosition())
+	t.frames++
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		t.shots++
+		for _, e := range g.world.donuts.Entities() {
+			pos, spr := g.world.positions.Get(e), g.world.sprites.Get(e)
+			radius := spr.width / 2
+			if math.Hypot(pos.x+radius-t.x, pos.y+radius-t.y) > radius {
+				continue
+			}
+			t.hits++
+			g.spawnFirework(pos.x+radius, pos.y+radius, 40)
+			g.world.Despawn(e)
+			g.numDonuts--
+			g.addStat(statPops, 1)
+			break
+		}
+	}
+
+	if g.numDonuts < targetDonuts {
+		t.pending += max(0, *targetRateFlag) / float64(ebiten.TPS())
+		for ; t.pending >= 1 && g.numDonuts < targetDonuts; t.pending-- {
+			g.respawnDonut(g.donutImageFor(g.numDonuts), 0)
+			g.numDonuts++
+		}
+	} else {
+		t.pending = 0
+	}
+	return t.frames >= durationFrames(targetRound)
+}
+
+func (t *target) draw(g *Game, screen *ebiten.Image) {
+	clr := g.theme.hud
+	x, y := float32(t.x), float32(t.y)
+	strokeCircle(screen, x, y, targetCrosshair, 2, clr, true)
+	strokeLine(screen, x-targetCrosshair*1.6, y, x-targetCrosshair/2, y, 2, clr, true)
+	strokeLine(screen, x+targetCrosshair/2, y, x+targetCrosshair*1.6, y, 2, clr, true)
+	strokeLine(screen, x, y-targetCrosshair*1.6, x, y-targetCrosshair/2, 2, clr, true)
+	strokeLine(screen, x, y+targetCrosshair/2, x, y+targetCrosshair*1.6, 2, clr, true)
+}
+
+func (t *target) scoreboard() string {
+	left := max(0, durationFrames(targetRound)-t.frames) / ebiten.TPS()
+	return trf("HITS %d   %d:%02d", t.hits, left/60, left%60)
+}
+
+// score is the number of hits
+func (t *target) score() float64 {
+	return float64(t.hits)
+}
+
+// summary returns the accuracy and hits per minute of the round
+func (t *target) summary() []string {
+	accuracy := 0.0
+	if t.shots > 0 {
+		accuracy = 100 * float64(t.hits) / float64(t.shots)
+	}
+	minutes := max(1, float64(t.frames)) / float64(ebiten.TPS()) / 60
+	return []string{
+		trf("%d hits, %d shots", t.hits, t.shots),
+		trf("accuracy %.0f%%", accuracy),
+		trf("%.1f hits per minute", float64(t.hits)/minutes),
+	}
+}
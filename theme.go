@@ -0,0 +1,192 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"maps"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mlctrez/donut/ecs"
+)
+
+// theme holds the colors used to draw a scene. Themes are part of the settings, so they
+// switch with presets, T and the API like the rest.
+type theme struct {
+	background color.RGBA
+	timer      color.RGBA
+	hud        color.RGBA   // Text of widgets, menus and games
+	highlight  color.RGBA   // Selected menu items
+	tints      []color.RGBA // Multiplied with the donut image in turn, white leaves it unchanged
+	particles  []color.RGBA // Firework colors, random hues when empty
+}
+
+const defaultTheme = "classic"
+
+var white = color.RGBA{255, 255, 255, 255}
+
+var themes = map[string]theme{
+	"classic": {
+		background: color.RGBA{A: 255},
+		timer:      color.RGBA{50, 150, 50, 255},
+		hud:        color.RGBA{50, 150, 50, 255},
+		highlight:  white,
+		tints:      []color.RGBA{white},
+	},
+	"neon": {
+		background: color.RGBA{10, 0, 30, 255},
+		timer:      color.RGBA{255, 60, 200, 255},
+		hud:        color.RGBA{255, 60, 200, 255},
+		highlight:  white,
+		tints:      []color.RGBA{{120, 255, 255, 255}},
+	},
+	"sepia": {
+		background: color.RGBA{30, 20, 10, 255},
+		timer:      color.RGBA{220, 180, 120, 255},
+		hud:        color.RGBA{220, 180, 120, 255},
+		highlight:  white,
+		tints:      []color.RGBA{{255, 220, 170, 255}},
+	},
+	"ice": {
+		background: color.RGBA{0, 20, 40, 255},
+		timer:      color.RGBA{150, 210, 255, 255},
+		hud:        color.RGBA{150, 210, 255, 255},
+		highlight:  white,
+		tints:      []color.RGBA{{190, 230, 255, 255}},
+	},
+	"dark": {
+		background: color.RGBA{18, 18, 24, 255},
+		timer:      color.RGBA{205, 205, 215, 255},
+		hud:        color.RGBA{140, 140, 155, 255},
+		highlight:  white,
+		tints:      []color.RGBA{white, {225, 225, 235, 255}},
+		particles:  []color.RGBA{{255, 200, 120, 255}, {255, 150, 90, 255}, {255, 240, 210, 255}},
+	},
+	"light": {
+		background: color.RGBA{238, 236, 230, 255},
+		timer:      color.RGBA{40, 40, 48, 255},
+		hud:        color.RGBA{75, 75, 85, 255},
+		highlight:  color.RGBA{0, 110, 200, 255},
+		tints:      []color.RGBA{white},
+		particles:  []color.RGBA{{0, 110, 200, 255}, {225, 75, 60, 255}, {235, 165, 0, 255}},
+	},
+	"synthwave": {
+		background: color.RGBA{22, 6, 42, 255},
+		timer:      color.RGBA{255, 60, 170, 255},
+		hud:        color.RGBA{0, 225, 255, 255},
+		highlight:  color.RGBA{255, 230, 80, 255},
+		tints:      []color.RGBA{{255, 130, 220, 255}, {130, 220, 255, 255}, {255, 200, 130, 255}},
+		particles:  []color.RGBA{{255, 60, 170, 255}, {0, 225, 255, 255}, {255, 230, 80, 255}, {160, 80, 255, 255}},
+	},
+	"pastel": {
+		background: color.RGBA{250, 240, 245, 255},
+		timer:      color.RGBA{125, 110, 165, 255},
+		hud:        color.RGBA{140, 125, 175, 255},
+		highlight:  color.RGBA{230, 115, 150, 255},
+		tints:      []color.RGBA{{255, 205, 225, 255}, {205, 230, 255, 255}, {210, 250, 215, 255}, {255, 240, 195, 255}},
+		particles:  []color.RGBA{{255, 180, 205, 255}, {180, 215, 255, 255}, {190, 240, 200, 255}, {255, 230, 170, 255}},
+	},
+}
+
+// themeNames returns the names of all themes in sorted order
+func themeNames() []string {
+	return slices.Sorted(maps.Keys(themes))
+}
+
+// tintFor returns the tint of donut e, the tints are handed out in turn
+func (t *theme) tintFor(e ecs.Entity) color.RGBA {
+	if len(t.tints) == 0 {
+		return white
+	}
+	return t.tints[int(e)%len(t.tints)]
+}
+
+// particleColor returns a firework color, random from the theme or around hue
+func (g *Game) particleColor(hue float64) color.RGBA {
+	if p := g.theme.particles; len(p) > 0 {
+		return p[g.fx.Intn(len(p))]
+	}
+	return hsvColor(hue+g.fx.Float64()*0.2, 0.8, 1)
+}
+
+// themeConfig is a custom theme in the config file. Colors left out come from the base
+// theme, so a theme can change only a few.
+type themeConfig struct {
+	Base       string     `json:"base"` // Built-in theme the colors start from, classic by default
+	Background *hexColor  `json:"background"`
+	Timer      *hexColor  `json:"timer"`
+	HUD        *hexColor  `json:"hud"`       // Text of widgets, menus and games
+	Highlight  *hexColor  `json:"highlight"` // Selected menu items
+	Tints      []hexColor `json:"tints"`     // Multiplied with the donut image, handed out in turn
+	Particles  []hexColor `json:"particles"` // Firework colors, random hues when empty
+}
+
+// addThemes adds the custom themes of the config to themes, replacing built-in themes of
+// the same name
+func addThemes(custom map[string]themeConfig) error {
+	builtin := maps.Clone(themes)
+	for _, name := range slices.Sorted(maps.Keys(custom)) {
+		t, err := custom[name].theme(builtin)
+		if err != nil {
+			return fmt.Errorf("theme %q: %w", name, err)
+		}
+		themes[name] = t
+	}
+	return nil
+}
+
+// theme returns the custom theme with its base taken from builtin
+func (c themeConfig) theme(builtin map[string]theme) (theme, error) {
+	t, ok := builtin[cmp.Or(c.Base, defaultTheme)]
+	if !ok {
+		return theme{}, fmt.Errorf("unknown base theme %q, available: %v", c.Base, slices.Sorted(maps.Keys(builtin)))
+	}
+	for _, field := range []struct {
+		dst *color.RGBA
+		src *hexColor
+	}{{&t.background, c.Background}, {&t.timer, c.Timer}, {&t.hud, c.HUD}, {&t.highlight, c.Highlight}} {
+		if field.src != nil {
+			*field.dst = field.src.premultiplied()
+		}
+	}
+	if len(c.Tints) > 0 {
+		t.tints = premultipliedColors(c.Tints)
+	}
+	if len(c.Particles) > 0 {
+		t.particles = premultipliedColors(c.Particles)
+	}
+	return t, nil
+}
+
+func premultipliedColors(colors []hexColor) []color.RGBA {
+	out := make([]color.RGBA, len(colors))
+	for i, c := range colors {
+		out[i] = c.premultiplied()
+	}
+	return out
+}
+
+// pollTheme switches to the next theme on T, or the previous one with Shift
+func (g *Game) pollTheme(events []replayEvent) []replayEvent {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		return events
+	}
+	dir := 1
+	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+		dir = -1
+	}
+	s := g.currentSettings()
+	s.Theme = cycle(themeNames(), s.Theme, dir)
+	return append(events, replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s})
+}
+
+// setTheme switches to the named theme as a recorded input
+func (g *Game) setTheme(name string) {
+	s := g.currentSettings()
+	s.Theme = name
+	ev := replayEvent{Frame: g.frame, Action: actionSettings, Settings: &s}
+	g.recordEvent(ev)
+	g.applyAction(ev)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	defaultTickerInterval = 15 * time.Minute
+	defaultTickerSpeed    = 2 // Pixels per frame
+	defaultTickerScale    = 2
+	tickerSeparator       = "   +++   "
+	tickerFetchTimeout    = 20 * time.Second
+)
+
+// tickerConfig scrolls headlines from RSS or Atom feeds along the bottom of the screen
+type tickerConfig struct {
+	Feeds    []string `json:"feeds"`    // Feed URLs
+	Interval duration `json:"interval"` // Time between polls, 15m by default
+	Speed    float64  `json:"speed"`    // Pixels per frame
+	Scale    float64  `json:"scale"`    // Text scale
+}
+
+// barHeight returns the height of the ticker bar at the bottom of the screen
+func (cfg tickerConfig) barHeight() float64 {
+	return baseFontHeight * cmp.Or(cfg.Scale, defaultTickerScale) * 1.5
+}
+
+// feedDocument holds the titles of an RSS 2.0, RSS 1.0 or Atom document
+type feedDocument struct {
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"` // RSS 2.0
+	Items   []feedItem `xml:"item"`  // RSS 1.0
+	Entries []feedItem `xml:"entry"` // Atom
+}
+
+type feedItem struct {
+	Title string `xml:"title"`
+}
+
+func (d feedDocument) titles() []string {
+	var titles []string
+	for _, items := range [][]feedItem{d.Channel.Items, d.Items, d.Entries} {
+		for _, item := range items {
+			if title := strings.Join(strings.Fields(item.Title), " "); title != "" {
+				titles = append(titles, title)
+			}
+		}
+	}
+	return titles
+}
+
+// cachedFeed is the last successful fetch of a feed
+type cachedFeed struct {
+	ETag         string   `json:"etag"`
+	LastModified string   `json:"last_modified"`
+	Titles       []string `json:"titles"`
+}
+
+// feedCache keeps the last headlines of every feed on disk so the ticker has something
+// to show when starting offline
+type feedCache struct {
+	path  string
+	feeds map[string]cachedFeed
+}
+
+func loadFeedCache() *feedCache {
+	c := &feedCache{feeds: map[string]cachedFeed{}}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return c
+	}
+	c.path = filepath.Join(dir, "donut", "feeds.json")
+	if data, err := os.ReadFile(c.path); err == nil {
+		if err := json.Unmarshal(data, &c.feeds); err != nil {
+			slog.Warn("ignoring feed cache", "err", err)
+		}
+	}
+	return c
+}
+
+func (c *feedCache) save() {
+	if c.path == "" {
+		return
+	}
+	data, err := json.Marshal(c.feeds)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(c.path, data, 0o644)
+	}
+	if err != nil {
+		slog.Warn("failed to save feed cache", "err", err)
+	}
+}
+
+// fetch updates the cached headlines of url. The cached headlines are kept when the feed
+// is unchanged or can't be fetched.
+func (c *feedCache) fetch(client *http.Client, url string) error {
+	cached := c.feeds[url]
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var doc feedDocument
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&doc); err != nil {
+		return fmt.Errorf("parsing feed: %w", err)
+	}
+	c.feeds[url] = cachedFeed{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Titles:       doc.titles(),
+	}
+	return nil
+}
+
+// tickerOverlay polls the feeds in the background and scrolls their headlines
+type tickerOverlay struct {
+	g   *Game
+	cfg tickerConfig
+
+	mu   sync.Mutex
+	text string // All headlines, empty until one feed has been fetched
+
+	offset float64 // Pixels scrolled since the text started entering the screen
+}
+
+func newTickerOverlay(g *Game, cfg tickerConfig) *tickerOverlay {
+	t := &tickerOverlay{g: g, cfg: cfg}
+	go t.poll()
+	return t
+}
+
+func (t *tickerOverlay) poll() {
+	interval := time.Duration(t.cfg.Interval)
+	if interval <= 0 {
+		interval = defaultTickerInterval
+	}
+	client := &http.Client{Timeout: tickerFetchTimeout}
+	cache := loadFeedCache()
+	t.show(cache)
+	for {
+		for _, url := range t.cfg.Feeds {
+			if err := cache.fetch(client, url); err != nil {
+				slog.Warn("feed failed", "url", url, "err", err)
+			}
+		}
+		cache.save()
+		t.show(cache)
+		time.Sleep(interval)
+	}
+}
+
+// show replaces the ticker text with the cached headlines
+func (t *tickerOverlay) show(cache *feedCache) {
+	var titles []string
+	for _, url := range t.cfg.Feeds {
+		titles = append(titles, cache.feeds[url].Titles...)
+	}
+	t.mu.Lock()
+	t.text = strings.Join(titles, tickerSeparator)
+	t.mu.Unlock()
+}
+
+func (t *tickerOverlay) Update(plugin.World) error {
+	t.offset += cmp.Or(t.cfg.Speed, defaultTickerSpeed)
+	return nil
+}
+
+func (t *tickerOverlay) Draw(dst *ebiten.Image, w plugin.World) {
+	t.mu.Lock()
+	text := t.text
+	t.mu.Unlock()
+	if text == "" {
+		return
+	}
+	text += tickerSeparator
+
+	scale := cmp.Or(t.cfg.Scale, defaultTickerScale)
+	width, height := w.Size()
+	barHeight := t.cfg.barHeight()
+	y := float64(height) - barHeight
+	drawFilledRect(dst, 0, float32(y), float32(width), float32(barHeight), t.g.theme.background, false)
+
+	// Repeat the text so the screen is always full, starting from the right edge
+	textW := textWidth(text, scale)
+	scroll := t.offset - float64(width)
+	if scroll >= textW {
+		t.offset -= textW
+		scroll -= textW
+	}
+	for x := -scroll; x < float64(width); x += textW {
+		if x+textW > 0 {
+			drawText(dst, text, x, y+barHeight/2-baseFontHeight*scale/2, scale, t.g.theme.hud)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// timerStartEnv sets the timer start like -timer-start-file, for provisioning tools that
+// configure displays through the environment
+const timerStartEnv = "DONUT_TIMER_START"
+
+var timerStartFile = runFlags.String("timer-start-file", "", "file holding the RFC 3339 time the timer started at, overriding "+timerStartEnv)
+
+// resolveTimerStart returns the time the timer started at: from -timer-start-file, else
+// from the DONUT_TIMER_START environment variable, else the built-in timerStartTime
+func resolveTimerStart() (time.Time, error) {
+	if *timerStartFile != "" {
+		data, err := os.ReadFile(*timerStartFile)
+		if err != nil {
+			return time.Time{}, err
+		}
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", *timerStartFile, err)
+		}
+		slog.Info("timer start from file", "path", *timerStartFile, "start", start)
+		return start, nil
+	}
+	if value, ok := os.LookupEnv(timerStartEnv); ok && strings.TrimSpace(value) != "" {
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: %w", timerStartEnv, err)
+		}
+		slog.Info("timer start from the environment", "start", start)
+		return start, nil
+	}
+	return timerStartTime, nil
+}
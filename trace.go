@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	traceFlag      = runFlags.String("trace", "", "log the positions and velocities of all donuts to this .csv or .json file, as JSON lines for .json")
+	traceEveryFlag = runFlags.Int("trace-every", 1, "log every n-th frame with -trace")
+)
+
+// traceWriter logs the donut trajectories for analysis outside the screensaver. Positions
+// are donut centers in pixels, velocities in pixels per frame.
+type traceWriter struct {
+	file  *os.File
+	w     *bufio.Writer
+	csv   *csv.Writer   // Nil when writing JSON
+	enc   *json.Encoder // Nil when writing CSV
+	every int
+	row   []string
+	frame traceFrame
+}
+
+// traceFrame is one line of a JSON trace
+type traceFrame struct {
+	Frame  int           `json:"frame"`
+	Donuts []traceSample `json:"donuts"`
+}
+
+// traceSample is one donut in a traced frame. ID identifies the donut over its life.
+type traceSample struct {
+	ID uint32  `json:"id"`
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	VX float64 `json:"vx"`
+	VY float64 `json:"vy"`
+}
+
+func newTraceWriter(path string, every int) (*traceWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &traceWriter{file: f, w: bufio.NewWriter(f), every: max(1, every)}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl":
+		t.enc = json.NewEncoder(t.w)
+	default:
+		t.csv = csv.NewWriter(t.w)
+		t.csv.Write([]string{"frame", "id", "x", "y", "vx", "vy"})
+	}
+	return t, nil
+}
+
+// write logs the donuts of the current frame when it's one to sample
+func (t *traceWriter) write(g *Game) error {
+	if g.frame%t.every != 0 {
+		return nil
+	}
+	w := g.world
+	t.frame.Frame = g.frame
+	t.frame.Donuts = t.frame.Donuts[:0]
+	for _, e := range w.donuts.Entities() {
+		pos, vel, spr := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e)
+		t.frame.Donuts = append(t.frame.Donuts, traceSample{uint32(e), pos.x + spr.width/2, pos.y + spr.height/2, vel.x, vel.y})
+	}
+	if t.enc != nil {
+		return t.enc.Encode(t.frame)
+	}
+
+	frame := strconv.Itoa(g.frame)
+	for _, s := range t.frame.Donuts {
+		t.row = append(t.row[:0], frame, strconv.FormatUint(uint64(s.ID), 10),
+			formatFloat(s.X), formatFloat(s.Y), formatFloat(s.VX), formatFloat(s.VY))
+		if err := t.csv.Write(t.row); err != nil {
+			return err
+		}
+	}
+	return t.csv.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
+
+func (t *traceWriter) Close() error {
+	if t.csv != nil {
+		t.csv.Flush()
+	}
+	if err := t.w.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// traceSystem logs the frame to the trace, if one is active
+func (g *Game) traceSystem() {
+	if g.trace == nil {
+		return
+	}
+	if err := g.trace.write(g); err != nil {
+		slog.Error("trace stopped", "err", err)
+		g.trace.Close()
+		g.trace = nil
+	}
+}
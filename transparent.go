@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"runtime"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// setupTransparentWindow turns the window into an undecorated, click-through, always on
+// top overlay covering the monitor, so only the donuts and the timer are visible
+func setupTransparentWindow() error {
+	switch runtime.GOOS {
+	case "js", "android", "ios":
+		return errors.New("transparent windows are not supported on " + runtime.GOOS)
+	case "linux":
+		// X11 needs a compositor for per-pixel alpha. Under Wayland GLFW windows run
+		// through XWayland, which composites but ignores the position.
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			slog.Warn("transparent mode under Wayland: the window may not cover the whole screen")
+		} else {
+			slog.Warn("transparent mode needs a compositing window manager, the background is black without one")
+		}
+	}
+
+	width, height := ebiten.ScreenSizeInFullscreen()
+	ebiten.SetWindowDecorated(false)
+	ebiten.SetWindowFloating(true)
+	ebiten.SetWindowMousePassthrough(true)
+	ebiten.SetWindowSize(width, height)
+	ebiten.SetWindowPosition(0, 0)
+	slog.Info("transparent mode: the window ignores the mouse, stop it with Ctrl+C")
+	return nil
+}
+
+// clearBackground reports whether the scene is drawn over a transparent background,
+// either for a transparent window or for a background plugin
+func (g *Game) clearBackground() bool {
+	return g.transparent || g.background != nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"fyne.io/systray"
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/draw"
+)
+
+const trayIconSize = 64
+
+// appCommand is a change requested from outside the game loop that needs the app, such as
+// switching scenes. Returning an error ends the app like a scene's Update does.
+type appCommand func(a *app) error
+
+// runAppCommands runs every queued app command
+func (a *app) runAppCommands() error {
+	for {
+		select {
+		case cmd := <-a.commands:
+			if err := cmd(a); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// send queues cmd, dropping it when the queue is full
+func (a *app) send(cmd appCommand) {
+	select {
+	case a.commands <- cmd:
+	default:
+	}
+}
+
+// startTray shows a tray icon whose menu controls the app. The returned function removes
+// the icon.
+func startTray(a *app) (stop func()) {
+	start, end := systray.RunWithExternalLoop(func() {
+		systray.SetIcon(trayIcon())
+		systray.SetTooltip(windowTitle)
+
+		pause := systray.AddMenuItem(tr("Pause"), tr("Pause or resume the donuts"))
+		add := systray.AddMenuItem(tr("Add donut"), "")
+		remove := systray.AddMenuItem(tr("Remove donut"), "")
+		reset := systray.AddMenuItem(tr("Reset timer"), tr("Restart the timer from now"))
+		settings := systray.AddMenuItem(tr("Settings"), tr("Open the settings"))
+		systray.AddSeparator()
+		quit := systray.AddMenuItem(tr("Quit"), "")
+
+		for {
+			select {
+			case <-pause.ClickedCh:
+				a.send(func(a *app) error {
+					if _, paused := a.scene.(pauseScene); paused {
+						a.switchTo(simulationScene{})
+						pause.SetTitle(tr("Pause"))
+					} else {
+						a.switchTo(pauseScene{})
+						pause.SetTitle(tr("Resume"))
+					}
+					return nil
+				})
+			case <-add.ClickedCh:
+				a.send(func(a *app) error { return a.game.input(actionAddDonut) })
+			case <-remove.ClickedCh:
+				a.send(func(a *app) error { return a.game.input(actionRemoveDonut) })
+			case <-reset.ClickedCh:
+				a.send(func(a *app) error { a.game.resetTimer(); return nil })
+			case <-settings.ClickedCh:
+				a.send(func(a *app) error { a.switchTo(&settingsScene{}); return nil })
+			case <-quit.ClickedCh:
+				a.send(func(a *app) error { return ebiten.Termination })
+			}
+		}
+	}, nil)
+	start()
+	return end
+}
+
+// input records and applies a simulation action that has no parameters
+func (g *Game) input(action inputAction) error {
+	ev := replayEvent{Frame: g.frame, Action: action}
+	g.recordEvent(ev)
+	g.applyAction(ev)
+	return nil
+}
+
+// resetTimer restarts the timer from now, resetting the incident counter in counter mode
+func (g *Game) resetTimer() {
+	if g.incident != nil {
+		g.resetIncident()
+		return
+	}
+	g.restartTimer(time.Now())
+}
+
+// restartTimer starts the timer and the milestones over at start
+func (g *Game) restartTimer(start time.Time) {
+	g.timerStartTime = start
+	if g.milestones != nil {
+		g.milestones = newMilestoneTracker(g.milestones.cfg, g.elapsed())
+	}
+}
+
+// trayIcon returns the donut scaled down to an icon, as PNG or on Windows as an ICO file
+// holding the PNG
+func trayIcon() []byte {
+	src, err := png.Decode(bytes.NewReader(donutPNG))
+	if err != nil {
+		slog.Warn("tray icon", "err", err)
+		return nil
+	}
+	small := image.NewRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+	draw.CatmullRom.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+	var buf bytes.Buffer
+	png.Encode(&buf, small)
+	if runtime.GOOS != "windows" {
+		return buf.Bytes()
+	}
+
+	var ico bytes.Buffer
+	binary.Write(&ico, binary.LittleEndian, []uint16{0, 1, 1}) // Reserved, icon type, one image
+	ico.Write([]byte{trayIconSize, trayIconSize, 0, 0})        // Width, height, no palette, reserved
+	binary.Write(&ico, binary.LittleEndian, []uint16{1, 32})   // Color planes, bits per pixel
+	binary.Write(&ico, binary.LittleEndian, []uint32{uint32(buf.Len()), 6 + 16})
+	ico.Write(buf.Bytes())
+	return ico.Bytes()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	baseFontHeight = 13 // basicfont.Face7x13 height
+	baseFontWidth  = 7  // basicfont.Face7x13 character width
+)
+
+// textOp is reused by drawText, which only runs on the game loop
+var textOp ebiten.DrawImageOptions
+
+// drawText draws str with its top left corner at x, y at scale times the size of basicfont,
+// with glyphs rasterized at that size
+func drawText(dst *ebiten.Image, str string, x, y, scale float64, clr color.Color) {
+	drawGlyphs(dst, str, x, y, scale, &textOp, clr)
+}
+
+// dimScreen darkens everything drawn so far, used behind menus and overlays
+func dimScreen(screen *ebiten.Image) {
+	w, h := logicalSize(screen)
+	drawFilledRect(screen, 0, 0, float32(w), float32(h), color.RGBA{A: 160}, false)
+}
+
+// anchor is a screen position that widgets are attached to
+type anchor string
+
+const (
+	anchorTopLeft     anchor = "top-left"
+	anchorTopRight    anchor = "top-right"
+	anchorBottomLeft  anchor = "bottom-left"
+	anchorBottomRight anchor = "bottom-right"
+	anchorCenter      anchor = "center"
+)
+
+var anchors = []anchor{anchorTopLeft, anchorTopRight, anchorBottomLeft, anchorBottomRight, anchorCenter}
+
+func (a *anchor) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if !slices.Contains(anchors, anchor(str)) {
+		return fmt.Errorf("invalid anchor %q, want one of %v", str, anchors)
+	}
+	*a = anchor(str)
+	return nil
+}
+
+// position returns the top left corner of a width x height box attached to the anchor,
+// margin pixels away from the screen edges. An empty anchor is top-left.
+func (a anchor) position(screenWidth, screenHeight int, width, height, margin float64) (float64, float64) {
+	sw, sh := float64(screenWidth), float64(screenHeight)
+	switch a {
+	case anchorTopRight:
+		return sw - width - margin, margin
+	case anchorBottomLeft:
+		return margin, sh - height - margin
+	case anchorBottomRight:
+		return sw - width - margin, sh - height - margin
+	case anchorCenter:
+		return (sw - width) / 2, (sh - height) / 2
+	}
+	return margin, margin
+}
+
+const (
+	defaultWidgetScale  = 2
+	defaultWidgetMargin = 20
+)
+
+// widgetConfig places a HUD widget on the screen
+type widgetConfig struct {
+	Anchor anchor  `json:"anchor"` // Corner or center of the screen, top-left by default
+	Margin *int    `json:"margin"` // Distance from the screen edges in pixels
+	Scale  float64 `json:"scale"`  // Text scale, 2 by default
+}
+
+// drawWidget draws lines of text on a translucent panel at the widget's anchor
+func drawWidget(dst *ebiten.Image, cfg widgetConfig, lines []string, clr color.Color) {
+	scale := cfg.Scale
+	if scale <= 0 {
+		scale = defaultWidgetScale
+	}
+	margin := float64(defaultWidgetMargin)
+	if cfg.Margin != nil {
+		margin = float64(*cfg.Margin)
+	}
+
+	var width float64
+	for _, line := range lines {
+		width = max(width, textWidth(line, scale))
+	}
+	lineHeight := baseFontHeight * scale * 1.2
+	pad := 4 * scale
+	w, h := width+2*pad, float64(len(lines))*lineHeight+2*pad
+
+	screenWidth, screenHeight := logicalSize(dst)
+	x, y := cfg.Anchor.position(int(screenWidth), int(screenHeight), w, h, margin)
+	fillRoundedRect(dst, float32(x), float32(y), float32(w), float32(h), float32(pad), color.RGBA{A: 140})
+	for i, line := range lines {
+		drawText(dst, line, x+pad, y+pad+float64(i)*lineHeight, scale, clr)
+	}
+}
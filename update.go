@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	updateRepo      = "mlctrez/donut"
+	updateTimeout   = 5 * time.Minute
+	checksumsAsset  = "checksums.txt"
+	signatureAsset  = checksumsAsset + ".minisig"
+	maxDownloadSize = 256 << 20 // Larger release assets are refused
+)
+
+// updateKey is the minisign public key the release checksums are signed with, stamped
+// into releases like the build stamps in version.go:
+//
+//	go build -ldflags "-X main.updateKey=RWQ..."
+//
+// Releases sign checksums.txt with minisign -S -l. Builds without a key can't install
+// updates, there is nothing to verify them with.
+var updateKey string
+
+var (
+	updateCheck = runFlags.Duration("update-check", 0, "check GitHub for a newer release at this interval (e.g. 24h), 0 disables")
+	autoUpdate  = runFlags.Bool("auto-update", false, "install newer releases found by -update-check, used from the next start")
+)
+
+// release is the part of a GitHub release used for updating
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the named asset
+func (r release) assetURL(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// releaseAssetName is the binary for the running platform, e.g. donut_linux_arm64
+func releaseAssetName() string {
+	name := fmt.Sprintf("donut_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// latestRelease asks GitHub for the latest release of repo
+func latestRelease(ctx context.Context, repo string) (release, error) {
+	var r release
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.github.com/repos/"+repo+"/releases/latest", nil)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("latest release: %s", resp.Status)
+	}
+	return r, json.NewDecoder(resp.Body).Decode(&r)
+}
+
+// newerVersion reports whether tag is a later version than current, comparing vMAJOR.MINOR.PATCH
+// numerically. Development builds are never updated automatically.
+func newerVersion(tag, current string) bool {
+	parse := func(v string) ([3]int, bool) {
+		var n [3]int
+		v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "-")
+		parts := strings.Split(v, ".")
+		if len(parts) != 3 {
+			return n, false
+		}
+		for i, p := range parts {
+			var err error
+			if n[i], err = strconv.Atoi(p); err != nil {
+				return n, false
+			}
+		}
+		return n, true
+	}
+	t, ok1 := parse(tag)
+	c, ok2 := parse(current)
+	if !ok1 || !ok2 {
+		return false
+	}
+	for i := range t {
+		if t[i] != c[i] {
+			return t[i] > c[i]
+		}
+	}
+	return false
+}
+
+// download returns the body of url, up to maxDownloadSize bytes
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err == nil && len(data) > maxDownloadSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxDownloadSize)
+	}
+	return data, err
+}
+
+// verifySignature checks that sig, a minisign signature file made with minisign -S -l,
+// signs msg with key, a minisign public key. The trusted comment must be signed too.
+func verifySignature(key string, msg, sig []byte) error {
+	pk, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(pk) != 10+ed25519.PublicKeySize || string(pk[:2]) != "Ed" {
+		return errors.New("invalid update key")
+	}
+	// Lines: untrusted comment, algorithm, key ID and signature, trusted comment, global signature
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(string(sig)), "\r\n", "\n"), "\n")
+	if len(lines) != 4 {
+		return errors.New("malformed signature")
+	}
+	s, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(s) != 10+ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	comment, ok := strings.CutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return errors.New("malformed signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+
+	switch {
+	case string(s[:2]) != "Ed":
+		return fmt.Errorf("unsupported signature algorithm %q, sign with minisign -l", s[:2])
+	case !bytes.Equal(s[2:10], pk[2:10]):
+		return errors.New("signed with another key")
+	case !ed25519.Verify(pk[10:], msg, s[10:]):
+		return errors.New("signature mismatch")
+	case !ed25519.Verify(pk[10:], slices.Concat(s[10:], []byte(comment)), global):
+		return errors.New("trusted comment signature mismatch")
+	}
+	return nil
+}
+
+// installRelease downloads the binary for this platform from r, verifies it against the
+// release's checksums.txt, whose signature is verified with updateKey, and replaces the
+// running executable with it. The old binary is kept next to it with a .old suffix.
+func installRelease(ctx context.Context, r release) error {
+	if updateKey == "" {
+		return errors.New("this build has no update key to verify releases with, install it from a release")
+	}
+	name := releaseAssetName()
+	binURL, ok := r.assetURL(name)
+	if !ok {
+		return fmt.Errorf("release %s has no %s", r.Tag, name)
+	}
+	sumsURL, ok := r.assetURL(checksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", r.Tag, checksumsAsset)
+	}
+
+	sigURL, ok := r.assetURL(signatureAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install an unsigned binary", r.Tag, signatureAsset)
+	}
+
+	sums, err := download(ctx, sumsURL)
+	if err != nil {
+		return err
+	}
+	sig, err := download(ctx, sigURL)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(updateKey, sums, sig); err != nil {
+		return fmt.Errorf("%s of release %s: %w", checksumsAsset, r.Tag, err)
+	}
+	var want string
+	scanner := bufio.NewScanner(strings.NewReader(string(sums)))
+	for scanner.Scan() {
+		// sha256sum format: "<hex>  <name>"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			want = fields[0]
+		}
+	}
+	if want == "" {
+		return fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+	}
+
+	bin, err := download(ctx, binURL)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(bin)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	// Write next to the executable so the renames stay on one filesystem. Renaming the
+	// running executable works on Windows too, overwriting it doesn't.
+	if err := os.WriteFile(exe+".new", bin, 0o755); err != nil {
+		return err
+	}
+	os.Remove(exe + ".old")
+	if err := os.Rename(exe, exe+".old"); err != nil {
+		os.Remove(exe + ".new")
+		return err
+	}
+	if err := os.Rename(exe+".new", exe); err != nil {
+		os.Rename(exe+".old", exe)
+		return err
+	}
+	return nil
+}
+
+// runUpdate is the update command: it installs the latest release when it is newer than
+// the running build
+func runUpdate(args []string) int {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	force := fs.Bool("force", false, "install the latest release even when it isn't newer")
+	repo := fs.String("repo", updateRepo, "GitHub repository to update from")
+	fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+	defer cancel()
+	r, err := latestRelease(ctx, *repo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "donut update:", err)
+		return 1
+	}
+	current := currentBuild().Version
+	if !*force && !newerVersion(r.Tag, current) {
+		fmt.Printf("donut %s is up to date, the latest release is %s\n", current, r.Tag)
+		return 0
+	}
+	if *check {
+		fmt.Printf("donut %s is available, running %s\n", r.Tag, current)
+		return 0
+	}
+	if err := installRelease(ctx, r); err != nil {
+		fmt.Fprintln(os.Stderr, "donut update:", err)
+		return 1
+	}
+	fmt.Printf("updated from %s to %s\n", current, r.Tag)
+	return 0
+}
+
+// checkUpdates looks for newer releases every interval in the background, installing them
+// when install is set. Kiosks pick the update up the next time the screensaver starts.
+func checkUpdates(interval time.Duration, install bool) {
+	go func() {
+		for ; ; time.Sleep(interval) {
+			ctx, cancel := context.WithTimeout(context.Background(), updateTimeout)
+			r, err := latestRelease(ctx, updateRepo)
+			switch {
+			case err != nil:
+				slog.Warn("update check failed", "err", err)
+			case !newerVersion(r.Tag, currentBuild().Version):
+				slog.Debug("no update available", "latest", r.Tag)
+			case !install:
+				slog.Info("update available, install it with donut update", "version", r.Tag)
+			default:
+				if err := installRelease(ctx, r); err != nil {
+					slog.Error("update failed", "version", r.Tag, "err", err)
+				} else {
+					slog.Info("update installed, used from the next start", "version", r.Tag)
+					cancel()
+					return
+				}
+			}
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// minisign returns a public key and a signature of msg in minisign's legacy formats
+func minisign(t *testing.T, keyID, msg, comment string) (key, sig string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ed25519.Sign(priv, []byte(msg))
+	global := ed25519.Sign(priv, slices.Concat(s, []byte(comment)))
+	enc := base64.StdEncoding.EncodeToString
+	key = enc(slices.Concat([]byte("Ed"), []byte(keyID), pub))
+	sig = strings.Join([]string{
+		"untrusted comment: signature from minisign secret key",
+		enc(slices.Concat([]byte("Ed"), []byte(keyID), s)),
+		"trusted comment: " + comment,
+		enc(global),
+	}, "\n") + "\n"
+	return key, sig
+}
+
+func TestVerifySignature(t *testing.T) {
+	const sums = "0123abcd  donut_linux_amd64\n"
+	key, sig := minisign(t, "12345678", sums, "timestamp:1700000000\tfile:checksums.txt")
+	otherKey, _ := minisign(t, "12345678", sums, "")
+	otherID, _ := minisign(t, "87654321", sums, "")
+	lines := strings.Split(sig, "\n")
+
+	tests := []struct {
+		name     string
+		key, msg string
+		sig      string
+		ok       bool
+	}{
+		{"valid", key, sums, sig, true},
+		{"windows line endings", key, sums, strings.ReplaceAll(sig, "\n", "\r\n"), true},
+		{"changed checksums", key, "ffff  donut_linux_amd64\n", sig, false},
+		{"other key", otherKey, sums, sig, false},
+		{"other key ID", otherID, sums, sig, false},
+		{"changed trusted comment", key, sums, strings.Replace(sig, "file:checksums.txt", "file:other.txt", 1), false},
+		{"missing global signature", key, sums, strings.Join(lines[:3], "\n"), false},
+		{"prehashed", key, sums, strings.Replace(sig, lines[1][:2], "RU", 1), false},
+		{"invalid key", "not a key", sums, sig, false},
+		{"empty", key, sums, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(tt.key, []byte(tt.msg), []byte(tt.sig))
+			if (err == nil) != tt.ok {
+				t.Errorf("verifySignature = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// Build stamps, set when building releases:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+//
+// Builds without them report what the Go toolchain recorded, the module version for go
+// install and the VCS revision for builds from a checkout.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// buildInfo identifies the running binary
+type buildInfo struct {
+	Version  string
+	Commit   string
+	Date     string
+	Modified bool // Built from a checkout with uncommitted changes
+	Go       string
+}
+
+// currentBuild returns the build stamps, falling back to the embedded build information
+func currentBuild() buildInfo {
+	b := buildInfo{Version: version, Commit: commit, Date: date}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.Go = info.GoVersion
+		b.Version = cmp.Or(b.Version, info.Main.Version)
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				b.Commit = cmp.Or(b.Commit, s.Value)
+			case "vcs.time":
+				b.Date = cmp.Or(b.Date, s.Value)
+			case "vcs.modified":
+				b.Modified = commit == "" && s.Value == "true"
+			}
+		}
+	}
+	b.Version = cmp.Or(b.Version, "(devel)")
+	return b
+}
+
+// String formats the build for bug reports, e.g. "donut v1.2.0 (1a2b3c4d5e6f, 2025-09-09T21:05:45Z, go1.25.0)"
+func (b buildInfo) String() string {
+	var details []string
+	if b.Commit != "" {
+		c := b.Commit[:min(len(b.Commit), 12)]
+		if b.Modified {
+			c += "+dirty"
+		}
+		details = append(details, c)
+	}
+	if b.Date != "" {
+		details = append(details, b.Date)
+	}
+	if b.Go != "" {
+		details = append(details, b.Go)
+	}
+	if len(details) == 0 {
+		return "donut " + b.Version
+	}
+	return fmt.Sprintf("donut %s (%s)", b.Version, strings.Join(details, ", "))
+}
+
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Parse(args)
+	fmt.Println(currentBuild())
+	return 0
+}
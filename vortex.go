@@ -0,0 +1,145 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mlctrez/donut/ecs"
+)
+
+const (
+	maxVortices           = 8
+	defaultVortexRadius   = 200  // Reach of a vortex in pixels
+	defaultVortexStrength = 0.15 // Pull at the center in pixels per frame²
+	vortexSwirl           = 1.5  // Sideways push relative to the pull, makes donuts spiral
+	vortexCore            = 12   // Donuts closer than this to the center are thrown out
+)
+
+// vortex pulls donuts within radius into a spiral around its position, the center of the
+// vortex. Donuts reaching the center are flung back out, or teleported to a random edge.
+type vortex struct {
+	radius, strength float64
+	teleport         bool
+}
+
+// vortexConfig places a vortex. Positions are relative to the screen size so a vortex
+// stays in place on any screen.
+type vortexConfig struct {
+	X        float64 `json:"x"`        // Horizontal position, 0 is the left edge and 1 the right
+	Y        float64 `json:"y"`        // Vertical position, 0 is the top edge and 1 the bottom
+	Radius   float64 `json:"radius"`   // Reach in pixels, 200 by default
+	Strength float64 `json:"strength"` // Pull at the center, 0.15 by default
+	Teleport bool    `json:"teleport"` // Teleport donuts reaching the center to an edge instead of flinging them
+}
+
+// validate checks the position and sizes of a vortex
+func (c vortexConfig) validate() error {
+	if c.X < 0 || c.X > 1 || c.Y < 0 || c.Y > 1 {
+		return fmt.Errorf("position %.2f, %.2f is outside the screen, want 0 to 1", c.X, c.Y)
+	}
+	if c.Radius < 0 || c.Strength < 0 {
+		return fmt.Errorf("radius and strength must not be negative")
+	}
+	return nil
+}
+
+// addVortex adds a vortex centered at x, y with the defaults filled into c
+func (g *Game) addVortex(x, y float64, c vortexConfig) {
+	if g.world.vortices.Len() >= maxVortices {
+		return
+	}
+	e := g.world.Spawn()
+	g.world.positions.Add(e, position{x, y})
+	g.world.vortices.Add(e, vortex{
+		radius:   cmp.Or(c.Radius, defaultVortexRadius),
+		strength: cmp.Or(c.Strength, defaultVortexStrength),
+		teleport: c.Teleport,
+	})
+}
+
+// toggleVortex removes the vortex at x, y or adds one there
+func (g *Game) toggleVortex(x, y float64) {
+	w := g.world
+	for i := range w.vortices.Len() {
+		e, _ := w.vortices.At(i)
+		if pos := w.positions.Get(e); math.Hypot(pos.x-x, pos.y-y) < 4*vortexCore {
+			w.Despawn(e)
+			return
+		}
+	}
+	g.addVortex(x, y, vortexConfig{})
+}
+
+// pollVortex returns the event placing or removing a vortex at the cursor on V
+func (g *Game) pollVortex(events []replayEvent) []replayEvent {
+	if !inpututil.IsKeyJustPressed(ebiten.KeyV) || ebiten.IsKeyPressed(ebiten.KeyShift) {
+		return events
+	}
+	x, y := g.camera.toWorld(cursorPosition())
+	return append(events, replayEvent{Frame: g.frame, Action: actionVortex, X: x, Y: y})
+}
+
+// vortexSystem pulls the donuts into the vortices
+func (g *Game) vortexSystem() {
+	w := g.world
+	for i := range w.vortices.Len() {
+		ve, v := w.vortices.At(i)
+		center := w.positions.Get(ve)
+		for _, e := range w.donuts.Entities() {
+			pos, vel, spr := w.positions.Get(e), w.velocities.Get(e), w.sprites.Get(e)
+			dx := center.x - (pos.x + spr.width/2)
+			dy := center.y - (pos.y + spr.height/2)
+			dist := math.Hypot(dx, dy)
+			if dist >= v.radius {
+				continue
+			}
+			if dist < vortexCore {
+				g.throwOut(e, pos, vel, spr, v, dx, dy, dist)
+				continue
+			}
+			// Pull toward the center and push sideways, stronger closer in
+			pull := v.strength * (1 - dist/v.radius) * g.step
+			nx, ny := dx/dist, dy/dist
+			vel.x += (nx - ny*vortexSwirl) * pull
+			vel.y += (ny + nx*vortexSwirl) * pull
+		}
+	}
+}
+
+// throwOut flings a donut that reached the center of v back out, or teleports it to a
+// random edge
+func (g *Game) throwOut(e ecs.Entity, pos *position, vel *velocity, spr *sprite, v *vortex, dx, dy, dist float64) {
+	if v.teleport {
+		g.replaceDonut(e, pos, vel, spr, g.rng.Intn(4))
+		return
+	}
+	angle := math.Atan2(-dy, -dx)
+	if dist == 0 {
+		angle = g.rng.Float64() * 2 * math.Pi
+	}
+	speed := 2 * g.maxSpeed * depthLayers[g.world.layerOf(e)].speed
+	vel.x, vel.y = math.Cos(angle)*speed, math.Sin(angle)*speed
+}
+
+// drawVortices draws each vortex as spiral arms turning with the frame
+func (g *Game) drawVortices(dst *ebiten.Image) {
+	const arms, dots = 3, 16
+	w := g.world
+	for i := range w.vortices.Len() {
+		e, v := w.vortices.At(i)
+		pos := w.positions.Get(e)
+		for arm := range arms {
+			for k := 1; k <= dots; k++ {
+				t := float64(k) / dots
+				angle := float64(arm)*2*math.Pi/arms + t*3*math.Pi - float64(g.frame)*0.05
+				x, y := pos.x+math.Cos(angle)*v.radius*t, pos.y+math.Sin(angle)*v.radius*t
+				a := uint8(180 * (1 - t))
+				drawFilledCircle(dst, float32(x), float32(y), float32(2+3*(1-t)), color.RGBA{a / 2, a / 3, a, a}, true)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const windowTitle = "Donut Screensaver"
+
+// setupWallpaperWindow sizes an undecorated window to the screen. It is moved behind the
+// desktop icons by attachWallpaper once it exists.
+func setupWallpaperWindow() {
+	width, height := ebiten.ScreenSizeInFullscreen()
+	ebiten.SetWindowDecorated(false)
+	ebiten.SetWindowMousePassthrough(true)
+	ebiten.SetWindowSize(width, height)
+	ebiten.SetWindowPosition(0, 0)
+}
+
+// attachWallpaperOnce moves the window to the desktop background on the first frame,
+// when the native window has been created
+func (g *Game) attachWallpaperOnce() {
+	if !g.wallpaperPending {
+		return
+	}
+	g.wallpaperPending = false
+	if err := attachWallpaper(windowTitle); err != nil {
+		slog.Warn("wallpaper mode unavailable, running as a normal window", "err", err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// attachWallpaper turns the X11 window titled title into a desktop window, which window
+// managers keep below all other windows, on every workspace and out of the task bar
+func attachWallpaper(title string) error {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	atom := func(name string) (xproto.Atom, error) {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			return 0, err
+		}
+		return reply.Atom, nil
+	}
+	clientList, err := atom("_NET_CLIENT_LIST")
+	if err != nil {
+		return err
+	}
+	wmName, err := atom("_NET_WM_NAME")
+	if err != nil {
+		return err
+	}
+	windowType, err := atom("_NET_WM_WINDOW_TYPE")
+	if err != nil {
+		return err
+	}
+	desktop, err := atom("_NET_WM_WINDOW_TYPE_DESKTOP")
+	if err != nil {
+		return err
+	}
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	clients, err := xproto.GetProperty(conn, false, root, clientList, xproto.AtomWindow, 0, 1<<16).Reply()
+	if err != nil {
+		return err
+	}
+	for i := 0; i+4 <= len(clients.Value); i += 4 {
+		win := xproto.Window(binary.LittleEndian.Uint32(clients.Value[i:]))
+		name, err := xproto.GetProperty(conn, false, win, wmName, xproto.GetPropertyTypeAny, 0, 256).Reply()
+		if err != nil || string(name.Value) != title {
+			continue
+		}
+
+		// Window managers read the type when a window is mapped
+		value := make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, uint32(desktop))
+		xproto.UnmapWindow(conn, win)
+		xproto.ChangeProperty(conn, xproto.PropModeReplace, win, windowType, xproto.AtomAtom, 32, 1, value)
+		return xproto.MapWindowChecked(conn, win).Check()
+	}
+	return fmt.Errorf("window %q not found", title)
+}
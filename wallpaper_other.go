@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func attachWallpaper(title string) error {
+	return errors.New("wallpaper mode is not supported on " + runtime.GOOS)
+}
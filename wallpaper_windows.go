@@ -0,0 +1,61 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32                 = windows.NewLazySystemDLL("user32.dll")
+	procFindWindowW        = user32.NewProc("FindWindowW")
+	procFindWindowExW      = user32.NewProc("FindWindowExW")
+	procSendMessageTimeout = user32.NewProc("SendMessageTimeoutW")
+	procEnumWindows        = user32.NewProc("EnumWindows")
+	procSetParent          = user32.NewProc("SetParent")
+)
+
+// attachWallpaper reparents the window titled title into the WorkerW window that Explorer
+// draws the wallpaper in, behind the desktop icons. The strings are kept as pointers and
+// only converted to uintptr in the arguments of each call, so they stay alive and in place.
+func attachWallpaper(title string) error {
+	titleW, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return err
+	}
+	hwnd, _, _ := procFindWindowW.Call(0, uintptr(unsafe.Pointer(titleW)))
+	if hwnd == 0 {
+		return errors.New("window not found")
+	}
+
+	// Asking Progman for message 0x052C makes Explorer create a WorkerW window between the
+	// wallpaper and the icons
+	progman, _, _ := procFindWindowW.Call(uintptr(unsafe.Pointer(windows.StringToUTF16Ptr("Progman"))), 0)
+	if progman == 0 {
+		return errors.New("Progman window not found, is Explorer running?")
+	}
+	var result uintptr
+	const smtoNormal = 0
+	procSendMessageTimeout.Call(progman, 0x052C, 0, 0, smtoNormal, 1000, uintptr(unsafe.Pointer(&result)))
+
+	// The WorkerW to use is the sibling after the window that hosts the icons
+	var workerW uintptr
+	shellView, workerClass := windows.StringToUTF16Ptr("SHELLDLL_DefView"), windows.StringToUTF16Ptr("WorkerW")
+	procEnumWindows.Call(windows.NewCallback(func(top, _ uintptr) uintptr {
+		if view, _, _ := procFindWindowExW.Call(top, 0, uintptr(unsafe.Pointer(shellView)), 0); view != 0 {
+			workerW, _, _ = procFindWindowExW.Call(0, top, uintptr(unsafe.Pointer(workerClass)), 0)
+		}
+		return 1 // Continue enumerating
+	}), 0)
+	if workerW == 0 {
+		return errors.New("desktop WorkerW window not found")
+	}
+
+	if parent, _, err := procSetParent.Call(hwnd, workerW); parent == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	defaultWeatherURL      = "https://api.open-meteo.com/v1/forecast"
+	defaultWeatherInterval = 15 * time.Minute
+	coldTemperature        = 5.0 // Celsius, donuts turn blue below it
+	raindropsPerFrame      = 3
+)
+
+// weatherConfig shows the current weather for a location in a HUD widget
+type weatherConfig struct {
+	widgetConfig
+	Latitude   float64  `json:"latitude"`
+	Longitude  float64  `json:"longitude"`
+	Fahrenheit bool     `json:"fahrenheit"` // Show temperatures in Fahrenheit instead of Celsius
+	Interval   duration `json:"interval"`   // Time between updates, 15m by default
+	URL        string   `json:"url"`        // Open-Meteo compatible forecast endpoint
+	Reactive   bool     `json:"reactive"`   // Rain particles, cold tint and sun glare following the weather
+}
+
+// weatherReport is the part of an Open-Meteo forecast response that is used
+type weatherReport struct {
+	Current struct {
+		Temperature float64 `json:"temperature_2m"` // Celsius
+		Code        int     `json:"weather_code"`   // WMO weather interpretation code
+		IsDay       int     `json:"is_day"`
+	} `json:"current"`
+}
+
+func (r weatherReport) raining() bool {
+	c := r.Current.Code
+	return 51 <= c && c <= 67 || 80 <= c && c <= 82 || c >= 95
+}
+
+func (r weatherReport) clear() bool { return r.Current.Code <= 1 && r.Current.IsDay == 1 }
+
+// conditions describes a WMO weather code
+func (r weatherReport) conditions() string {
+	switch c := r.Current.Code; {
+	case c == 0:
+		return "Clear"
+	case c <= 3:
+		return "Cloudy"
+	case c <= 48:
+		return "Fog"
+	case c <= 57:
+		return "Drizzle"
+	case c <= 67, c >= 80 && c <= 82:
+		return "Rain"
+	case c <= 77, c == 85, c == 86:
+		return "Snow"
+	case c >= 95:
+		return "Thunderstorm"
+	}
+	return "Unknown"
+}
+
+// weatherWidget fetches the weather in the background, shows it and optionally changes
+// the scene to match
+type weatherWidget struct {
+	g   *Game
+	cfg weatherConfig
+
+	mu     sync.Mutex
+	report *weatherReport // nil until the first successful fetch
+}
+
+func newWeatherWidget(g *Game, cfg weatherConfig) *weatherWidget {
+	w := &weatherWidget{g: g, cfg: cfg}
+	go w.poll()
+	return w
+}
+
+func (w *weatherWidget) poll() {
+	interval := time.Duration(w.cfg.Interval)
+	if interval <= 0 {
+		interval = defaultWeatherInterval
+	}
+	client := &http.Client{Timeout: 20 * time.Second}
+	for ; ; time.Sleep(interval) {
+		report, err := w.fetch(client)
+		if err != nil {
+			// Keep showing the last report while offline
+			slog.Warn("weather", "err", err)
+			continue
+		}
+		w.mu.Lock()
+		w.report = report
+		w.mu.Unlock()
+	}
+}
+
+func (w *weatherWidget) fetch(client *http.Client) (*weatherReport, error) {
+	query := url.Values{
+		"latitude":  {strconv.FormatFloat(w.cfg.Latitude, 'f', -1, 64)},
+		"longitude": {strconv.FormatFloat(w.cfg.Longitude, 'f', -1, 64)},
+		"current":   {"temperature_2m,weather_code,is_day"},
+	}
+	resp, err := client.Get(cmp.Or(w.cfg.URL, defaultWeatherURL) + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var report weatherReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (w *weatherWidget) current() *weatherReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.report
+}
+
+// tint returns the color donuts are multiplied with, blue when it's cold
+func (w *weatherWidget) tint() color.RGBA {
+	if r := w.current(); w.cfg.Reactive && r != nil && r.Current.Temperature < coldTemperature {
+		return color.RGBA{170, 200, 255, 255}
+	}
+	return color.RGBA{255, 255, 255, 255}
+}
+
+// Update lets it rain while it rains
+func (w *weatherWidget) Update(world plugin.World) error {
+	r := w.current()
+	if !w.cfg.Reactive || r == nil || !r.raining() {
+		return nil
+	}
+	width, height := world.Size()
+	fx := w.g.fx
+	for range raindropsPerFrame {
+		speed := 8 + fx.Float64()*4
+		w.g.world.spawnParticle(fx.Float64()*float64(width), -5, -1, speed, 1.5, color.RGBA{140, 170, 230, 200}, ticks(int(float64(height)/speed)+1))
+	}
+	return nil
+}
+
+func (w *weatherWidget) Draw(dst *ebiten.Image, world plugin.World) {
+	r := w.current()
+	if r == nil {
+		return
+	}
+	if w.cfg.Reactive && r.clear() {
+		drawSunGlare(dst)
+	}
+	temp, unit := r.Current.Temperature, "C"
+	if w.cfg.Fahrenheit {
+		temp, unit = temp*9/5+32, "F"
+	}
+	drawWidget(dst, w.cfg.widgetConfig, []string{fmt.Sprintf("%.0f%s %s", temp, unit, tr(r.conditions()))}, w.g.theme.hud)
+}
+
+// drawSunGlare draws a soft glow from the top right corner
+func drawSunGlare(dst *ebiten.Image) {
+	w, _ := logicalSize(dst)
+	width := float32(w)
+	for i := range 8 {
+		r := float32(60 + i*40)
+		drawFilledCircle(dst, width, 0, r, color.RGBA{24, 20, 8, 12}, true)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// windowConfig sets up a normal window instead of fullscreen
+type windowConfig struct {
+	Windowed    bool `json:"windowed"`      // Run in a window instead of fullscreen
+	Borderless  bool `json:"borderless"`    // Hide the title bar and borders
+	AlwaysOnTop bool `json:"always_on_top"` // Keep the window above other windows
+	X           *int `json:"x"`             // Fixed position, the last position when unset
+	Y           *int `json:"y"`
+	Width       int  `json:"width"` // Size, the last size when unset
+	Height      int  `json:"height"`
+}
+
+// windowGeometry is the last window position and size, remembered in the state file
+type windowGeometry struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// parsePosition parses a "x,y" window position
+func parsePosition(str string) (x, y int, err error) {
+	if _, err := fmt.Sscanf(str, "%d,%d", &x, &y); err != nil {
+		return 0, 0, fmt.Errorf("invalid position %q, want x,y", str)
+	}
+	return x, y, nil
+}
+
+// setupWindow applies cfg, restoring the remembered geometry for anything not configured
+func setupWindow(cfg windowConfig, last *windowGeometry) {
+	ebiten.SetWindowDecorated(!cfg.Borderless)
+	ebiten.SetWindowFloating(cfg.AlwaysOnTop)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
+
+	width, height := cfg.Width, cfg.Height
+	if (width <= 0 || height <= 0) && last != nil {
+		width, height = last.Width, last.Height
+	}
+	if width > 0 && height > 0 {
+		ebiten.SetWindowSize(width, height)
+	}
+	if cfg.X != nil && cfg.Y != nil {
+		ebiten.SetWindowPosition(*cfg.X, *cfg.Y)
+	} else if last != nil {
+		ebiten.SetWindowPosition(last.X, last.Y)
+	}
+}
+
+// trackWindow remembers the geometry of a normal window so it can be saved on exit
+func (g *Game) trackWindow() {
+	if !g.windowed || ebiten.IsFullscreen() {
+		return
+	}
+	x, y := ebiten.WindowPosition()
+	w, h := ebiten.WindowSize()
+	g.lastWindow = &windowGeometry{X: x, Y: y, Width: w, Height: h}
+}
+
+// saveWindow writes the last window geometry to the state file
+func (g *Game) saveWindow() {
+	if g.lastWindow != nil {
+		updateState(func(st *appState) { st.Window = g.lastWindow })
+	}
+}
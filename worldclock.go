@@ -0,0 +1,104 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/mlctrez/donut/plugin"
+)
+
+const (
+	defaultWorldClockScale  = 2
+	defaultWorldClockFormat = "15:04"
+)
+
+// worldClockConfig shows the time in several time zones in a row along the bottom of the
+// screen, above the ticker when there is one
+type worldClockConfig struct {
+	Zones  []worldClockZone `json:"zones"`
+	Format string           `json:"format"` // Go time layout, "15:04" by default
+	Scale  float64          `json:"scale"`  // Text scale of the times, labels are drawn smaller
+}
+
+// worldClockZone is one clock of the row
+type worldClockZone struct {
+	Label    string `json:"label"`    // e.g. "Tokyo", the location when empty
+	Location string `json:"location"` // IANA time zone, e.g. "Asia/Tokyo"
+}
+
+// worldClockWidget draws the clocks, formatting the times again only when the second
+// changes
+type worldClockWidget struct {
+	g         *Game
+	cfg       worldClockConfig
+	bottom    float64 // Space left free at the bottom of the screen for the ticker
+	locations []*time.Location
+	labels    []string
+	times     []string
+	second    int64
+}
+
+func newWorldClockWidget(g *Game, cfg worldClockConfig, bottom float64) (*worldClockWidget, error) {
+	if len(cfg.Zones) == 0 {
+		return nil, fmt.Errorf("the world clock has no zones")
+	}
+	w := &worldClockWidget{g: g, cfg: cfg, bottom: bottom, second: -1}
+	for _, zone := range cfg.Zones {
+		location, err := time.LoadLocation(zone.Location)
+		if err != nil {
+			return nil, err
+		}
+		w.locations = append(w.locations, location)
+		w.labels = append(w.labels, cmp.Or(zone.Label, zone.Location))
+	}
+	w.times = make([]string, len(cfg.Zones))
+	return w, nil
+}
+
+func (w *worldClockWidget) Update(plugin.World) error {
+	now := w.g.now()
+	if now.Unix() == w.second {
+		return nil
+	}
+	w.second = now.Unix()
+	local := now.Local()
+	format := cmp.Or(w.cfg.Format, defaultWorldClockFormat)
+	for i, location := range w.locations {
+		t := now.In(location)
+		w.times[i] = t.Format(format)
+		// Mark zones already in tomorrow or still in yesterday
+		if days := dayNumber(t) - dayNumber(local); days != 0 {
+			w.times[i] += fmt.Sprintf(" %+d", days)
+		}
+	}
+	return nil
+}
+
+// dayNumber returns the days between 1970-01-01 and t's date in its own location
+func dayNumber(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func (w *worldClockWidget) Draw(dst *ebiten.Image, world plugin.World) {
+	if w.second < 0 {
+		return
+	}
+	scale := cmp.Or(w.cfg.Scale, defaultWorldClockScale)
+	labelScale := max(1, scale/2)
+	width, height := world.Size()
+	labelHeight := baseFontHeight * labelScale * 1.2
+	barHeight := labelHeight + baseFontHeight*scale*1.4
+	y := float64(height) - w.bottom - barHeight
+	drawFilledRect(dst, 0, float32(y), float32(width), float32(barHeight), w.g.theme.background, false)
+
+	// Each clock is centered in an equal share of the width
+	cell := float64(width) / float64(len(w.times))
+	for i, t := range w.times {
+		cx := cell * (float64(i) + 0.5)
+		drawText(dst, w.labels[i], cx-textWidth(w.labels[i], labelScale)/2, y+baseFontHeight*labelScale*0.2, labelScale, w.g.theme.hud)
+		drawText(dst, t, cx-textWidth(t, scale)/2, y+labelHeight, scale, w.g.theme.hud)
+	}
+}